@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+
+	"github.com/Woeter69/hackoverflow/internal/config"
+	"github.com/Woeter69/hackoverflow/internal/database"
+	"github.com/Woeter69/hackoverflow/internal/policy"
+	"github.com/Woeter69/hackoverflow/internal/store"
+)
+
+const userUsage = `usage: main user grant-role [-actor name] <user-id> <role>
+       main user revoke-role [-actor name] <user-id> <role>`
+
+// runUser implements "user grant-role|revoke-role" and returns the exit code
+func runUser(cfg config.Config, args []string) int {
+	if len(args) == 0 || (args[0] != "grant-role" && args[0] != "revoke-role") {
+		fmt.Fprintln(os.Stderr, userUsage)
+		return 2
+	}
+	flags := flag.NewFlagSet("user "+args[0], flag.ContinueOnError)
+	actor := flags.String("actor", defaultActor(), "who is changing the role")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, userUsage)
+		return 2
+	}
+	userID := flags.Arg(0)
+	role, ok := policy.ParseRole(flags.Arg(1))
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown role %q\n", flags.Arg(1))
+		return 2
+	}
+
+	st, err := openStore(context.Background(), cfg)
+	if err != nil {
+		slog.Error("Database Error", "err", err)
+		return 1
+	}
+	defer database.DB.Close()
+	ctx := context.Background()
+	if _, err := st.Users.Get(ctx, userID); err != nil {
+		return reportStoreError("User "+userID, err)
+	}
+
+	// The store audits the roles before and after, in the same transaction
+	by := store.Actor{ID: *actor}
+	if args[0] == "grant-role" {
+		if err := st.Users.GrantRole(ctx, userID, role, by); err != nil {
+			return reportStoreError("GrantRole", err)
+		}
+		fmt.Printf("granted %s to %s\n", role, userID)
+	} else {
+		if err := st.Users.RevokeRole(ctx, userID, role, by); err != nil {
+			return reportStoreError("RevokeRole", err)
+		}
+		fmt.Printf("revoked %s from %s\n", role, userID)
+	}
+	return 0
+}
+
+const creditsUsage = `usage: main credits adjust -reason text [-actor name] <user-id> <amount>`
+
+// runCredits implements "credits adjust". Every adjustment is recorded in
+// credit_adjustments with the operator and reason, and audited with it.
+func runCredits(cfg config.Config, args []string) int {
+	if len(args) == 0 || args[0] != "adjust" {
+		fmt.Fprintln(os.Stderr, creditsUsage)
+		return 2
+	}
+	flags := flag.NewFlagSet("credits adjust", flag.ContinueOnError)
+	reason := flags.String("reason", "", "why the balance is corrected (required)")
+	actor := flags.String("actor", defaultActor(), "who is making the adjustment")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		fmt.Fprintln(os.Stderr, creditsUsage)
+		return 2
+	}
+	amount, err := strconv.Atoi(flags.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid amount %q\n", flags.Arg(1))
+		return 2
+	}
+
+	st, err := openStore(context.Background(), cfg)
+	if err != nil {
+		slog.Error("Database Error", "err", err)
+		return 1
+	}
+	defer database.DB.Close()
+	userID := flags.Arg(0)
+	balance, err := st.Users.AdjustCredits(context.Background(), userID, store.CreditAdjustment{
+		Amount: amount,
+		Reason: *reason,
+	}, store.Actor{ID: *actor})
+	if err != nil {
+		return reportStoreError("AdjustCredits", err)
+	}
+	fmt.Printf("adjusted %s by %+d, balance is now %d\n", userID, amount, balance)
+	return 0
+}
+
+const beaconUsage = `usage: main beacon clear <campus-id>`
+
+// runBeacon implements "beacon clear", standing down a stuck emergency
+func runBeacon(cfg config.Config, args []string) int {
+	if len(args) != 2 || args[0] != "clear" {
+		fmt.Fprintln(os.Stderr, beaconUsage)
+		return 2
+	}
+	campusID := args[1]
+
+	st, err := openStore(context.Background(), cfg)
+	if err != nil {
+		slog.Error("Database Error", "err", err)
+		return 1
+	}
+	defer database.DB.Close()
+	ctx := context.Background()
+	if _, err := st.Campuses.Get(ctx, campusID); err != nil {
+		return reportStoreError("Campus "+campusID, err)
+	}
+	beacon, err := st.Beacons.Active(ctx, campusID)
+	if err == store.ErrNotFound {
+		fmt.Printf("no active beacon on %s\n", campusID)
+		return 0
+	} else if err != nil {
+		return reportStoreError("Beacon", err)
+	}
+	// The store audits the beacon it stands down, in the same transaction
+	if err := st.Beacons.Clear(ctx, campusID, store.Actor{ID: defaultActor()}); err != nil {
+		return reportStoreError("ClearBeacon", err)
+	}
+	// The server pushes beacon changes to connected clients itself; this
+	// process cannot reach them
+	fmt.Printf("cleared beacon %s on %s (raised by %s at %s); connected clients see it on reconnect\n",
+		beacon.ID, campusID, beacon.UserID, beacon.CreatedAt.Format("2006-01-02 15:04 MST"))
+	return 0
+}
+
+const auditUsage = `usage: main audit verify`
+
+// runAudit implements "audit verify", which recomputes the audit log's hash
+// chain and fails at the first entry that was altered or removed
+func runAudit(cfg config.Config, args []string) int {
+	if len(args) != 1 || args[0] != "verify" {
+		fmt.Fprintln(os.Stderr, auditUsage)
+		return 2
+	}
+
+	st, err := openStore(context.Background(), cfg)
+	if err != nil {
+		slog.Error("Database Error", "err", err)
+		return 1
+	}
+	defer database.DB.Close()
+	n, head, err := store.VerifyAudit(context.Background(), st.Audit)
+	var broken *store.AuditBreak
+	if errors.As(err, &broken) {
+		fmt.Fprintf(os.Stderr, "audit log is broken: %v\n", broken)
+		return 1
+	} else if err != nil {
+		return reportStoreError("VerifyAudit", err)
+	}
+	// Entries cut from the end leave a valid chain; compare the last hash
+	// with one recorded earlier to notice
+	fmt.Printf("verified %d audit entries; last hash %s\n", n, head)
+	return 0
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Woeter69/hackoverflow/internal/config"
+	"github.com/Woeter69/hackoverflow/internal/database"
+	"github.com/Woeter69/hackoverflow/internal/geo"
+	"github.com/Woeter69/hackoverflow/internal/logging"
+	"github.com/Woeter69/hackoverflow/internal/seed"
+	"github.com/Woeter69/hackoverflow/internal/store"
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+const usage = `usage: main [command] [arguments]
+
+commands:
+  serve                      run the API server (the default)
+  config print               show the configuration the server would use
+  migrate up|down|status     apply or roll back schema migrations
+  seed                       generate synthetic users, errands and travel plans
+  user grant-role|revoke-role <user-id> <role>
+  credits adjust <user-id> <amount>
+  beacon clear <campus-id>
+  audit verify               check the audit log's hash chain
+  export errands|places|campuses
+
+Run "main <command> -h" for the flags of a command. Commands other than
+serve and config print read the file named by CONFIG_FILE, if any, and the
+environment.`
+
+// commands are the subcommands that run against the configuration loaded
+// from CONFIG_FILE and the environment
+var commands = map[string]func(cfg config.Config, args []string) int{
+	"migrate": runMigrate,
+	"seed":    runSeed,
+	"user":    runUser,
+	"credits": runCredits,
+	"beacon":  runBeacon,
+	"audit":   runAudit,
+	"export":  runExport,
+}
+
+func main() {
+	// Try loading from current dir or parent for Docker/Air flexibility
+	_ = godotenv.Load()
+	_ = godotenv.Load("/app/.env")
+
+	if len(os.Args) < 2 {
+		os.Exit(runServe(nil))
+	}
+	name, args := os.Args[1], os.Args[2:]
+	switch name {
+	case "serve":
+		os.Exit(runServe(args))
+	case "config":
+		os.Exit(runConfig(args))
+	case "help", "-h", "--help":
+		fmt.Println(usage)
+		return
+	}
+
+	run, ok := commands[name]
+	if !ok {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(run(cfg, args))
+}
+
+// runServe implements "serve", which takes the configuration flags
+func runServe(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	configFlags := config.RegisterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+	cfg, err := configFlags.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		return 1
+	}
+	logging.Setup(cfg.Log, os.Stderr)
+
+	// Shut down on SIGINT or SIGTERM; a second signal kills the process
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	// Being stopped while still starting up is not a failure
+	if err := serve(ctx, cfg); err != nil && !errors.Is(err, context.Canceled) {
+		slog.Error("Server Error", "err", err)
+		return 1
+	}
+	return 0
+}
+
+const configUsage = `usage: main config print [-redacted] [-format yaml|toml|env] [configuration flags]`
+
+// runConfig implements "config print", showing the configuration "serve"
+// would run with given the same flags
+func runConfig(args []string) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, configUsage)
+		return 2
+	}
+	flags := flag.NewFlagSet("config print", flag.ContinueOnError)
+	redact := flags.Bool("redacted", false, "mask passwords and secrets")
+	format := flags.String("format", "yaml", "output format: yaml, toml or env")
+	configFlags := config.RegisterFlags(flags)
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	cfg, err := configFlags.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		return 1
+	}
+
+	var out []byte
+	switch *format {
+	case "yaml":
+		out, err = yaml.Marshal(cfg.Tree(*redact))
+	case "toml":
+		out, err = toml.Marshal(cfg.Tree(*redact))
+	case "env":
+		out = []byte(strings.Join(cfg.Env(*redact), "\n") + "\n")
+	default:
+		fmt.Fprintln(os.Stderr, configUsage)
+		return 2
+	}
+	if err != nil {
+		slog.Error("Config Error", "err", err)
+		return 1
+	}
+	os.Stdout.Write(out)
+	return 0
+}
+
+// openStore connects to the configured database, as the server does. The
+// caller closes database.DB.
+func openStore(ctx context.Context, cfg config.Config) (*store.Store, error) {
+	// Mask password in logs
+	slog.Info("Initializing DB connection", "host", cfg.Database.Host, "port", cfg.Database.Port, "db", cfg.Database.Name)
+	if err := database.InitDB(ctx, cfg.Database); err != nil {
+		return nil, err
+	}
+	return store.NewPostgres(database.DB), nil
+}
+
+// defaultActor names the operator running a command in the records it
+// leaves behind
+func defaultActor() string {
+	if user := os.Getenv("USER"); user != "" {
+		return "cli:" + user
+	}
+	return "cli"
+}
+
+// reportStoreError prints a store failure in operator terms and returns the
+// exit code
+func reportStoreError(what string, err error) int {
+	var invalid *store.InvalidError
+	if errors.As(err, &invalid) {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", what, invalid.Reason)
+		return 1
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		fmt.Fprintf(os.Stderr, "%s: not found\n", what)
+		return 1
+	}
+	slog.Error(what+" Error", "err", err)
+	return 1
+}
+
+// runSeed implements "seed" and returns the exit code
+func runSeed(cfg config.Config, args []string) int {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	campusID := flags.String("campus", "default", "campus to populate")
+	users := flags.Int("users", 20, "number of users")
+	errands := flags.Int("errands", 50, "number of errands")
+	plans := flags.Int("plans", 20, "number of travel plans")
+	lat := flags.Float64("lat", 12.9716, "latitude points are scattered around when the campus has no boundary")
+	lng := flags.Float64("lng", 77.5946, "longitude points are scattered around when the campus has no boundary")
+	radius := flags.Float64("radius", seed.DefaultRadius, "scatter radius in meters when the campus has no boundary")
+	randSeed := flags.Int64("rand", 0, "random seed for repeatable data (0 picks one)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 0 || *users < 0 || *errands < 0 || *plans < 0 {
+		flags.Usage()
+		return 2
+	}
+	if *randSeed == 0 {
+		*randSeed = time.Now().UnixNano()
+	}
+
+	st, err := openStore(context.Background(), cfg)
+	if err != nil {
+		slog.Error("Database Error", "err", err)
+		return 1
+	}
+	defer database.DB.Close()
+	sum, err := seed.Generate(context.Background(), st, seed.Options{
+		CampusID: *campusID,
+		Users:    *users,
+		Errands:  *errands,
+		Plans:    *plans,
+		Center:   geo.Point{Lng: *lng, Lat: *lat},
+		Radius:   *radius,
+		Rand:     rand.New(rand.NewSource(*randSeed)),
+	})
+	fmt.Printf("seeded %s: %d users, %d errands, %d travel plans (-rand %d)\n",
+		*campusID, sum.Users, sum.Errands, sum.Plans, *randSeed)
+	if err != nil {
+		return reportStoreError("Seed", err)
+	}
+	return 0
+}
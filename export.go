@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/Woeter69/hackoverflow/internal/config"
+	"github.com/Woeter69/hackoverflow/internal/database"
+	"github.com/Woeter69/hackoverflow/internal/store"
+)
+
+const exportUsage = `usage: main export [-campus id] [-o file] errands|places|campuses`
+
+// exportPageSize is how many errands are read per feed page
+const exportPageSize = 500
+
+// exportPlaceLimit bounds the places exported per campus
+const exportPlaceLimit = 100000
+
+// errandStatuses are every state an errand can be in
+var errandStatuses = []string{"pending", "matched", "completed", "cancelled", "disputed"}
+
+// runExport implements "export", writing one JSON object per line
+func runExport(cfg config.Config, args []string) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	campusID := flags.String("campus", "default", "campus to export errands and places of")
+	out := flags.String("o", "", "file to write to instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, exportUsage)
+		return 2
+	}
+	kind := flags.Arg(0)
+	if kind != "errands" && kind != "places" && kind != "campuses" {
+		fmt.Fprintln(os.Stderr, exportUsage)
+		return 2
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "export: %v\n", err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	buf := bufio.NewWriter(w)
+	enc := json.NewEncoder(buf)
+
+	st, err := openStore(context.Background(), cfg)
+	if err != nil {
+		slog.Error("Database Error", "err", err)
+		return 1
+	}
+	defer database.DB.Close()
+	ctx := context.Background()
+	count, err := exportRecords(ctx, st, kind, *campusID, enc.Encode)
+	if err != nil {
+		return reportStoreError("Export", err)
+	}
+	if err := buf.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "export: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "exported %d %s\n", count, kind)
+	return 0
+}
+
+// exportRecords passes every record of the kind to emit and returns how
+// many there were
+func exportRecords(ctx context.Context, st *store.Store, kind, campusID string, emit func(interface{}) error) (int, error) {
+	count := 0
+	switch kind {
+	case "campuses":
+		campuses, err := st.Campuses.List(ctx)
+		if err != nil {
+			return 0, err
+		}
+		for _, c := range campuses {
+			if err := emit(c); err != nil {
+				return count, err
+			}
+			count++
+		}
+	case "places":
+		if _, err := st.Campuses.Get(ctx, campusID); err != nil {
+			return 0, err
+		}
+		places, err := st.Places.Search(ctx, store.PlaceSearch{CampusID: campusID, Limit: exportPlaceLimit})
+		if err != nil {
+			return 0, err
+		}
+		for _, p := range places {
+			if err := emit(p); err != nil {
+				return count, err
+			}
+			count++
+		}
+	case "errands":
+		if _, err := st.Campuses.Get(ctx, campusID); err != nil {
+			return 0, err
+		}
+		q := store.ErrandQuery{
+			CampusID: campusID,
+			Statuses: errandStatuses,
+			Sort:     store.SortAge,
+			Limit:    exportPageSize,
+		}
+		for {
+			page, err := st.Errands.Feed(ctx, q)
+			if err != nil {
+				return count, err
+			}
+			for _, e := range page.Errands {
+				if err := emit(e.ErrandRequest); err != nil {
+					return count, err
+				}
+				count++
+			}
+			if page.Next == nil {
+				break
+			}
+			q.After = page.Next
+		}
+	}
+	return count, nil
+}
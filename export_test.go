@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/Woeter69/hackoverflow/internal/geo"
+	"github.com/Woeter69/hackoverflow/internal/models"
+	"github.com/Woeter69/hackoverflow/internal/seed"
+	"github.com/Woeter69/hackoverflow/internal/store"
+)
+
+func TestExportErrandsReadsEveryPage(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory().Store()
+	total := exportPageSize + 7
+	if _, err := seed.Generate(ctx, st, seed.Options{
+		CampusID: "default",
+		Users:    2,
+		Errands:  total,
+		Center:   geo.Point{Lng: 77.5946, Lat: 12.9716},
+		Rand:     rand.New(rand.NewSource(1)),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Closed errands are exported too
+	page, err := st.Errands.Feed(ctx, store.ErrandQuery{CampusID: "default", Statuses: []string{"pending"}, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancelled := page.Errands[0].ID.String()
+	if err := st.Errands.SetStatus(ctx, cancelled, "cancelled"); err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]bool{}
+	count, err := exportRecords(ctx, st, "errands", "default", func(v interface{}) error {
+		seen[v.(models.ErrandRequest).ID.String()] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != total || len(seen) != total {
+		t.Fatalf("expected %d distinct errands, got %d (%d distinct)", total, count, len(seen))
+	}
+	if !seen[cancelled] {
+		t.Fatal("the cancelled errand was not exported")
+	}
+
+	if _, err := exportRecords(ctx, st, "places", "nowhere", func(interface{}) error { return nil }); err != store.ErrNotFound {
+		t.Fatalf("expected an unknown campus to be rejected, got %v", err)
+	}
+}
@@ -4,7 +4,7 @@ import { OrbitControls, Grid } from '@react-three/drei';
 import { EffectComposer, Bloom, Noise, Vignette } from '@react-three/postprocessing';
 import * as THREE from 'three';
 import { onAuthStateChanged, signOut, User } from 'firebase/auth';
-import { auth } from '../lib/firebase';
+import { auth, getAuthToken } from '../lib/firebase';
 import { useNavigate } from 'react-router-dom';
 import { X, Info, Activity, Users, MapPin, Navigation, CheckCircle, LayoutDashboard, HelpCircle, AlertTriangle, MessageSquare } from 'lucide-react';
 import { api, MatchResponse, ErrandResponse } from '../lib/api';
@@ -379,7 +379,7 @@ const CampusHologram = () => {
   useEffect(() => {
     if (!user) return;
 
-    wsService.connect(user.uid);
+    wsService.connect(() => getAuthToken(user));
     fetchErrands();
     fetchProfile();
     
@@ -11,12 +11,15 @@ class WebSocketService {
         this.urlBase = `${protocol}//${host}/ws`;
     }
 
-    connect(userId?: string) {
+    // The server authenticates the upgrade with the token passed after the
+    // "bearer" subprotocol, since browsers can't set an Authorization header
+    async connect(getToken: () => Promise<string | null>) {
         if (this.socket) return;
 
-        const url = userId ? `${this.urlBase}?userId=${userId}` : this.urlBase;
         try {
-            this.socket = new WebSocket(url);
+            const token = await getToken();
+            if (!token || this.socket) return;
+            this.socket = new WebSocket(this.urlBase, ['bearer', token]);
 
             this.socket.onopen = () => {
                 console.log("WebSocket Connected");
@@ -41,11 +44,11 @@ class WebSocketService {
             this.socket.onclose = () => {
                 console.log("WebSocket Disconnected. Reconnecting in 5s...");
                 this.socket = null;
-                setTimeout(() => this.connect(userId), 5000);
+                setTimeout(() => this.connect(getToken), 5000);
             };
         } catch (e) {
             console.error("WS Connect Error", e);
-            setTimeout(() => this.connect(userId), 5000);
+            setTimeout(() => this.connect(getToken), 5000);
         }
     }
 
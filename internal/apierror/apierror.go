@@ -0,0 +1,82 @@
+// Package apierror writes the API's error responses. Every error has the
+// same shape:
+//
+//	{"error": "Mission is no longer available", "code": "mission_unavailable", "request_id": "..."}
+//
+// Clients branch on the code, which is stable; the message is for people
+// and may change. Server errors never carry the underlying error, which is
+// logged under the request id instead.
+package apierror
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/Woeter69/hackoverflow/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+// Codes for the general kinds of failure
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeInvalidGeometry      = "invalid_geometry"
+	CodeUnauthenticated      = "unauthenticated"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeConflict             = "conflict"
+	CodePayloadTooLarge      = "payload_too_large"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeInternal             = "internal"
+	CodeUnavailable          = "unavailable"
+)
+
+// Codes for failures clients are expected to handle specifically
+const (
+	CodeMissingToken           = "missing_token"
+	CodeInvalidToken           = "invalid_token"
+	CodeInsufficientRole       = "insufficient_role"
+	CodeEmailUnverified        = "email_unverified"
+	CodeOtherCampus            = "other_campus"
+	CodeNoCampus               = "no_campus"
+	CodeVerifiedRunnerRequired = "verified_runner_required"
+	CodeUnknownCategory        = "unknown_category"
+	CodeMissionUnavailable     = "mission_unavailable"
+	CodeMissionDisputed        = "mission_disputed"
+	CodeMissionCompleted       = "mission_completed"
+)
+
+// Respond aborts the request with an error response
+func Respond(c *gin.Context, status int, code, message string) {
+	RespondWith(c, status, code, message, nil)
+}
+
+// RespondWith aborts the request with an error response carrying extra
+// fields, such as the problems found in a geometry
+func RespondWith(c *gin.Context, status int, code, message string, extra gin.H) {
+	body := gin.H{}
+	for k, v := range extra {
+		body[k] = v
+	}
+	body["error"] = message
+	body["code"] = code
+	if id := logging.RequestID(c.Request.Context()); id != "" {
+		body["request_id"] = id
+	}
+	c.AbortWithStatusJSON(status, body)
+}
+
+// Internal logs err under what and responds with a generic server error,
+// so the cause stays out of the response
+func Internal(c *gin.Context, what string, err error, message string) {
+	slog.ErrorContext(c.Request.Context(), what, "err", err)
+	Respond(c, http.StatusInternalServerError, CodeInternal, message)
+}
+
+// Recovery turns a panic into a logged server error
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, recovered any) {
+		slog.ErrorContext(c.Request.Context(), "Panic", "panic", recovered, "stack", string(debug.Stack()))
+		Respond(c, http.StatusInternalServerError, CodeInternal, "Internal server error")
+	})
+}
@@ -0,0 +1,95 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Woeter69/hackoverflow/internal/logging"
+	"github.com/gin-gonic/gin"
+)
+
+type response struct {
+	Error     string            `json:"error"`
+	Code      string            `json:"code"`
+	RequestID string            `json:"request_id"`
+	Fields    map[string]string `json:"fields"`
+}
+
+func serve(t *testing.T, method, body string, h gin.HandlerFunc) (*httptest.ResponseRecorder, response) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Recovery(), logging.RequestIDMiddleware())
+	r.Handle(method, "/", h)
+
+	req := httptest.NewRequest(method, "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(logging.RequestIDHeader, "req-1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var resp response
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("response is not JSON: %s", w.Body.String())
+	}
+	return w, resp
+}
+
+func TestInternalHidesTheCause(t *testing.T) {
+	w, resp := serve(t, http.MethodGet, "", func(c *gin.Context) {
+		Internal(c, "Insert Error", errors.New(`pq: duplicate key value violates unique constraint "users_pkey"`), "Failed to save")
+	})
+	if w.Code != http.StatusInternalServerError || resp.Code != CodeInternal || resp.Error != "Failed to save" {
+		t.Fatalf("unexpected response %d %+v", w.Code, resp)
+	}
+	if resp.RequestID != "req-1" {
+		t.Fatalf("expected the request id in the response, got %q", resp.RequestID)
+	}
+	if strings.Contains(w.Body.String(), "pq:") {
+		t.Fatalf("the cause leaked into the response: %s", w.Body.String())
+	}
+}
+
+func TestInvalidBodyNamesJSONFields(t *testing.T) {
+	type request struct {
+		Title  string `json:"title" binding:"required"`
+		Reward int    `json:"reward_estimate" binding:"min=1"`
+	}
+	bind := func(c *gin.Context) {
+		var req request
+		if err := c.ShouldBindJSON(&req); err != nil {
+			InvalidBody(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+
+	w, resp := serve(t, http.MethodPost, `{"reward_estimate": 0}`, bind)
+	if w.Code != http.StatusBadRequest || resp.Code != CodeInvalidRequest {
+		t.Fatalf("unexpected response %d %+v", w.Code, resp)
+	}
+	if resp.Fields["title"] != "required" || resp.Fields["reward_estimate"] != "min" {
+		t.Fatalf("expected the failing fields by JSON name, got %v", resp.Fields)
+	}
+
+	_, resp = serve(t, http.MethodPost, `{"title": "Coffee", "reward_estimate": "lots"}`, bind)
+	if resp.Fields["reward_estimate"] != "type" {
+		t.Fatalf("expected a type error on reward_estimate, got %+v", resp)
+	}
+
+	_, resp = serve(t, http.MethodPost, `{"title": `, bind)
+	if resp.Error != "Request body is not valid JSON" {
+		t.Fatalf("expected a syntax error, got %+v", resp)
+	}
+}
+
+func TestRecoveryRespondsWithInternal(t *testing.T) {
+	w, resp := serve(t, http.MethodGet, "", func(c *gin.Context) { panic("boom") })
+	if w.Code != http.StatusInternalServerError || resp.Code != CodeInternal || resp.RequestID != "req-1" {
+		t.Fatalf("unexpected response %d %+v", w.Code, resp)
+	}
+}
@@ -0,0 +1,77 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	// Report fields by the names clients send rather than the Go names
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(fieldName)
+	}
+}
+
+// fieldName is a field's JSON name, or its form name for query bindings
+func fieldName(f reflect.StructField) string {
+	for _, tag := range []string{"json", "form"} {
+		name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// InvalidBody responds to a request whose body or query failed to bind.
+// Validation failures list each offending field with the rule it broke:
+//
+//	{"error": "Invalid request body", "code": "invalid_request", "fields": {"title": "required"}}
+func InvalidBody(c *gin.Context, err error) {
+	var (
+		invalid   validator.ValidationErrors
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		numErr    *strconv.NumError
+	)
+	switch {
+	case errors.As(err, &invalid):
+		fields := gin.H{}
+		for _, fe := range invalid {
+			fields[fieldPath(fe)] = fe.Tag()
+		}
+		RespondWith(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body", gin.H{"fields": fields})
+	case errors.Is(err, io.EOF):
+		Respond(c, http.StatusBadRequest, CodeInvalidRequest, "Request body is empty")
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		Respond(c, http.StatusBadRequest, CodeInvalidRequest, "Request body is not valid JSON")
+	case errors.As(err, &typeErr):
+		RespondWith(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body",
+			gin.H{"fields": gin.H{typeErr.Field: "type"}})
+	case errors.As(err, &numErr):
+		Respond(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid number: "+strconv.Quote(numErr.Num))
+	default:
+		Respond(c, http.StatusBadRequest, CodeInvalidRequest, "Invalid request body")
+	}
+}
+
+// fieldPath is the field's path below the request, such as "stops[0].lat"
+func fieldPath(fe validator.FieldError) string {
+	_, path, found := strings.Cut(fe.Namespace(), ".")
+	if !found {
+		return fe.Field()
+	}
+	return path
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	firebase "firebase.google.com/go/v4"
+	"github.com/Woeter69/hackoverflow/internal/policy"
+)
+
+// Supported values for AUTH_MODE
+const (
+	ModeFirebase = "firebase"
+	ModeLocal    = "local"
+)
+
+// ErrInvalidToken is returned when a bearer token fails verification
+var ErrInvalidToken = errors.New("invalid token")
+
+// Identity is the verified caller behind a bearer token
+type Identity struct {
+	UID           string
+	Email         string
+	Name          string
+	EmailVerified bool
+	Roles         []policy.Role // roles carried in the token's custom claims
+}
+
+// Verifier turns a bearer token into a verified identity
+type Verifier interface {
+	Verify(ctx context.Context, token string) (*Identity, error)
+}
+
+// KeyChecker is implemented by verifiers whose signing keys are fetched at
+// runtime, so their availability can be probed
+type KeyChecker interface {
+	CheckKeys(ctx context.Context) error
+}
+
+// Config selects and configures the auth mode
+type Config struct {
+	Mode string
+
+	// Local mode only
+	LocalSecret   string
+	LocalTokenTTL time.Duration
+}
+
+// New builds the verifier for the configured mode. In local mode the returned
+// issuer can mint tokens for the /dev/token endpoint; in Firebase mode it is nil.
+// Any initialization failure is returned so the server can refuse to start.
+func New(ctx context.Context, cfg Config) (Verifier, *LocalIssuer, error) {
+	switch cfg.Mode {
+	case ModeFirebase, "":
+		app, err := firebase.NewApp(ctx, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("firebase init failed: %w", err)
+		}
+		v, err := NewFirebaseVerifier(ctx, app)
+		if err != nil {
+			return nil, nil, err
+		}
+		return v, nil, nil
+	case ModeLocal:
+		issuer, err := NewLocalIssuer(cfg.LocalSecret, cfg.LocalTokenTTL)
+		if err != nil {
+			return nil, nil, err
+		}
+		return issuer, issuer, nil
+	}
+	return nil, nil, fmt.Errorf("unknown auth mode %q (expected %q or %q)", cfg.Mode, ModeFirebase, ModeLocal)
+}
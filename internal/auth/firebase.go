@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	firebase "firebase.google.com/go/v4"
+	fbauth "firebase.google.com/go/v4/auth"
+	"github.com/Woeter69/hackoverflow/internal/policy"
+)
+
+// firebaseKeysURL serves the certificates Firebase ID tokens are signed with
+const firebaseKeysURL = "https://www.googleapis.com/robot/v1/metadata/x509/securetoken@system.gserviceaccount.com"
+
+// keyCheckInterval is how long a successful key fetch is trusted, so frequent
+// readiness probes do not each call Google
+const keyCheckInterval = 5 * time.Minute
+
+// FirebaseVerifier verifies Firebase ID tokens
+type FirebaseVerifier struct {
+	client *fbauth.Client
+
+	keysMu      sync.Mutex
+	keysFetched time.Time
+}
+
+// NewFirebaseVerifier creates the Firebase Auth client up front so a missing
+// or broken service account is caught at startup rather than on first request.
+func NewFirebaseVerifier(ctx context.Context, app *firebase.App) (*FirebaseVerifier, error) {
+	client, err := app.Auth(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("firebase auth client init failed: %w", err)
+	}
+	return &FirebaseVerifier{client: client}, nil
+}
+
+func (v *FirebaseVerifier) Verify(ctx context.Context, token string) (*Identity, error) {
+	t, err := v.client.VerifyIDToken(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	id := &Identity{
+		UID:   t.UID,
+		Roles: policy.RolesFromClaims(t.Claims),
+	}
+	id.Email, _ = t.Claims["email"].(string)
+	id.Name, _ = t.Claims["name"].(string)
+	id.EmailVerified, _ = t.Claims["email_verified"].(bool)
+	return id, nil
+}
+
+// CheckKeys makes sure the certificates ID tokens are verified against can be
+// fetched. Tokens from the Auth emulator are unsigned, so nothing is fetched
+// when it is in use.
+func (v *FirebaseVerifier) CheckKeys(ctx context.Context) error {
+	if os.Getenv("FIREBASE_AUTH_EMULATOR_HOST") != "" {
+		return nil
+	}
+	v.keysMu.Lock()
+	defer v.keysMu.Unlock()
+	if time.Since(v.keysFetched) < keyCheckInterval {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, firebaseKeysURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("firebase keys unavailable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("firebase keys unavailable: %s", resp.Status)
+	}
+	var certs map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&certs); err != nil || len(certs) == 0 {
+		return errors.New("firebase keys unavailable: no certificates in the response")
+	}
+	v.keysFetched = time.Now()
+	return nil
+}
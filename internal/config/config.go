@@ -0,0 +1,272 @@
+// Package config loads the server's settings. Each setting has a default
+// and can be overridden, in increasing order of precedence, by a YAML or
+// TOML file, an environment variable and a command-line flag.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Woeter69/hackoverflow/internal/auth"
+	"github.com/Woeter69/hackoverflow/internal/geo"
+	"github.com/Woeter69/hackoverflow/internal/policy"
+)
+
+// Config is every setting of the server. Field tags name the file key,
+// the environment variable and the flag help of each setting; secret
+// settings are masked when the configuration is printed redacted.
+type Config struct {
+	Server    Server    `yaml:"server"`
+	Auth      Auth      `yaml:"auth"`
+	Database  Database  `yaml:"database"`
+	Redis     Redis     `yaml:"redis"`
+	Matching  Matching  `yaml:"matching"`
+	WebSocket WebSocket `yaml:"websocket"`
+	Tracing   Tracing   `yaml:"tracing"`
+	Log       Log       `yaml:"log"`
+	Features  Features  `yaml:"features"`
+}
+
+// Server is the HTTP listener and where files are kept
+type Server struct {
+	Port          int    `yaml:"port" env:"PORT" usage:"HTTP listen port"`
+	Mode          string `yaml:"mode" env:"GIN_MODE" usage:"gin mode: debug, release or test"`
+	PublicBaseURL string `yaml:"public_base_url" env:"PUBLIC_BASE_URL" usage:"public origin used in calendar feed links; the request's host when empty"`
+	UploadDir     string `yaml:"upload_dir" env:"UPLOAD_DIR" usage:"directory for uploaded student IDs"`
+	NetworkDir    string `yaml:"network_dir" env:"ROUTING_NETWORK_DIR" usage:"directory of campus path networks named <campus_id>.osm or .geojson"`
+	// ShutdownTimeout bounds the whole shutdown, including draining requests
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" usage:"time allowed to drain requests and close connections on shutdown"`
+	// DrainDelay is spent at the start of the shutdown reporting not ready,
+	// while load balancers take the instance out of rotation
+	DrainDelay time.Duration `yaml:"drain_delay" env:"DRAIN_DELAY" usage:"time /readyz reports not ready before connections stop being accepted"`
+}
+
+// Auth selects how bearer tokens are verified
+type Auth struct {
+	Mode        string `yaml:"mode" env:"AUTH_MODE" usage:"token verifier: firebase or local"`
+	LocalSecret string `yaml:"local_secret" env:"LOCAL_AUTH_SECRET" secret:"true" usage:"signing secret for local auth (32+ bytes)"`
+}
+
+// Database is the Postgres connection and its pool. URL takes precedence
+// over the individual connection fields.
+type Database struct {
+	URL      string `yaml:"url" env:"DB_URL" secret:"url" usage:"connection URL; overrides the other connection settings"`
+	Host     string `yaml:"host" env:"DB_HOST" usage:"database host"`
+	Port     int    `yaml:"port" env:"DB_PORT" usage:"database port"`
+	User     string `yaml:"user" env:"DB_USER" usage:"database user"`
+	Password string `yaml:"password" env:"DB_PASSWORD" secret:"true" usage:"database password"`
+	Name     string `yaml:"name" env:"DB_NAME" usage:"database name"`
+	SSLMode  string `yaml:"sslmode" env:"DB_SSLMODE" usage:"lib/pq sslmode"`
+
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" usage:"open connections at most; 0 is unlimited"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" usage:"idle connections kept in the pool"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" usage:"connections are closed after this long; 0 keeps them"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" usage:"idle connections are closed after this long; 0 keeps them"`
+	ConnectAttempts int           `yaml:"connect_attempts" env:"DB_CONNECT_ATTEMPTS" usage:"tries to reach the database at startup"`
+	ConnectInterval time.Duration `yaml:"connect_interval" env:"DB_CONNECT_INTERVAL" usage:"wait between connection attempts"`
+}
+
+// Redis is the cache connection
+type Redis struct {
+	Addr     string `yaml:"addr" env:"REDIS_ADDR" usage:"host:port of the Redis server"`
+	Username string `yaml:"username" env:"REDIS_USERNAME" usage:"ACL username"`
+	Password string `yaml:"password" env:"REDIS_PASSWORD" secret:"true" usage:"password"`
+	DB       int    `yaml:"db" env:"REDIS_DB" usage:"database index"`
+	TLS      bool   `yaml:"tls" env:"REDIS_TLS" usage:"connect over TLS"`
+}
+
+// Matching tunes how errands are matched to travel plans
+type Matching struct {
+	DefaultBufferM           float64 `yaml:"default_buffer_m" env:"DEFAULT_BUFFER_M" usage:"route buffer (meters) of new campuses that set none"`
+	HighValueRewardThreshold float64 `yaml:"high_value_reward_threshold" env:"HIGH_VALUE_REWARD_THRESHOLD" usage:"rewards at or above this need a verified runner"`
+	RouteSimplifyToleranceM  float64 `yaml:"route_simplify_tolerance_m" env:"ROUTE_SIMPLIFY_TOLERANCE_M" usage:"Douglas-Peucker tolerance (meters) for uploaded tracks"`
+}
+
+// WebSocket limits each realtime connection
+type WebSocket struct {
+	MaxMessageSize int64         `yaml:"max_message_size" env:"WS_MAX_MESSAGE_SIZE" usage:"largest message (bytes) accepted from a client"`
+	SendBuffer     int           `yaml:"send_buffer" env:"WS_SEND_BUFFER" usage:"messages queued per client before it is dropped"`
+	PongWait       time.Duration `yaml:"pong_wait" env:"WS_PONG_WAIT" usage:"a client that has not answered a ping for this long is dropped"`
+	WriteWait      time.Duration `yaml:"write_wait" env:"WS_WRITE_WAIT" usage:"time allowed to write a message to a client"`
+}
+
+// Tracing selects where OpenTelemetry spans are sent
+type Tracing struct {
+	Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER" usage:"span exporter: none, otlp or stdout"`
+	Endpoint    string  `yaml:"endpoint" env:"TRACING_ENDPOINT" usage:"OTLP/HTTP collector host:port"`
+	Insecure    bool    `yaml:"insecure" env:"TRACING_INSECURE" usage:"send spans to the collector over plain HTTP"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" usage:"fraction of new traces recorded; traces sampled upstream are always recorded"`
+	ServiceName string  `yaml:"service_name" env:"TRACING_SERVICE_NAME" usage:"service.name reported with every span"`
+}
+
+// Log selects how the server logs
+type Log struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" usage:"least severe level logged: debug, info, warn or error"`
+	Format string `yaml:"format" env:"LOG_FORMAT" usage:"log line format: json or text"`
+}
+
+// Features switches optional parts of the server on or off
+type Features struct {
+	Redis           bool `yaml:"redis" env:"FEATURE_REDIS" usage:"connect to Redis"`
+	SeriesScheduler bool `yaml:"series_scheduler" env:"FEATURE_SERIES_SCHEDULER" usage:"materialize departures of recurring travel plans"`
+	Frontend        bool `yaml:"frontend" env:"FEATURE_FRONTEND" usage:"serve the built frontend from ./dist"`
+	Metrics         bool `yaml:"metrics" env:"FEATURE_METRICS" usage:"serve Prometheus metrics at /metrics"`
+}
+
+// Default returns the settings used when nothing overrides them
+func Default() Config {
+	return Config{
+		Server: Server{
+			Port:            8080,
+			UploadDir:       "uploads",
+			NetworkDir:      "networks",
+			ShutdownTimeout: 20 * time.Second,
+		},
+		Auth: Auth{Mode: auth.ModeFirebase},
+		Database: Database{
+			Host:            "localhost", // Default for local run outside docker
+			Port:            5432,
+			MaxIdleConns:    2,
+			ConnectAttempts: 10,
+			ConnectInterval: 3 * time.Second,
+		},
+		Redis: Redis{Addr: "localhost:6379"},
+		Matching: Matching{
+			DefaultBufferM:           200,
+			HighValueRewardThreshold: policy.HighValueThreshold,
+			RouteSimplifyToleranceM:  geo.DefaultSimplifyTolerance,
+		},
+		WebSocket: WebSocket{
+			MaxMessageSize: 512,
+			SendBuffer:     256,
+			PongWait:       60 * time.Second,
+			WriteWait:      10 * time.Second,
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			Endpoint:    "localhost:4318",
+			Insecure:    true,
+			SampleRatio: 1,
+			ServiceName: "campusloop",
+		},
+		Log: Log{Level: "info", Format: "json"},
+		Features: Features{
+			Redis:           true,
+			SeriesScheduler: true,
+			Frontend:        true,
+			Metrics:         true,
+		},
+	}
+}
+
+// DSN returns the connection string for lib/pq
+func (d Database) DSN() string {
+	if d.URL != "" {
+		return d.URL
+	}
+	u := url.URL{
+		Scheme: "postgres",
+		Host:   net.JoinHostPort(d.Host, strconv.Itoa(d.Port)),
+		Path:   "/" + d.Name,
+	}
+	if d.User != "" {
+		u.User = url.UserPassword(d.User, d.Password)
+	}
+	if d.SSLMode != "" {
+		u.RawQuery = "sslmode=" + url.QueryEscape(d.SSLMode)
+	}
+	return u.String()
+}
+
+// Validate reports every setting that is out of range or inconsistent
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Port > 0 && c.Server.Port < 65536, "server.port %d is not a valid port", c.Server.Port)
+	switch c.Server.Mode {
+	case "", "debug", "release", "test":
+	default:
+		check(false, "server.mode must be debug, release or test, not %q", c.Server.Mode)
+	}
+	if c.Server.PublicBaseURL != "" {
+		u, err := url.Parse(c.Server.PublicBaseURL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"server.public_base_url must be an http(s) origin")
+	}
+	check(c.Server.UploadDir != "", "server.upload_dir is required")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+	check(c.Server.DrainDelay >= 0 && c.Server.DrainDelay < c.Server.ShutdownTimeout,
+		"server.drain_delay must be at least zero and shorter than server.shutdown_timeout")
+
+	switch c.Auth.Mode {
+	case auth.ModeFirebase:
+	case auth.ModeLocal:
+		// gin falls back to debug when no mode is set, but so would a
+		// production deploy that forgot to set one
+		check(c.Server.Mode == "debug" || c.Server.Mode == "test",
+			"auth.mode local needs server.mode set to debug or test, not %q", c.Server.Mode)
+	default:
+		check(false, "auth.mode must be %s or %s, not %q", auth.ModeFirebase, auth.ModeLocal, c.Auth.Mode)
+	}
+
+	db := c.Database
+	if db.URL != "" {
+		u, err := url.Parse(db.URL)
+		check(err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql"), "database.url must be a postgres:// URL")
+	} else {
+		check(db.Host != "" && db.Name != "", "database.url or database.host and database.name are required")
+		check(db.Port > 0 && db.Port < 65536, "database.port %d is not a valid port", db.Port)
+	}
+	check(db.MaxOpenConns >= 0, "database.max_open_conns must not be negative")
+	check(db.MaxIdleConns >= 0, "database.max_idle_conns must not be negative")
+	check(db.MaxOpenConns == 0 || db.MaxIdleConns <= db.MaxOpenConns,
+		"database.max_idle_conns (%d) exceeds database.max_open_conns (%d)", db.MaxIdleConns, db.MaxOpenConns)
+	check(db.ConnMaxLifetime >= 0, "database.conn_max_lifetime must not be negative")
+	check(db.ConnMaxIdleTime >= 0, "database.conn_max_idle_time must not be negative")
+	check(db.ConnectAttempts >= 1, "database.connect_attempts must be at least 1")
+	check(db.ConnectInterval > 0, "database.connect_interval must be positive")
+
+	if c.Features.Redis {
+		_, _, err := net.SplitHostPort(c.Redis.Addr)
+		check(err == nil, "redis.addr %q must be host:port", c.Redis.Addr)
+	}
+	check(c.Redis.DB >= 0, "redis.db must not be negative")
+
+	check(c.Matching.DefaultBufferM > 0, "matching.default_buffer_m must be positive")
+	check(c.Matching.HighValueRewardThreshold > 0, "matching.high_value_reward_threshold must be positive")
+	check(c.Matching.RouteSimplifyToleranceM >= 0, "matching.route_simplify_tolerance_m must not be negative")
+
+	check(c.WebSocket.MaxMessageSize > 0, "websocket.max_message_size must be positive")
+	check(c.WebSocket.SendBuffer > 0, "websocket.send_buffer must be positive")
+	check(c.WebSocket.PongWait > 0, "websocket.pong_wait must be positive")
+	check(c.WebSocket.WriteWait > 0, "websocket.write_wait must be positive")
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		_, _, err := net.SplitHostPort(c.Tracing.Endpoint)
+		check(err == nil, "tracing.endpoint %q must be host:port", c.Tracing.Endpoint)
+	default:
+		check(false, "tracing.exporter must be none, otlp or stdout, not %q", c.Tracing.Exporter)
+	}
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio must be between 0 and 1")
+	check(c.Tracing.ServiceName != "", "tracing.service_name is required")
+
+	switch c.Log.Level {
+	case "debug", "info", "warn", "error":
+	default:
+		check(false, "log.level must be debug, info, warn or error, not %q", c.Log.Level)
+	}
+	check(c.Log.Format == "json" || c.Log.Format == "text", "log.format must be json or text, not %q", c.Log.Format)
+
+	return errors.Join(errs...)
+}
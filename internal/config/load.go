@@ -0,0 +1,277 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the configuration file when no -config flag is given
+const FileEnv = "CONFIG_FILE"
+
+// redacted replaces secrets in printed configuration
+const redacted = "REDACTED"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setting is one leaf field of Config
+type setting struct {
+	key    string // section.name, as in files and flags
+	env    string
+	usage  string
+	secret string // "true", or "url" when only the password is secret
+	value  reflect.Value
+}
+
+// settings lists the fields of c in declaration order
+func settings(c *Config) []setting {
+	var out []setting
+	root := reflect.ValueOf(c).Elem()
+	for i := 0; i < root.NumField(); i++ {
+		section := root.Type().Field(i)
+		sectionValue := root.Field(i)
+		for j := 0; j < sectionValue.NumField(); j++ {
+			field := section.Type.Field(j)
+			out = append(out, setting{
+				key:    section.Tag.Get("yaml") + "." + field.Tag.Get("yaml"),
+				env:    field.Tag.Get("env"),
+				usage:  field.Tag.Get("usage"),
+				secret: field.Tag.Get("secret"),
+				value:  sectionValue.Field(j),
+			})
+		}
+	}
+	return out
+}
+
+// set parses s into the setting
+func (s setting) set(text string) error {
+	v := s.value
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(text)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a duration like 30s or 5m", s.key, text)
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(text)
+	case v.Kind() == reflect.Int || v.Kind() == reflect.Int64:
+		n, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a whole number", s.key, text)
+		}
+		v.SetInt(n)
+	case v.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not a number", s.key, text)
+		}
+		v.SetFloat(f)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("%s: %q is not true or false", s.key, text)
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("%s: unsupported setting type %s", s.key, v.Type())
+	}
+	return nil
+}
+
+// display returns the setting as it is printed, masking secrets when asked
+func (s setting) display(redact bool) string {
+	var text string
+	if s.value.Type() == durationType {
+		text = time.Duration(s.value.Int()).String()
+	} else {
+		text = fmt.Sprint(s.value.Interface())
+	}
+	if !redact || text == "" {
+		return text
+	}
+	switch s.secret {
+	case "true":
+		return redacted
+	case "url":
+		if u, err := url.Parse(text); err == nil && u.User != nil {
+			if _, ok := u.User.Password(); ok {
+				u.User = url.UserPassword(u.User.Username(), redacted)
+			}
+			return u.String()
+		}
+	}
+	return text
+}
+
+// applyFile overrides c with the settings in a .yaml, .yml or .toml file.
+// Unknown keys are rejected, so a misspelt setting does not go unnoticed.
+func applyFile(c *Config, path string) error {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var doc map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(body, &doc)
+	case ".toml":
+		err = toml.Unmarshal(body, &doc)
+	default:
+		return fmt.Errorf("%s: configuration files must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	byKey := map[string]setting{}
+	for _, s := range settings(c) {
+		byKey[s.key] = s
+	}
+	for section, body := range doc {
+		fields, ok := body.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: %s must be a table of settings", path, section)
+		}
+		for name, value := range fields {
+			s, ok := byKey[section+"."+name]
+			if !ok {
+				return fmt.Errorf("%s: unknown setting %s.%s", path, section, name)
+			}
+			if value == nil {
+				continue
+			}
+			if err := s.set(fmt.Sprint(value)); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// applyEnv overrides c with the environment. Empty variables are ignored,
+// as in .env files that list a setting without a value.
+func applyEnv(c *Config) error {
+	for _, s := range settings(c) {
+		if text := os.Getenv(s.env); s.env != "" && text != "" {
+			if err := s.set(text); err != nil {
+				return fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+	return nil
+}
+
+// load reads the defaults, the file at path, if any, and the environment
+func load(path string) (Config, error) {
+	c := Default()
+	if path != "" {
+		if err := applyFile(&c, path); err != nil {
+			return c, err
+		}
+	}
+	return c, applyEnv(&c)
+}
+
+// Load reads the defaults, the file named by CONFIG_FILE and the
+// environment, and validates the result
+func Load() (Config, error) {
+	c, err := load(os.Getenv(FileEnv))
+	if err != nil {
+		return c, err
+	}
+	return c, c.Validate()
+}
+
+// Flags are the configuration flags registered on a flag set: -config and
+// one flag per setting, named by its key
+type Flags struct {
+	fs   *flag.FlagSet
+	file *string
+}
+
+// RegisterFlags adds the configuration flags to fs
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{fs: fs}
+	f.file = fs.String("config", "", "YAML or TOML configuration file (default $"+FileEnv+")")
+	defaults := Default()
+	for _, s := range settings(&defaults) {
+		usage := s.usage
+		if s.env != "" {
+			usage += " ($" + s.env + ")"
+		}
+		fs.String(s.key, s.display(false), usage)
+	}
+	return f
+}
+
+// Load is like the package's Load, with the flags set on the command line
+// taking precedence over everything else. Call it after parsing.
+func (f *Flags) Load() (Config, error) {
+	path := *f.file
+	if path == "" {
+		path = os.Getenv(FileEnv)
+	}
+	c, err := load(path)
+	if err != nil {
+		return c, err
+	}
+
+	byKey := map[string]setting{}
+	for _, s := range settings(&c) {
+		byKey[s.key] = s
+	}
+	f.fs.Visit(func(fl *flag.Flag) {
+		if s, ok := byKey[fl.Name]; ok && err == nil {
+			if setErr := s.set(fl.Value.String()); setErr != nil {
+				err = fmt.Errorf("-%s: %w", fl.Name, setErr)
+			}
+		}
+	})
+	if err != nil {
+		return c, err
+	}
+	return c, c.Validate()
+}
+
+// Tree returns the settings grouped by section, for printing as YAML or
+// TOML. Secrets are masked when redact is set.
+func (c Config) Tree(redact bool) map[string]map[string]interface{} {
+	tree := map[string]map[string]interface{}{}
+	for _, s := range settings(&c) {
+		section, name, _ := strings.Cut(s.key, ".")
+		if tree[section] == nil {
+			tree[section] = map[string]interface{}{}
+		}
+		if s.value.Type() == durationType || (redact && s.secret != "") {
+			tree[section][name] = s.display(redact)
+		} else {
+			tree[section][name] = s.value.Interface()
+		}
+	}
+	return tree
+}
+
+// Env returns the settings as NAME=value lines in a stable order, masking
+// secrets when redact is set
+func (c Config) Env(redact bool) []string {
+	var lines []string
+	for _, s := range settings(&c) {
+		if s.env != "" {
+			lines = append(lines, s.env+"="+s.display(redact))
+		}
+	}
+	sort.Strings(lines)
+	return lines
+}
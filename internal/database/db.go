@@ -1,34 +1,66 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"time"
 
+	"github.com/Woeter69/hackoverflow/internal/config"
+	"github.com/XSAM/otelsql"
 	_ "github.com/lib/pq"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
 )
 
 var DB *sql.DB
 
-func InitDB(url string) {
-	var err error
-	
-	// Retry logic for DB connection (30s timeout total)
-	maxRetries := 10
-	for i := 0; i < maxRetries; i++ {
-		DB, err = sql.Open("postgres", url)
-		if err == nil {
-			err = DB.Ping()
-			if err == nil {
-				fmt.Println("Database connection established")
-				return
-			}
+// InitDB connects to the database and sizes its connection pool. It retries
+// an unreachable database as configured, giving up early when ctx is done.
+func InitDB(ctx context.Context, cfg config.Database) error {
+	// Every statement gets a span; the statement is recorded with its
+	// placeholders, never its arguments
+	db, err := otelsql.Open("postgres", cfg.DSN(),
+		otelsql.WithAttributes(semconv.DBSystemNamePostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{OmitRows: true, OmitConnResetSession: true, DisableErrSkip: true}),
+	)
+	if err != nil {
+		return err
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	for attempt := 1; ; attempt++ {
+		if err = db.PingContext(ctx); err == nil {
+			DB = db
+			slog.InfoContext(ctx, "Database connection established")
+			return nil
+		}
+		if attempt == cfg.ConnectAttempts {
+			break
+		}
+		slog.WarnContext(ctx, "Database unreachable, retrying", "attempt", attempt, "retry_in", cfg.ConnectInterval.String(), "err", err)
+		select {
+		case <-ctx.Done():
+			db.Close()
+			return ctx.Err()
+		case <-time.After(cfg.ConnectInterval):
 		}
-		
-		log.Printf("Attempt %d: Database unreachable, retrying in 3s... (Error: %v)", i+1, err)
-		time.Sleep(3 * time.Second)
 	}
+	db.Close()
+	return fmt.Errorf("database unreachable after %d attempts: %w", cfg.ConnectAttempts, err)
+}
 
-	log.Fatalf("Failed to connect to database after %d attempts: %v", maxRetries, err)
+// CheckPostGIS makes sure the PostGIS extension every geographic query relies
+// on is installed in the database
+func CheckPostGIS(ctx context.Context, db *sql.DB) error {
+	var version string
+	err := db.QueryRowContext(ctx, `SELECT extversion FROM pg_extension WHERE extname = 'postgis'`).Scan(&version)
+	if err == sql.ErrNoRows {
+		return errors.New("postgis extension is not installed")
+	}
+	return err
 }
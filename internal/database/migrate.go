@@ -0,0 +1,237 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+//go:embed seed.sql
+var seedSQL string
+
+// migrationLockID is the Postgres advisory lock held while migrating, so
+// instances started together do not apply the same migration twice
+const migrationLockID = 4_217_309_511
+
+var migrationName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is one numbered schema change
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationState is a migration and when it was applied, if it was
+type MigrationState struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrations returns the embedded migrations in version order. Every
+// migration must come with both an up and a down script.
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		m := migrationName.FindStringSubmatch(entry.Name())
+		if m == nil {
+			return nil, fmt.Errorf("unexpected migration file %s", entry.Name())
+		}
+		version, _ := strconv.ParseInt(m[1], 10, 64)
+		body, err := migrationFiles.ReadFile("migrations/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		} else if mig.Name != m[2] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, mig.Name, m[2])
+		}
+		if m[3] == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" || mig.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down script", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// withMigrationLock runs fn on a single connection holding the migration
+// lock, after making sure schema_migrations exists
+func withMigrationLock(ctx context.Context, db *sql.DB, fn func(conn *sql.Conn) error) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	// Unlock even if ctx was cancelled, or the lock outlives the call on a
+	// pooled connection
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+func appliedMigrations(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// runMigration applies one script and records the result in the same
+// transaction, so a failed migration leaves no trace
+func runMigration(ctx context.Context, conn *sql.Conn, script string, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MigrateUp applies every pending migration in order and returns the ones
+// it applied
+func MigrateUp(ctx context.Context, db *sql.DB) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	err = withMigrationLock(ctx, db, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if err := runMigration(ctx, conn, m.Up, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", m.Version, m.Name); err != nil {
+				return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrateDown rolls back the latest steps applied migrations, newest first,
+// and returns the ones it rolled back
+func MigrateDown(ctx context.Context, db *sql.DB, steps int) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	err = withMigrationLock(ctx, db, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if err := runMigration(ctx, conn, m.Down, "DELETE FROM schema_migrations WHERE version = $1", m.Version); err != nil {
+				return fmt.Errorf("rollback %d_%s: %w", m.Version, m.Name, err)
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrationStatus lists every embedded migration and whether it is applied
+func MigrationStatus(ctx context.Context, db *sql.DB) ([]MigrationState, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var states []MigrationState
+	err = withMigrationLock(ctx, db, func(conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, m := range migrations {
+			state := MigrationState{Migration: m}
+			if at, ok := applied[m.Version]; ok {
+				state.AppliedAt = &at
+			}
+			states = append(states, state)
+		}
+		return nil
+	})
+	return states, err
+}
+
+// Seed loads the sample data used by development databases. It expects
+// the schema to be migrated.
+func Seed(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, seedSQL)
+	return err
+}
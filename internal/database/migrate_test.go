@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+)
+
+func TestMigrationsArePairedAndOrdered(t *testing.T) {
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 || migrations[0].Version != 1 || migrations[0].Name != "baseline" {
+		t.Fatalf("expected the baseline first, got %+v", migrations)
+	}
+	for i, m := range migrations {
+		if m.Up == "" || m.Down == "" {
+			t.Fatalf("migration %d is missing a script", m.Version)
+		}
+		if i > 0 && m.Version <= migrations[i-1].Version {
+			t.Fatalf("migration %d is out of order", m.Version)
+		}
+	}
+}
+
+// TestMigrateRoundTrip rolls the newest migration back and forth on the
+// database named by TEST_DB_URL
+func TestMigrateRoundTrip(t *testing.T) {
+	url := os.Getenv("TEST_DB_URL")
+	if url == "" {
+		t.Skip("TEST_DB_URL not set")
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	if _, err := MigrateUp(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	if again, err := MigrateUp(ctx, db); err != nil || len(again) != 0 {
+		t.Fatalf("a migrated schema should have nothing to apply, got %v, %v", again, err)
+	}
+
+	migrations, _ := Migrations()
+	latest := migrations[len(migrations)-1]
+	if latest.Version == 1 {
+		t.Skip("only the baseline exists; rolling it back would drop every table")
+	}
+	rolledBack, err := MigrateDown(ctx, db, 1)
+	if err != nil || len(rolledBack) != 1 || rolledBack[0].Version != latest.Version {
+		t.Fatalf("expected to roll back %d, got %v, %v", latest.Version, rolledBack, err)
+	}
+	states, err := MigrationStatus(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := states[len(states)-1]; last.AppliedAt != nil {
+		t.Fatalf("migration %d is still applied", last.Version)
+	}
+	if applied, err := MigrateUp(ctx, db); err != nil || len(applied) != 1 {
+		t.Fatalf("expected to reapply %d, got %v, %v", latest.Version, applied, err)
+	}
+}
@@ -0,0 +1,20 @@
+-- Drops every table of the baseline, and with it all data. The PostGIS
+-- extension is left installed.
+DROP TABLE IF EXISTS
+    calendar_feeds,
+    travel_plan_series,
+    credit_holds,
+    dispute_statements,
+    errand_disputes,
+    errand_events,
+    messages,
+    emergency_beacons,
+    errand_requests,
+    travel_plans,
+    places,
+    user_roles,
+    runner_verifications,
+    campus_email_domains,
+    users,
+    campuses
+CASCADE;
@@ -0,0 +1,298 @@
+-- Baseline: the schema as it stood in schema.sql. Every statement is
+-- idempotent so databases created from schema.sql can adopt migrations by
+-- running it once.
+
+-- Enable PostGIS extension
+CREATE EXTENSION IF NOT EXISTS postgis;
+
+-- Campuses (tenants). Every user, errand, plan and beacon belongs to one.
+CREATE TABLE IF NOT EXISTS campuses (
+    id VARCHAR(50) PRIMARY KEY, -- slug, e.g. 'default', 'north-campus'
+    name VARCHAR(100) NOT NULL,
+    boundary GEOGRAPHY(POLYGON, 4326), -- service area
+    currency_name VARCHAR(30) DEFAULT 'Credits',
+    default_buffer_m DOUBLE PRECISION DEFAULT 200, -- route matching buffer in meters
+    categories TEXT[] DEFAULT '{delivery,borrow,favor}', -- allowed errand categories
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO campuses (id, name) VALUES ('default', 'Main Campus') ON CONFLICT (id) DO NOTHING;
+
+-- Users Table
+CREATE TABLE IF NOT EXISTS users (
+    id TEXT PRIMARY KEY, -- Firebase UID
+    username VARCHAR(50), -- chosen by the user, unique when set
+    email VARCHAR(100), -- synced from the verified token
+    display_name VARCHAR(100), -- seeded from the token, editable
+    email_verified BOOLEAN DEFAULT FALSE, -- synced from the verified token
+    campus_verified BOOLEAN DEFAULT FALSE, -- verified email on an allowed campus domain
+    campus_id VARCHAR(50) REFERENCES campuses(id), -- assigned from the email domain
+    credits INT DEFAULT 100,
+    xp INT DEFAULT 0,
+    rating DECIMAL(3, 2) DEFAULT 5.0,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Columns added after the initial release
+ALTER TABLE users ADD COLUMN IF NOT EXISTS display_name VARCHAR(100);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified BOOLEAN DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS campus_verified BOOLEAN DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS campus_id VARCHAR(50) REFERENCES campuses(id);
+
+-- Lazily created profiles all shared the placeholder username
+UPDATE users SET username = NULL WHERE username = 'Traveler';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (LOWER(username));
+
+-- Institutional email domains allowed to transact (subdomains match too)
+CREATE TABLE IF NOT EXISTS campus_email_domains (
+    domain VARCHAR(255) PRIMARY KEY CHECK (domain = LOWER(domain)),
+    campus_id VARCHAR(50) NOT NULL DEFAULT 'default' REFERENCES campuses(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Verified Runner applications (student ID reviewed by admins)
+CREATE TABLE IF NOT EXISTS runner_verifications (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    document_path TEXT NOT NULL, -- uploaded student ID, relative to UPLOAD_DIR
+    document_type VARCHAR(100),
+    status VARCHAR(20) DEFAULT 'pending', -- 'pending', 'approved', 'rejected'
+    review_notes TEXT,
+    reviewed_by TEXT,
+    reviewed_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- One application under review per user
+CREATE UNIQUE INDEX IF NOT EXISTS idx_runner_verifications_pending ON runner_verifications(user_id) WHERE status = 'pending';
+
+-- User Roles (every user is implicitly a 'student')
+CREATE TABLE IF NOT EXISTS user_roles (
+    user_id TEXT NOT NULL, -- Firebase UID
+    role VARCHAR(30) NOT NULL CHECK (role IN ('student', 'verified_runner', 'campus_responder', 'moderator', 'admin')),
+    granted_by TEXT,
+    granted_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, role)
+);
+
+-- Travel Plans (Carpool/Commute)
+CREATE TABLE IF NOT EXISTS travel_plans (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id TEXT REFERENCES users(id) ON DELETE CASCADE,
+    origin_name TEXT,
+    destination_name TEXT,
+    origin_geom GEOGRAPHY(POINT, 4326),
+    destination_geom GEOGRAPHY(POINT, 4326),
+    -- The route can be simplified to a LineString for matching
+    route_geom GEOGRAPHY(LINESTRING, 4326),
+    mode VARCHAR(20), -- 'walk', 'cycle', 'car', 'cab'
+    start_time TIMESTAMP WITH TIME ZONE NOT NULL,
+    seats_available INT DEFAULT 1,
+    is_active BOOLEAN DEFAULT TRUE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Errand Requests (Piggyback/Orders)
+CREATE TABLE IF NOT EXISTS errand_requests (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id TEXT REFERENCES users(id) ON DELETE CASCADE,
+    title VARCHAR(100) NOT NULL,
+    description TEXT,
+    category VARCHAR(50),
+    pickup_geom GEOGRAPHY(POINT, 4326) NOT NULL,
+    dropoff_geom GEOGRAPHY(POINT, 4326) NOT NULL,
+    status VARCHAR(20) DEFAULT 'pending', -- 'pending', 'matched', 'picked_up', 'delivered', 'completed', 'cancelled', 'disputed'
+    urgency_level INT DEFAULT 1,
+    reward_estimate DECIMAL(10, 2),
+    runner_id TEXT REFERENCES users(id) ON DELETE SET NULL, -- The traveler who accepted the errand
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Databases created before errands had a category
+ALTER TABLE errand_requests ADD COLUMN IF NOT EXISTS category VARCHAR(50);
+
+-- Emergency Beacons (Panic Button)
+CREATE TABLE IF NOT EXISTS emergency_beacons (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id TEXT,
+    current_location GEOGRAPHY(POINT, 4326) NOT NULL,
+    is_active BOOLEAN DEFAULT TRUE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Spatial Indexes for Performance
+CREATE INDEX IF NOT EXISTS idx_travel_plans_route ON travel_plans USING GIST (route_geom);
+CREATE INDEX IF NOT EXISTS idx_errand_requests_pickup ON errand_requests USING GIST (pickup_geom);
+CREATE INDEX IF NOT EXISTS idx_emergency_beacons_location ON emergency_beacons USING GIST (current_location);
+
+-- Chat Messages for Errands
+CREATE TABLE IF NOT EXISTS messages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    errand_id UUID REFERENCES errand_requests(id) ON DELETE CASCADE,
+    sender_id TEXT REFERENCES users(id) ON DELETE SET NULL, -- Firebase UID
+    content TEXT NOT NULL,
+    is_encrypted BOOLEAN DEFAULT TRUE, -- Just for flavor/visuals
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_messages_errand_id ON messages(errand_id);
+
+-- Tenancy columns for databases created before multi-campus support.
+-- Existing rows belong to the default campus.
+ALTER TABLE travel_plans ADD COLUMN IF NOT EXISTS campus_id VARCHAR(50) NOT NULL DEFAULT 'default' REFERENCES campuses(id);
+ALTER TABLE errand_requests ADD COLUMN IF NOT EXISTS campus_id VARCHAR(50) NOT NULL DEFAULT 'default' REFERENCES campuses(id);
+ALTER TABLE emergency_beacons ADD COLUMN IF NOT EXISTS campus_id VARCHAR(50) NOT NULL DEFAULT 'default' REFERENCES campuses(id);
+
+CREATE INDEX IF NOT EXISTS idx_travel_plans_campus ON travel_plans(campus_id) WHERE is_active;
+CREATE INDEX IF NOT EXISTS idx_errand_requests_campus_status ON errand_requests(campus_id, status, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_emergency_beacons_campus ON emergency_beacons(campus_id) WHERE is_active;
+
+-- Foreign keys for databases created before users were provisioned from tokens.
+-- Backfill placeholder rows for any IDs that were only stored as loose strings.
+INSERT INTO users (id) SELECT DISTINCT user_id FROM travel_plans WHERE user_id IS NOT NULL ON CONFLICT (id) DO NOTHING;
+INSERT INTO users (id) SELECT DISTINCT user_id FROM errand_requests WHERE user_id IS NOT NULL ON CONFLICT (id) DO NOTHING;
+INSERT INTO users (id) SELECT DISTINCT runner_id FROM errand_requests WHERE runner_id IS NOT NULL ON CONFLICT (id) DO NOTHING;
+INSERT INTO users (id) SELECT DISTINCT sender_id FROM messages WHERE sender_id IS NOT NULL ON CONFLICT (id) DO NOTHING;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'travel_plans_user_id_fkey') THEN
+        ALTER TABLE travel_plans ADD CONSTRAINT travel_plans_user_id_fkey
+            FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'errand_requests_user_id_fkey') THEN
+        ALTER TABLE errand_requests ADD CONSTRAINT errand_requests_user_id_fkey
+            FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE;
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'errand_requests_runner_id_fkey') THEN
+        ALTER TABLE errand_requests ADD CONSTRAINT errand_requests_runner_id_fkey
+            FOREIGN KEY (runner_id) REFERENCES users(id) ON DELETE SET NULL;
+    END IF;
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'messages_sender_id_fkey') THEN
+        ALTER TABLE messages ADD CONSTRAINT messages_sender_id_fkey
+            FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE SET NULL;
+    END IF;
+END $$;
+
+-- Errand History (status changes, disputes and their outcomes)
+CREATE TABLE IF NOT EXISTS errand_events (
+    id BIGSERIAL PRIMARY KEY,
+    errand_id UUID REFERENCES errand_requests(id) ON DELETE CASCADE,
+    actor_id TEXT, -- Firebase UID of whoever triggered the event
+    event VARCHAR(40) NOT NULL, -- 'status_changed', 'dispute_opened', 'dispute_resolved', ...
+    details JSONB DEFAULT '{}'::jsonb,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_errand_events_errand_id ON errand_events(errand_id);
+
+-- Disputes raised on matched or completed errands
+CREATE TABLE IF NOT EXISTS errand_disputes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    errand_id UUID REFERENCES errand_requests(id) ON DELETE CASCADE,
+    opened_by TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    previous_status VARCHAR(20) NOT NULL, -- errand status before the dispute froze it
+    status VARCHAR(20) DEFAULT 'open', -- 'open', 'resolved'
+    outcome VARCHAR(20), -- 'refund', 'payout', 'partial'
+    payout_amount DECIMAL(10, 2), -- credits released to the runner
+    refund_amount DECIMAL(10, 2), -- credits returned to the requester
+    resolution_note TEXT,
+    resolved_by TEXT,
+    resolved_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Only one open dispute per errand at a time
+CREATE UNIQUE INDEX IF NOT EXISTS idx_errand_disputes_open ON errand_disputes(errand_id) WHERE status = 'open';
+
+-- Statements and evidence submitted by either party
+CREATE TABLE IF NOT EXISTS dispute_statements (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    dispute_id UUID REFERENCES errand_disputes(id) ON DELETE CASCADE,
+    author_id TEXT NOT NULL,
+    statement TEXT NOT NULL,
+    evidence_urls TEXT[] DEFAULT '{}',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_dispute_statements_dispute_id ON dispute_statements(dispute_id);
+
+-- Credit Holds: reward frozen while a dispute is open
+CREATE TABLE IF NOT EXISTS credit_holds (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    errand_id UUID REFERENCES errand_requests(id) ON DELETE CASCADE,
+    dispute_id UUID REFERENCES errand_disputes(id) ON DELETE CASCADE,
+    runner_id TEXT,
+    amount DECIMAL(10, 2) NOT NULL,
+    clawed_back BOOLEAN DEFAULT FALSE, -- TRUE if the payout had already been made and was reversed
+    status VARCHAR(20) DEFAULT 'held', -- 'held', 'released'
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    released_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Places: named buildings, cafes, gates and hostels on a campus
+CREATE TABLE IF NOT EXISTS places (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    campus_id VARCHAR(50) NOT NULL DEFAULT 'default' REFERENCES campuses(id) ON DELETE CASCADE,
+    name VARCHAR(150) NOT NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('building', 'cafe', 'gate', 'hostel', 'other')),
+    code VARCHAR(30), -- short label, e.g. 'LIB'
+    location GEOGRAPHY(POINT, 4326) NOT NULL, -- marker / label position
+    footprint GEOGRAPHY(POLYGON, 4326), -- building outline
+    entrances GEOGRAPHY(MULTIPOINT, 4326), -- doors, used as pickup/dropoff points
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_places_campus ON places(campus_id, kind);
+CREATE INDEX IF NOT EXISTS idx_places_location ON places USING GIST (location);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_places_name ON places(campus_id, LOWER(name));
+
+-- Errands and plans can name the places they start and end at
+ALTER TABLE errand_requests ADD COLUMN IF NOT EXISTS pickup_place_id UUID REFERENCES places(id) ON DELETE SET NULL;
+ALTER TABLE errand_requests ADD COLUMN IF NOT EXISTS dropoff_place_id UUID REFERENCES places(id) ON DELETE SET NULL;
+ALTER TABLE travel_plans ADD COLUMN IF NOT EXISTS origin_place_id UUID REFERENCES places(id) ON DELETE SET NULL;
+ALTER TABLE travel_plans ADD COLUMN IF NOT EXISTS destination_place_id UUID REFERENCES places(id) ON DELETE SET NULL;
+
+-- Recurring travel plans: a weekly schedule whose departures are
+-- materialized into travel_plans ahead of time
+CREATE TABLE IF NOT EXISTS travel_plan_series (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    campus_id VARCHAR(50) NOT NULL REFERENCES campuses(id),
+    origin_name TEXT,
+    destination_name TEXT,
+    origin_place_id UUID REFERENCES places(id) ON DELETE SET NULL,
+    destination_place_id UUID REFERENCES places(id) ON DELETE SET NULL,
+    origin_geom GEOGRAPHY(POINT, 4326) NOT NULL,
+    destination_geom GEOGRAPHY(POINT, 4326) NOT NULL,
+    route_geom GEOGRAPHY(LINESTRING, 4326) NOT NULL,
+    mode VARCHAR(20) NOT NULL DEFAULT 'walk',
+    seats_available INT DEFAULT 1,
+    rrule TEXT NOT NULL, -- e.g. 'FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR'
+    first_departure TIMESTAMP WITH TIME ZONE NOT NULL,
+    timezone TEXT NOT NULL DEFAULT 'UTC', -- departures keep their wall-clock time in this zone
+    exceptions DATE[] NOT NULL DEFAULT '{}', -- holidays and other skipped dates
+    paused BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_travel_plan_series_user ON travel_plan_series(user_id);
+
+ALTER TABLE travel_plans ADD COLUMN IF NOT EXISTS series_id UUID REFERENCES travel_plan_series(id) ON DELETE SET NULL;
+
+-- Each departure of a series is materialized once
+CREATE UNIQUE INDEX IF NOT EXISTS idx_travel_plans_series_start ON travel_plans(series_id, start_time) WHERE series_id IS NOT NULL;
+
+-- Private calendar feeds. The token in the feed URL is the only credential
+-- a calendar app can send, so only its hash is stored.
+CREATE TABLE IF NOT EXISTS calendar_feeds (
+    user_id TEXT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    token_hash TEXT NOT NULL UNIQUE, -- hex SHA-256 of the token
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,7 @@
+ALTER TABLE emergency_beacons DROP COLUMN IF EXISTS place_id;
+ALTER TABLE emergency_beacons DROP COLUMN IF EXISTS building_id;
+ALTER TABLE emergency_beacons DROP COLUMN IF EXISTS message;
+
+-- Beacons raised without a location cannot be kept once it is required again
+DELETE FROM emergency_beacons WHERE current_location IS NULL;
+ALTER TABLE emergency_beacons ALTER COLUMN current_location SET NOT NULL;
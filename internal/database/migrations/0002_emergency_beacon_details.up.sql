@@ -0,0 +1,6 @@
+-- Emergency beacons replace the in-process record of who raised an alert,
+-- so the alert survives restarts and is shared between instances
+ALTER TABLE emergency_beacons ALTER COLUMN current_location DROP NOT NULL;
+ALTER TABLE emergency_beacons ADD COLUMN IF NOT EXISTS message TEXT;
+ALTER TABLE emergency_beacons ADD COLUMN IF NOT EXISTS building_id INT;
+ALTER TABLE emergency_beacons ADD COLUMN IF NOT EXISTS place_id UUID REFERENCES places(id) ON DELETE SET NULL;
@@ -0,0 +1,13 @@
+-- Manual credit corrections made by operators. Kept when the user is
+-- deleted, since they are the audit trail for the balance.
+CREATE TABLE IF NOT EXISTS credit_adjustments (
+    id BIGSERIAL PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    amount INT NOT NULL CHECK (amount <> 0),
+    balance INT NOT NULL, -- credits after the adjustment
+    reason TEXT NOT NULL CHECK (reason <> ''),
+    actor TEXT NOT NULL, -- operator who made the correction
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_credit_adjustments_user ON credit_adjustments(user_id, created_at);
@@ -0,0 +1,36 @@
+-- Append-only record of sensitive actions: credit movements, cancellations,
+-- emergencies and admin overrides. Each row's hash covers its contents and
+-- the previous row's hash, so editing or removing a row breaks the chain
+-- from that row on (see "main audit verify").
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGINT PRIMARY KEY, -- 1, 2, 3, ... with no gaps
+    actor TEXT NOT NULL, -- user id, or the operator of a command ("cli:$USER" by default)
+    action TEXT NOT NULL,
+    target TEXT NOT NULL, -- "<kind>:<id>", e.g. "errand:<uuid>"
+    before JSONB,
+    after JSONB,
+    request_id TEXT NOT NULL DEFAULT '',
+    prev_hash TEXT NOT NULL,
+    hash TEXT NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor, id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_action ON audit_log(action, id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_target ON audit_log(target, id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(created_at);
+
+-- Rows can only be added. A superuser can still drop the trigger, which is
+-- what the hash chain is for.
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_no_update ON audit_log;
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+DROP TRIGGER IF EXISTS audit_log_no_truncate ON audit_log;
+CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
@@ -2,27 +2,35 @@ package database
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
-	"os"
+	"net"
 
+	"github.com/Woeter69/hackoverflow/internal/config"
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
 var RedisClient *redis.Client
 
-func InitRedis() error {
-	redisAddr := os.Getenv("REDIS_ADDR")
-	if redisAddr == "" {
-		redisAddr = "localhost:6379"
+// InitRedis connects to the configured Redis server
+func InitRedis(ctx context.Context, cfg config.Redis) error {
+	opts := &redis.Options{
+		Addr:     cfg.Addr,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	}
+	if cfg.TLS {
+		host, _, _ := net.SplitHostPort(cfg.Addr)
+		opts.TLSConfig = &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	}
+	RedisClient = redis.NewClient(opts)
+	if err := redisotel.InstrumentTracing(RedisClient); err != nil {
+		return fmt.Errorf("failed to trace redis: %v", err)
 	}
 
-	RedisClient = redis.NewClient(&redis.Options{
-		Addr:     redisAddr,
-		Password: "", // no password set
-		DB:       0,  // use default DB
-	})
-
-	_, err := RedisClient.Ping(context.Background()).Result()
+	_, err := RedisClient.Ping(ctx).Result()
 	if err != nil {
 		return fmt.Errorf("failed to connect to redis: %v", err)
 	}
@@ -0,0 +1,39 @@
+-- Sample data for development databases. Safe to apply more than once.
+
+INSERT INTO users (id, username, email, credits, xp, rating, campus_id)
+VALUES ('system-bot', 'CampusGuard', 'bot@campusloop.com', 9999, 1000, 5.0, 'default')
+ON CONFLICT (id) DO NOTHING;
+
+-- Sample Errand
+INSERT INTO errand_requests (user_id, title, description, category, pickup_geom, dropoff_geom, status, reward_estimate)
+SELECT
+    'system-bot',
+    'Coffee from Starbucks',
+    'Need a Venti Latte from the library Starbucks.',
+    'delivery',
+    ST_GeomFromText('POINT(77.5946 12.9716)', 4326)::geography,
+    ST_GeomFromText('POINT(77.5950 12.9720)', 4326)::geography,
+    'pending',
+    5.00
+WHERE NOT EXISTS (SELECT 1 FROM errand_requests WHERE user_id = 'system-bot');
+
+-- Sample Places
+INSERT INTO places (campus_id, name, kind, code, location)
+VALUES
+    ('default', 'Main Gate', 'gate', 'GATE1', ST_GeomFromText('POINT(77.5940 12.9710)', 4326)::geography),
+    ('default', 'Library Cafe', 'cafe', 'LIBCAFE', ST_GeomFromText('POINT(77.5946 12.9716)', 4326)::geography),
+    ('default', 'Science Block', 'building', 'SCI', ST_GeomFromText('POINT(77.5960 12.9730)', 4326)::geography)
+ON CONFLICT DO NOTHING;
+
+-- Sample Travel Plan
+INSERT INTO travel_plans (user_id, origin_name, destination_name, origin_geom, destination_geom, route_geom, mode, start_time)
+SELECT
+    'system-bot',
+    'Main Gate',
+    'Science Block',
+    ST_GeomFromText('POINT(77.5940 12.9710)', 4326)::geography,
+    ST_GeomFromText('POINT(77.5960 12.9730)', 4326)::geography,
+    ST_GeogFromText('LINESTRING(77.5940 12.9710, 77.5950 12.9720, 77.5960 12.9730)'),
+    'walk',
+    CURRENT_TIMESTAMP + INTERVAL '1 hour'
+WHERE NOT EXISTS (SELECT 1 FROM travel_plans WHERE user_id = 'system-bot' AND is_active);
@@ -0,0 +1,136 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// BBox is a longitude/latitude rectangle. Boxes crossing the antimeridian
+// are not supported.
+type BBox struct {
+	MinLng, MinLat, MaxLng, MaxLat float64
+}
+
+// ParseBBox reads "minLng,minLat,maxLng,maxLat", the GeoJSON bbox order
+func ParseBBox(s string) (BBox, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return BBox{}, newError(CodeInvalidGeometry, "bbox must be minLng,minLat,maxLng,maxLat")
+	}
+	var v [4]float64
+	for i, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return BBox{}, newError(CodeInvalidGeometry, "bbox must be minLng,minLat,maxLng,maxLat")
+		}
+		v[i] = f
+	}
+	b := BBox{MinLng: v[0], MinLat: v[1], MaxLng: v[2], MaxLat: v[3]}
+	for _, corner := range []Point{{Lng: b.MinLng, Lat: b.MinLat}, {Lng: b.MaxLng, Lat: b.MaxLat}} {
+		if err := checkRange(corner); err != nil {
+			return BBox{}, err
+		}
+	}
+	if b.MinLng > b.MaxLng || b.MinLat > b.MaxLat {
+		return BBox{}, newError(CodeInvalidGeometry, "bbox minimums must not exceed its maximums")
+	}
+	return b, nil
+}
+
+// Contains reports whether p lies in the box, edges included
+func (b BBox) Contains(p Point) bool {
+	return p.Lng >= b.MinLng && p.Lng <= b.MaxLng && p.Lat >= b.MinLat && p.Lat <= b.MaxLat
+}
+
+// Bounds returns the smallest box holding the polygon's outer ring
+func (p Polygon) Bounds() BBox {
+	if len(p) == 0 || len(p[0]) == 0 {
+		return BBox{}
+	}
+	b := BBox{MinLng: p[0][0].Lng, MinLat: p[0][0].Lat, MaxLng: p[0][0].Lng, MaxLat: p[0][0].Lat}
+	for _, pt := range p[0][1:] {
+		b.MinLng, b.MaxLng = math.Min(b.MinLng, pt.Lng), math.Max(b.MaxLng, pt.Lng)
+		b.MinLat, b.MaxLat = math.Min(b.MinLat, pt.Lat), math.Max(b.MaxLat, pt.Lat)
+	}
+	return b
+}
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// DecodeGeohash returns the cell a geohash names. Longer hashes are smaller
+// cells: 5 characters is about 5km, 7 about 150m.
+func DecodeGeohash(hash string) (BBox, error) {
+	hash = strings.ToLower(strings.TrimSpace(hash))
+	if hash == "" || len(hash) > 12 {
+		return BBox{}, newError(CodeInvalidGeometry, "geohash must be 1 to 12 characters")
+	}
+	b := BBox{MinLng: -180, MinLat: -90, MaxLng: 180, MaxLat: 90}
+	even := true // bits alternate, starting with longitude
+	for _, r := range hash {
+		idx := strings.IndexRune(geohashAlphabet, r)
+		if idx < 0 {
+			return BBox{}, newError(CodeInvalidGeometry, fmt.Sprintf("geohash has an invalid character %q", r))
+		}
+		for bit := 4; bit >= 0; bit-- {
+			on := idx>>uint(bit)&1 == 1
+			if even {
+				mid := (b.MinLng + b.MaxLng) / 2
+				if on {
+					b.MinLng = mid
+				} else {
+					b.MaxLng = mid
+				}
+			} else {
+				mid := (b.MinLat + b.MaxLat) / 2
+				if on {
+					b.MinLat = mid
+				} else {
+					b.MaxLat = mid
+				}
+			}
+			even = !even
+		}
+	}
+	return b, nil
+}
+
+// EncodeGeohash returns the geohash of the cell containing p
+func EncodeGeohash(p Point, precision int) string {
+	if precision < 1 {
+		precision = 1
+	}
+	if precision > 12 {
+		precision = 12
+	}
+	b := BBox{MinLng: -180, MinLat: -90, MaxLng: 180, MaxLat: 90}
+	var out strings.Builder
+	even := true
+	for out.Len() < precision {
+		idx := 0
+		for bit := 0; bit < 5; bit++ {
+			idx <<= 1
+			if even {
+				mid := (b.MinLng + b.MaxLng) / 2
+				if p.Lng >= mid {
+					idx |= 1
+					b.MinLng = mid
+				} else {
+					b.MaxLng = mid
+				}
+			} else {
+				mid := (b.MinLat + b.MaxLat) / 2
+				if p.Lat >= mid {
+					idx |= 1
+					b.MinLat = mid
+				} else {
+					b.MaxLat = mid
+				}
+			}
+			even = !even
+		}
+		out.WriteByte(geohashAlphabet[idx])
+	}
+	return out.String()
+}
@@ -0,0 +1,185 @@
+// Package geo parses and validates the geometries clients send (WKT or
+// GeoJSON) before they reach PostGIS. Coordinates are WGS 84 (SRID 4326) in
+// longitude, latitude order, as in both formats.
+package geo
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+const earthRadiusM = 6371008.8
+
+// Point is a WGS 84 position
+type Point struct {
+	Lng float64
+	Lat float64
+}
+
+// LineString is an ordered path of positions, e.g. a travel route
+type LineString []Point
+
+// Polygon is an outer ring followed by any holes. Rings are closed: the
+// first and last positions are equal.
+type Polygon [][]Point
+
+func formatCoord(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func (p Point) coords() string {
+	return formatCoord(p.Lng) + " " + formatCoord(p.Lat)
+}
+
+func joinCoords(points []Point) string {
+	parts := make([]string, len(points))
+	for i, p := range points {
+		parts[i] = p.coords()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// WKT renders the point as canonical WKT for ST_GeomFromText
+func (p Point) WKT() string {
+	return "POINT(" + p.coords() + ")"
+}
+
+// WKT renders the line as canonical WKT for ST_GeomFromText
+func (l LineString) WKT() string {
+	return "LINESTRING(" + joinCoords(l) + ")"
+}
+
+// WKT renders the polygon as canonical WKT for ST_GeogFromText
+func (p Polygon) WKT() string {
+	rings := make([]string, len(p))
+	for i, ring := range p {
+		rings[i] = "(" + joinCoords(ring) + ")"
+	}
+	return "POLYGON(" + strings.Join(rings, ", ") + ")"
+}
+
+// Distance returns the great-circle distance between two points in meters
+func Distance(a, b Point) float64 {
+	lat1 := a.Lat * math.Pi / 180
+	lat2 := b.Lat * math.Pi / 180
+	dLat := lat2 - lat1
+	dLng := (b.Lng - a.Lng) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusM * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Length returns the length of the path in meters
+func (l LineString) Length() float64 {
+	total := 0.0
+	for i := 1; i < len(l); i++ {
+		total += Distance(l[i-1], l[i])
+	}
+	return total
+}
+
+// DistanceTo returns the shortest distance in meters from pt to the path.
+// Like Simplify it works on a local plane around pt, which is accurate at
+// campus scale.
+func (l LineString) DistanceTo(pt Point) float64 {
+	if len(l) == 1 {
+		return Distance(l[0], pt)
+	}
+	scale := earthRadiusM * math.Pi / 180
+	cosLat := math.Cos(pt.Lat * math.Pi / 180)
+	project := func(p Point) [2]float64 {
+		return [2]float64{(p.Lng - pt.Lng) * scale * cosLat, (p.Lat - pt.Lat) * scale}
+	}
+
+	best := math.Inf(1)
+	for i := 1; i < len(l); i++ {
+		best = math.Min(best, segmentDistance([2]float64{}, project(l[i-1]), project(l[i])))
+	}
+	return best
+}
+
+// DistanceTo returns how far pt is from the polygon in meters; points inside
+// it are at 0
+func (p Polygon) DistanceTo(pt Point) float64 {
+	if p.Contains(pt) {
+		return 0
+	}
+	best := math.Inf(1)
+	for _, ring := range p {
+		best = math.Min(best, LineString(ring).DistanceTo(pt))
+	}
+	return best
+}
+
+// Contains reports whether the point lies inside the polygon (on its outer
+// ring and outside every hole). Campus areas are small enough to treat
+// longitude/latitude as planar.
+func (p Polygon) Contains(pt Point) bool {
+	if len(p) == 0 || !ringContains(p[0], pt) {
+		return false
+	}
+	for _, hole := range p[1:] {
+		if ringContains(hole, pt) {
+			return false
+		}
+	}
+	return true
+}
+
+// ContainsLine reports whether the whole path stays inside the polygon:
+// every vertex is inside and no segment crosses the boundary.
+func (p Polygon) ContainsLine(l LineString) bool {
+	for _, pt := range l {
+		if !p.Contains(pt) {
+			return false
+		}
+	}
+	for i := 1; i < len(l); i++ {
+		for _, ring := range p {
+			for j := 1; j < len(ring); j++ {
+				if segmentsCross(l[i-1], l[i], ring[j-1], ring[j]) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// ringContains is the even-odd ray casting test
+func ringContains(ring []Point, pt Point) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Lat > pt.Lat) != (b.Lat > pt.Lat) &&
+			pt.Lng < (b.Lng-a.Lng)*(pt.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lng {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func orientation(a, b, c Point) float64 {
+	return (b.Lng-a.Lng)*(c.Lat-a.Lat) - (b.Lat-a.Lat)*(c.Lng-a.Lng)
+}
+
+// segmentsCross reports whether segments ab and cd properly intersect
+// (touching at an endpoint does not count)
+func segmentsCross(a, b, c, d Point) bool {
+	d1 := orientation(c, d, a)
+	d2 := orientation(c, d, b)
+	d3 := orientation(a, b, c)
+	d4 := orientation(a, b, d)
+	return ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) &&
+		((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0))
+}
+
+// MultiPointWKT renders points as a WKT multipoint
+func MultiPointWKT(points []Point) string {
+	parts := make([]string, len(points))
+	for i, p := range points {
+		parts[i] = "(" + p.coords() + ")"
+	}
+	return "MULTIPOINT(" + strings.Join(parts, ", ") + ")"
+}
@@ -0,0 +1,357 @@
+package geo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func codeOf(err error) string {
+	var gerr *Error
+	if errors.As(err, &gerr) {
+		return gerr.Code
+	}
+	return ""
+}
+
+func TestParsePoint(t *testing.T) {
+	cases := []struct {
+		in   string
+		want Point
+	}{
+		{"POINT(77.5946 12.9716)", Point{77.5946, 12.9716}},
+		{"  point ( 77.5946   12.9716 ) ", Point{77.5946, 12.9716}},
+		{"SRID=4326;POINT(77.5946 12.9716)", Point{77.5946, 12.9716}},
+		{`{"type":"Point","coordinates":[77.5946,12.9716]}`, Point{77.5946, 12.9716}},
+		{`{"type":"Feature","geometry":{"type":"Point","coordinates":[77.5946,12.9716,920]}}`, Point{77.5946, 12.9716}},
+	}
+	for _, tc := range cases {
+		got, err := ParsePoint(tc.in)
+		if err != nil {
+			t.Errorf("ParsePoint(%q): %v", tc.in, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("ParsePoint(%q) = %v, want %v", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestParseRejectsBadInput(t *testing.T) {
+	cases := []struct {
+		in   string
+		code string
+	}{
+		{"", CodeInvalidGeometry},
+		{"POINT(77.5946)", CodeInvalidGeometry},
+		{"POINT(abc 12)", CodeInvalidGeometry},
+		{"POINT(77.5946 12.9716", CodeInvalidGeometry},
+		{"POINT Z (1 2 3)", CodeInvalidGeometry},
+		{"SRID=3857;POINT(1 2)", CodeInvalidGeometry},
+		{"LINESTRING(1 2, 3 4)", CodeWrongType},
+		{"CIRCLE(1 2)", CodeWrongType},
+		{"POINT(200 12)", CodeOutOfRange},
+		{"POINT(77 -91)", CodeOutOfRange},
+		{`{"type":"Point","coordinates":[77]}`, CodeInvalidGeometry},
+		{`{"type":"Point","coordinates":"x"}`, CodeInvalidGeometry},
+		{`{"type":"Point"`, CodeInvalidGeometry},
+		{`{"type":"MultiPoint","coordinates":[[1,2]]}`, CodeWrongType},
+	}
+	for _, tc := range cases {
+		_, err := ParsePoint(tc.in)
+		if got := codeOf(err); got != tc.code {
+			t.Errorf("ParsePoint(%q) code = %q (%v), want %q", tc.in, got, err, tc.code)
+		}
+	}
+}
+
+func TestParseLineString(t *testing.T) {
+	l, err := ParseLineString("LINESTRING(77.5940 12.9710, 77.5950 12.9720)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 2 || l[1] != (Point{77.5950, 12.9720}) {
+		t.Fatalf("unexpected line %v", l)
+	}
+	if l.WKT() != "LINESTRING(77.594 12.971, 77.595 12.972)" {
+		t.Fatalf("unexpected WKT %s", l.WKT())
+	}
+
+	if _, err := ParseLineString("LINESTRING(77.5940 12.9710)"); codeOf(err) != CodeTooFewVertices {
+		t.Errorf("single-position line: %v", err)
+	}
+	if _, err := ParseLineString(`{"type":"LineString","coordinates":[[1,2],[3,4]]}`); err != nil {
+		t.Errorf("GeoJSON line: %v", err)
+	}
+}
+
+func TestParsePolygon(t *testing.T) {
+	p, err := ParsePolygon("POLYGON((0 0, 10 0, 10 10, 0 10, 0 0), (4 4, 6 4, 6 6, 4 6, 4 4))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p) != 2 {
+		t.Fatalf("expected 2 rings, got %d", len(p))
+	}
+	if _, err := ParsePolygon("POLYGON((0 0, 10 0, 10 10, 0 10))"); codeOf(err) != CodeInvalidGeometry {
+		t.Errorf("open ring: %v", err)
+	}
+	if _, err := ParsePolygon("POLYGON((0 0, 10 0, 0 0))"); codeOf(err) != CodeTooFewVertices {
+		t.Errorf("degenerate ring: %v", err)
+	}
+	if _, err := ParsePolygon(`{"type":"Polygon","coordinates":[[[0,0],[10,0],[10,10],[0,0]]]}`); err != nil {
+		t.Errorf("GeoJSON polygon: %v", err)
+	}
+}
+
+func TestPolygonContains(t *testing.T) {
+	// A U-shaped area: the notch between x=4 and x=6 above y=4 is outside
+	u, err := ParsePolygon("POLYGON((0 0, 10 0, 10 10, 6 10, 6 4, 4 4, 4 10, 0 10, 0 0))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !u.Contains(Point{2, 8}) || !u.Contains(Point{8, 8}) {
+		t.Error("arms of the U should be inside")
+	}
+	if u.Contains(Point{5, 8}) || u.Contains(Point{20, 5}) {
+		t.Error("notch and far points should be outside")
+	}
+	if !u.ContainsLine(LineString{{2, 8}, {2, 2}, {8, 2}, {8, 8}}) {
+		t.Error("path around the notch should be inside")
+	}
+	if u.ContainsLine(LineString{{2, 8}, {8, 8}}) {
+		t.Error("path across the notch leaves the area")
+	}
+
+	holed, _ := ParsePolygon("POLYGON((0 0, 10 0, 10 10, 0 10, 0 0), (4 4, 6 4, 6 6, 4 6, 4 4))")
+	if holed.Contains(Point{5, 5}) {
+		t.Error("point in a hole should be outside")
+	}
+}
+
+func TestValidateRoute(t *testing.T) {
+	short := LineString{{77.5940, 12.9710}, {77.5950, 12.9720}}
+	if err := ValidateRoute(short); err != nil {
+		t.Errorf("short route rejected: %v", err)
+	}
+
+	// Bangalore to Chennai is roughly 290km
+	long := LineString{{77.5946, 12.9716}, {80.2707, 13.0827}}
+	if err := ValidateRoute(long); codeOf(err) != CodeRouteTooLong {
+		t.Errorf("long route: %v", err)
+	}
+
+	many := make(LineString, MaxRouteVertices+1)
+	for i := range many {
+		many[i] = Point{77.5940, 12.9710}
+	}
+	if err := ValidateRoute(many); codeOf(err) != CodeTooManyVertices {
+		t.Errorf("dense route: %v", err)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	// One degree of latitude is about 111.2km
+	d := Distance(Point{0, 0}, Point{0, 1})
+	if math.Abs(d-111195) > 100 {
+		t.Fatalf("Distance = %.0f, want ~111195", d)
+	}
+}
+
+func TestDistanceTo(t *testing.T) {
+	// An east-west path; a point 0.001° north of its middle is ~111m away
+	path := LineString{{77.5900, 12.9700}, {77.5920, 12.9700}}
+	if d := path.DistanceTo(Point{77.5910, 12.9710}); math.Abs(d-111) > 1 {
+		t.Errorf("beside the path: %.1f, want ~111", d)
+	}
+	// Past the end the distance is to the endpoint
+	if d, want := path.DistanceTo(Point{77.5930, 12.9700}), Distance(Point{77.5920, 12.9700}, Point{77.5930, 12.9700}); math.Abs(d-want) > 1 {
+		t.Errorf("past the end: %.1f, want %.1f", d, want)
+	}
+
+	square, _ := ParsePolygon("POLYGON((77.59 12.97, 77.60 12.97, 77.60 12.98, 77.59 12.98, 77.59 12.97))")
+	if d := square.DistanceTo(Point{77.595, 12.975}); d != 0 {
+		t.Errorf("inside the polygon: %.1f", d)
+	}
+	if d := square.DistanceTo(Point{77.595, 12.981}); math.Abs(d-111) > 1 {
+		t.Errorf("north of the polygon: %.1f, want ~111", d)
+	}
+}
+
+func TestRawGeometryAcceptsStringsAndObjects(t *testing.T) {
+	var body struct {
+		A RawGeometry `json:"a"`
+		B RawGeometry `json:"b"`
+		C RawGeometry `json:"c"`
+	}
+	in := `{"a":"POINT(1 2)","b":{"type": "Point", "coordinates": [1, 2]},"c":null}`
+	if err := json.Unmarshal([]byte(in), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.A != "POINT(1 2)" || body.B != `{"type":"Point","coordinates":[1,2]}` || body.C != "" {
+		t.Fatalf("unexpected %+v", body)
+	}
+	for _, raw := range []RawGeometry{body.A, body.B} {
+		if p, err := ParsePoint(string(raw)); err != nil || p != (Point{1, 2}) {
+			t.Errorf("ParsePoint(%q) = %v, %v", raw, p, err)
+		}
+	}
+}
+
+func TestGeoJSONRoundTrip(t *testing.T) {
+	line := LineString{{77.594, 12.971}, {77.595, 12.972}}
+	fc := NewFeatureCollection([]Feature{NewFeature("plan-1", line.Geometry(), map[string]interface{}{"mode": "walk"})})
+	data, err := json.Marshal(fc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"FeatureCollection","features":[{"type":"Feature","id":"plan-1","geometry":{"type":"LineString","coordinates":[[77.594,12.971],[77.595,12.972]]},"properties":{"mode":"walk"}}]}`
+	if string(data) != want {
+		t.Fatalf("got %s", data)
+	}
+
+	geometry, _ := json.Marshal(line.Geometry())
+	parsed, err := ParseLineString(string(geometry))
+	if err != nil || len(parsed) != 2 || parsed[1] != line[1] {
+		t.Fatalf("round trip = %v, %v", parsed, err)
+	}
+}
+
+func TestPolyline(t *testing.T) {
+	// The example from Google's format documentation
+	l, err := ParsePolyline("_p~iF~ps|U_ulLnnqC_mqNvxq`@", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := LineString{{-120.2, 38.5}, {-120.95, 40.7}, {-126.453, 43.252}}
+	if len(l) != len(want) {
+		t.Fatalf("got %v", l)
+	}
+	for i := range want {
+		if math.Abs(l[i].Lng-want[i].Lng) > 1e-9 || math.Abs(l[i].Lat-want[i].Lat) > 1e-9 {
+			t.Fatalf("position %d = %v, want %v", i, l[i], want[i])
+		}
+	}
+	if got := EncodePolyline(want, 5); got != "_p~iF~ps|U_ulLnnqC_mqNvxq`@" {
+		t.Fatalf("EncodePolyline = %q", got)
+	}
+
+	campus := LineString{{77.594123, 12.971456}, {77.595987, 12.972001}}
+	if back, err := ParsePolyline(EncodePolyline(campus, 6), 6); err != nil || back[1] != campus[1] {
+		t.Fatalf("polyline6 round trip = %v, %v", back, err)
+	}
+
+	for _, bad := range []string{"", "_p~iF", "_p~iF~ps|U", "_p~iF~ps|U \x01"} {
+		if _, err := ParsePolyline(bad, 5); err == nil {
+			t.Errorf("ParsePolyline(%q) should fail", bad)
+		}
+	}
+}
+
+func TestSimplify(t *testing.T) {
+	// East along a street with GPS jitter of about a meter, then a turn north
+	track := LineString{
+		{77.5900, 12.9700}, {77.5905, 12.97001}, {77.5905, 12.97001}, {77.5910, 12.96999},
+		{77.5915, 12.97000}, {77.5920, 12.9700}, {77.5920, 12.9710}, {77.5920, 12.9720},
+	}
+	got := Simplify(track, 5)
+	want := LineString{{77.5900, 12.9700}, {77.5920, 12.9700}, {77.5920, 12.9720}}
+	if len(got) != len(want) {
+		t.Fatalf("Simplify = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Simplify = %v, want %v", got, want)
+		}
+	}
+
+	if got := Simplify(track, 0); len(got) != len(track)-1 {
+		t.Fatalf("zero tolerance should only drop the repeated position, got %d positions", len(got))
+	}
+}
+
+func TestGPX(t *testing.T) {
+	in := `<?xml version="1.0"?>
+<gpx version="1.1" creator="Strava" xmlns="http://www.topografix.com/GPX/1/1">
+  <trk><name>Morning Ride</name>
+    <trkseg><trkpt lat="12.9700" lon="77.5900"><ele>920</ele></trkpt><trkpt lat="12.9705" lon="77.5905"/></trkseg>
+    <trkseg><trkpt lat="12.9710" lon="77.5910"/></trkseg>
+  </trk>
+</gpx>`
+	l, err := ParseGPX(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l) != 3 || l[2] != (Point{77.5910, 12.9710}) {
+		t.Fatalf("unexpected track %v", l)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteGPX(&buf, "Hostel to Library", time.Time{}, l); err != nil {
+		t.Fatal(err)
+	}
+	back, err := ParseGPX(&buf)
+	if err != nil || len(back) != 3 || back[1] != l[1] {
+		t.Fatalf("GPX round trip = %v, %v", back, err)
+	}
+
+	for _, bad := range []string{"not xml", `<gpx><wpt lat="1" lon="2"/></gpx>`, `<gpx><rte><rtept lat="95" lon="2"/><rtept lat="1" lon="2"/></rte></gpx>`} {
+		if _, err := ParseGPX(strings.NewReader(bad)); err == nil {
+			t.Errorf("ParseGPX(%q) should fail", bad)
+		}
+	}
+}
+
+func TestGeohash(t *testing.T) {
+	// Reference value from the original geohash.org announcement
+	b, err := DecodeGeohash("u4pruydqqvj")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jutland := Point{Lng: 10.40744, Lat: 57.64911}
+	if !b.Contains(jutland) || b.MaxLat-b.MinLat > 1e-5 {
+		t.Fatalf("cell %+v does not pin down %v", b, jutland)
+	}
+	if got := EncodeGeohash(jutland, 11); got != "u4pruydqqvj" {
+		t.Fatalf("EncodeGeohash = %s", got)
+	}
+
+	library := Point{Lng: 77.5946, Lat: 12.9716}
+	cell, _ := DecodeGeohash(EncodeGeohash(library, 7))
+	if !cell.Contains(library) {
+		t.Fatalf("cell %+v should contain %v", cell, library)
+	}
+
+	for _, bad := range []string{"", "u4pa", "u4pruydqqvjxx"} {
+		if _, err := DecodeGeohash(bad); codeOf(err) != CodeInvalidGeometry {
+			t.Errorf("DecodeGeohash(%q) = %v", bad, err)
+		}
+	}
+}
+
+func TestParseBBox(t *testing.T) {
+	b, err := ParseBBox("77.59, 12.97,77.60,12.98")
+	if err != nil || b != (BBox{MinLng: 77.59, MinLat: 12.97, MaxLng: 77.60, MaxLat: 12.98}) {
+		t.Fatalf("ParseBBox = %+v, %v", b, err)
+	}
+	for bad, code := range map[string]string{
+		"77.59,12.97,77.60":       CodeInvalidGeometry,
+		"77.60,12.97,77.59,12.98": CodeInvalidGeometry,
+		"77.59,12.97,77.60,95":    CodeOutOfRange,
+	} {
+		if _, err := ParseBBox(bad); codeOf(err) != code {
+			t.Errorf("ParseBBox(%q) = %v, want %s", bad, err, code)
+		}
+	}
+}
+
+func TestPolygonBounds(t *testing.T) {
+	area := Polygon{{{77.59, 12.97}, {77.61, 12.965}, {77.60, 12.98}, {77.59, 12.97}}}
+	if b := area.Bounds(); b != (BBox{MinLng: 77.59, MinLat: 12.965, MaxLng: 77.61, MaxLat: 12.98}) {
+		t.Fatalf("Bounds = %+v", b)
+	}
+}
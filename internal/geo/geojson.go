@@ -0,0 +1,63 @@
+package geo
+
+// Geometry is a GeoJSON geometry object
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// Feature is a GeoJSON feature. Geometry may be a Geometry, raw GeoJSON
+// from PostGIS, or nil.
+type Feature struct {
+	Type       string                 `json:"type"`
+	ID         string                 `json:"id,omitempty"`
+	Geometry   interface{}            `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// FeatureCollection is a GeoJSON feature collection
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+func NewFeature(id string, geometry interface{}, properties map[string]interface{}) Feature {
+	if properties == nil {
+		properties = map[string]interface{}{}
+	}
+	return Feature{Type: "Feature", ID: id, Geometry: geometry, Properties: properties}
+}
+
+func NewFeatureCollection(features []Feature) FeatureCollection {
+	if features == nil {
+		features = []Feature{}
+	}
+	return FeatureCollection{Type: "FeatureCollection", Features: features}
+}
+
+func positions(points []Point) [][2]float64 {
+	coords := make([][2]float64, len(points))
+	for i, p := range points {
+		coords[i] = [2]float64{p.Lng, p.Lat}
+	}
+	return coords
+}
+
+// Geometry returns the point as a GeoJSON geometry
+func (p Point) Geometry() Geometry {
+	return Geometry{Type: "Point", Coordinates: [2]float64{p.Lng, p.Lat}}
+}
+
+// Geometry returns the line as a GeoJSON geometry
+func (l LineString) Geometry() Geometry {
+	return Geometry{Type: "LineString", Coordinates: positions(l)}
+}
+
+// Geometry returns the polygon as a GeoJSON geometry
+func (p Polygon) Geometry() Geometry {
+	rings := make([][][2]float64, len(p))
+	for i, ring := range p {
+		rings[i] = positions(ring)
+	}
+	return Geometry{Type: "Polygon", Coordinates: rings}
+}
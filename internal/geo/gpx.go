@@ -0,0 +1,103 @@
+package geo
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// MaxTrackPoints bounds how many positions an uploaded track may have
+// before simplification
+var MaxTrackPoints = 100000
+
+type gpxPoint struct {
+	Lat float64 `xml:"lat,attr"`
+	Lon float64 `xml:"lon,attr"`
+}
+
+// ParseGPX reads the path from a GPX file: the segments of its first track
+// joined in order, or else its first route
+func ParseGPX(r io.Reader) (LineString, error) {
+	var doc struct {
+		XMLName xml.Name `xml:"gpx"`
+		Tracks  []struct {
+			Segments []struct {
+				Points []gpxPoint `xml:"trkpt"`
+			} `xml:"trkseg"`
+		} `xml:"trk"`
+		Routes []struct {
+			Points []gpxPoint `xml:"rtept"`
+		} `xml:"rte"`
+	}
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, newError(CodeInvalidGeometry, "invalid GPX: "+err.Error())
+	}
+
+	var points []gpxPoint
+	if len(doc.Tracks) > 0 {
+		for _, seg := range doc.Tracks[0].Segments {
+			points = append(points, seg.Points...)
+		}
+	} else if len(doc.Routes) > 0 {
+		points = doc.Routes[0].Points
+	}
+	if len(points) > MaxTrackPoints {
+		return nil, newError(CodeTooManyVertices, fmt.Sprintf("tracks may have at most %d points, got %d", MaxTrackPoints, len(points)))
+	}
+
+	line := make(LineString, len(points))
+	for i, pt := range points {
+		line[i] = Point{Lng: pt.Lon, Lat: pt.Lat}
+		if err := checkRange(line[i]); err != nil {
+			return nil, err
+		}
+	}
+	if len(line) < 2 {
+		return nil, newError(CodeTooFewVertices, "the GPX file needs a track or route with at least 2 points")
+	}
+	return line, nil
+}
+
+// WriteGPX writes the line as a single-track GPX 1.1 document
+func WriteGPX(w io.Writer, name string, start time.Time, l LineString) error {
+	type trkpt struct {
+		Lat float64 `xml:"lat,attr"`
+		Lon float64 `xml:"lon,attr"`
+	}
+	doc := struct {
+		XMLName  xml.Name `xml:"gpx"`
+		Version  string   `xml:"version,attr"`
+		Creator  string   `xml:"creator,attr"`
+		Xmlns    string   `xml:"xmlns,attr"`
+		Metadata struct {
+			Name string `xml:"name"`
+			Time string `xml:"time,omitempty"`
+		} `xml:"metadata"`
+		Track struct {
+			Name   string  `xml:"name"`
+			Points []trkpt `xml:"trkseg>trkpt"`
+		} `xml:"trk"`
+	}{Version: "1.1", Creator: "CampusLoop", Xmlns: "http://www.topografix.com/GPX/1/1"}
+
+	doc.Metadata.Name = name
+	if !start.IsZero() {
+		doc.Metadata.Time = start.UTC().Format(time.RFC3339)
+	}
+	doc.Track.Name = name
+	doc.Track.Points = make([]trkpt, len(l))
+	for i, p := range l {
+		doc.Track.Points[i] = trkpt{Lat: p.Lat, Lon: p.Lng}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,325 @@
+package geo
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// RawGeometry is a geometry field in a request body. It accepts a WKT string,
+// a GeoJSON object, or a GeoJSON object encoded as a string.
+type RawGeometry string
+
+func (g *RawGeometry) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		*g = ""
+	case len(data) > 0 && data[0] == '"':
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*g = RawGeometry(s)
+	default:
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, data); err != nil {
+			return err
+		}
+		*g = RawGeometry(compact.String())
+	}
+	return nil
+}
+
+// shape is a parsed geometry before it is narrowed to a concrete type.
+// Points use rings[0][0], lines rings[0] and polygons every ring.
+type shape struct {
+	kind  string
+	rings [][]Point
+}
+
+// ParsePoint parses a WKT or GeoJSON point
+func ParsePoint(s string) (Point, error) {
+	sh, err := parse(s, "Point")
+	if err != nil {
+		return Point{}, err
+	}
+	return sh.rings[0][0], nil
+}
+
+// ParseLineString parses a WKT or GeoJSON line with at least two positions
+func ParseLineString(s string) (LineString, error) {
+	sh, err := parse(s, "LineString")
+	if err != nil {
+		return nil, err
+	}
+	if len(sh.rings[0]) < 2 {
+		return nil, newError(CodeTooFewVertices, "a line needs at least 2 positions")
+	}
+	return LineString(sh.rings[0]), nil
+}
+
+// ParsePolygon parses a WKT or GeoJSON polygon whose rings are closed and
+// have at least four positions
+func ParsePolygon(s string) (Polygon, error) {
+	sh, err := parse(s, "Polygon")
+	if err != nil {
+		return nil, err
+	}
+	for _, ring := range sh.rings {
+		if len(ring) < 4 {
+			return nil, newError(CodeTooFewVertices, "polygon rings need at least 4 positions")
+		}
+		if ring[0] != ring[len(ring)-1] {
+			return nil, newError(CodeInvalidGeometry, "polygon rings must be closed")
+		}
+	}
+	return Polygon(sh.rings), nil
+}
+
+func parse(s, want string) (shape, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return shape{}, newError(CodeInvalidGeometry, "geometry is required")
+	}
+
+	var sh shape
+	var err error
+	if s[0] == '{' {
+		sh, err = parseGeoJSON([]byte(s))
+	} else {
+		sh, err = parseWKT(s)
+	}
+	if err != nil {
+		return shape{}, err
+	}
+	if sh.kind != want {
+		return shape{}, newError(CodeWrongType, "expected a "+want+", got a "+sh.kind)
+	}
+	for _, ring := range sh.rings {
+		for _, p := range ring {
+			if err := checkRange(p); err != nil {
+				return shape{}, err
+			}
+		}
+	}
+	return sh, nil
+}
+
+func checkRange(p Point) error {
+	if math.IsNaN(p.Lng) || math.IsNaN(p.Lat) || math.IsInf(p.Lng, 0) || math.IsInf(p.Lat, 0) {
+		return newError(CodeInvalidGeometry, "coordinates must be finite numbers")
+	}
+	if p.Lng < -180 || p.Lng > 180 || p.Lat < -90 || p.Lat > 90 {
+		return newError(CodeOutOfRange, "coordinates must be longitude [-180, 180] and latitude [-90, 90]: "+p.coords())
+	}
+	return nil
+}
+
+var wktKinds = map[string]string{
+	"POINT":      "Point",
+	"LINESTRING": "LineString",
+	"POLYGON":    "Polygon",
+}
+
+func parseWKT(s string) (shape, error) {
+	// EWKT prefix, as printed by PostGIS
+	if strings.HasPrefix(strings.ToUpper(s), "SRID=") {
+		semi := strings.IndexByte(s, ';')
+		if semi < 0 || strings.TrimSpace(s[5:semi]) != "4326" {
+			return shape{}, newError(CodeInvalidGeometry, "only SRID 4326 is supported")
+		}
+		s = strings.TrimSpace(s[semi+1:])
+	}
+
+	open := strings.IndexByte(s, '(')
+	if open < 0 || !strings.HasSuffix(s, ")") {
+		return shape{}, newError(CodeInvalidGeometry, "malformed WKT")
+	}
+	name := strings.ToUpper(strings.TrimSpace(s[:open]))
+	kind, ok := wktKinds[name]
+	if !ok {
+		if fields := strings.Fields(name); len(fields) == 2 {
+			return shape{}, newError(CodeInvalidGeometry, "only 2D coordinates are supported")
+		}
+		return shape{}, newError(CodeWrongType, "unsupported WKT geometry: "+name)
+	}
+	body := s[open+1 : len(s)-1]
+
+	switch kind {
+	case "Point":
+		p, err := parsePosition(body)
+		if err != nil {
+			return shape{}, err
+		}
+		return shape{kind: kind, rings: [][]Point{{p}}}, nil
+	case "LineString":
+		line, err := parsePositions(body)
+		if err != nil {
+			return shape{}, err
+		}
+		return shape{kind: kind, rings: [][]Point{line}}, nil
+	default:
+		rings, err := parseRings(body)
+		if err != nil {
+			return shape{}, err
+		}
+		return shape{kind: kind, rings: rings}, nil
+	}
+}
+
+func parsePosition(s string) (Point, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return Point{}, newError(CodeInvalidGeometry, "positions must be \"lng lat\": "+strings.TrimSpace(s))
+	}
+	lng, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return Point{}, newError(CodeInvalidGeometry, "invalid longitude: "+fields[0])
+	}
+	lat, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return Point{}, newError(CodeInvalidGeometry, "invalid latitude: "+fields[1])
+	}
+	return Point{Lng: lng, Lat: lat}, nil
+}
+
+func parsePositions(s string) ([]Point, error) {
+	if strings.ContainsAny(s, "()") {
+		return nil, newError(CodeInvalidGeometry, "malformed WKT")
+	}
+	parts := strings.Split(s, ",")
+	points := make([]Point, 0, len(parts))
+	for _, part := range parts {
+		p, err := parsePosition(part)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
+
+// parseRings parses "(x y, ...), (x y, ...)"
+func parseRings(s string) ([][]Point, error) {
+	var rings [][]Point
+	rest := strings.TrimSpace(s)
+	for rest != "" {
+		if rest[0] != '(' {
+			return nil, newError(CodeInvalidGeometry, "malformed WKT")
+		}
+		end := strings.IndexByte(rest, ')')
+		if end < 0 {
+			return nil, newError(CodeInvalidGeometry, "malformed WKT")
+		}
+		ring, err := parsePositions(rest[1:end])
+		if err != nil {
+			return nil, err
+		}
+		rings = append(rings, ring)
+
+		rest = strings.TrimSpace(rest[end+1:])
+		if rest == "" {
+			break
+		}
+		if rest[0] != ',' {
+			return nil, newError(CodeInvalidGeometry, "malformed WKT")
+		}
+		rest = strings.TrimSpace(rest[1:])
+		if rest == "" {
+			return nil, newError(CodeInvalidGeometry, "malformed WKT")
+		}
+	}
+	if len(rings) == 0 {
+		return nil, newError(CodeInvalidGeometry, "polygon has no rings")
+	}
+	return rings, nil
+}
+
+type geoJSONObject struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Geometry    json.RawMessage `json:"geometry"`
+}
+
+func parseGeoJSON(data []byte) (shape, error) {
+	var obj geoJSONObject
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return shape{}, newError(CodeInvalidGeometry, "malformed GeoJSON: "+err.Error())
+	}
+	if obj.Type == "Feature" {
+		if len(obj.Geometry) == 0 || string(obj.Geometry) == "null" {
+			return shape{}, newError(CodeInvalidGeometry, "feature has no geometry")
+		}
+		return parseGeoJSON(obj.Geometry)
+	}
+
+	malformed := func(err error) (shape, error) {
+		return shape{}, newError(CodeInvalidGeometry, "malformed "+obj.Type+" coordinates: "+err.Error())
+	}
+	switch obj.Type {
+	case "Point":
+		var pos []float64
+		if err := json.Unmarshal(obj.Coordinates, &pos); err != nil {
+			return malformed(err)
+		}
+		p, err := geoJSONPosition(pos)
+		if err != nil {
+			return shape{}, err
+		}
+		return shape{kind: obj.Type, rings: [][]Point{{p}}}, nil
+	case "LineString":
+		var positions [][]float64
+		if err := json.Unmarshal(obj.Coordinates, &positions); err != nil {
+			return malformed(err)
+		}
+		line, err := geoJSONPositions(positions)
+		if err != nil {
+			return shape{}, err
+		}
+		return shape{kind: obj.Type, rings: [][]Point{line}}, nil
+	case "Polygon":
+		var rings [][][]float64
+		if err := json.Unmarshal(obj.Coordinates, &rings); err != nil {
+			return malformed(err)
+		}
+		if len(rings) == 0 {
+			return shape{}, newError(CodeInvalidGeometry, "polygon has no rings")
+		}
+		sh := shape{kind: obj.Type}
+		for _, positions := range rings {
+			ring, err := geoJSONPositions(positions)
+			if err != nil {
+				return shape{}, err
+			}
+			sh.rings = append(sh.rings, ring)
+		}
+		return sh, nil
+	case "":
+		return shape{}, newError(CodeInvalidGeometry, "GeoJSON object has no type")
+	default:
+		return shape{}, newError(CodeWrongType, "unsupported GeoJSON geometry: "+obj.Type)
+	}
+}
+
+// geoJSONPosition reads [lng, lat], ignoring any altitude
+func geoJSONPosition(pos []float64) (Point, error) {
+	if len(pos) < 2 {
+		return Point{}, newError(CodeInvalidGeometry, "positions must be [lng, lat]")
+	}
+	return Point{Lng: pos[0], Lat: pos[1]}, nil
+}
+
+func geoJSONPositions(positions [][]float64) ([]Point, error) {
+	points := make([]Point, 0, len(positions))
+	for _, pos := range positions {
+		p, err := geoJSONPosition(pos)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
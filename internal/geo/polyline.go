@@ -0,0 +1,89 @@
+package geo
+
+import (
+	"math"
+	"strings"
+)
+
+// ParsePolyline decodes a Google encoded polyline. precision is the number
+// of decimal places encoded: 5 for the classic format, 6 for polyline6.
+func ParsePolyline(s string, precision int) (LineString, error) {
+	if precision != 5 && precision != 6 {
+		return nil, newError(CodeInvalidGeometry, "polyline precision must be 5 or 6")
+	}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, newError(CodeInvalidGeometry, "polyline is required")
+	}
+	factor := math.Pow10(precision)
+
+	var line LineString
+	var lat, lng int64
+	for i := 0; i < len(s); {
+		var deltas [2]int64
+		for k := range deltas {
+			var result int64
+			var shift uint
+			for {
+				if i >= len(s) {
+					return nil, newError(CodeInvalidGeometry, "polyline ends in the middle of a position")
+				}
+				b := int64(s[i]) - 63
+				i++
+				if b < 0 || b > 63 || shift > 60 {
+					return nil, newError(CodeInvalidGeometry, "polyline contains an invalid character")
+				}
+				result |= (b & 0x1f) << shift
+				shift += 5
+				if b < 0x20 {
+					break
+				}
+			}
+			if result&1 != 0 {
+				deltas[k] = ^(result >> 1)
+			} else {
+				deltas[k] = result >> 1
+			}
+		}
+		lat += deltas[0]
+		lng += deltas[1]
+
+		p := Point{Lng: float64(lng) / factor, Lat: float64(lat) / factor}
+		if err := checkRange(p); err != nil {
+			return nil, err
+		}
+		line = append(line, p)
+	}
+
+	if len(line) < 2 {
+		return nil, newError(CodeTooFewVertices, "a line needs at least 2 positions")
+	}
+	return line, nil
+}
+
+// EncodePolyline encodes the line as a Google encoded polyline
+func EncodePolyline(l LineString, precision int) string {
+	factor := math.Pow10(precision)
+	var b strings.Builder
+	var prevLat, prevLng int64
+	for _, p := range l {
+		lat := int64(math.Round(p.Lat * factor))
+		lng := int64(math.Round(p.Lng * factor))
+		encodeSigned(&b, lat-prevLat)
+		encodeSigned(&b, lng-prevLng)
+		prevLat, prevLng = lat, lng
+	}
+	return b.String()
+}
+
+func encodeSigned(b *strings.Builder, v int64) {
+	u := v << 1
+	if v < 0 {
+		u = ^u
+	}
+	for u >= 0x20 {
+		b.WriteByte(byte((0x20 | (u & 0x1f)) + 63))
+		u >>= 5
+	}
+	b.WriteByte(byte(u + 63))
+}
@@ -0,0 +1,71 @@
+package geo
+
+import "math"
+
+// DefaultSimplifyTolerance is how far (meters) an uploaded track may be moved
+// when it is simplified before storage
+var DefaultSimplifyTolerance = 5.0
+
+// Simplify reduces a line with the Douglas–Peucker algorithm, dropping
+// positions that lie within tolerance meters of the simplified path. The
+// endpoints are always kept; a tolerance of 0 only removes repeated positions.
+func Simplify(l LineString, tolerance float64) LineString {
+	// Repeated positions carry no shape and confuse the distance test
+	deduped := make(LineString, 0, len(l))
+	for _, p := range l {
+		if len(deduped) == 0 || deduped[len(deduped)-1] != p {
+			deduped = append(deduped, p)
+		}
+	}
+	if len(deduped) < 3 || tolerance <= 0 {
+		return deduped
+	}
+
+	// Project onto a local plane in meters; campus-scale tracks are small
+	// enough for an equirectangular projection
+	origin := deduped[0]
+	scale := earthRadiusM * math.Pi / 180
+	cosLat := math.Cos(origin.Lat * math.Pi / 180)
+	xy := make([][2]float64, len(deduped))
+	for i, p := range deduped {
+		xy[i] = [2]float64{(p.Lng - origin.Lng) * scale * cosLat, (p.Lat - origin.Lat) * scale}
+	}
+
+	keep := make([]bool, len(deduped))
+	keep[0], keep[len(deduped)-1] = true, true
+	stack := [][2]int{{0, len(deduped) - 1}}
+	for len(stack) > 0 {
+		span := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		first, last := span[0], span[1]
+
+		farthest, maxDist := -1, tolerance
+		for i := first + 1; i < last; i++ {
+			if d := segmentDistance(xy[i], xy[first], xy[last]); d > maxDist {
+				farthest, maxDist = i, d
+			}
+		}
+		if farthest >= 0 {
+			keep[farthest] = true
+			stack = append(stack, [2]int{first, farthest}, [2]int{farthest, last})
+		}
+	}
+
+	simplified := make(LineString, 0, len(deduped))
+	for i, p := range deduped {
+		if keep[i] {
+			simplified = append(simplified, p)
+		}
+	}
+	return simplified
+}
+
+// segmentDistance is the distance from p to the segment ab on the plane
+func segmentDistance(p, a, b [2]float64) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	t := 0.0
+	if lengthSq := dx*dx + dy*dy; lengthSq > 0 {
+		t = math.Max(0, math.Min(1, ((p[0]-a[0])*dx+(p[1]-a[1])*dy)/lengthSq))
+	}
+	return math.Hypot(p[0]-(a[0]+t*dx), p[1]-(a[1]+t*dy))
+}
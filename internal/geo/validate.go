@@ -0,0 +1,79 @@
+package geo
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Stable codes reported for rejected geometries
+const (
+	CodeInvalidGeometry = "invalid_geometry"
+	CodeWrongType       = "wrong_geometry_type"
+	CodeOutOfRange      = "coordinate_out_of_range"
+	CodeTooFewVertices  = "too_few_vertices"
+	CodeTooManyVertices = "too_many_vertices"
+	CodeRouteTooLong    = "route_too_long"
+	CodeOutsideCampus   = "outside_campus"
+)
+
+// Limits for submitted routes
+var (
+	MaxRouteLength   = 25000.0 // meters
+	MaxRouteVertices = 2000
+)
+
+// Error describes why a geometry was rejected
+type Error struct {
+	Field   string `json:"field,omitempty"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	if e.Field != "" {
+		return e.Field + ": " + e.Message
+	}
+	return e.Message
+}
+
+func newError(code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// FieldError attributes err to a request field
+func FieldError(field string, err error) *Error {
+	var gerr *Error
+	if errors.As(err, &gerr) {
+		copied := *gerr
+		copied.Field = field
+		return &copied
+	}
+	return &Error{Field: field, Code: CodeInvalidGeometry, Message: err.Error()}
+}
+
+// ValidateRoute enforces the vertex and length limits on a route
+func ValidateRoute(l LineString) error {
+	if len(l) > MaxRouteVertices {
+		return newError(CodeTooManyVertices, fmt.Sprintf("routes may have at most %d positions, got %d", MaxRouteVertices, len(l)))
+	}
+	if length := l.Length(); length > MaxRouteLength {
+		return newError(CodeRouteTooLong, fmt.Sprintf("routes may be at most %.0fm long, got %.0fm", MaxRouteLength, length))
+	}
+	return nil
+}
+
+// CheckPointInside rejects points outside the service area
+func CheckPointInside(area Polygon, p Point) error {
+	if !area.Contains(p) {
+		return newError(CodeOutsideCampus, "location is outside the campus service area")
+	}
+	return nil
+}
+
+// CheckLineInside rejects paths that leave the service area
+func CheckLineInside(area Polygon, l LineString) error {
+	if !area.ContainsLine(l) {
+		return newError(CodeOutsideCampus, "route leaves the campus service area")
+	}
+	return nil
+}
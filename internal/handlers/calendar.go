@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Woeter69/hackoverflow/internal/apierror"
+	"github.com/Woeter69/hackoverflow/internal/geo"
+	"github.com/Woeter69/hackoverflow/internal/ical"
+	"github.com/Woeter69/hackoverflow/internal/models"
+	"github.com/Woeter69/hackoverflow/internal/routing"
+	"github.com/Woeter69/hackoverflow/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+const calendarContentType = "text/calendar"
+
+// maxCalendarUpload bounds the size of an uploaded timetable
+const maxCalendarUpload = 2 << 20
+
+// errandEventLength is how long an accepted errand blocks in the feed
+const errandEventLength = 30 * time.Minute
+
+// timetableSlot is one occurrence of a timetable event
+type timetableSlot struct {
+	Summary  string
+	Location string
+	Start    time.Time
+	End      time.Time
+	Place    *models.Place
+}
+
+type TimetableStop struct {
+	Summary   string `json:"summary"`
+	Location  string `json:"location"`
+	PlaceID   string `json:"place_id"`
+	PlaceName string `json:"place_name"`
+}
+
+// TravelSuggestion is a trip between two consecutive lectures. Plan can be
+// posted as is to /travel-plans.
+type TravelSuggestion struct {
+	From      TimetableStop `json:"from"`
+	To        TimetableStop `json:"to"`
+	DepartAt  time.Time     `json:"depart_at"`
+	ArriveBy  time.Time     `json:"arrive_by"`
+	DistanceM float64       `json:"distance_m"`
+	DurationS int           `json:"duration_s"`
+	Routed    bool          `json:"routed"` // false when estimated from the straight-line distance
+	Fits      bool          `json:"fits"`   // the trip can be made between the lectures
+	Plan      gin.H         `json:"plan"`
+}
+
+func stopOf(s timetableSlot) TimetableStop {
+	return TimetableStop{Summary: s.Summary, Location: s.Location, PlaceID: s.Place.ID, PlaceName: s.Place.Name}
+}
+
+// matchPlace finds the campus place a timetable location refers to ("Room
+// 204, Main Library" is the Main Library), or nil when there is none
+func (h *Handler) matchPlace(ctx context.Context, campusID, location string) (*models.Place, error) {
+	p, err := h.store.Places.Match(ctx, campusID, location)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// readCalendar reads an uploaded .ics file, sent as the raw body or as the
+// "ics" field of a multipart form
+func readCalendar(c *gin.Context) ([]byte, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxCalendarUpload)
+	if c.ContentType() == gin.MIMEMultipartPOSTForm {
+		return uploadedFile(c, "ics")
+	}
+	return io.ReadAll(c.Request.Body)
+}
+
+// ImportTimetable reads a class timetable (.ics) and suggests travel plans
+// between the buildings of consecutive lectures on the same day. Nothing is
+// stored; the caller posts the suggestions it wants to keep.
+//
+// Query parameters: from (YYYY-MM-DD, default today), days (1-28, default 7),
+// timezone (IANA zone for floating times and days, default UTC), max_gap
+// (minutes between lectures, default 120) and mode.
+func (h *Handler) ImportTimetable(c *gin.Context) {
+	campusID, ok := callerCampus(c)
+	if !ok {
+		return
+	}
+	_, mode, ok := parseTravelMode(c, c.Query("mode"))
+	if !ok {
+		return
+	}
+	loc, err := time.LoadLocation(c.DefaultQuery("timezone", "UTC"))
+	if err != nil || loc.String() == "Local" {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "timezone must be an IANA zone such as Asia/Kolkata")
+		return
+	}
+	from := time.Now().In(loc)
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	if v := c.Query("from"); v != "" {
+		if from, err = time.ParseInLocation("2006-01-02", v, loc); err != nil {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "from must be a YYYY-MM-DD date")
+			return
+		}
+	}
+	days, err := strconv.Atoi(c.DefaultQuery("days", "7"))
+	if err != nil || days < 1 || days > 28 {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "days must be between 1 and 28")
+		return
+	}
+	maxGap, err := strconv.Atoi(c.DefaultQuery("max_gap", "120"))
+	if err != nil || maxGap < 1 || maxGap > 720 {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "max_gap must be between 1 and 720 minutes")
+		return
+	}
+
+	body, err := readCalendar(c)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	if len(body) == 0 {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Upload an .ics file as the body or the ics form field")
+		return
+	}
+	events, err := ical.Parse(bytes.NewReader(body), loc)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	ctx := c.Request.Context()
+	campus, err := h.store.Campuses.Get(ctx, campusID)
+	if err != nil {
+		apierror.Internal(c, "ImportTimetable Campus Error", err, "Failed to load campus")
+		return
+	}
+
+	// Expand the timetable over the window and find each lecture's building
+	to := from.AddDate(0, 0, days)
+	places := map[string]*models.Place{}
+	unresolved := []string{}
+	var slots []timetableSlot
+	for _, e := range events {
+		if e.AllDay || e.Location == "" {
+			continue
+		}
+		starts, err := e.Occurrences(from, to)
+		if err != nil {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, fmt.Sprintf("event %q: %v", e.Summary, err))
+			return
+		}
+		if len(starts) == 0 {
+			continue
+		}
+
+		place, seen := places[e.Location]
+		if !seen {
+			if place, err = h.matchPlace(ctx, campusID, e.Location); err != nil {
+				apierror.Internal(c, "ImportTimetable DB Error", err, "Failed to look up places")
+				return
+			}
+			places[e.Location] = place
+			if place == nil {
+				unresolved = append(unresolved, e.Location)
+			}
+		}
+		if place == nil {
+			continue
+		}
+		for _, s := range starts {
+			slots = append(slots, timetableSlot{Summary: e.Summary, Location: e.Location, Start: s, End: s.Add(e.End.Sub(e.Start)), Place: place})
+		}
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i].Start.Before(slots[j].Start) })
+
+	suggestions := []TravelSuggestion{}
+	for i := 1; i < len(slots); i++ {
+		prev, next := slots[i-1], slots[i]
+		gap := next.Start.Sub(prev.End)
+		if prev.Place.ID == next.Place.ID || gap < 0 || gap > time.Duration(maxGap)*time.Minute ||
+			prev.End.In(loc).Format("2006-01-02") != next.Start.In(loc).Format("2006-01-02") {
+			continue
+		}
+
+		s := TravelSuggestion{From: stopOf(prev), To: stopOf(next), DepartAt: prev.End, ArriveBy: next.Start}
+		origin, dest := prev.Place.Location.Geo(), next.Place.Location.Geo()
+		if route := h.routeBetween(newGeometryCheck(campus), campusID, origin, dest, mode); route != nil {
+			s.DistanceM, s.Routed = route.Distance, true
+			s.DurationS = int(route.Duration.Seconds())
+		} else {
+			s.DistanceM = geo.Distance(origin, dest)
+			s.DurationS = int(routing.TravelTime(mode, s.DistanceM).Seconds())
+		}
+		s.Fits = time.Duration(s.DurationS)*time.Second <= gap
+		s.Plan = gin.H{
+			"origin_place_id":      prev.Place.ID,
+			"destination_place_id": next.Place.ID,
+			"mode":                 c.DefaultQuery("mode", string(routing.ModeWalk)),
+			"start_time":           prev.End,
+		}
+		suggestions = append(suggestions, s)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events":               len(slots),
+		"suggestions":          suggestions,
+		"unresolved_locations": unresolved,
+	})
+}
+
+func hashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (h *Handler) feedURL(c *gin.Context, token string) string {
+	base := h.publicBaseURL
+	if base == "" {
+		scheme := "http"
+		if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		base = scheme + "://" + c.Request.Host
+	}
+	return base + "/calendar/" + token + ".ics"
+}
+
+// CreateCalendarFeed issues the caller a private calendar feed URL. Calling
+// it again replaces the token, so an old link that leaked stops working.
+func (h *Handler) CreateCalendarFeed(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to create calendar feed")
+		return
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	if err := h.store.CalendarFeeds.Issue(c.Request.Context(), userID, hashFeedToken(token)); err != nil {
+		apierror.Internal(c, "CreateCalendarFeed DB Error", err, "Failed to create calendar feed")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"url": h.feedURL(c, token), "token": token})
+}
+
+// RevokeCalendarFeed turns off the caller's calendar feed
+func (h *Handler) RevokeCalendarFeed(c *gin.Context) {
+	if err := h.store.CalendarFeeds.Revoke(c.Request.Context(), c.GetString("userID")); err != nil {
+		apierror.Internal(c, "RevokeCalendarFeed DB Error", err, "Failed to revoke calendar feed")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "revoked"})
+}
+
+// ServeCalendarFeed publishes a user's upcoming travel plans and the errands
+// they have accepted as iCalendar. It is mounted outside the API: calendar
+// apps cannot send a bearer token, so the token in the URL is the credential.
+func (h *Handler) ServeCalendarFeed(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+
+	ctx := c.Request.Context()
+	userID, err := h.store.CalendarFeeds.Owner(ctx, hashFeedToken(token))
+	if errors.Is(err, store.ErrNotFound) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Calendar not found")
+		return
+	} else if err != nil {
+		apierror.Internal(c, "ServeCalendarFeed DB Error", err, "Failed to build calendar")
+		return
+	}
+
+	events, err := h.feedEvents(ctx, userID)
+	if err != nil {
+		apierror.Internal(c, "ServeCalendarFeed DB Error", err, "Failed to build calendar")
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := (ical.Calendar{Name: "CampusLoop", Events: events}).Write(&buf); err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to build calendar")
+		return
+	}
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Header("Content-Disposition", `inline; filename="campusloop.ics"`)
+	c.Data(http.StatusOK, calendarContentType+"; charset=utf-8", buf.Bytes())
+}
+
+// feedEvents lists a user's travel plans from the past day on and the
+// errands they are running
+func (h *Handler) feedEvents(ctx context.Context, userID string) ([]ical.Event, error) {
+	plans, err := h.store.TravelPlans.Since(ctx, userID, time.Now().Add(-24*time.Hour), 500)
+	if err != nil {
+		return nil, err
+	}
+
+	events := []ical.Event{}
+	for _, p := range plans {
+		duration := routing.TravelTime(routing.Mode(p.Mode), p.Route.Geo().Length())
+		if duration < 5*time.Minute {
+			duration = 5 * time.Minute
+		}
+		events = append(events, ical.Event{
+			UID:         "travel-plan-" + p.ID.String() + "@campusloop",
+			Summary:     fmt.Sprintf("Trip: %s → %s", p.OriginName, p.DestinationName),
+			Location:    p.OriginName,
+			Description: fmt.Sprintf("%s, %d seats offered", p.Mode, p.SeatsAvailable),
+			Start:       p.StartTime,
+			End:         p.StartTime.Add(duration),
+			Stamp:       p.CreatedAt,
+		})
+	}
+
+	// Accepted errands start when they were accepted
+	errands, err := h.store.Errands.AcceptedBy(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range errands {
+		description := "Status: " + e.Status
+		if e.DropoffPlaceName != "" {
+			description += "\nDeliver to: " + e.DropoffPlaceName
+		}
+		events = append(events, ical.Event{
+			UID:         "errand-" + e.ID.String() + "@campusloop",
+			Summary:     "Errand: " + e.Title,
+			Location:    e.PickupPlaceName,
+			Description: description,
+			Start:       e.AcceptedAt,
+			End:         e.AcceptedAt.Add(errandEventLength),
+			Stamp:       e.AcceptedAt,
+		})
+	}
+	return events, nil
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"regexp"
+
+	"github.com/Woeter69/hackoverflow/internal/apierror"
+	"github.com/Woeter69/hackoverflow/internal/geo"
+	"github.com/Woeter69/hackoverflow/internal/models"
+	"github.com/Woeter69/hackoverflow/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+var campusIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,49}$`)
+
+type CreateCampusRequest struct {
+	ID             string          `json:"id" binding:"required"`
+	Name           string          `json:"name" binding:"required"`
+	Boundary       geo.RawGeometry `json:"boundary"` // WKT or GeoJSON Polygon
+	CurrencyName   string          `json:"currency_name"`
+	DefaultBufferM float64         `json:"default_buffer_m"`
+	Categories     []string        `json:"categories"`
+}
+
+// UpdateCampusRequest only changes the fields that are present
+type UpdateCampusRequest struct {
+	Name           *string          `json:"name"`
+	Boundary       *geo.RawGeometry `json:"boundary"` // WKT or GeoJSON Polygon; "" clears it
+	CurrencyName   *string          `json:"currency_name"`
+	DefaultBufferM *float64         `json:"default_buffer_m"`
+	Categories     []string         `json:"categories"`
+}
+
+type AssignCampusRequest struct {
+	CampusID string `json:"campus_id" binding:"required"`
+}
+
+// allowsCategory reports whether errands of the category can be posted on the campus
+func allowsCategory(campus models.Campus, category string) bool {
+	if category == "" || len(campus.Categories) == 0 {
+		return true
+	}
+	for _, allowed := range campus.Categories {
+		if allowed == category {
+			return true
+		}
+	}
+	return false
+}
+
+// callerCampus resolves the campus the caller acts on. On failure the
+// response has already been written.
+func callerCampus(c *gin.Context) (string, bool) {
+	campusID := c.GetString("campusID")
+	if campusID == "" {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeNoCampus, "Your account is not assigned to a campus")
+		return "", false
+	}
+	return campusID, true
+}
+
+// parseBoundary validates a campus boundary (empty for none). On failure
+// the response has already been written.
+func parseBoundary(c *gin.Context, raw geo.RawGeometry) (geo.Polygon, bool) {
+	if raw == "" {
+		return nil, true
+	}
+	area, err := geo.ParsePolygon(string(raw))
+	if err != nil {
+		apierror.RespondWith(c, http.StatusUnprocessableEntity, apierror.CodeInvalidGeometry, "Invalid geometry", gin.H{"details": []*geo.Error{geo.FieldError("boundary", err)}})
+		return nil, false
+	}
+	return area, true
+}
+
+// campusWriteError maps store errors to client errors
+func campusWriteError(c *gin.Context, err error) {
+	var invalid *store.InvalidError
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Campus not found")
+	case errors.Is(err, store.ErrConflict):
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, "Campus already exists")
+	case errors.As(err, &invalid):
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid campus: "+invalid.Reason)
+	default:
+		apierror.Internal(c, "Campus DB Error", err, "Failed to save campus")
+	}
+}
+
+// GetCampus returns the configuration of the caller's campus
+func (h *Handler) GetCampus(c *gin.Context) {
+	campusID, ok := callerCampus(c)
+	if !ok {
+		return
+	}
+
+	campus, err := h.store.Campuses.Get(c.Request.Context(), campusID)
+	if err != nil {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Campus not found")
+		return
+	}
+
+	respondCampus(c, http.StatusOK, campus)
+}
+
+func respondCampus(c *gin.Context, status int, campus models.Campus) {
+	if wantsGeoJSON(c) {
+		respondGeoJSON(c, status, campusFeature(campus))
+		return
+	}
+	c.JSON(status, campus)
+}
+
+// ListCampuses returns every campus on the deployment (admin only)
+func (h *Handler) ListCampuses(c *gin.Context) {
+	campuses, err := h.store.Campuses.List(c.Request.Context())
+	if err != nil {
+		apierror.Internal(c, "ListCampuses DB Error", err, "Failed to fetch campuses")
+		return
+	}
+
+	if wantsGeoJSON(c) {
+		features := make([]geo.Feature, len(campuses))
+		for i, campus := range campuses {
+			features[i] = campusFeature(campus)
+		}
+		respondGeoJSON(c, http.StatusOK, geo.NewFeatureCollection(features))
+		return
+	}
+	c.JSON(http.StatusOK, campuses)
+}
+
+// CreateCampus adds a new tenant (admin only)
+func (h *Handler) CreateCampus(c *gin.Context) {
+	var req CreateCampusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.InvalidBody(c, err)
+		return
+	}
+	if !campusIDPattern.MatchString(req.ID) {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Campus id must be a lowercase slug")
+		return
+	}
+	if req.DefaultBufferM < 0 {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "default_buffer_m must not be negative")
+		return
+	}
+	if req.CurrencyName == "" {
+		req.CurrencyName = "Credits"
+	}
+	if req.DefaultBufferM == 0 {
+		req.DefaultBufferM = h.defaultBufferM
+	}
+	if req.Categories == nil {
+		req.Categories = []string{"delivery", "borrow", "favor"}
+	}
+	boundary, ok := parseBoundary(c, req.Boundary)
+	if !ok {
+		return
+	}
+
+	campus := models.Campus{
+		ID:             req.ID,
+		Name:           req.Name,
+		CurrencyName:   req.CurrencyName,
+		DefaultBufferM: req.DefaultBufferM,
+		Categories:     req.Categories,
+	}
+	if err := h.store.Campuses.Create(c.Request.Context(), &campus, boundary); err != nil {
+		campusWriteError(c, err)
+		return
+	}
+
+	respondCampus(c, http.StatusCreated, campus)
+}
+
+// UpdateCampus changes a campus's boundary or configuration (admin only)
+func (h *Handler) UpdateCampus(c *gin.Context) {
+	var req UpdateCampusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.InvalidBody(c, err)
+		return
+	}
+	if req.DefaultBufferM != nil && *req.DefaultBufferM <= 0 {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "default_buffer_m must be positive")
+		return
+	}
+
+	update := store.CampusUpdate{
+		Name:           req.Name,
+		CurrencyName:   req.CurrencyName,
+		DefaultBufferM: req.DefaultBufferM,
+		Categories:     req.Categories,
+	}
+	if req.Boundary != nil {
+		area, ok := parseBoundary(c, *req.Boundary)
+		if !ok {
+			return
+		}
+		update.Boundary = &area
+	}
+
+	campus, err := h.store.Campuses.Update(c.Request.Context(), c.Param("id"), update)
+	if err != nil {
+		campusWriteError(c, err)
+		return
+	}
+
+	respondCampus(c, http.StatusOK, campus)
+}
+
+// AssignUserCampus moves a user to a campus, e.g. staff without an
+// institutional email address (admin only)
+func (h *Handler) AssignUserCampus(c *gin.Context) {
+	var req AssignCampusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.InvalidBody(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	if _, err := h.store.Campuses.Get(ctx, req.CampusID); err != nil {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Campus not found")
+		return
+	}
+	userID := c.Param("id")
+	previous, _ := h.store.Users.Get(ctx, userID)
+	if err := h.store.Users.AssignCampus(ctx, userID, req.CampusID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "User not found")
+			return
+		}
+		apierror.Internal(c, "AssignUserCampus DB Error", err, "Failed to assign campus")
+		return
+	}
+
+	h.audit(c, store.AuditCampusAssign, store.AuditTarget("user", userID),
+		gin.H{"campus_id": previous.CampusID}, gin.H{"campus_id": req.CampusID})
+	slog.InfoContext(c.Request.Context(), "User assigned to campus", "user_id", userID, "campus_id", req.CampusID, "by", c.GetString("userID"))
+	h.resetProvisioning()
+	c.JSON(http.StatusOK, gin.H{"user_id": userID, "campus_id": req.CampusID})
+}
@@ -1,152 +1,207 @@
 package handlers
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
-	"github.com/Woeter69/hackoverflow/internal/database"
+	"github.com/Woeter69/hackoverflow/internal/apierror"
+	"github.com/Woeter69/hackoverflow/internal/geo"
+	"github.com/Woeter69/hackoverflow/internal/metrics"
+	"github.com/Woeter69/hackoverflow/internal/middleware"
 	"github.com/Woeter69/hackoverflow/internal/models"
-	"github.com/Woeter69/hackoverflow/internal/websocket"
+	"github.com/Woeter69/hackoverflow/internal/policy"
+	"github.com/Woeter69/hackoverflow/internal/store"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
-var wsHub *websocket.Hub
+// completionXP is the experience awarded for completing a mission
+const completionXP = 50
 
-func SetHub(hub *websocket.Hub) {
-	wsHub = hub
-}
+// emergencyTTL is how long a beacon stays up unless it is cleared first
+const emergencyTTL = 24 * time.Hour
 
 // DTOs for JSON binding
 type CreateTravelPlanRequest struct {
-	UserID    string `json:"user_id"`
-	RouteGeom string `json:"route_geom"` // WKT
+	UserID             string          `json:"user_id,omitempty" form:"user_id"`                 // Optional; must match the authenticated user
+	RouteGeom          geo.RawGeometry `json:"route_geom" form:"route_geom"`                     // WKT or GeoJSON LineString; generated from the endpoints when omitted
+	RoutePolyline      string          `json:"route_polyline" form:"route_polyline"`             // Google encoded polyline, instead of route_geom
+	PolylinePrecision  int             `json:"polyline_precision" form:"polyline_precision"`     // 5 (default) or 6
+	SimplifyTolerance  *float64        `json:"simplify_tolerance_m" form:"simplify_tolerance_m"` // Douglas–Peucker tolerance for supplied routes
+	OriginGeom         geo.RawGeometry `json:"origin_geom,omitempty" form:"origin_geom"`
+	DestinationGeom    geo.RawGeometry `json:"destination_geom,omitempty" form:"destination_geom"`
+	OriginPlaceID      string          `json:"origin_place_id,omitempty" form:"origin_place_id"`
+	DestinationPlaceID string          `json:"destination_place_id,omitempty" form:"destination_place_id"`
+	Mode               string          `json:"mode,omitempty" form:"mode"`             // walk (default), cycle, car, cab
+	StartTime          *time.Time      `json:"start_time,omitempty" form:"start_time"` // defaults to now
+
+	gpx []byte // uploaded GPX track, see bindTravelPlan
 }
 
 type CreateErrandRequestDTO struct {
-	UserID         string  `json:"user_id"`
-	Title          string  `json:"title"`
-	Description    string  `json:"description"`
-	Category       string  `json:"category"`
-	PickupGeom     string  `json:"pickup_geom"`  // WKT
-	DropoffGeom    string  `json:"dropoff_geom"` // WKT
-	RewardEstimate float64 `json:"reward_estimate"`
+	UserID         string          `json:"user_id,omitempty"` // Optional; must match the authenticated user
+	Title          string          `json:"title"`
+	Description    string          `json:"description"`
+	Category       string          `json:"category"`
+	PickupGeom     geo.RawGeometry `json:"pickup_geom"`  // WKT or GeoJSON Point; optional with pickup_place_id
+	DropoffGeom    geo.RawGeometry `json:"dropoff_geom"` // WKT or GeoJSON Point; optional with dropoff_place_id
+	PickupPlaceID  string          `json:"pickup_place_id,omitempty"`
+	DropoffPlaceID string          `json:"dropoff_place_id,omitempty"`
+	RewardEstimate float64         `json:"reward_estimate"`
+	UrgencyLevel   int             `json:"urgency_level"` // 1 (whenever, default) to 3 (as soon as possible)
 }
 
-func CreateTravelPlan(c *gin.Context) {
-	var req CreateTravelPlanRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+func (h *Handler) CreateTravelPlan(c *gin.Context) {
+	req, ok := bindTravelPlan(c)
+	if !ok {
 		return
 	}
 
-	// Default values for fields not in the simplified UI
-	originName := "Point A"
-	destName := "Point B"
-	mode := "walk"
+	userID, ok := authenticatedOwner(c, req.UserID)
+	if !ok {
+		return
+	}
+	campusID, ok := callerCampus(c)
+	if !ok {
+		return
+	}
+	mode, routingMode, ok := parseTravelMode(c, req.Mode)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	campus, err := h.store.Campuses.Get(ctx, campusID)
+	if err != nil {
+		apierror.Internal(c, "CreateTravelPlan Campus Error", err, "Failed to load campus")
+		return
+	}
+
+	check := newGeometryCheck(campus)
+	plan := h.planRoute(ctx, check, campusID, req, routingMode)
+	if check.respond(c) {
+		return
+	}
+	route := plan.path
+	originName, destName := plan.names()
 	startTime := time.Now()
+	if req.StartTime != nil {
+		// Allow for a little clock skew on the client
+		if req.StartTime.Before(startTime.Add(-startTimeSkew)) {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "start_time must not be in the past")
+			return
+		}
+		startTime = *req.StartTime
+	}
 
-	// Extract start/end points from LineString for origin/dest (Simplified)
-	// We just insert the route_geom. PostGIS matching uses route_geom.
-	// For origin_geom and destination_geom, we can use ST_StartPoint and ST_EndPoint in SQL but they work on Geometry, not Geography directly in some versions without casting.
-    // Let's use a robust query.
-	
-	fullQuery := `
-		INSERT INTO travel_plans (user_id, origin_name, destination_name, origin_geom, destination_geom, route_geom, mode, start_time)
-		VALUES (
-			$1, $2, $3, 
-			ST_StartPoint(ST_GeomFromText($4, 4326))::geography, 
-			ST_EndPoint(ST_GeomFromText($4, 4326))::geography, 
-			ST_GeogFromText($4), 
-			$5, $6
-		)
-		RETURNING id
-	`
-
-	var newID string
-	err := database.DB.QueryRow(fullQuery, req.UserID, originName, destName, req.RouteGeom, mode, startTime).Scan(&newID)
-	if err != nil {
-		log.Printf("CreateTravelPlan DB Error: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "DB Insert Failed: " + err.Error()})
+	p := models.TravelPlan{
+		UserID:             userID,
+		CampusID:           campusID,
+		OriginName:         originName,
+		DestinationName:    destName,
+		OriginPlaceID:      placeIDOf(plan.originPlace),
+		DestinationPlaceID: placeIDOf(plan.destPlace),
+		Origin:             models.PointFromGeo(route[0]),
+		Destination:        models.PointFromGeo(route[len(route)-1]),
+		Route:              models.LineStringFromGeo(route),
+		Mode:               mode,
+		StartTime:          startTime,
+	}
+	if err := h.store.TravelPlans.Create(ctx, &p); err != nil {
+		apierror.Internal(c, "CreateTravelPlan DB Error", err, "Failed to create travel plan")
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"id": newID, "status": "created"})
+	c.JSON(http.StatusCreated, gin.H{"id": p.ID, "status": "created", "origin_name": originName, "destination_name": destName})
 }
 
-func CreateErrandRequest(c *gin.Context) {
+func (h *Handler) CreateErrandRequest(c *gin.Context) {
 	var req CreateErrandRequestDTO
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.InvalidBody(c, err)
 		return
 	}
 
-	query := `
-		INSERT INTO errand_requests (user_id, title, description, category, pickup_geom, dropoff_geom, status, urgency_level, reward_estimate)
-		VALUES ($1, $2, $3, $4, ST_GeomFromText($5, 4326)::geography, ST_GeomFromText($6, 4326)::geography, 'pending', 1, $7)
-		RETURNING id
-	`
-
-	var newID string
-	err := database.DB.QueryRow(query, req.UserID, req.Title, req.Description, req.Category, req.PickupGeom, req.DropoffGeom, req.RewardEstimate).Scan(&newID)
+	userID, ok := authenticatedOwner(c, req.UserID)
+	if !ok {
+		return
+	}
+	if !policy.Can(middleware.Subject(c), policy.ActionCreateErrand, policy.Resource{}) {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeEmailUnverified, "Verify your campus email to post errands")
+		return
+	}
+	if req.UrgencyLevel == 0 {
+		req.UrgencyLevel = minUrgency
+	}
+	if req.UrgencyLevel < minUrgency || req.UrgencyLevel > maxUrgency {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, fmt.Sprintf("urgency_level must be between %d and %d", minUrgency, maxUrgency))
+		return
+	}
+	campusID, ok := callerCampus(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	campus, err := h.store.Campuses.Get(ctx, campusID)
 	if err != nil {
-		log.Printf("CreateErrandRequest DB Error: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "DB Insert Failed: " + err.Error()})
+		apierror.Internal(c, "CreateErrandRequest Campus Error", err, "Failed to load campus")
+		return
+	}
+	if !allowsCategory(campus, req.Category) {
+		apierror.RespondWith(c, http.StatusBadRequest, apierror.CodeUnknownCategory, "Unknown category for this campus: "+req.Category, gin.H{"categories": campus.Categories})
+		return
+	}
+
+	check := newGeometryCheck(campus)
+	pickup, pickupPlace := h.resolveEndpoint(ctx, check, campusID, "pickup_geom", req.PickupGeom, "pickup_place_id", req.PickupPlaceID)
+	dropoff, dropoffPlace := h.resolveEndpoint(ctx, check, campusID, "dropoff_geom", req.DropoffGeom, "dropoff_place_id", req.DropoffPlaceID)
+	if check.respond(c) {
+		return
+	}
+
+	errand := models.ErrandRequest{
+		UserID:         userID,
+		Title:          req.Title,
+		Description:    req.Description,
+		Category:       req.Category,
+		Pickup:         models.PointFromGeo(pickup),
+		Dropoff:        models.PointFromGeo(dropoff),
+		UrgencyLevel:   req.UrgencyLevel,
+		RewardEstimate: req.RewardEstimate,
+		CampusID:       campusID,
+		PickupPlaceID:  placeIDOf(pickupPlace),
+		DropoffPlaceID: placeIDOf(dropoffPlace),
+	}
+	if err := h.store.Errands.Create(ctx, &errand); err != nil {
+		apierror.Internal(c, "CreateErrandRequest DB Error", err, "Failed to create errand")
 		return
 	}
+	metrics.ErrandsCreated.Inc()
+	errand.PickupPlaceName, errand.DropoffPlaceName = placeName(pickupPlace), placeName(dropoffPlace)
 
 	// Broadcast the new errand via WebSocket
-	if wsHub != nil {
-		// Fetch the full details with coordinates
-		var e ErrandResponseDTO
-		q := `
-			SELECT 
-				id, title, description, category, reward_estimate,
-				ST_Y(pickup_geom::geometry) as pickup_lat,
-				ST_X(pickup_geom::geometry) as pickup_lng,
-				ST_Y(dropoff_geom::geometry) as dropoff_lat,
-				ST_X(dropoff_geom::geometry) as dropoff_lng
-			FROM errand_requests
-			WHERE id = $1
-		`
-		if err := database.DB.QueryRow(q, newID).Scan(&e.ID, &e.Title, &e.Description, &e.Category, &e.RewardEstimate, &e.PickupLat, &e.PickupLng, &e.DropoffLat, &e.DropoffLng); err == nil {
-			wsHub.BroadcastJSON("NEW_ERRAND", e)
-
-			// --- Notification Logic for Matching Travelers ---
-			// Find travelers whose route is within 200m of this new errand's pickup
-			matchQuery := `
-				SELECT user_id 
-				FROM travel_plans 
-				WHERE is_active = TRUE 
-				AND ST_DWithin(route_geom, ST_GeomFromText($1, 4326), 200)
-			`
-			rows, matchErr := database.DB.Query(matchQuery, req.PickupGeom)
-			if matchErr == nil {
-				defer rows.Close()
-				var matchedUserIDs []string
-				for rows.Next() {
-					var uid string
-					if err := rows.Scan(&uid); err == nil {
-						matchedUserIDs = append(matchedUserIDs, uid)
-					}
-				}
-
-				if len(matchedUserIDs) > 0 {
-					wsHub.BroadcastJSON("MATCH_NOTIFICATION", gin.H{
-						"errand":           e,
-						"matched_user_ids": matchedUserIDs,
-					})
-					log.Printf("Notified %d travelers about new errand %s", len(matchedUserIDs), newID)
-				}
-			} else {
-				log.Printf("Error finding matching travelers: %v", matchErr)
-			}
-			// ------------------------------------------------
+	if h.hub != nil {
+		e := errandResponse(errand)
+		h.hub.BroadcastToCampus(ctx, campusID, "NEW_ERRAND", e)
+
+		// Notify travelers on this campus whose route passes within the
+		// campus buffer of the pickup
+		matchedUserIDs, err := h.store.TravelPlans.TravelersNear(ctx, campusID, pickup, campus.DefaultBufferM)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error finding matching travelers", "err", err)
+		} else if len(matchedUserIDs) > 0 {
+			h.hub.BroadcastToCampus(ctx, campusID, "MATCH_NOTIFICATION", gin.H{
+				"errand":           e,
+				"matched_user_ids": matchedUserIDs,
+			})
+			slog.InfoContext(ctx, "Notified travelers about new errand", "travelers", len(matchedUserIDs), "errand_id", errand.ID)
 		}
 	}
 
-	c.JSON(http.StatusCreated, gin.H{"id": newID, "status": "created"})
+	c.JSON(http.StatusCreated, gin.H{"id": errand.ID, "status": "created"})
 }
 
 type ErrandResponseDTO struct {
@@ -158,44 +213,91 @@ type ErrandResponseDTO struct {
 	Description    string  `json:"description"`
 	Category       string  `json:"category"`
 	RewardEstimate float64 `json:"reward_estimate"`
+	UrgencyLevel   int     `json:"urgency_level"`
 	PickupLat      float64 `json:"pickup_lat"`
 	PickupLng      float64 `json:"pickup_lng"`
 	DropoffLat     float64 `json:"dropoff_lat"`
 	DropoffLng     float64 `json:"dropoff_lng"`
+
+	Pickup  models.Point `json:"pickup"`
+	Dropoff models.Point `json:"dropoff"`
+
+	PickupPlaceID    string `json:"pickup_place_id,omitempty"`
+	PickupPlaceName  string `json:"pickup_place_name,omitempty"`
+	DropoffPlaceID   string `json:"dropoff_place_id,omitempty"`
+	DropoffPlaceName string `json:"dropoff_place_name,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	DistanceM *float64  `json:"distance_m,omitempty"` // from the feed's near point
+}
+
+// errandResponse renders an errand, copying the pickup and dropoff points
+// into the flat fields older clients read
+func errandResponse(e models.ErrandRequest) ErrandResponseDTO {
+	return ErrandResponseDTO{
+		ID:               e.ID.String(),
+		UserID:           e.UserID,
+		RunnerID:         e.RunnerID,
+		Status:           e.Status,
+		Title:            e.Title,
+		Description:      e.Description,
+		Category:         e.Category,
+		RewardEstimate:   e.RewardEstimate,
+		UrgencyLevel:     e.UrgencyLevel,
+		PickupLat:        e.Pickup.Lat,
+		PickupLng:        e.Pickup.Lng,
+		DropoffLat:       e.Dropoff.Lat,
+		DropoffLng:       e.Dropoff.Lng,
+		Pickup:           e.Pickup,
+		Dropoff:          e.Dropoff,
+		PickupPlaceID:    e.PickupPlaceID,
+		PickupPlaceName:  e.PickupPlaceName,
+		DropoffPlaceID:   e.DropoffPlaceID,
+		DropoffPlaceName: e.DropoffPlaceName,
+		CreatedAt:        e.CreatedAt,
+	}
 }
 
-func GetPendingErrands(c *gin.Context) {
-	query := `
-		SELECT 
-			id, user_id, COALESCE(runner_id, '') as runner_id, status, title, description, category, reward_estimate::FLOAT,
-			ST_Y(pickup_geom::geometry) as pickup_lat,
-			ST_X(pickup_geom::geometry) as pickup_lng,
-			ST_Y(dropoff_geom::geometry) as dropoff_lat,
-			ST_X(dropoff_geom::geometry) as dropoff_lng
-		FROM errand_requests
-		WHERE status IN ('pending', 'matched')
-		ORDER BY created_at DESC
-		LIMIT 50
-	`
-
-	rows, err := database.DB.Query(query)
+// GetPendingErrands lists the open errands on the caller's campus, newest
+// first unless another sort is asked for. The filters are described on
+// parseErrandFeedQuery. The total number of matches is sent in X-Total-Count
+// and, when more follow, the cursor for the next page in X-Next-Cursor.
+func (h *Handler) GetPendingErrands(c *gin.Context) {
+	campusID, ok := callerCampus(c)
+	if !ok {
+		return
+	}
+	feed, err := parseErrandFeedQuery(c)
 	if err != nil {
-		log.Printf("GetPendingErrands DB Error: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Query failed: " + err.Error()})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
 		return
 	}
-	defer rows.Close()
+	feed.CampusID = campusID
 
-	errands := []ErrandResponseDTO{}
-	for rows.Next() {
-		var e ErrandResponseDTO
-		if err := rows.Scan(&e.ID, &e.UserID, &e.RunnerID, &e.Status, &e.Title, &e.Description, &e.Category, &e.RewardEstimate, &e.PickupLat, &e.PickupLng, &e.DropoffLat, &e.DropoffLng); err != nil {
-			log.Printf("Scan Error: %v\n", err)
-			continue
-		}
-		errands = append(errands, e)
+	page, err := h.store.Errands.Feed(c.Request.Context(), feed)
+	if err != nil {
+		apierror.Internal(c, "GetPendingErrands DB Error", err, "Failed to fetch errands")
+		return
+	}
+
+	errands := make([]ErrandResponseDTO, len(page.Errands))
+	for i, e := range page.Errands {
+		errands[i] = errandResponse(e.ErrandRequest)
+		errands[i].DistanceM = e.Distance
 	}
 
+	c.Header("X-Total-Count", strconv.Itoa(page.Total))
+	if page.Next != nil {
+		c.Header("X-Next-Cursor", cursorAt(feed.Sort, *page.Next).encode())
+	}
+	if wantsGeoJSON(c) {
+		features := make([]geo.Feature, len(errands))
+		for i, e := range errands {
+			features[i] = errandFeature(e)
+		}
+		respondGeoJSON(c, http.StatusOK, geo.NewFeatureCollection(features))
+		return
+	}
 	c.JSON(http.StatusOK, errands)
 }
 
@@ -203,101 +305,118 @@ type UpdateErrandStatusRequest struct {
 	Status string `json:"status" binding:"required,oneof=completed cancelled pending matched"`
 }
 
-func UpdateErrandStatus(c *gin.Context) {
+func (h *Handler) UpdateErrandStatus(c *gin.Context) {
 	id := c.Param("id")
 	var req UpdateErrandStatusRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.InvalidBody(c, err)
 		return
 	}
 
+	ctx := c.Request.Context()
 	userID := c.GetString("userID")
+	subject := middleware.Subject(c)
+	errand, err := h.store.Errands.Get(ctx, id)
+	if err != nil {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Errand not found")
+		return
+	}
+	campusID := errand.CampusID
+	parties := policy.Resource{OwnerID: errand.UserID, AssigneeID: errand.RunnerID, CampusID: campusID}
 
 	// Special handling for matching (accepting) an errand
 	if req.Status == "matched" {
-		_, err := database.DB.Exec("UPDATE errand_requests SET status = 'matched', runner_id = $1 WHERE id = $2 AND status = 'pending'", userID, id)
-		if err != nil {
-			log.Printf("AcceptErrand DB Error: %v\n", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept errand"})
+		// Running your own mission would pay you your own reward
+		if errand.UserID == userID {
+			apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "You cannot run your own mission")
+			return
+		}
+		if !policy.Can(subject, policy.ActionAcceptErrand, policy.Resource{Value: errand.RewardEstimate, CampusID: campusID}) {
+			if campusID != subject.CampusID {
+				apierror.Respond(c, http.StatusForbidden, apierror.CodeOtherCampus, "This mission belongs to another campus")
+			} else if !subject.CampusVerified {
+				apierror.Respond(c, http.StatusForbidden, apierror.CodeEmailUnverified, "Verify your campus email to accept missions")
+			} else {
+				apierror.Respond(c, http.StatusForbidden, apierror.CodeVerifiedRunnerRequired, "High-value missions require a verified runner")
+			}
+			return
+		}
+		if err := h.store.Errands.Accept(ctx, id, userID); err != nil {
+			if errors.Is(err, store.ErrConflict) {
+				apierror.Respond(c, http.StatusConflict, apierror.CodeMissionUnavailable, "Mission is no longer available")
+				return
+			}
+			apierror.Internal(c, "AcceptErrand DB Error", err, "Failed to accept errand")
 			return
 		}
 	} else if req.Status == "cancelled" {
 		// Authorization check: Only requester, runner, or admin can cancel
-		var requesterID, runnerID string
-		err := database.DB.QueryRow("SELECT user_id, COALESCE(runner_id, '') FROM errand_requests WHERE id = $1", id).Scan(&requesterID, &runnerID)
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Errand not found"})
+		if !policy.Can(subject, policy.ActionCancelErrand, parties) {
+			slog.WarnContext(ctx, "Unauthorized cancel attempt", "user_id", userID, "requester_id", errand.UserID, "runner_id", errand.RunnerID)
+			apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "You are not authorized to cancel this mission")
+			return
+		}
+		if !h.checkUnsettled(c, errand) {
 			return
 		}
 
-		// In this hackathon build, we assume 'admin' can be a specific ID or we check context
-		isAdmin := userID == "dev-user-123" // Allow dev user to act as admin
-		
-		if userID != requesterID && userID != runnerID && !isAdmin {
-			log.Printf("Unauthorized cancel attempt by %s (Requester: %s, Runner: %s)\n", userID, requesterID, runnerID)
-			c.JSON(http.StatusForbidden, gin.H{"error": "You are not authorized to cancel this mission"})
+		if err := h.store.Errands.SetStatus(ctx, id, "cancelled"); err != nil {
+			h.respondSetStatusError(c, "CancelErrand DB Error", err, "Failed to cancel mission")
+			return
+		}
+		h.audit(c, store.AuditErrandCancel, store.AuditTarget("errand", id),
+			gin.H{"status": errand.Status, "runner_id": errand.RunnerID}, gin.H{"status": "cancelled"})
+	} else if req.Status == "completed" {
+		if !policy.Can(subject, policy.ActionUpdateErrandStatus, parties) {
+			apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "Unauthorized status update")
+			return
+		}
+		if !h.checkUnsettled(c, errand) {
+			return
+		}
+		if errand.Status != "matched" || errand.RunnerID == "" {
+			apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, "Only missions with a runner can be completed")
 			return
 		}
 
-		_, err = database.DB.Exec("UPDATE errand_requests SET status = 'cancelled' WHERE id = $1", id)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel mission"})
+		// Completing and paying the runner happen together, so a mission
+		// pays out once however many requests race to complete it
+		completed, err := h.store.Errands.Complete(ctx, id, completionXP, actor(c))
+		if errors.Is(err, store.ErrConflict) {
+			apierror.Respond(c, http.StatusConflict, apierror.CodeMissionCompleted, "Mission can no longer be completed")
+			return
+		} else if err != nil {
+			apierror.Internal(c, "CompleteErrand DB Error", err, "Failed to complete mission")
 			return
 		}
+		reward := int(completed.RewardEstimate)
+		metrics.CreditsMoved.WithLabelValues(metrics.CreditsAward).Add(float64(reward))
+		slog.InfoContext(ctx, "Awarded credits", "credits", reward, "user_id", completed.RunnerID, "errand_id", id)
 	} else {
-		// Authorization for completion/other status updates
-		var requesterID, runnerID string
-		err := database.DB.QueryRow("SELECT user_id, COALESCE(runner_id, '') FROM errand_requests WHERE id = $1", id).Scan(&requesterID, &runnerID)
-		if err == nil {
-			isAdmin := userID == "dev-user-123"
-			if userID != requesterID && userID != runnerID && !isAdmin {
-				c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized status update"})
-				return
-			}
+		// Authorization for other status updates
+		if !policy.Can(subject, policy.ActionUpdateErrandStatus, parties) {
+			apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "Unauthorized status update")
+			return
+		}
+		if !h.checkUnsettled(c, errand) {
+			return
 		}
 
-		// Update status in DB for other statuses (e.g., completed)
-		_, err = database.DB.Exec("UPDATE errand_requests SET status = $1 WHERE id = $2", req.Status, id)
-		if err != nil {
-			log.Printf("UpdateErrandStatus DB Error: %v\n", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update status"})
+		if err := h.store.Errands.SetStatus(ctx, id, req.Status); err != nil {
+			h.respondSetStatusError(c, "UpdateErrandStatus DB Error", err, "Failed to update status")
 			return
 		}
 	}
 
-	// If completed, award credits/XP to the person who did it
-	if req.Status == "completed" {
-		// Get reward amount and the IDs
-		var reward float64
-		var requesterID, runnerID string
-		err := database.DB.QueryRow("SELECT reward_estimate, user_id, COALESCE(runner_id, '') FROM errand_requests WHERE id = $1", id).Scan(&reward, &requesterID, &runnerID)
-		
-		if err != nil {
-			log.Printf("Completion Award Fetch Error: %v\n", err)
-		} else {
-			log.Printf("Processing award for mission %s: Reward=%.2f, Requester=%s, Runner=%s\n", id, reward, requesterID, runnerID)
-			
-			targetID := runnerID
-			if targetID == "" {
-				// Fallback for demo: If no runner, award to requester so they see the number change
-				log.Printf("No runner assigned to mission %s, awarding to requester %s (Demo Mode)\n", id, requesterID)
-				targetID = requesterID
-			}
+	metrics.ErrandTransitions.WithLabelValues(req.Status).Inc()
 
-			if targetID != "" {
-				_, updateErr := database.DB.Exec("UPDATE users SET credits = credits + $1, xp = xp + 50 WHERE id = $2", int(reward), targetID)
-				if updateErr != nil {
-					log.Printf("Failed to award credits to %s: %v\n", targetID, updateErr)
-				} else {
-					log.Printf("SUCCESS: Awarded %d credits to user %s for errand %s\n", int(reward), targetID, id)
-				}
-			}
-		}
+	if err := h.store.Errands.RecordEvent(ctx, id, userID, "status_changed", gin.H{"status": req.Status}); err != nil {
+		slog.ErrorContext(ctx, "UpdateErrandStatus History Error", "err", err)
 	}
 
 	// Broadcast update
-	if wsHub != nil {
-		wsHub.BroadcastJSON("ERRAND_STATUS_UPDATE", gin.H{
+	if h.hub != nil {
+		h.hub.BroadcastToCampus(ctx, campusID, "ERRAND_STATUS_UPDATE", gin.H{
 			"id":     id,
 			"status": req.Status,
 		})
@@ -306,152 +425,199 @@ func UpdateErrandStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "updated"})
 }
 
+// checkUnsettled rejects changes to a mission that is under dispute or has
+// been completed. On failure the response has already been written.
+func (h *Handler) checkUnsettled(c *gin.Context, errand models.ErrandRequest) bool {
+	switch errand.Status {
+	case "disputed":
+		// Disputed missions are frozen until an admin resolves them
+		apierror.Respond(c, http.StatusConflict, apierror.CodeMissionDisputed, "Mission is under dispute")
+		return false
+	case "completed":
+		// The runner has been paid; only a dispute can reopen it
+		apierror.Respond(c, http.StatusConflict, apierror.CodeMissionCompleted, "Mission already completed")
+		return false
+	}
+	return true
+}
+
+// respondSetStatusError reports a failed status change. A conflict means
+// the mission was settled since it was read.
+func (h *Handler) respondSetStatusError(c *gin.Context, msg string, err error, public string) {
+	if errors.Is(err, store.ErrConflict) {
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, "Mission was settled in the meantime")
+		return
+	}
+	apierror.Internal(c, msg, err, public)
+}
+
 type EmergencyToggleRequest struct {
 	Active     bool   `json:"active"`
 	Message    string `json:"message"`
 	BuildingID int    `json:"building_id"`
+	PlaceID    string `json:"place_id,omitempty"` // registered place the alert is about
 }
 
-func GetChatHistory(c *gin.Context) {
+func (h *Handler) GetChatHistory(c *gin.Context) {
 	errandID := c.Param("id")
-	
-	query := `
-		SELECT id, errand_id, sender_id, content, is_encrypted, created_at
-		FROM messages
-		WHERE errand_id = $1
-		ORDER BY created_at ASC
-	`
-	rows, err := database.DB.Query(query, errandID)
+
+	ctx := c.Request.Context()
+	parties, err := h.errandParties(ctx, errandID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch chat"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Errand not found")
+		return
+	}
+	if !policy.Can(middleware.Subject(c), policy.ActionViewChat, parties) {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "You are not a party to this mission")
 		return
 	}
-	defer rows.Close()
 
-	messages := []models.Message{}
-	for rows.Next() {
-		var m models.Message
-		if err := rows.Scan(&m.ID, &m.ErrandID, &m.SenderID, &m.Content, &m.IsEncrypted, &m.CreatedAt); err != nil {
-			continue
-		}
-		messages = append(messages, m)
+	messages, err := h.store.Messages.List(ctx, errandID)
+	if err != nil {
+		apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to fetch chat")
+		return
 	}
 
 	c.JSON(http.StatusOK, messages)
 }
 
-func SendMessage(c *gin.Context) {
+func (h *Handler) SendMessage(c *gin.Context) {
 	errandIDStr := c.Param("id")
 	errandID, err := uuid.Parse(errandIDStr)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid errand ID"})
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid errand ID")
 		return
 	}
 
 	var req struct {
+		SenderID string `json:"sender_id,omitempty"` // Optional; must match the authenticated user
 		Content  string `json:"content"`
 	}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.InvalidBody(c, err)
 		return
 	}
 
-	senderID := c.GetString("userID")
-	if senderID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	senderID, ok := authenticatedOwner(c, req.SenderID)
+	if !ok {
 		return
 	}
 
-	query := `
-		INSERT INTO messages (errand_id, sender_id, content)
-		VALUES ($1, $2, $3)
-		RETURNING id, created_at
-	`
-	var m models.Message
-	m.ErrandID = errandID
-	m.SenderID = senderID
-	m.Content = req.Content
-	m.IsEncrypted = true
-
-	err = database.DB.QueryRow(query, errandID, senderID, req.Content).Scan(&m.ID, &m.CreatedAt)
+	ctx := c.Request.Context()
+	parties, err := h.errandParties(ctx, errandIDStr)
 	if err != nil {
-		log.Printf("SendMessage DB Error: %v", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save message"})
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Errand not found")
+		return
+	}
+	if !policy.Can(middleware.Subject(c), policy.ActionSendMessage, parties) {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "You are not a party to this mission")
 		return
 	}
 
-	// Broadcast via WebSocket
-	if wsHub != nil {
-		wsHub.BroadcastJSON("NEW_MESSAGE", m)
+	m := models.Message{ErrandID: errandID, SenderID: senderID, Content: req.Content}
+	if err := h.store.Messages.Create(ctx, &m); err != nil {
+		apierror.Internal(c, "SendMessage DB Error", err, "Failed to save message")
+		return
+	}
 
-		// Send targeted notification to the other party
-		var requesterID, runnerID string
-		err := database.DB.QueryRow("SELECT user_id, runner_id FROM errand_requests WHERE id = $1", errandID).Scan(&requesterID, &runnerID)
-		if err == nil {
-			recipientID := requesterID
-			if senderID == requesterID {
-				recipientID = runnerID
-			}
-			if recipientID != "" {
-				wsHub.SendToUser(recipientID, "INCOMING_CHAT", gin.H{
-					"errand_id": errandID,
-					"sender_id": senderID,
-				})
+	// Only the two parties see the conversation
+	if h.hub != nil {
+		for _, party := range []string{parties.OwnerID, parties.AssigneeID} {
+			if party != "" {
+				h.hub.SendToUser(ctx, party, "NEW_MESSAGE", m)
 			}
 		}
+
+		// Send targeted notification to the other party
+		recipientID := parties.OwnerID
+		if senderID == parties.OwnerID {
+			recipientID = parties.AssigneeID
+		}
+		if recipientID != "" {
+			h.hub.SendToUser(ctx, recipientID, "INCOMING_CHAT", gin.H{
+				"errand_id": errandID,
+				"sender_id": senderID,
+			})
+		}
 	}
 
 	c.JSON(http.StatusCreated, m)
 }
 
-func ToggleEmergency(c *gin.Context) {
+func (h *Handler) ToggleEmergency(c *gin.Context) {
 	var req EmergencyToggleRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.InvalidBody(c, err)
 		return
 	}
-	
+
 	userID := c.GetString("userID")
-	log.Printf("Emergency toggled by %s: %v", userID, req)
+	campusID, ok := callerCampus(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	var placeName string
+	if req.PlaceID != "" {
+		place, err := h.loadPlace(ctx, campusID, req.PlaceID)
+		if err != nil {
+			apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Place not found")
+			return
+		}
+		placeName = place.Name
+	}
 
-	// Persist and Broadcast the emergency state via WebSocket
-	if wsHub != nil {
-		wsHub.SetEmergencyState(gin.H{
+	// Anyone can raise the alarm, but only the person who raised it or a
+	// campus responder can stand it down.
+	var raisedBy string
+	active, err := h.store.Beacons.Active(ctx, campusID)
+	switch {
+	case err == nil:
+		raisedBy = active.UserID
+	case !errors.Is(err, store.ErrNotFound):
+		apierror.Internal(c, "ToggleEmergency DB Error", err, "Failed to load emergency")
+		return
+	}
+	action := policy.ActionRaiseEmergency
+	if !req.Active {
+		action = policy.ActionClearEmergency
+	}
+	if !policy.Can(middleware.Subject(c), action, policy.Resource{OwnerID: raisedBy, CampusID: campusID}) {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "Only campus responders can clear an emergency")
+		return
+	}
+	var beacon *models.Beacon
+	if req.Active {
+		beacon = &models.Beacon{
+			CampusID:   campusID,
+			UserID:     userID,
+			Message:    req.Message,
+			BuildingID: req.BuildingID,
+			PlaceID:    req.PlaceID,
+			ExpiresAt:  time.Now().Add(emergencyTTL),
+		}
+		err = h.store.Beacons.Raise(ctx, beacon, actor(c))
+	} else {
+		err = h.store.Beacons.Clear(ctx, campusID, actor(c))
+	}
+	if err != nil {
+		apierror.Internal(c, "ToggleEmergency DB Error", err, "Failed to save emergency")
+		return
+	}
+	// The beacon's message is the user's own words and is not logged
+	slog.InfoContext(ctx, "Emergency toggled", "campus_id", campusID, "user_id", userID, "active", req.Active, "place_id", req.PlaceID)
+
+	// Broadcast the emergency state via WebSocket
+	if h.hub != nil {
+		h.hub.SetEmergencyState(ctx, campusID, gin.H{
 			"active":      req.Active,
 			"message":     req.Message,
 			"building_id": req.BuildingID,
+			"place_id":    req.PlaceID,
+			"place_name":  placeName,
 			"user_id":     userID,
 		})
 	}
 
 	c.JSON(http.StatusOK, gin.H{"status": "success", "active": req.Active})
 }
-
-func GetUserProfile(c *gin.Context) {
-	userID := c.GetString("userID")
-	if userID == "" {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-		return
-	}
-
-	var u models.User
-	query := "SELECT id, username, email, credits, xp, rating, created_at FROM users WHERE id = $1"
-	err := database.DB.QueryRow(query, userID).Scan(&u.ID, &u.Username, &u.Email, &u.Credits, &u.XP, &u.Rating, &u.CreatedAt)
-	if err != nil {
-		// Lazy Registration: If user not found, create them
-		log.Printf("User %s not found, creating new profile...", userID)
-		
-		insertQuery := `
-			INSERT INTO users (id, username, email, credits, xp, rating)
-			VALUES ($1, 'Traveler', 'traveler@campusloop.xyz', 100, 0, 5.0)
-			RETURNING id, username, email, credits, xp, rating, created_at
-		`
-		err = database.DB.QueryRow(insertQuery, userID).Scan(&u.ID, &u.Username, &u.Email, &u.Credits, &u.XP, &u.Rating, &u.CreatedAt)
-		if err != nil {
-			log.Printf("GetUserProfile Create Error: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user profile"})
-			return
-		}
-	}
-	c.JSON(http.StatusOK, u)
-}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Woeter69/hackoverflow/internal/apierror"
+	"github.com/Woeter69/hackoverflow/internal/auth"
+	"github.com/Woeter69/hackoverflow/internal/policy"
+	"github.com/gin-gonic/gin"
+)
+
+type DevTokenRequest struct {
+	UserID        string   `json:"user_id" binding:"required"`
+	Email         string   `json:"email"`
+	Name          string   `json:"name"`
+	EmailVerified bool     `json:"email_verified"`
+	Roles         []string `json:"roles"`
+}
+
+// IssueDevToken mints a local auth token for any identity. It is only
+// mounted when the server runs with AUTH_MODE=local.
+func IssueDevToken(issuer *auth.LocalIssuer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req DevTokenRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			apierror.InvalidBody(c, err)
+			return
+		}
+
+		id := auth.Identity{
+			UID:           req.UserID,
+			Email:         req.Email,
+			Name:          req.Name,
+			EmailVerified: req.EmailVerified,
+		}
+		for _, name := range req.Roles {
+			r, ok := policy.ParseRole(name)
+			if !ok {
+				apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Unknown role: "+name)
+				return
+			}
+			id.Roles = append(id.Roles, r)
+		}
+
+		token, expires, err := issuer.Issue(id)
+		if err != nil {
+			apierror.Respond(c, http.StatusInternalServerError, apierror.CodeInternal, "Failed to issue token")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"token": token, "expires_at": expires})
+	}
+}
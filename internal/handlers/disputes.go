@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/Woeter69/hackoverflow/internal/apierror"
+	"github.com/Woeter69/hackoverflow/internal/metrics"
+	"github.com/Woeter69/hackoverflow/internal/middleware"
+	"github.com/Woeter69/hackoverflow/internal/models"
+	"github.com/Woeter69/hackoverflow/internal/policy"
+	"github.com/Woeter69/hackoverflow/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// Reputation adjustments applied when a dispute is resolved
+const (
+	refundRatingPenalty    = 0.5 // runner, when the requester is fully refunded
+	partialRatingPenalty   = 0.2 // runner, when the reward is split
+	frivolousRatingPenalty = 0.2 // requester, when a dispute they opened is paid out in full
+	disputeFullPayoutXP    = 50  // same XP as a normal completion
+	disputePartialPayoutXP = 25
+)
+
+type OpenDisputeRequest struct {
+	Reason       string   `json:"reason" binding:"required"`
+	EvidenceURLs []string `json:"evidence_urls"`
+}
+
+type DisputeStatementRequest struct {
+	Statement    string   `json:"statement" binding:"required"`
+	EvidenceURLs []string `json:"evidence_urls"`
+}
+
+type ResolveDisputeRequest struct {
+	Outcome      string `json:"outcome" binding:"required,oneof=refund payout partial"`
+	PayoutAmount int    `json:"payout_amount"` // credits released to the runner, only for 'partial'
+	Note         string `json:"note"`
+}
+
+// OpenDispute freezes an errand's reward and records the opener's statement.
+// If the reward was already paid out, it is clawed back from the runner into the hold.
+func (h *Handler) OpenDispute(c *gin.Context) {
+	errandID := c.Param("id")
+	var req OpenDisputeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.InvalidBody(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	userID := c.GetString("userID")
+	errand, err := h.store.Errands.Get(ctx, errandID)
+	if err != nil {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Errand not found")
+		return
+	}
+	requesterID, runnerID, campusID := errand.UserID, errand.RunnerID, errand.CampusID
+
+	if !policy.Can(middleware.Subject(c), policy.ActionOpenDispute, policy.Resource{OwnerID: requesterID, AssigneeID: runnerID, CampusID: campusID}) {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "Only the requester or runner can dispute this mission")
+		return
+	}
+	if errand.Status != "matched" && errand.Status != "completed" {
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, "Only matched or completed missions can be disputed")
+		return
+	}
+	if runnerID == "" {
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, "Mission has no runner to dispute with")
+		return
+	}
+
+	d, err := h.store.Disputes.Open(ctx, store.DisputeOpening{
+		ErrandID:     errandID,
+		Reason:       req.Reason,
+		EvidenceURLs: req.EvidenceURLs,
+		ClawbackXP:   disputeFullPayoutXP,
+	}, actor(c))
+	if errors.Is(err, store.ErrConflict) {
+		// The errand changed since it was read
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, "Only matched or completed missions can be disputed")
+		return
+	} else if err != nil {
+		apierror.Internal(c, "OpenDispute DB Error", err, "Failed to open dispute")
+		return
+	}
+	disputeID := d.ID.String()
+	held := int(d.HeldAmount)
+
+	metrics.ErrandTransitions.WithLabelValues("disputed").Inc()
+	if d.PreviousStatus == "completed" {
+		metrics.CreditsMoved.WithLabelValues(metrics.CreditsClawback).Add(float64(held))
+	}
+
+	if h.hub != nil {
+		h.hub.BroadcastToCampus(ctx, campusID, "ERRAND_STATUS_UPDATE", gin.H{
+			"id":     errandID,
+			"status": "disputed",
+		})
+		for _, party := range []string{requesterID, runnerID} {
+			if party != userID {
+				h.hub.SendToUser(ctx, party, "DISPUTE_OPENED", gin.H{
+					"errand_id":  errandID,
+					"dispute_id": disputeID,
+				})
+			}
+		}
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"id": disputeID, "status": "open", "held_amount": held})
+}
+
+// AddDisputeStatement lets either party add a statement and evidence to an open dispute
+func (h *Handler) AddDisputeStatement(c *gin.Context) {
+	errandID := c.Param("id")
+	var req DisputeStatementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.InvalidBody(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	d, err := h.store.Disputes.Latest(ctx, errandID)
+	if err != nil || d.Status != "open" {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "No open dispute for this mission")
+		return
+	}
+	errand, err := h.store.Errands.Get(ctx, errandID)
+	if err != nil {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "No open dispute for this mission")
+		return
+	}
+
+	if !policy.Can(middleware.Subject(c), policy.ActionAddDisputeStatement, policy.Resource{OwnerID: errand.UserID, AssigneeID: errand.RunnerID, CampusID: errand.CampusID}) {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "You are not a party to this dispute")
+		return
+	}
+
+	s := models.DisputeStatement{
+		DisputeID:    d.ID,
+		AuthorID:     c.GetString("userID"),
+		Statement:    req.Statement,
+		EvidenceURLs: req.EvidenceURLs,
+	}
+	if err := h.store.Disputes.AddStatement(ctx, &s); errors.Is(err, store.ErrNotFound) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "No open dispute for this mission")
+		return
+	} else if err != nil {
+		apierror.Internal(c, "AddDisputeStatement DB Error", err, "Failed to save statement")
+		return
+	}
+
+	c.JSON(http.StatusCreated, s)
+}
+
+// GetDispute returns the most recent dispute on an errand with all statements
+func (h *Handler) GetDispute(c *gin.Context) {
+	errandID := c.Param("id")
+
+	ctx := c.Request.Context()
+	errand, err := h.store.Errands.Get(ctx, errandID)
+	if err != nil {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "No dispute for this mission")
+		return
+	}
+	d, err := h.store.Disputes.Latest(ctx, errandID)
+	if errors.Is(err, store.ErrNotFound) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "No dispute for this mission")
+		return
+	} else if err != nil {
+		apierror.Internal(c, "GetDispute DB Error", err, "Failed to fetch dispute")
+		return
+	}
+
+	if !policy.Can(middleware.Subject(c), policy.ActionViewDispute, policy.Resource{OwnerID: errand.UserID, AssigneeID: errand.RunnerID, CampusID: errand.CampusID}) {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "You are not a party to this dispute")
+		return
+	}
+
+	c.JSON(http.StatusOK, d)
+}
+
+// ResolveDispute releases the held reward according to a staff decision
+// and adjusts both parties' reputation.
+func (h *Handler) ResolveDispute(c *gin.Context) {
+	errandID := c.Param("id")
+	var req ResolveDisputeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.InvalidBody(c, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	userID := c.GetString("userID")
+	subject := middleware.Subject(c)
+	if !policy.Can(subject, policy.ActionResolveDispute, policy.Resource{}) {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "Only staff can resolve disputes")
+		return
+	}
+
+	d, err := h.store.Disputes.Latest(ctx, errandID)
+	if err != nil || d.Status != "open" {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "No open dispute for this mission")
+		return
+	}
+	errand, err := h.store.Errands.Get(ctx, errandID)
+	if err != nil {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "No open dispute for this mission")
+		return
+	}
+	disputeID := d.ID.String()
+	requesterID, runnerID, campusID := errand.UserID, errand.RunnerID, errand.CampusID
+	if !policy.Can(subject, policy.ActionResolveDispute, policy.Resource{CampusID: campusID}) {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeOtherCampus, "This mission belongs to another campus")
+		return
+	}
+
+	heldCredits := int(d.HeldAmount)
+	r := store.DisputeResolution{Outcome: req.Outcome, Note: req.Note, ErrandStatus: "completed"}
+	switch req.Outcome {
+	case "refund":
+		r.RunnerPenalty = refundRatingPenalty
+		r.ErrandStatus = "cancelled"
+	case "payout":
+		r.Payout = heldCredits
+		r.RunnerXP = disputeFullPayoutXP
+		if d.OpenedBy == requesterID {
+			r.RequesterPenalty = frivolousRatingPenalty
+		}
+	case "partial":
+		if req.PayoutAmount <= 0 || req.PayoutAmount >= heldCredits {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "payout_amount must be between 0 and the held amount for a partial resolution")
+			return
+		}
+		r.Payout = req.PayoutAmount
+		r.RunnerXP = disputePartialPayoutXP
+		r.RunnerPenalty = partialRatingPenalty
+	}
+	r.Refund = heldCredits - r.Payout
+	payout, refund, finalStatus := r.Payout, r.Refund, r.ErrandStatus
+
+	if err := h.store.Disputes.Resolve(ctx, disputeID, r, actor(c)); errors.Is(err, store.ErrConflict) {
+		// Another staff member resolved it first
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "No open dispute for this mission")
+		return
+	} else if err != nil {
+		apierror.Internal(c, "ResolveDispute DB Error", err, "Failed to resolve dispute")
+		return
+	}
+	metrics.ErrandTransitions.WithLabelValues(finalStatus).Inc()
+	metrics.CreditsMoved.WithLabelValues(metrics.CreditsPayout).Add(float64(payout))
+
+	slog.InfoContext(ctx, "Dispute resolved", "dispute_id", disputeID, "errand_id", errandID, "by", userID, "outcome", req.Outcome, "payout", payout, "refund", refund)
+
+	if h.hub != nil {
+		h.hub.BroadcastToCampus(ctx, campusID, "ERRAND_STATUS_UPDATE", gin.H{
+			"id":     errandID,
+			"status": finalStatus,
+		})
+		for _, party := range []string{requesterID, runnerID} {
+			h.hub.SendToUser(ctx, party, "DISPUTE_RESOLVED", gin.H{
+				"errand_id":     errandID,
+				"dispute_id":    disputeID,
+				"outcome":       req.Outcome,
+				"payout_amount": payout,
+				"refund_amount": refund,
+			})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":        "resolved",
+		"outcome":       req.Outcome,
+		"payout_amount": payout,
+		"refund_amount": refund,
+	})
+}
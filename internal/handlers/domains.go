@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/Woeter69/hackoverflow/internal/apierror"
+	"github.com/Woeter69/hackoverflow/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+var domainPattern = regexp.MustCompile(`^([a-z0-9-]+\.)+[a-z]{2,}$`)
+
+type AddEmailDomainRequest struct {
+	Domain   string `json:"domain" binding:"required"`
+	CampusID string `json:"campus_id"`
+}
+
+// ListEmailDomains returns the institutional domains allowed to transact (admin only)
+func (h *Handler) ListEmailDomains(c *gin.Context) {
+	domains, err := h.store.EmailDomains.List(c.Request.Context())
+	if err != nil {
+		apierror.Internal(c, "ListEmailDomains DB Error", err, "Failed to fetch domains")
+		return
+	}
+
+	c.JSON(http.StatusOK, domains)
+}
+
+// AddEmailDomain allows a new institutional email domain (admin only)
+func (h *Handler) AddEmailDomain(c *gin.Context) {
+	var req AddEmailDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.InvalidBody(c, err)
+		return
+	}
+
+	domain := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(req.Domain)), "@")
+	if !domainPattern.MatchString(domain) {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid domain: "+req.Domain)
+		return
+	}
+	campusID := req.CampusID
+	if campusID == "" {
+		campusID = "default"
+	}
+
+	d, err := h.store.EmailDomains.Add(c.Request.Context(), domain, campusID)
+	if errors.Is(err, store.ErrNotFound) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Campus not found: "+campusID)
+		return
+	} else if err != nil {
+		apierror.Internal(c, "AddEmailDomain DB Error", err, "Failed to add domain")
+		return
+	}
+
+	// Re-evaluate campus membership on everyone's next request
+	h.resetProvisioning()
+	c.JSON(http.StatusCreated, d)
+}
+
+// RemoveEmailDomain removes an institutional email domain (admin only)
+func (h *Handler) RemoveEmailDomain(c *gin.Context) {
+	domain := strings.ToLower(c.Param("domain"))
+
+	err := h.store.EmailDomains.Remove(c.Request.Context(), domain)
+	if errors.Is(err, store.ErrNotFound) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Domain not found")
+		return
+	} else if err != nil {
+		apierror.Internal(c, "RemoveEmailDomain DB Error", err, "Failed to remove domain")
+		return
+	}
+
+	h.resetProvisioning()
+	c.JSON(http.StatusOK, gin.H{"status": "removed", "domain": domain})
+}
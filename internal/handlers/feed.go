@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Woeter69/hackoverflow/internal/geo"
+	"github.com/Woeter69/hackoverflow/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// Limits for the errand feed
+const (
+	defaultFeedLimit  = 50
+	maxFeedLimit      = 100
+	defaultFeedRadius = 1000.0  // meters, when near is given without radius
+	maxFeedRadius     = 20000.0 // meters
+)
+
+// Urgency levels an errand can be posted with
+const (
+	minUrgency = 1 // whenever
+	maxUrgency = 3 // as soon as possible
+)
+
+// feedStatuses are the errand states the feed can show
+var feedStatuses = map[string]bool{"pending": true, "matched": true}
+
+// feedCursor marks the last errand of a page
+type feedCursor struct {
+	Sort      store.ErrandSort `json:"s"`
+	Key       float64          `json:"k"`
+	CreatedAt time.Time        `json:"t"`
+	ID        string           `json:"id"`
+}
+
+// cursorAt is the cursor continuing a feed ranked by sort after pos
+func cursorAt(sort store.ErrandSort, pos store.FeedPosition) feedCursor {
+	return feedCursor{Sort: sort, Key: pos.Key, CreatedAt: pos.CreatedAt, ID: pos.ID}
+}
+
+func (fc feedCursor) encode() string {
+	raw, _ := json.Marshal(fc)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeFeedCursor(s string) (feedCursor, error) {
+	var fc feedCursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err == nil {
+		err = json.Unmarshal(raw, &fc)
+	}
+	if err != nil || fc.ID == "" {
+		return fc, fmt.Errorf("cursor is invalid")
+	}
+	return fc, nil
+}
+
+// parseLatLng reads "lat,lng"
+func parseLatLng(s string) (geo.Point, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return geo.Point{}, fmt.Errorf("near must be lat,lng")
+	}
+	lat, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	lng, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err1 != nil || err2 != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return geo.Point{}, fmt.Errorf("near must be lat,lng")
+	}
+	return geo.Point{Lng: lng, Lat: lat}, nil
+}
+
+// commaList splits a comma-separated query value, dropping blanks
+func commaList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// parseErrandFeedQuery reads the feed filters:
+//
+//	near=lat,lng&radius=meters   errands picked up within radius of a point
+//	bbox=minLng,minLat,maxLng,maxLat or geohash=tdr1w   errands picked up in an area
+//	category=a,b  min_reward=n  urgency=n (minimum)  status=pending,matched
+//	sort=age|distance|reward|urgency  limit=n  cursor=...
+//
+// The caller fills in the campus.
+func parseErrandFeedQuery(c *gin.Context) (store.ErrandQuery, error) {
+	q := store.ErrandQuery{Sort: store.SortAge, Limit: defaultFeedLimit, Statuses: []string{"pending", "matched"}}
+
+	if v := c.Query("near"); v != "" {
+		pt, err := parseLatLng(v)
+		if err != nil {
+			return q, err
+		}
+		q.Near = &pt
+		q.Radius = defaultFeedRadius
+	}
+	if v := c.Query("radius"); v != "" {
+		if q.Near == nil {
+			return q, fmt.Errorf("radius needs near")
+		}
+		r, err := strconv.ParseFloat(v, 64)
+		if err != nil || r <= 0 || r > maxFeedRadius {
+			return q, fmt.Errorf("radius must be between 0 and %.0f meters", maxFeedRadius)
+		}
+		q.Radius = r
+	}
+
+	bbox, geohash := c.Query("bbox"), c.Query("geohash")
+	switch {
+	case bbox != "" && geohash != "":
+		return q, fmt.Errorf("use either bbox or geohash, not both")
+	case bbox != "":
+		b, err := geo.ParseBBox(bbox)
+		if err != nil {
+			return q, err
+		}
+		q.Area = &b
+	case geohash != "":
+		b, err := geo.DecodeGeohash(geohash)
+		if err != nil {
+			return q, err
+		}
+		q.Area = &b
+	}
+
+	q.Categories = commaList(c.Query("category"))
+	if v := c.Query("min_reward"); v != "" {
+		r, err := strconv.ParseFloat(v, 64)
+		if err != nil || r < 0 {
+			return q, fmt.Errorf("min_reward must be a non-negative number")
+		}
+		q.MinReward = r
+	}
+	if v := c.Query("urgency"); v != "" {
+		u, err := strconv.Atoi(v)
+		if err != nil || u < minUrgency || u > maxUrgency {
+			return q, fmt.Errorf("urgency must be between %d and %d", minUrgency, maxUrgency)
+		}
+		q.MinUrgency = u
+	}
+	if v := c.Query("status"); v != "" {
+		q.Statuses = commaList(v)
+		for _, s := range q.Statuses {
+			if !feedStatuses[s] {
+				return q, fmt.Errorf("status must be pending or matched")
+			}
+		}
+	}
+
+	if v := c.Query("sort"); v != "" {
+		q.Sort = store.ErrandSort(v)
+		if !q.Sort.Valid() {
+			return q, fmt.Errorf("sort must be age, distance, reward or urgency")
+		}
+	}
+	if q.Sort == store.SortDistance && q.Near == nil {
+		return q, fmt.Errorf("sort=distance needs near")
+	}
+	if v := c.Query("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l < 1 || l > maxFeedLimit {
+			return q, fmt.Errorf("limit must be between 1 and %d", maxFeedLimit)
+		}
+		q.Limit = l
+	}
+	if v := c.Query("cursor"); v != "" {
+		fc, err := decodeFeedCursor(v)
+		if err != nil {
+			return q, err
+		}
+		if fc.Sort != q.Sort {
+			return q, fmt.Errorf("cursor belongs to sort=%s", fc.Sort)
+		}
+		q.After = &store.FeedPosition{Key: fc.Key, CreatedAt: fc.CreatedAt, ID: fc.ID}
+	}
+	return q, nil
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/Woeter69/hackoverflow/internal/geo"
+	"github.com/Woeter69/hackoverflow/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+const geoJSONContentType = "application/geo+json"
+
+// wantsGeoJSON reports whether the client asked for GeoJSON, either with
+// ?format=geojson, an Accept header, or a .geojson route. An explicit format
+// wins over the Accept header.
+func wantsGeoJSON(c *gin.Context) bool {
+	if c.GetBool("geojson") {
+		return true
+	}
+	if format := c.Query("format"); format != "" {
+		return strings.EqualFold(format, "geojson")
+	}
+	return strings.Contains(c.GetHeader("Accept"), geoJSONContentType)
+}
+
+// asGeoJSON serves a handler's GeoJSON representation regardless of negotiation
+func asGeoJSON(h gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("geojson", true)
+		h(c)
+	}
+}
+
+func respondGeoJSON(c *gin.Context, status int, body interface{}) {
+	c.Header("Content-Type", geoJSONContentType)
+	c.JSON(status, body)
+}
+
+func errandFeature(e ErrandResponseDTO) geo.Feature {
+	props := map[string]interface{}{
+		"user_id":         e.UserID,
+		"runner_id":       e.RunnerID,
+		"status":          e.Status,
+		"title":           e.Title,
+		"description":     e.Description,
+		"category":        e.Category,
+		"reward_estimate": e.RewardEstimate,
+		"urgency_level":   e.UrgencyLevel,
+		"created_at":      e.CreatedAt,
+		"dropoff":         e.Dropoff.Geo().Geometry(),
+	}
+	if e.DistanceM != nil {
+		props["distance_m"] = *e.DistanceM
+	}
+	if e.PickupPlaceID != "" {
+		props["pickup_place_id"], props["pickup_place_name"] = e.PickupPlaceID, e.PickupPlaceName
+	}
+	if e.DropoffPlaceID != "" {
+		props["dropoff_place_id"], props["dropoff_place_name"] = e.DropoffPlaceID, e.DropoffPlaceName
+	}
+	return geo.NewFeature(e.ID, e.Pickup.Geo().Geometry(), props)
+}
+
+func matchFeature(m models.MatchResponse) geo.Feature {
+	e := m.Errand
+	return geo.NewFeature(e.ID.String(), e.Pickup.Geo().Geometry(), map[string]interface{}{
+		"user_id":             e.UserID,
+		"title":               e.Title,
+		"description":         e.Description,
+		"status":              e.Status,
+		"urgency_level":       e.UrgencyLevel,
+		"reward_estimate":     e.RewardEstimate,
+		"dropoff":             e.Dropoff.Geo().Geometry(),
+		"distance_from_route": m.DistanceFromRoute,
+		"created_at":          e.CreatedAt,
+	})
+}
+
+// travelPlanFeature draws a plan as its route, or nothing for legacy plans
+// stored without one
+func travelPlanFeature(p models.TravelPlan) geo.Feature {
+	var geometry interface{}
+	if len(p.Route) >= 2 {
+		geometry = p.Route.Geo().Geometry()
+	}
+	props := map[string]interface{}{
+		"user_id":          p.UserID,
+		"campus_id":        p.CampusID,
+		"origin_name":      p.OriginName,
+		"destination_name": p.DestinationName,
+		"origin":           p.Origin.Geo().Geometry(),
+		"destination":      p.Destination.Geo().Geometry(),
+		"mode":             p.Mode,
+		"start_time":       p.StartTime,
+		"seats_available":  p.SeatsAvailable,
+		"is_active":        p.IsActive,
+	}
+	if p.OriginPlaceID != "" {
+		props["origin_place_id"] = p.OriginPlaceID
+	}
+	if p.DestinationPlaceID != "" {
+		props["destination_place_id"] = p.DestinationPlaceID
+	}
+	if p.SeriesID != "" {
+		props["series_id"] = p.SeriesID
+	}
+	return geo.NewFeature(p.ID.String(), geometry, props)
+}
+
+func placeFeature(p models.Place) geo.Feature {
+	props := map[string]interface{}{
+		"campus_id": p.CampusID,
+		"name":      p.Name,
+		"kind":      p.Kind,
+		"footprint": p.Footprint,
+		"entrances": p.Entrances,
+	}
+	if p.Code != "" {
+		props["code"] = p.Code
+	}
+	if p.Distance != nil {
+		props["distance_m"] = *p.Distance
+	}
+	return geo.NewFeature(p.ID, p.Location.Geo().Geometry(), props)
+}
+
+func campusFeature(campus models.Campus) geo.Feature {
+	return geo.NewFeature(campus.ID, campus.Boundary, map[string]interface{}{
+		"name":             campus.Name,
+		"currency_name":    campus.CurrencyName,
+		"default_buffer_m": campus.DefaultBufferM,
+		"categories":       campus.Categories,
+	})
+}
+
+func seriesFeature(s models.TravelPlanSeries) geo.Feature {
+	var geometry interface{}
+	if len(s.Route) >= 2 {
+		geometry = s.Route.Geo().Geometry()
+	}
+	return geo.NewFeature(s.ID, geometry, map[string]interface{}{
+		"user_id":          s.UserID,
+		"campus_id":        s.CampusID,
+		"origin_name":      s.OriginName,
+		"destination_name": s.DestinationName,
+		"mode":             s.Mode,
+		"seats_available":  s.SeatsAvailable,
+		"rrule":            s.RRule,
+		"start":            s.Start,
+		"timezone":         s.Timezone,
+		"exceptions":       s.Exceptions,
+		"paused":           s.Paused,
+		"next_departures":  s.NextDepartures,
+	})
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/Woeter69/hackoverflow/internal/apierror"
+	"github.com/Woeter69/hackoverflow/internal/geo"
+	"github.com/Woeter69/hackoverflow/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+// geometryCheck parses the geometries of one request and validates them
+// against the campus service area, collecting every problem so the client
+// gets them all in a single 422 response.
+type geometryCheck struct {
+	area   geo.Polygon // nil when the campus has no boundary
+	errors []*geo.Error
+}
+
+func newGeometryCheck(campus models.Campus) *geometryCheck {
+	g := &geometryCheck{}
+	if len(campus.Boundary) > 0 {
+		area, err := geo.ParsePolygon(string(campus.Boundary))
+		if err != nil {
+			slog.Error("Campus has an unusable boundary", "campus_id", campus.ID, "err", err)
+		} else {
+			g.area = area
+		}
+	}
+	return g
+}
+
+func (g *geometryCheck) fail(field string, err error) {
+	g.errors = append(g.errors, geo.FieldError(field, err))
+}
+
+// point parses a location that must lie inside the campus
+func (g *geometryCheck) point(field string, raw geo.RawGeometry) geo.Point {
+	p, err := geo.ParsePoint(string(raw))
+	if err != nil {
+		g.fail(field, err)
+		return p
+	}
+	if g.area != nil {
+		if err := geo.CheckPointInside(g.area, p); err != nil {
+			g.fail(field, err)
+		}
+	}
+	return p
+}
+
+// line checks an already parsed path against the route limits and the campus
+func (g *geometryCheck) line(field string, l geo.LineString) {
+	if err := geo.ValidateRoute(l); err != nil {
+		g.fail(field, err)
+		return
+	}
+	if g.area != nil {
+		if err := geo.CheckLineInside(g.area, l); err != nil {
+			g.fail(field, err)
+		}
+	}
+}
+
+// polygon parses an area whose outline must stay on campus
+func (g *geometryCheck) polygon(field string, raw geo.RawGeometry) geo.Polygon {
+	area, err := geo.ParsePolygon(string(raw))
+	if err != nil {
+		g.fail(field, err)
+		return area
+	}
+	if g.area != nil {
+		if err := geo.CheckLineInside(g.area, geo.LineString(area[0])); err != nil {
+			g.fail(field, err)
+		}
+	}
+	return area
+}
+
+// respond writes the collected errors, if any, and reports whether it did
+func (g *geometryCheck) respond(c *gin.Context) bool {
+	if len(g.errors) == 0 {
+		return false
+	}
+	apierror.RespondWith(c, http.StatusUnprocessableEntity, apierror.CodeInvalidGeometry, "Invalid geometry", gin.H{"details": g.errors})
+	return true
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/Woeter69/hackoverflow/internal/middleware"
+	"github.com/Woeter69/hackoverflow/internal/routing"
+	"github.com/Woeter69/hackoverflow/internal/store"
+	"github.com/Woeter69/hackoverflow/internal/websocket"
+)
+
+// defaultUploadDir holds runner verification documents unless Deps says otherwise
+const defaultUploadDir = "uploads"
+
+// defaultBufferM is the route buffer (meters) of new campuses that set none,
+// unless Deps says otherwise
+const defaultBufferM = 200
+
+// Deps are the dependencies the API handlers run against
+type Deps struct {
+	Store *store.Store
+	// Hub pushes realtime updates; nil disables them
+	Hub *websocket.Hub
+	// Networks are the campus path networks used for route generation
+	Networks routing.Networks
+	// Provisioner is reset when campus membership rules change
+	Provisioner *middleware.Provisioner
+	// PublicBaseURL is the externally visible origin used in calendar feed
+	// links; the request's own origin is used when empty
+	PublicBaseURL string
+	UploadDir     string
+	// DefaultBufferM is the route buffer (meters) given to new campuses
+	DefaultBufferM float64
+}
+
+// Handler serves the API endpoints
+type Handler struct {
+	store          *store.Store
+	hub            *websocket.Hub
+	networks       routing.Networks
+	provisioner    *middleware.Provisioner
+	publicBaseURL  string
+	uploadDir      string
+	defaultBufferM float64
+}
+
+// New returns the API handlers for the given dependencies
+func New(deps Deps) *Handler {
+	h := &Handler{
+		store:          deps.Store,
+		hub:            deps.Hub,
+		networks:       deps.Networks,
+		provisioner:    deps.Provisioner,
+		publicBaseURL:  strings.TrimRight(deps.PublicBaseURL, "/"),
+		uploadDir:      deps.UploadDir,
+		defaultBufferM: deps.DefaultBufferM,
+	}
+	if h.uploadDir == "" {
+		h.uploadDir = defaultUploadDir
+	}
+	if h.defaultBufferM <= 0 {
+		h.defaultBufferM = defaultBufferM
+	}
+	return h
+}
+
+// resetProvisioning makes every user's campus membership be recomputed on
+// their next request
+func (h *Handler) resetProvisioning() {
+	if h.provisioner != nil {
+		h.provisioner.Reset()
+	}
+}
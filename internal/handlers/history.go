@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/Woeter69/hackoverflow/internal/apierror"
+	"github.com/gin-gonic/gin"
+)
+
+// GetErrandHistory returns the chronological history of an errand
+func (h *Handler) GetErrandHistory(c *gin.Context) {
+	events, err := h.store.Errands.Events(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		apierror.Internal(c, "GetErrandHistory DB Error", err, "Failed to fetch history")
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Woeter69/hackoverflow/internal/apierror"
+	"github.com/Woeter69/hackoverflow/internal/policy"
+	"github.com/gin-gonic/gin"
+)
+
+// authenticatedOwner resolves who a write is performed as. Ownership always
+// comes from the verified token in the gin context; an ID supplied in the
+// request body is only tolerated when it matches. On failure the response has
+// already been written.
+func authenticatedOwner(c *gin.Context, bodyUserID string) (string, bool) {
+	userID := c.GetString("userID")
+	if userID == "" {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthenticated, "Unauthorized")
+		return "", false
+	}
+	if bodyUserID != "" && bodyUserID != userID {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "Request body user does not match the authenticated user")
+		return "", false
+	}
+	return userID, true
+}
+
+// errandParties returns the requester (owner), runner (assignee) and campus of an errand
+func (h *Handler) errandParties(ctx context.Context, errandID string) (policy.Resource, error) {
+	e, err := h.store.Errands.Get(ctx, errandID)
+	return policy.Resource{OwnerID: e.UserID, AssigneeID: e.RunnerID, CampusID: e.CampusID}, err
+}
@@ -1,63 +1,59 @@
 package handlers
 
 import (
-	"fmt"
 	"net/http"
+	"time"
 
-	"github.com/Woeter69/hackoverflow/internal/database"
-	"github.com/Woeter69/hackoverflow/internal/models"
+	"github.com/Woeter69/hackoverflow/internal/apierror"
+	"github.com/Woeter69/hackoverflow/internal/geo"
+	"github.com/Woeter69/hackoverflow/internal/metrics"
+	"github.com/Woeter69/hackoverflow/internal/middleware"
+	"github.com/Woeter69/hackoverflow/internal/policy"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 // FindMatchingErrands finds errands within a certain distance (buffer) of a travel plan's route
-func FindMatchingErrands(c *gin.Context) {
-	travelPlanIDStr := c.Param("id")
-	travelPlanID, err := uuid.Parse(travelPlanIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid travel plan ID"})
+func (h *Handler) FindMatchingErrands(c *gin.Context) {
+	ctx := c.Request.Context()
+	if _, err := uuid.Parse(c.Param("id")); err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid travel plan ID")
 		return
 	}
 
-	// Buffer distance in meters (default 200m)
-	buffer := 200.0
+	p, err := h.store.TravelPlans.Get(ctx, c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Travel plan not found")
+		return
+	}
+	if !policy.Can(middleware.Subject(c), policy.ActionViewTravelPlan, policy.Resource{OwnerID: p.UserID, CampusID: p.CampusID}) {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "You can only match errands against your own travel plans")
+		return
+	}
 
-	query := `
-		SELECT 
-			e.id, e.user_id, e.title, e.description, 
-			ST_Y(e.pickup_geom::geometry) as pickup_lat, ST_X(e.pickup_geom::geometry) as pickup_lng,
-			ST_Y(e.dropoff_geom::geometry) as dropoff_lat, ST_X(e.dropoff_geom::geometry) as dropoff_lng,
-			e.status, e.urgency_level, e.reward_estimate, e.created_at,
-			ST_Distance(e.pickup_geom, t.route_geom) as distance_from_route
-		FROM errand_requests e, travel_plans t
-		WHERE t.id = $1
-		  AND e.status = 'pending'
-		  AND ST_DWithin(e.pickup_geom, t.route_geom, $2)
-		ORDER BY distance_from_route ASC;
-	`
+	// Buffer distance in meters comes from the plan's campus (default 200m)
+	campus, err := h.store.Campuses.Get(ctx, p.CampusID)
+	if err != nil {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Campus not found")
+		return
+	}
 
-	rows, err := database.DB.Query(query, travelPlanID, buffer)
+	start := time.Now()
+	matches, err := h.store.Errands.NearRoute(ctx, p.CampusID, p.Route.Geo(), campus.DefaultBufferM)
+	metrics.MatchingDuration.Observe(time.Since(start).Seconds())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Query failed: %v", err)})
+		apierror.Internal(c, "NearRoute DB Error", err, "Failed to match errands")
 		return
 	}
-	defer rows.Close()
+	metrics.MatchingResults.Observe(float64(len(matches)))
 
-	var matches []models.MatchResponse
-	for rows.Next() {
-		var m models.MatchResponse
-		err := rows.Scan(
-			&m.Errand.ID, &m.Errand.UserID, &m.Errand.Title, &m.Errand.Description,
-			&m.Errand.Pickup.Lat, &m.Errand.Pickup.Lng,
-			&m.Errand.Dropoff.Lat, &m.Errand.Dropoff.Lng,
-			&m.Errand.Status, &m.Errand.UrgencyLevel, &m.Errand.RewardEstimate, &m.Errand.CreatedAt,
-			&m.DistanceFromRoute,
-		)
-		if err != nil {
-			continue
+	if wantsGeoJSON(c) {
+		features := make([]geo.Feature, len(matches))
+		for i, m := range matches {
+			features[i] = matchFeature(m)
 		}
-		matches = append(matches, m)
+		respondGeoJSON(c, http.StatusOK, geo.NewFeatureCollection(features))
+		return
 	}
-
 	c.JSON(http.StatusOK, matches)
 }
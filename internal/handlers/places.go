@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Woeter69/hackoverflow/internal/apierror"
+	"github.com/Woeter69/hackoverflow/internal/geo"
+	"github.com/Woeter69/hackoverflow/internal/middleware"
+	"github.com/Woeter69/hackoverflow/internal/models"
+	"github.com/Woeter69/hackoverflow/internal/policy"
+	"github.com/Woeter69/hackoverflow/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// placeSnapDistance is how close (meters) a raw coordinate must be to a place
+// for an errand or plan to be named after it
+const placeSnapDistance = 50.0
+
+type PlaceRequest struct {
+	CampusID  string            `json:"campus_id"` // defaults to the admin's own campus
+	Name      string            `json:"name" binding:"required"`
+	Kind      string            `json:"kind" binding:"required,oneof=building cafe gate hostel other"`
+	Code      string            `json:"code"`
+	Location  geo.RawGeometry   `json:"location"`  // WKT or GeoJSON Point; defaults to a point on the footprint
+	Footprint geo.RawGeometry   `json:"footprint"` // WKT or GeoJSON Polygon
+	Entrances []geo.RawGeometry `json:"entrances"` // WKT or GeoJSON Points
+}
+
+// loadPlace returns a place on the given campus
+func (h *Handler) loadPlace(ctx context.Context, campusID, placeID string) (models.Place, error) {
+	p, err := h.store.Places.Get(ctx, placeID)
+	if err == nil && p.CampusID != campusID {
+		return models.Place{}, store.ErrNotFound
+	}
+	return p, err
+}
+
+// namedEndpoint finds the place an errand or plan endpoint refers to: the
+// given place id, or else any place within placeSnapDistance of pt. Unknown
+// ids are added to the check.
+func (h *Handler) namedEndpoint(ctx context.Context, check *geometryCheck, campusID, placeField, placeID string, pt geo.Point) *models.Place {
+	if placeID != "" {
+		place, err := h.loadPlace(ctx, campusID, placeID)
+		if err != nil {
+			check.errors = append(check.errors, &geo.Error{Field: placeField, Code: "unknown_place", Message: "no place with that id on this campus"})
+			return nil
+		}
+		return &place
+	}
+	place, err := h.store.Places.Nearest(ctx, campusID, pt, placeSnapDistance, "")
+	if err != nil {
+		return nil
+	}
+	return &place
+}
+
+// resolveEndpoint works out where an errand starts or ends from a
+// coordinate, a place id, or both. A place id alone uses the place's location.
+func (h *Handler) resolveEndpoint(ctx context.Context, check *geometryCheck, campusID, field string, raw geo.RawGeometry, placeField, placeID string) (geo.Point, *models.Place) {
+	if placeID != "" && raw == "" {
+		place := h.namedEndpoint(ctx, check, campusID, placeField, placeID, geo.Point{})
+		if place == nil {
+			return geo.Point{}, nil
+		}
+		return place.Location.Geo(), place
+	}
+
+	before := len(check.errors)
+	pt := check.point(field, raw)
+	if len(check.errors) > before {
+		return pt, nil
+	}
+	return pt, h.namedEndpoint(ctx, check, campusID, placeField, placeID, pt)
+}
+
+func placeIDOf(p *models.Place) string {
+	if p == nil {
+		return ""
+	}
+	return p.ID
+}
+
+// SearchPlaces finds places on the caller's campus by name or code
+func (h *Handler) SearchPlaces(c *gin.Context) {
+	campusID, ok := callerCampus(c)
+	if !ok {
+		return
+	}
+
+	q := strings.ToLower(strings.TrimSpace(c.Query("q")))
+	kind := c.Query("kind")
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "limit must be between 1 and 100")
+		return
+	}
+
+	places, err := h.store.Places.Search(c.Request.Context(), store.PlaceSearch{CampusID: campusID, Query: q, Kind: kind, Limit: limit})
+	if err != nil {
+		apierror.Internal(c, "SearchPlaces DB Error", err, "Failed to search places")
+		return
+	}
+
+	if wantsGeoJSON(c) {
+		features := make([]geo.Feature, len(places))
+		for i, p := range places {
+			features[i] = placeFeature(p)
+		}
+		respondGeoJSON(c, http.StatusOK, geo.NewFeatureCollection(features))
+		return
+	}
+	c.JSON(http.StatusOK, places)
+}
+
+// NearestPlace reverse-geocodes a coordinate to the closest place on the caller's campus
+func (h *Handler) NearestPlace(c *gin.Context) {
+	campusID, ok := callerCampus(c)
+	if !ok {
+		return
+	}
+
+	lng, errLng := strconv.ParseFloat(c.Query("lng"), 64)
+	lat, errLat := strconv.ParseFloat(c.Query("lat"), 64)
+	if errLng != nil || errLat != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "lng and lat are required numbers")
+		return
+	}
+	pt, err := geo.ParsePoint(geo.Point{Lng: lng, Lat: lat}.WKT())
+	if err != nil {
+		apierror.RespondWith(c, http.StatusUnprocessableEntity, apierror.CodeInvalidGeometry, "Invalid geometry", gin.H{"details": []*geo.Error{geo.FieldError("lng/lat", err)}})
+		return
+	}
+	within := 0.0
+	if v := c.Query("within"); v != "" {
+		if within, err = strconv.ParseFloat(v, 64); err != nil || within < 0 {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "within must be a non-negative distance in meters")
+			return
+		}
+	}
+
+	place, err := h.store.Places.Nearest(c.Request.Context(), campusID, pt, within, c.Query("kind"))
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "No place nearby")
+			return
+		}
+		apierror.Internal(c, "NearestPlace DB Error", err, "Failed to look up place")
+		return
+	}
+
+	respondPlace(c, http.StatusOK, place)
+}
+
+// GetPlace returns a place on the caller's campus
+func (h *Handler) GetPlace(c *gin.Context) {
+	campusID, ok := callerCampus(c)
+	if !ok {
+		return
+	}
+
+	place, err := h.loadPlace(c.Request.Context(), campusID, c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Place not found")
+		return
+	}
+
+	respondPlace(c, http.StatusOK, place)
+}
+
+func respondPlace(c *gin.Context, status int, place models.Place) {
+	if wantsGeoJSON(c) {
+		respondGeoJSON(c, status, placeFeature(place))
+		return
+	}
+	c.JSON(status, place)
+}
+
+// bindPlace validates a place body against its campus. It returns the place
+// and its geometry; on failure the response has already been written.
+func (h *Handler) bindPlace(c *gin.Context) (place models.Place, shape store.PlaceShape, ok bool) {
+	var req PlaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.InvalidBody(c, err)
+		return
+	}
+
+	campusID := req.CampusID
+	if campusID == "" {
+		if campusID, ok = callerCampus(c); !ok {
+			return
+		}
+	} else if !policy.Can(middleware.Subject(c), policy.ActionManagePlaces, policy.Resource{CampusID: campusID}) {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeOtherCampus, "You cannot manage places on that campus")
+		return place, shape, false
+	}
+	campus, err := h.store.Campuses.Get(c.Request.Context(), campusID)
+	if err != nil {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Campus not found")
+		return place, shape, false
+	}
+
+	check := newGeometryCheck(campus)
+	if req.Location != "" {
+		location := check.point("location", req.Location)
+		shape.Location = &location
+	} else if req.Footprint == "" {
+		check.errors = append(check.errors, &geo.Error{Field: "location", Code: geo.CodeInvalidGeometry, Message: "location or footprint is required"})
+	}
+	if req.Footprint != "" {
+		shape.Footprint = check.polygon("footprint", req.Footprint)
+	}
+	for i, raw := range req.Entrances {
+		shape.Entrances = append(shape.Entrances, check.point(fmt.Sprintf("entrances[%d]", i), raw))
+	}
+	if check.respond(c) {
+		return place, shape, false
+	}
+	place = models.Place{CampusID: campusID, Name: req.Name, Kind: req.Kind, Code: req.Code}
+	return place, shape, true
+}
+
+func placeWriteError(c *gin.Context, err error) {
+	var invalid *store.InvalidError
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Place not found")
+	case errors.Is(err, store.ErrConflict):
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, "A place with that name already exists on this campus")
+	case errors.As(err, &invalid):
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Invalid place: "+invalid.Reason)
+	default:
+		apierror.Internal(c, "Place DB Error", err, "Failed to save place")
+	}
+}
+
+// CreatePlace adds a place to a campus (admin only)
+func (h *Handler) CreatePlace(c *gin.Context) {
+	p, shape, ok := h.bindPlace(c)
+	if !ok {
+		return
+	}
+
+	if err := h.store.Places.Create(c.Request.Context(), &p, shape); err != nil {
+		placeWriteError(c, err)
+		return
+	}
+
+	respondPlace(c, http.StatusCreated, p)
+}
+
+// UpdatePlace replaces a place's details (admin only)
+func (h *Handler) UpdatePlace(c *gin.Context) {
+	p, shape, ok := h.bindPlace(c)
+	if !ok {
+		return
+	}
+
+	p.ID = c.Param("id")
+	if err := h.store.Places.Update(c.Request.Context(), &p, shape); err != nil {
+		placeWriteError(c, err)
+		return
+	}
+
+	respondPlace(c, http.StatusOK, p)
+}
+
+// DeletePlace removes a place; errands and plans keep their coordinates (admin only)
+func (h *Handler) DeletePlace(c *gin.Context) {
+	subject := middleware.Subject(c)
+	ctx := c.Request.Context()
+
+	place, err := h.store.Places.Get(ctx, c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Place not found")
+		return
+	}
+	if !policy.Can(subject, policy.ActionManagePlaces, policy.Resource{CampusID: place.CampusID}) {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeOtherCampus, "You cannot manage places on that campus")
+		return
+	}
+
+	if err := h.store.Places.Delete(ctx, place.ID); err != nil {
+		apierror.Internal(c, "DeletePlace DB Error", err, "Failed to delete place")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "deleted", "id": c.Param("id")})
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/Woeter69/hackoverflow/internal/apierror"
+	"github.com/Woeter69/hackoverflow/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.]{3,30}$`)
+
+const maxDisplayNameLength = 100
+
+// UpdateProfileRequest holds the user-editable profile fields. Omitted fields are left unchanged.
+type UpdateProfileRequest struct {
+	Username    *string `json:"username"`
+	DisplayName *string `json:"display_name"`
+}
+
+// GetUserProfile returns the caller's profile. The row is created by the
+// ProvisionUser middleware from the verified token.
+func (h *Handler) GetUserProfile(c *gin.Context) {
+	userID := c.GetString("userID")
+	if userID == "" {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthenticated, "Unauthorized")
+		return
+	}
+
+	u, err := h.store.Users.Get(c.Request.Context(), userID)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "GetUserProfile DB Error", "err", err)
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Profile not found")
+		return
+	}
+	c.JSON(http.StatusOK, u)
+}
+
+// UpdateUserProfile edits the caller's username and display name
+func (h *Handler) UpdateUserProfile(c *gin.Context) {
+	userID := c.GetString("userID")
+	if userID == "" {
+		apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthenticated, "Unauthorized")
+		return
+	}
+
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.InvalidBody(c, err)
+		return
+	}
+
+	if req.Username != nil {
+		*req.Username = strings.TrimSpace(*req.Username)
+		if !usernamePattern.MatchString(*req.Username) {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Username must be 3-30 letters, digits, '_' or '.'")
+			return
+		}
+	}
+	if req.DisplayName != nil {
+		*req.DisplayName = strings.TrimSpace(*req.DisplayName)
+		if *req.DisplayName == "" || len(*req.DisplayName) > maxDisplayNameLength {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Display name must be between 1 and 100 characters")
+			return
+		}
+	}
+
+	u, err := h.store.Users.UpdateProfile(c.Request.Context(), userID, store.ProfileUpdate{Username: req.Username, DisplayName: req.DisplayName})
+	if err != nil {
+		if errors.Is(err, store.ErrConflict) {
+			apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, "Username is already taken")
+			return
+		}
+		if errors.Is(err, store.ErrNotFound) {
+			apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Profile not found")
+			return
+		}
+		apierror.Internal(c, "UpdateUserProfile DB Error", err, "Failed to update profile")
+		return
+	}
+
+	c.JSON(http.StatusOK, u)
+}
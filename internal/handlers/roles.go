@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/Woeter69/hackoverflow/internal/apierror"
+	"github.com/Woeter69/hackoverflow/internal/policy"
+	"github.com/Woeter69/hackoverflow/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+type GrantRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// GetUserRoles lists the roles stored for a user (admin only)
+func (h *Handler) GetUserRoles(c *gin.Context) {
+	targetID := c.Param("id")
+
+	roles, err := h.store.Users.Roles(c.Request.Context(), targetID)
+	if err != nil {
+		apierror.Internal(c, "GetUserRoles DB Error", err, "Failed to fetch roles")
+		return
+	}
+	if roles == nil {
+		roles = []policy.Role{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user_id": targetID, "roles": roles})
+}
+
+// GrantUserRole assigns a role to a user (admin only)
+func (h *Handler) GrantUserRole(c *gin.Context) {
+	targetID := c.Param("id")
+	var req GrantRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.InvalidBody(c, err)
+		return
+	}
+
+	role, ok := policy.ParseRole(req.Role)
+	if !ok || role == policy.RoleStudent {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Unknown role: "+req.Role)
+		return
+	}
+
+	userID := c.GetString("userID")
+	if err := h.store.Users.GrantRole(c.Request.Context(), targetID, role, actor(c)); errors.Is(err, store.ErrNotFound) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "User not found")
+		return
+	} else if err != nil {
+		apierror.Internal(c, "GrantUserRole DB Error", err, "Failed to grant role")
+		return
+	}
+
+	slog.InfoContext(c.Request.Context(), "Role granted", "role", role, "user_id", targetID, "by", userID)
+	c.JSON(http.StatusOK, gin.H{"status": "granted", "user_id": targetID, "role": role})
+}
+
+// RevokeUserRole removes a role from a user (admin only)
+func (h *Handler) RevokeUserRole(c *gin.Context) {
+	targetID := c.Param("id")
+	role, ok := policy.ParseRole(c.Param("role"))
+	if !ok {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "Unknown role: "+c.Param("role"))
+		return
+	}
+
+	userID := c.GetString("userID")
+	if targetID == userID && role == policy.RoleAdmin {
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, "Admins cannot revoke their own admin role")
+		return
+	}
+
+	if err := h.store.Users.RevokeRole(c.Request.Context(), targetID, role, actor(c)); errors.Is(err, store.ErrNotFound) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "User not found")
+		return
+	} else if err != nil {
+		apierror.Internal(c, "RevokeUserRole DB Error", err, "Failed to revoke role")
+		return
+	}
+
+	slog.InfoContext(c.Request.Context(), "Role revoked", "role", role, "user_id", targetID, "by", userID)
+	c.JSON(http.StatusOK, gin.H{"status": "revoked", "user_id": targetID, "role": role})
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"github.com/Woeter69/hackoverflow/internal/middleware"
+	"github.com/Woeter69/hackoverflow/internal/policy"
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes mounts every API endpoint on the given group. The group is
+// expected to already run AuthMiddleware.
+func (h *Handler) RegisterRoutes(api *gin.RouterGroup) {
+	api.POST("/travel-plans", h.CreateTravelPlan)
+	api.GET("/travel-plans", h.ListTravelPlans)
+	api.GET("/travel-plans.geojson", asGeoJSON(h.ListTravelPlans))
+	api.GET("/travel-plans/:id/matches", h.FindMatchingErrands)
+	api.GET("/travel-plans/:id/route.gpx", h.ExportTravelPlanGPX)
+	api.POST("/travel-plan-series", h.CreateTravelPlanSeries)
+	api.GET("/travel-plan-series", h.ListTravelPlanSeries)
+	api.GET("/travel-plan-series/:id", h.GetTravelPlanSeries)
+	api.PATCH("/travel-plan-series/:id", h.UpdateTravelPlanSeries)
+	api.DELETE("/travel-plan-series/:id", h.DeleteTravelPlanSeries)
+	api.POST("/errand-requests", h.CreateErrandRequest)
+	api.GET("/errand-requests", h.GetPendingErrands)
+	api.GET("/errand-requests.geojson", asGeoJSON(h.GetPendingErrands))
+	api.PUT("/errand-requests/:id/status", h.UpdateErrandStatus)
+	api.POST("/emergency", h.ToggleEmergency)
+	api.GET("/profile", h.GetUserProfile)
+	api.PATCH("/profile", h.UpdateUserProfile)
+	api.GET("/campus", h.GetCampus)
+	api.GET("/places/search", h.SearchPlaces)
+	api.GET("/places/nearest", h.NearestPlace)
+	api.GET("/places/:id", h.GetPlace)
+	api.POST("/routes", h.PlanRoute)
+	api.POST("/timetable/import", h.ImportTimetable)
+	api.POST("/calendar/feed", h.CreateCalendarFeed)
+	api.DELETE("/calendar/feed", h.RevokeCalendarFeed)
+	api.GET("/errand-requests/:id/chat", h.GetChatHistory)
+	api.POST("/errand-requests/:id/chat", h.SendMessage)
+	api.GET("/errand-requests/:id/history", h.GetErrandHistory)
+	api.GET("/errand-requests/:id/dispute", h.GetDispute)
+	api.POST("/errand-requests/:id/dispute", h.OpenDispute)
+	api.POST("/errand-requests/:id/dispute/statements", h.AddDisputeStatement)
+	api.POST("/errand-requests/:id/dispute/resolve", h.ResolveDispute)
+	api.GET("/runner-verification", h.GetRunnerVerification)
+	api.POST("/runner-verification", h.SubmitRunnerVerification)
+
+	// Admin Routes
+	admin := api.Group("/admin", middleware.RequireRole(policy.RoleAdmin))
+	{
+		admin.GET("/users/:id/roles", h.GetUserRoles)
+		admin.POST("/users/:id/roles", h.GrantUserRole)
+		admin.DELETE("/users/:id/roles/:role", h.RevokeUserRole)
+		admin.PUT("/users/:id/campus", h.AssignUserCampus)
+		admin.GET("/campuses", h.ListCampuses)
+		admin.POST("/campuses", h.CreateCampus)
+		admin.PUT("/campuses/:id", h.UpdateCampus)
+		admin.POST("/places", h.CreatePlace)
+		admin.PUT("/places/:id", h.UpdatePlace)
+		admin.DELETE("/places/:id", h.DeletePlace)
+		admin.GET("/email-domains", h.ListEmailDomains)
+		admin.POST("/email-domains", h.AddEmailDomain)
+		admin.DELETE("/email-domains/:domain", h.RemoveEmailDomain)
+		admin.GET("/runner-verifications", h.ListRunnerVerifications)
+		admin.GET("/runner-verifications/:id/document", h.GetRunnerVerificationDocument)
+		admin.POST("/runner-verifications/:id/review", h.ReviewRunnerVerification)
+		admin.GET("/audit", h.ListAuditLog)
+	}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Woeter69/hackoverflow/internal/apierror"
+	"github.com/Woeter69/hackoverflow/internal/geo"
+	"github.com/Woeter69/hackoverflow/internal/models"
+	"github.com/Woeter69/hackoverflow/internal/routing"
+	"github.com/gin-gonic/gin"
+)
+
+type RouteRequest struct {
+	Origin             geo.RawGeometry `json:"origin"`      // WKT or GeoJSON Point; optional with origin_place_id
+	Destination        geo.RawGeometry `json:"destination"` // WKT or GeoJSON Point; optional with destination_place_id
+	OriginPlaceID      string          `json:"origin_place_id,omitempty"`
+	DestinationPlaceID string          `json:"destination_place_id,omitempty"`
+	Mode               string          `json:"mode"` // walk (default), cycle, car, cab
+}
+
+// parseTravelMode validates a travel mode and returns the name to store. On
+// failure the response has already been written.
+func parseTravelMode(c *gin.Context, mode string) (string, routing.Mode, bool) {
+	routed, err := routing.ParseMode(mode)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "mode must be walk, cycle, car or cab")
+		return "", "", false
+	}
+	if mode == "" {
+		return string(routing.ModeWalk), routed, true
+	}
+	return strings.ToLower(strings.TrimSpace(mode)), routed, true
+}
+
+// routeBetween computes a path on the campus network, adding any failure to the check
+func (h *Handler) routeBetween(check *geometryCheck, campusID string, from, to geo.Point, mode routing.Mode) *routing.Route {
+	graph := h.networks[campusID]
+	if graph == nil {
+		check.errors = append(check.errors, &geo.Error{Field: "route_geom", Code: "routing_unavailable", Message: "no path network is loaded for this campus; send route_geom instead"})
+		return nil
+	}
+
+	route, err := graph.Route(from, to, mode)
+	switch {
+	case errors.Is(err, routing.ErrOffNetwork):
+		check.errors = append(check.errors, &geo.Error{Field: "route_geom", Code: "off_network", Message: err.Error()})
+		return nil
+	case err != nil:
+		check.errors = append(check.errors, &geo.Error{Field: "route_geom", Code: "no_route", Message: err.Error()})
+		return nil
+	}
+
+	check.line("route_geom", route.Path)
+	return route
+}
+
+// routeEndpoints resolves both ends of a route request and routes between them
+func (h *Handler) routeEndpoints(ctx context.Context, check *geometryCheck, campusID string, req RouteRequest, mode routing.Mode) (*routing.Route, *models.Place, *models.Place) {
+	from, originPlace := h.resolveEndpoint(ctx, check, campusID, "origin", req.Origin, "origin_place_id", req.OriginPlaceID)
+	to, destPlace := h.resolveEndpoint(ctx, check, campusID, "destination", req.Destination, "destination_place_id", req.DestinationPlaceID)
+	if len(check.errors) > 0 {
+		return nil, originPlace, destPlace
+	}
+	return h.routeBetween(check, campusID, from, to, mode), originPlace, destPlace
+}
+
+func placeName(p *models.Place) string {
+	if p == nil {
+		return ""
+	}
+	return p.Name
+}
+
+// PlanRoute computes a walking, cycling or driving route on the caller's campus
+func (h *Handler) PlanRoute(c *gin.Context) {
+	var req RouteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.InvalidBody(c, err)
+		return
+	}
+	campusID, ok := callerCampus(c)
+	if !ok {
+		return
+	}
+	_, mode, ok := parseTravelMode(c, req.Mode)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	campus, err := h.store.Campuses.Get(ctx, campusID)
+	if err != nil {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Campus not found")
+		return
+	}
+
+	check := newGeometryCheck(campus)
+	route, originPlace, destPlace := h.routeEndpoints(ctx, check, campusID, req, mode)
+	if check.respond(c) {
+		return
+	}
+
+	if wantsGeoJSON(c) {
+		respondGeoJSON(c, http.StatusOK, geo.NewFeature("", route.Path.Geometry(), map[string]interface{}{
+			"mode":             route.Mode,
+			"distance_m":       route.Distance,
+			"duration_s":       int(route.Duration.Seconds()),
+			"origin_name":      placeName(originPlace),
+			"destination_name": placeName(destPlace),
+		}))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"mode":             route.Mode,
+		"route_geom":       route.Path.WKT(),
+		"distance_m":       route.Distance,
+		"duration_s":       int(route.Duration.Seconds()),
+		"origin_name":      placeName(originPlace),
+		"destination_name": placeName(destPlace),
+	})
+}
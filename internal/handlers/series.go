@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/Woeter69/hackoverflow/internal/apierror"
+	"github.com/Woeter69/hackoverflow/internal/geo"
+	"github.com/Woeter69/hackoverflow/internal/middleware"
+	"github.com/Woeter69/hackoverflow/internal/models"
+	"github.com/Woeter69/hackoverflow/internal/policy"
+	"github.com/Woeter69/hackoverflow/internal/schedule"
+	"github.com/Woeter69/hackoverflow/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// SeriesHorizon is how far ahead the departures of recurring plans are
+// materialized as travel plans
+var SeriesHorizon = 14 * 24 * time.Hour
+
+// seriesPreview is how many upcoming departures a series response lists
+const seriesPreview = 5
+
+type CreateTravelPlanSeriesRequest struct {
+	CreateTravelPlanRequest
+	RRule          string    `json:"rrule" binding:"required"` // weekly RRULE, e.g. FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR
+	Start          time.Time `json:"start" binding:"required"` // first departure (RFC 3339)
+	Timezone       string    `json:"timezone"`                 // IANA zone departures follow; defaults to UTC
+	Exceptions     []string  `json:"exceptions"`               // YYYY-MM-DD dates without a departure
+	SeatsAvailable *int      `json:"seats_available"`
+}
+
+// UpdateTravelPlanSeriesRequest only changes the fields that are present
+type UpdateTravelPlanSeriesRequest struct {
+	RRule          *string    `json:"rrule"`
+	Start          *time.Time `json:"start"`
+	Timezone       *string    `json:"timezone"`
+	Exceptions     *[]string  `json:"exceptions"` // replaces the list; [] clears it
+	Paused         *bool      `json:"paused"`
+	SeatsAvailable *int       `json:"seats_available"`
+}
+
+// seriesSchedule validates a schedule and anchors it in its time zone
+func seriesSchedule(rrule string, start time.Time, timezone string, exceptions []string) (schedule.Series, error) {
+	rule, err := schedule.ParseRule(rrule)
+	if err != nil {
+		return schedule.Series{}, err
+	}
+	if timezone == "Local" {
+		return schedule.Series{}, fmt.Errorf("unknown timezone %q", timezone)
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return schedule.Series{}, fmt.Errorf("unknown timezone %q", timezone)
+	}
+	for _, d := range exceptions {
+		if _, err := time.Parse(schedule.DateLayout, d); err != nil {
+			return schedule.Series{}, fmt.Errorf("exceptions must be YYYY-MM-DD dates, got %q", d)
+		}
+	}
+	return schedule.Series{Rule: rule, Start: start.In(loc), Exceptions: exceptions}, nil
+}
+
+func storedSchedule(s models.TravelPlanSeries) (schedule.Series, error) {
+	return seriesSchedule(s.RRule, s.Start, s.Timezone, s.Exceptions)
+}
+
+// upcomingDepartures lists the departures of a series within the horizon after now
+func upcomingDepartures(s models.TravelPlanSeries, now time.Time) ([]time.Time, error) {
+	if s.Paused {
+		return nil, nil
+	}
+	sched, err := storedSchedule(s)
+	if err != nil {
+		return nil, err
+	}
+	return sched.Between(now, now.Add(SeriesHorizon)), nil
+}
+
+// materializeSeries creates the departures of a series within the horizon.
+// Departures that already exist are left alone, so it is safe to run
+// repeatedly and from several instances at once.
+func (h *Handler) materializeSeries(ctx context.Context, s models.TravelPlanSeries, now time.Time) (int64, error) {
+	departures, err := upcomingDepartures(s, now)
+	if err != nil || len(departures) == 0 {
+		return 0, err
+	}
+	return h.store.Series.Materialize(ctx, s, departures)
+}
+
+// reconcileSeries brings the upcoming departures of an edited series in line
+// with its schedule: departures no longer scheduled are removed, the rest
+// pick up the new seat count, and missing ones are created. Departures keep
+// their ids when the edit does not move them.
+func (h *Handler) reconcileSeries(ctx context.Context, s models.TravelPlanSeries, now time.Time) error {
+	departures, err := upcomingDepartures(s, now)
+	if err != nil {
+		return err
+	}
+	return h.store.Series.Reconcile(ctx, s, departures, now)
+}
+
+// MaterializeRecurringPlans tops up the departures of every active series
+// and returns how many travel plans it created
+func (h *Handler) MaterializeRecurringPlans(ctx context.Context, now time.Time) (int64, error) {
+	active, err := h.store.Series.ListActive(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var created int64
+	for _, s := range active {
+		n, err := h.materializeSeries(ctx, s, now)
+		if err != nil {
+			slog.ErrorContext(ctx, "Materialize series Error", "series_id", s.ID, "err", err)
+			continue
+		}
+		created += n
+	}
+	return created, nil
+}
+
+// RunSeriesScheduler materializes recurring plans immediately and then every
+// interval until ctx is done
+func (h *Handler) RunSeriesScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if n, err := h.MaterializeRecurringPlans(ctx, time.Now()); err != nil {
+			slog.ErrorContext(ctx, "Recurring plan scheduler Error", "err", err)
+		} else if n > 0 {
+			slog.InfoContext(ctx, "Materialized recurring travel plans", "count", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// withPreview lists the next few departures of an active series
+func withPreview(s models.TravelPlanSeries) models.TravelPlanSeries {
+	if s.Paused {
+		return s
+	}
+	if sched, err := storedSchedule(s); err == nil {
+		s.NextDepartures = sched.Next(time.Now(), seriesPreview)
+	}
+	return s
+}
+
+func respondSeries(c *gin.Context, status int, s models.TravelPlanSeries) {
+	s = withPreview(s)
+	if wantsGeoJSON(c) {
+		respondGeoJSON(c, status, seriesFeature(s))
+		return
+	}
+	c.JSON(status, s)
+}
+
+// authorizeSeries loads a series the caller may act on. On failure the
+// response has already been written.
+func (h *Handler) authorizeSeries(c *gin.Context, action policy.Action) (models.TravelPlanSeries, bool) {
+	s, err := h.store.Series.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Recurring plan not found")
+		return s, false
+	}
+	if !policy.Can(middleware.Subject(c), action, policy.Resource{OwnerID: s.UserID, CampusID: s.CampusID}) {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "You can only manage your own recurring plans")
+		return s, false
+	}
+	return s, true
+}
+
+// CreateTravelPlanSeries posts a recurring travel plan. The route is given as
+// for a single plan; the schedule is a weekly RRULE anchored at the first
+// departure.
+func (h *Handler) CreateTravelPlanSeries(c *gin.Context) {
+	var req CreateTravelPlanSeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.InvalidBody(c, err)
+		return
+	}
+	if !checkTrackOptions(c, &req.CreateTravelPlanRequest) {
+		return
+	}
+
+	userID, ok := authenticatedOwner(c, req.UserID)
+	if !ok {
+		return
+	}
+	campusID, ok := callerCampus(c)
+	if !ok {
+		return
+	}
+	mode, routingMode, ok := parseTravelMode(c, req.Mode)
+	if !ok {
+		return
+	}
+	sched, err := seriesSchedule(req.RRule, req.Start, req.Timezone, req.Exceptions)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+	if len(sched.Next(sched.Start, 1)) == 0 {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "The schedule has no departures")
+		return
+	}
+	seats := 1
+	if req.SeatsAvailable != nil {
+		if *req.SeatsAvailable < 0 {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "seats_available must not be negative")
+			return
+		}
+		seats = *req.SeatsAvailable
+	}
+	if req.Exceptions == nil {
+		req.Exceptions = []string{}
+	}
+
+	ctx := c.Request.Context()
+	campus, err := h.store.Campuses.Get(ctx, campusID)
+	if err != nil {
+		apierror.Internal(c, "CreateTravelPlanSeries Campus Error", err, "Failed to load campus")
+		return
+	}
+	check := newGeometryCheck(campus)
+	plan := h.planRoute(ctx, check, campusID, req.CreateTravelPlanRequest, routingMode)
+	if check.respond(c) {
+		return
+	}
+	originName, destName := plan.names()
+
+	s := models.TravelPlanSeries{
+		UserID:             userID,
+		CampusID:           campusID,
+		OriginName:         originName,
+		DestinationName:    destName,
+		OriginPlaceID:      placeIDOf(plan.originPlace),
+		DestinationPlaceID: placeIDOf(plan.destPlace),
+		Route:              models.LineStringFromGeo(plan.path),
+		Mode:               mode,
+		SeatsAvailable:     seats,
+		RRule:              sched.Rule.String(),
+		Start:              sched.Start,
+		Timezone:           sched.Start.Location().String(),
+		Exceptions:         req.Exceptions,
+	}
+	if err := h.store.Series.Create(ctx, &s); err != nil {
+		apierror.Internal(c, "CreateTravelPlanSeries DB Error", err, "Failed to create recurring plan")
+		return
+	}
+
+	if _, err := h.materializeSeries(ctx, s, time.Now()); err != nil {
+		// The scheduler will catch up on its next run
+		slog.ErrorContext(ctx, "Materialize series Error", "series_id", s.ID, "err", err)
+	}
+
+	respondSeries(c, http.StatusCreated, s)
+}
+
+// ListTravelPlanSeries returns the caller's recurring plans
+func (h *Handler) ListTravelPlanSeries(c *gin.Context) {
+	campusID, ok := callerCampus(c)
+	if !ok {
+		return
+	}
+
+	series, err := h.store.Series.List(c.Request.Context(), c.GetString("userID"), campusID)
+	if err != nil {
+		apierror.Internal(c, "ListTravelPlanSeries DB Error", err, "Failed to fetch recurring plans")
+		return
+	}
+	for i := range series {
+		series[i] = withPreview(series[i])
+	}
+
+	if wantsGeoJSON(c) {
+		features := make([]geo.Feature, len(series))
+		for i, s := range series {
+			features[i] = seriesFeature(s)
+		}
+		respondGeoJSON(c, http.StatusOK, geo.NewFeatureCollection(features))
+		return
+	}
+	c.JSON(http.StatusOK, series)
+}
+
+// GetTravelPlanSeries returns a recurring plan and its next departures
+func (h *Handler) GetTravelPlanSeries(c *gin.Context) {
+	s, ok := h.authorizeSeries(c, policy.ActionViewTravelPlan)
+	if !ok {
+		return
+	}
+	respondSeries(c, http.StatusOK, s)
+}
+
+// UpdateTravelPlanSeries edits the schedule, pauses or resumes a recurring
+// plan. Upcoming departures are adjusted to match.
+func (h *Handler) UpdateTravelPlanSeries(c *gin.Context) {
+	var req UpdateTravelPlanSeriesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.InvalidBody(c, err)
+		return
+	}
+	s, ok := h.authorizeSeries(c, policy.ActionManageTravelPlan)
+	if !ok {
+		return
+	}
+
+	if req.RRule != nil {
+		s.RRule = *req.RRule
+	}
+	if req.Start != nil {
+		s.Start = *req.Start
+	}
+	if req.Timezone != nil {
+		s.Timezone = *req.Timezone
+	}
+	if req.Exceptions != nil {
+		s.Exceptions = *req.Exceptions
+		if s.Exceptions == nil {
+			s.Exceptions = []string{}
+		}
+	}
+	if req.Paused != nil {
+		s.Paused = *req.Paused
+	}
+	if req.SeatsAvailable != nil {
+		if *req.SeatsAvailable < 0 {
+			apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "seats_available must not be negative")
+			return
+		}
+		s.SeatsAvailable = *req.SeatsAvailable
+	}
+	sched, err := storedSchedule(s)
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	ctx := c.Request.Context()
+	s.RRule, s.Start, s.Timezone = sched.Rule.String(), sched.Start, sched.Start.Location().String()
+	if err := h.store.Series.Update(ctx, &s); err != nil {
+		apierror.Internal(c, "UpdateTravelPlanSeries DB Error", err, "Failed to update recurring plan")
+		return
+	}
+
+	if err := h.reconcileSeries(ctx, s, time.Now()); err != nil {
+		apierror.Internal(c, "Reconcile series Error", err, "Failed to update upcoming departures")
+		return
+	}
+
+	respondSeries(c, http.StatusOK, s)
+}
+
+// DeleteTravelPlanSeries ends a recurring plan. Upcoming departures are
+// removed; past ones stay as ordinary travel plans.
+func (h *Handler) DeleteTravelPlanSeries(c *gin.Context) {
+	s, ok := h.authorizeSeries(c, policy.ActionManageTravelPlan)
+	if !ok {
+		return
+	}
+
+	err := h.store.Series.Delete(c.Request.Context(), s.ID, time.Now())
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		apierror.Internal(c, "DeleteTravelPlanSeries DB Error", err, "Failed to delete recurring plan")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "deleted", "id": s.ID})
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Woeter69/hackoverflow/internal/apierror"
+	"github.com/Woeter69/hackoverflow/internal/geo"
+	"github.com/Woeter69/hackoverflow/internal/middleware"
+	"github.com/Woeter69/hackoverflow/internal/models"
+	"github.com/Woeter69/hackoverflow/internal/policy"
+	"github.com/Woeter69/hackoverflow/internal/routing"
+	"github.com/gin-gonic/gin"
+)
+
+const gpxContentType = "application/gpx+xml"
+
+// maxTrackUpload bounds the size of a travel plan body, including GPX uploads
+const maxTrackUpload = 10 << 20
+
+// maxSimplifyTolerance caps the per-request simplification tolerance (meters)
+const maxSimplifyTolerance = 100.0
+
+// startTimeSkew is how far in the past a requested start_time may be
+const startTimeSkew = 5 * time.Minute
+
+// ListTravelPlans returns the caller's active travel plans with their routes
+func (h *Handler) ListTravelPlans(c *gin.Context) {
+	campusID, ok := callerCampus(c)
+	if !ok {
+		return
+	}
+
+	plans, err := h.store.TravelPlans.ListActive(c.Request.Context(), c.GetString("userID"), campusID)
+	if err != nil {
+		apierror.Internal(c, "ListTravelPlans DB Error", err, "Failed to fetch travel plans")
+		return
+	}
+
+	if wantsGeoJSON(c) {
+		features := make([]geo.Feature, len(plans))
+		for i, p := range plans {
+			features[i] = travelPlanFeature(p)
+		}
+		respondGeoJSON(c, http.StatusOK, geo.NewFeatureCollection(features))
+		return
+	}
+	c.JSON(http.StatusOK, plans)
+}
+
+// bindTravelPlan reads a plan from JSON, from a multipart form with an
+// optional "gpx" file, or from a raw GPX body with the other fields in the
+// query string. On failure the response has already been written.
+func bindTravelPlan(c *gin.Context) (CreateTravelPlanRequest, bool) {
+	var req CreateTravelPlanRequest
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxTrackUpload)
+
+	var err error
+	switch c.ContentType() {
+	case gpxContentType, "application/xml", "text/xml":
+		if err = c.ShouldBindQuery(&req); err == nil {
+			req.gpx, err = io.ReadAll(c.Request.Body)
+		}
+	case gin.MIMEMultipartPOSTForm:
+		if err = c.ShouldBind(&req); err == nil {
+			req.gpx, err = uploadedFile(c, "gpx")
+		}
+	default:
+		err = c.ShouldBindJSON(&req)
+	}
+	if err != nil {
+		apierror.InvalidBody(c, err)
+		return req, false
+	}
+	return req, checkTrackOptions(c, &req)
+}
+
+// checkTrackOptions validates and defaults the options for supplied routes.
+// On failure the response has already been written.
+func checkTrackOptions(c *gin.Context, req *CreateTravelPlanRequest) bool {
+	if req.PolylinePrecision == 0 {
+		req.PolylinePrecision = 5
+	}
+	if req.PolylinePrecision != 5 && req.PolylinePrecision != 6 {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "polyline_precision must be 5 or 6")
+		return false
+	}
+	if req.SimplifyTolerance == nil {
+		tolerance := geo.DefaultSimplifyTolerance
+		req.SimplifyTolerance = &tolerance
+	}
+	if *req.SimplifyTolerance < 0 || *req.SimplifyTolerance > maxSimplifyTolerance {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, fmt.Sprintf("simplify_tolerance_m must be between 0 and %.0f", maxSimplifyTolerance))
+		return false
+	}
+	return true
+}
+
+// uploadedFile returns the contents of a multipart file, or nil when the
+// field is absent
+func uploadedFile(c *gin.Context, field string) ([]byte, error) {
+	header, err := c.FormFile(field)
+	if errors.Is(err, http.ErrMissingFile) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	f, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// suppliedRoute parses the route the client sent, if any: a GPX track, an
+// encoded polyline, or WKT/GeoJSON. Recorded tracks are dense and jittery, so
+// the route is simplified before it is validated and stored.
+func suppliedRoute(check *geometryCheck, req CreateTravelPlanRequest) (geo.LineString, bool) {
+	var field string
+	var route geo.LineString
+	var err error
+	switch {
+	case len(req.gpx) > 0:
+		field = "gpx"
+		route, err = geo.ParseGPX(bytes.NewReader(req.gpx))
+	case req.RoutePolyline != "":
+		field = "route_polyline"
+		route, err = geo.ParsePolyline(req.RoutePolyline, req.PolylinePrecision)
+	case req.RouteGeom != "":
+		field = "route_geom"
+		route, err = geo.ParseLineString(string(req.RouteGeom))
+	default:
+		return nil, false
+	}
+	if err != nil {
+		check.fail(field, err)
+		return nil, true
+	}
+
+	route = geo.Simplify(route, *req.SimplifyTolerance)
+	if len(route) < 2 {
+		// The track never moved
+		check.fail(field, &geo.Error{Code: geo.CodeTooFewVertices, Message: "a line needs at least 2 distinct positions"})
+		return nil, true
+	}
+	check.line(field, route)
+	return route, true
+}
+
+// plannedRoute is a validated travel route and the places at either end
+type plannedRoute struct {
+	path        geo.LineString
+	originPlace *models.Place
+	destPlace   *models.Place
+}
+
+// names returns the origin and destination names, falling back to the
+// generic labels of the simplified UI
+func (p plannedRoute) names() (string, string) {
+	originName, destName := "Point A", "Point B"
+	if p.originPlace != nil {
+		originName = p.originPlace.Name
+	}
+	if p.destPlace != nil {
+		destName = p.destPlace.Name
+	}
+	return originName, destName
+}
+
+// planRoute works out a plan's route from a supplied track, or by routing
+// between its endpoints on the campus path network. Problems are added to
+// the check.
+func (h *Handler) planRoute(ctx context.Context, check *geometryCheck, campusID string, req CreateTravelPlanRequest, mode routing.Mode) plannedRoute {
+	var plan plannedRoute
+	route, supplied := suppliedRoute(check, req)
+	if supplied {
+		if len(check.errors) == 0 {
+			plan.path = route
+			plan.originPlace = h.namedEndpoint(ctx, check, campusID, "origin_place_id", req.OriginPlaceID, route[0])
+			plan.destPlace = h.namedEndpoint(ctx, check, campusID, "destination_place_id", req.DestinationPlaceID, route[len(route)-1])
+		}
+		return plan
+	}
+
+	// Only the endpoints were given: generate the route on the campus path network
+	var planned *routing.Route
+	planned, plan.originPlace, plan.destPlace = h.routeEndpoints(ctx, check, campusID, RouteRequest{
+		Origin:             req.OriginGeom,
+		Destination:        req.DestinationGeom,
+		OriginPlaceID:      req.OriginPlaceID,
+		DestinationPlaceID: req.DestinationPlaceID,
+	}, mode)
+	if planned != nil {
+		plan.path = planned.Path
+	}
+	return plan
+}
+
+// ExportTravelPlanGPX downloads a plan's route as a GPX track
+func (h *Handler) ExportTravelPlanGPX(c *gin.Context) {
+	p, err := h.store.TravelPlans.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Travel plan not found")
+		return
+	}
+	if !policy.Can(middleware.Subject(c), policy.ActionViewTravelPlan, policy.Resource{OwnerID: p.UserID, CampusID: p.CampusID}) {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "You can only export your own travel plans")
+		return
+	}
+	if len(p.Route) < 2 {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Travel plan has no route")
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := geo.WriteGPX(&buf, p.OriginName+" to "+p.DestinationName, p.StartTime, p.Route.Geo()); err != nil {
+		apierror.Internal(c, "ExportTravelPlanGPX Error", err, "Failed to export route")
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="travel-plan-%s.gpx"`, p.ID))
+	c.Data(http.StatusOK, gpxContentType, buf.Bytes())
+}
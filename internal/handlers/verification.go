@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Woeter69/hackoverflow/internal/apierror"
+	"github.com/Woeter69/hackoverflow/internal/middleware"
+	"github.com/Woeter69/hackoverflow/internal/models"
+	"github.com/Woeter69/hackoverflow/internal/policy"
+	"github.com/Woeter69/hackoverflow/internal/store"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const maxStudentIDSize = 5 << 20 // 5 MB
+
+// Accepted student ID document types and the extension they are stored with
+var studentIDTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"application/pdf": ".pdf",
+}
+
+type ReviewVerificationRequest struct {
+	Decision string `json:"decision" binding:"required,oneof=approve reject"`
+	Notes    string `json:"notes"`
+}
+
+// SubmitRunnerVerification uploads a student ID to apply for the verified runner tier
+func (h *Handler) SubmitRunnerVerification(c *gin.Context) {
+	userID := c.GetString("userID")
+	subject := middleware.Subject(c)
+	if !policy.Can(subject, policy.ActionApplyVerifiedRunner, policy.Resource{}) {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeEmailUnverified, "Verify your campus email before applying")
+		return
+	}
+	if subject.HasRole(policy.RoleVerifiedRunner) {
+		apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, "You are already a verified runner")
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxStudentIDSize+1<<20)
+	file, err := c.FormFile("student_id")
+	if err != nil {
+		apierror.Respond(c, http.StatusBadRequest, apierror.CodeInvalidRequest, "student_id file is required")
+		return
+	}
+	if file.Size > maxStudentIDSize {
+		apierror.Respond(c, http.StatusRequestEntityTooLarge, apierror.CodePayloadTooLarge, "Student ID must be 5 MB or smaller")
+		return
+	}
+	contentType := file.Header.Get("Content-Type")
+	ext, ok := studentIDTypes[contentType]
+	if !ok {
+		apierror.Respond(c, http.StatusUnsupportedMediaType, apierror.CodeUnsupportedMediaType, "Student ID must be a JPEG, PNG or PDF")
+		return
+	}
+
+	relPath := filepath.Join("student-ids", uuid.NewString()+ext)
+	dst := filepath.Join(h.uploadDir, relPath)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o750); err != nil {
+		apierror.Internal(c, "SubmitRunnerVerification Storage Error", err, "Failed to store document")
+		return
+	}
+	if err := c.SaveUploadedFile(file, dst); err != nil {
+		apierror.Internal(c, "SubmitRunnerVerification Storage Error", err, "Failed to store document")
+		return
+	}
+
+	v := models.RunnerVerification{UserID: userID, DocumentPath: relPath, DocumentType: contentType}
+	if err := h.store.Verifications.Submit(c.Request.Context(), &v); err != nil {
+		os.Remove(dst)
+		if errors.Is(err, store.ErrConflict) {
+			apierror.Respond(c, http.StatusConflict, apierror.CodeConflict, "You already have an application under review")
+			return
+		}
+		apierror.Internal(c, "SubmitRunnerVerification DB Error", err, "Failed to submit application")
+		return
+	}
+
+	c.JSON(http.StatusCreated, v)
+}
+
+// GetRunnerVerification returns the caller's most recent application
+func (h *Handler) GetRunnerVerification(c *gin.Context) {
+	userID := c.GetString("userID")
+
+	v, err := h.store.Verifications.Latest(c.Request.Context(), userID)
+	if errors.Is(err, store.ErrNotFound) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "No application found")
+		return
+	} else if err != nil {
+		apierror.Internal(c, "GetRunnerVerification DB Error", err, "Failed to fetch application")
+		return
+	}
+
+	c.JSON(http.StatusOK, v)
+}
+
+// ListRunnerVerifications lists applications, pending by default (admin only)
+func (h *Handler) ListRunnerVerifications(c *gin.Context) {
+	status := c.DefaultQuery("status", "pending")
+
+	list, err := h.store.Verifications.List(c.Request.Context(), status, 100)
+	if err != nil {
+		apierror.Internal(c, "ListRunnerVerifications DB Error", err, "Failed to fetch applications")
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// GetRunnerVerificationDocument streams the uploaded student ID (admin only)
+func (h *Handler) GetRunnerVerificationDocument(c *gin.Context) {
+	v, err := h.store.Verifications.Get(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Application not found")
+		return
+	}
+	relPath, contentType := v.DocumentPath, v.DocumentType
+
+	// Stored paths are generated server-side, but never serve outside the upload dir
+	if strings.Contains(relPath, "..") {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Document not found")
+		return
+	}
+	if contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+	c.File(filepath.Join(h.uploadDir, relPath))
+}
+
+// ReviewRunnerVerification approves or rejects an application. Approval grants
+// the verified_runner role (admin only).
+func (h *Handler) ReviewRunnerVerification(c *gin.Context) {
+	var req ReviewVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		apierror.InvalidBody(c, err)
+		return
+	}
+
+	reviewerID := c.GetString("userID")
+	if !policy.Can(middleware.Subject(c), policy.ActionReviewVerification, policy.Resource{}) {
+		apierror.Respond(c, http.StatusForbidden, apierror.CodeForbidden, "Only admins can review applications")
+		return
+	}
+
+	v, err := h.store.Verifications.Review(c.Request.Context(), c.Param("id"), req.Decision == "approve", req.Notes, actor(c))
+	if errors.Is(err, store.ErrNotFound) {
+		apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "No pending application with that ID")
+		return
+	} else if err != nil {
+		apierror.Internal(c, "ReviewRunnerVerification DB Error", err, "Failed to review application")
+		return
+	}
+	status := v.Status
+
+	slog.InfoContext(c.Request.Context(), "Runner verification reviewed", "verification_id", v.ID, "user_id", v.UserID, "status", status, "by", reviewerID)
+	if h.hub != nil {
+		h.hub.SendToUser(c.Request.Context(), v.UserID, "RUNNER_VERIFICATION_REVIEWED", gin.H{"id": v.ID, "status": status})
+	}
+
+	c.JSON(http.StatusOK, v)
+}
@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"github.com/Woeter69/hackoverflow/internal/websocket"
+	"github.com/gin-gonic/gin"
+)
+
+// ServeWebSocket upgrades the connection and joins the client to its
+// campus's broadcasts. It must run after AuthMiddleware and the provisioner,
+// so the user is the verified caller and the campus their membership.
+func (h *Handler) ServeWebSocket(c *gin.Context) {
+	campusID, ok := callerCampus(c)
+	if !ok {
+		return
+	}
+
+	websocket.ServeWs(h.hub, c, c.GetString("userID"), campusID)
+}
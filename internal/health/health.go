@@ -0,0 +1,185 @@
+// Package health answers container orchestrator probes. Liveness covers
+// only the process itself, so an outage of a shared dependency does not get
+// every instance restarted at once; readiness also covers the dependencies
+// requests need, and fails as soon as shutdown begins.
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultTimeout bounds a check that sets no timeout of its own
+const DefaultTimeout = 2 * time.Second
+
+// Statuses of a report. A degraded server still takes traffic: only checks
+// that are not critical are failing.
+const (
+	StatusOK           = "ok"
+	StatusDegraded     = "degraded"
+	StatusUnavailable  = "unavailable"
+	StatusShuttingDown = "shutting_down"
+)
+
+// Statuses of a single check
+const (
+	CheckPassing = "pass"
+	CheckFailing = "fail"
+)
+
+// Check probes one dependency
+type Check struct {
+	Name string
+	// Critical checks make the server unavailable when they fail; the
+	// others only degrade it
+	Critical bool
+	Timeout  time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Result is the outcome of one check
+type Result struct {
+	Status    string  `json:"status"`
+	Critical  bool    `json:"critical"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the outcome of a probe
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Checker runs the liveness and readiness checks
+type Checker struct {
+	// stopping is done once shutdown begins
+	stopping context.Context
+
+	mu    sync.RWMutex
+	live  []Check
+	ready []Check
+}
+
+// New returns a checker that reports not ready once ctx is done
+func New(ctx context.Context) *Checker {
+	return &Checker{stopping: ctx}
+}
+
+// Live adds a check of the process itself. Liveness checks are part of
+// readiness too.
+func (c *Checker) Live(check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.live = append(c.live, check)
+}
+
+// Ready adds a check of a dependency
+func (c *Checker) Ready(check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ready = append(c.ready, check)
+}
+
+// Liveness runs the liveness checks
+func (c *Checker) Liveness(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := append([]Check(nil), c.live...)
+	c.mu.RUnlock()
+	return run(ctx, checks)
+}
+
+// Readiness runs every check, unless shutdown has begun
+func (c *Checker) Readiness(ctx context.Context) Report {
+	if c.stopping.Err() != nil {
+		return Report{Status: StatusShuttingDown, Checks: map[string]Result{}}
+	}
+	c.mu.RLock()
+	checks := append(append([]Check(nil), c.live...), c.ready...)
+	c.mu.RUnlock()
+	return run(ctx, checks)
+}
+
+// ServeLive handles GET /livez
+func (c *Checker) ServeLive(ctx *gin.Context) {
+	respond(ctx, c.Liveness(ctx.Request.Context()))
+}
+
+// ServeReady handles GET /readyz
+func (c *Checker) ServeReady(ctx *gin.Context) {
+	respond(ctx, c.Readiness(ctx.Request.Context()))
+}
+
+// respond answers 200 while the server can take traffic and 503 otherwise
+func respond(ctx *gin.Context, report Report) {
+	code := http.StatusOK
+	if report.Status != StatusOK && report.Status != StatusDegraded {
+		code = http.StatusServiceUnavailable
+	}
+	ctx.Header("Cache-Control", "no-store")
+	ctx.JSON(code, report)
+}
+
+// run runs the checks concurrently, each under its own timeout
+func run(ctx context.Context, checks []Check) Report {
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+			result := probe(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[check.Name] = result
+			if result.Status == CheckPassing {
+				return
+			}
+			if check.Critical {
+				report.Status = StatusUnavailable
+			} else if report.Status == StatusOK {
+				report.Status = StatusDegraded
+			}
+		}(check)
+	}
+	wg.Wait()
+	return report
+}
+
+// probe runs one check and times it
+func probe(ctx context.Context, check Check) Result {
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// A check that ignores its context is abandoned at the timeout
+	errc := make(chan error, 1)
+	start := time.Now()
+	go func() { errc <- check.Run(ctx) }()
+	var err error
+	select {
+	case err = <-errc:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := Result{
+		Status:    CheckPassing,
+		Critical:  check.Critical,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = CheckFailing
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,98 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func passing(context.Context) error { return nil }
+
+func failing(context.Context) error { return errors.New("connection refused") }
+
+func serve(t *testing.T, handler gin.HandlerFunc) (int, Report) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", handler)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var report Report
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	return w.Code, report
+}
+
+func TestReadinessStates(t *testing.T) {
+	c := New(context.Background())
+	c.Live(Check{Name: "hub", Critical: true, Run: passing})
+	c.Ready(Check{Name: "postgres", Critical: true, Run: passing})
+	redis := Check{Name: "redis", Run: failing}
+	c.Ready(redis)
+
+	// A failing optional dependency degrades the server but keeps it in rotation
+	code, report := serve(t, c.ServeReady)
+	if code != http.StatusOK || report.Status != StatusDegraded {
+		t.Fatalf("expected a degraded 200, got %d %s", code, report.Status)
+	}
+	if got := report.Checks["redis"]; got.Status != CheckFailing || got.Error != "connection refused" || got.Critical {
+		t.Fatalf("unexpected redis result %+v", got)
+	}
+	if len(report.Checks) != 3 {
+		t.Fatalf("readiness should include the liveness checks, got %v", report.Checks)
+	}
+
+	// A failing critical dependency takes it out
+	c.Ready(Check{Name: "postgis", Critical: true, Run: failing})
+	if code, report := serve(t, c.ServeReady); code != http.StatusServiceUnavailable || report.Status != StatusUnavailable {
+		t.Fatalf("expected an unavailable 503, got %d %s", code, report.Status)
+	}
+
+	// Liveness ignores the dependencies
+	if code, report := serve(t, c.ServeLive); code != http.StatusOK || report.Status != StatusOK || len(report.Checks) != 1 {
+		t.Fatalf("expected a live 200 with only the hub, got %d %+v", code, report)
+	}
+}
+
+func TestSlowCheckTimesOut(t *testing.T) {
+	c := New(context.Background())
+	c.Live(Check{Name: "hub", Critical: true, Timeout: 20 * time.Millisecond, Run: func(context.Context) error {
+		select {} // ignores its context
+	}})
+
+	start := time.Now()
+	code, report := serve(t, c.ServeLive)
+	if time.Since(start) > time.Second {
+		t.Fatal("the probe waited past the check's timeout")
+	}
+	got := report.Checks["hub"]
+	if code != http.StatusServiceUnavailable || got.Error != context.DeadlineExceeded.Error() || got.LatencyMS < 20 {
+		t.Fatalf("expected the hub to time out after 20ms, got %d %+v", code, got)
+	}
+}
+
+func TestNotReadyOnceShutdownBegins(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := New(ctx)
+	c.Ready(Check{Name: "postgres", Critical: true, Run: passing})
+	if code, _ := serve(t, c.ServeReady); code != http.StatusOK {
+		t.Fatalf("expected ready, got %d", code)
+	}
+
+	cancel()
+	if code, report := serve(t, c.ServeReady); code != http.StatusServiceUnavailable || report.Status != StatusShuttingDown {
+		t.Fatalf("expected not ready while shutting down, got %d %s", code, report.Status)
+	}
+	// The process is still alive while it drains
+	if code, _ := serve(t, c.ServeLive); code != http.StatusOK {
+		t.Fatalf("expected live while shutting down, got %d", code)
+	}
+}
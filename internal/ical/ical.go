@@ -0,0 +1,452 @@
+// Package ical reads and writes the subset of iCalendar (RFC 5545) used by
+// timetable exports and calendar subscriptions: VEVENTs with a time span, a
+// location and an optional weekly recurrence.
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Woeter69/hackoverflow/internal/schedule"
+)
+
+var ErrInvalid = errors.New("invalid iCalendar data")
+
+// MaxEvents bounds how many events Parse reads from one calendar
+var MaxEvents = 5000
+
+// Event is a VEVENT
+type Event struct {
+	UID         string
+	Summary     string
+	Location    string
+	Description string
+	URL         string
+	Start       time.Time
+	End         time.Time
+	AllDay      bool
+	RRule       string      // RRULE value, without the "RRULE:" prefix
+	ExDates     []time.Time // excluded occurrences
+	Stamp       time.Time   // DTSTAMP; written as the current time when zero
+}
+
+func invalid(line int, format string, args ...interface{}) error {
+	return fmt.Errorf("%w: line %d: %s", ErrInvalid, line, fmt.Sprintf(format, args...))
+}
+
+// contentLine is one unfolded "NAME;PARAM=VALUE:value" line
+type contentLine struct {
+	number int
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Parse reads the events of a VCALENDAR. Floating times (no TZID and no
+// trailing Z) and times in zones this build does not know, such as Windows
+// zone names, are read in the floating location. Cancelled events are dropped.
+func Parse(r io.Reader, floating *time.Location) ([]Event, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) == 0 || lines[0].name != "BEGIN" || !strings.EqualFold(lines[0].value, "VCALENDAR") {
+		return nil, fmt.Errorf("%w: expected BEGIN:VCALENDAR", ErrInvalid)
+	}
+
+	var events []Event
+	var stack []string
+	var ev *Event
+	var duration string
+	cancelled := false
+	for _, l := range lines {
+		switch l.name {
+		case "BEGIN":
+			stack = append(stack, strings.ToUpper(l.value))
+			if len(stack) == 2 && stack[1] == "VEVENT" {
+				ev, duration, cancelled = &Event{}, "", false
+			}
+			continue
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1] != strings.ToUpper(l.value) {
+				return nil, invalid(l.number, "unexpected END:%s", l.value)
+			}
+			if len(stack) == 2 && ev != nil {
+				if err := finish(ev, duration, l.number); err != nil {
+					return nil, err
+				}
+				if !cancelled {
+					if len(events) >= MaxEvents {
+						return nil, fmt.Errorf("%w: more than %d events", ErrInvalid, MaxEvents)
+					}
+					events = append(events, *ev)
+				}
+				ev = nil
+			}
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		// Only properties of the event itself, not of its alarms
+		if ev == nil || len(stack) != 2 {
+			continue
+		}
+		switch l.name {
+		case "UID":
+			ev.UID = l.value
+		case "SUMMARY":
+			ev.Summary = unescape(l.value)
+		case "LOCATION":
+			ev.Location = unescape(l.value)
+		case "DESCRIPTION":
+			ev.Description = unescape(l.value)
+		case "URL":
+			ev.URL = l.value
+		case "STATUS":
+			cancelled = strings.EqualFold(l.value, "CANCELLED")
+		case "DTSTART":
+			t, allDay, err := parseTime(l, floating)
+			if err != nil {
+				return nil, err
+			}
+			ev.Start, ev.AllDay = t, allDay
+		case "DTEND":
+			t, _, err := parseTime(l, floating)
+			if err != nil {
+				return nil, err
+			}
+			ev.End = t
+		case "DURATION":
+			duration = l.value
+		case "DTSTAMP":
+			if t, _, err := parseTime(l, floating); err == nil {
+				ev.Stamp = t
+			}
+		case "RRULE":
+			ev.RRule = l.value
+		case "EXDATE":
+			for _, v := range strings.Split(l.value, ",") {
+				t, _, err := parseTime(contentLine{number: l.number, params: l.params, value: v}, floating)
+				if err != nil {
+					return nil, err
+				}
+				ev.ExDates = append(ev.ExDates, t)
+			}
+		}
+	}
+	if len(stack) != 0 {
+		return nil, fmt.Errorf("%w: missing END:%s", ErrInvalid, stack[len(stack)-1])
+	}
+	return events, nil
+}
+
+// finish checks an event and fills in its end
+func finish(ev *Event, duration string, line int) error {
+	if ev.Start.IsZero() {
+		return invalid(line, "event %q has no DTSTART", ev.Summary)
+	}
+	switch {
+	case !ev.End.IsZero():
+	case duration != "":
+		d, err := parseDuration(duration)
+		if err != nil {
+			return invalid(line, "%v", err)
+		}
+		ev.End = ev.Start.Add(d)
+	case ev.AllDay:
+		ev.End = ev.Start.AddDate(0, 0, 1)
+	default:
+		ev.End = ev.Start
+	}
+	if ev.End.Before(ev.Start) {
+		return invalid(line, "event %q ends before it starts", ev.Summary)
+	}
+	return nil
+}
+
+// unfold splits the input into content lines, joining folded continuations
+func unfold(r io.Reader) ([]contentLine, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+
+	var raw []string
+	var numbers []int
+	n := 0
+	for scanner.Scan() {
+		n++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if n == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if text == "" {
+			continue
+		}
+		if (text[0] == ' ' || text[0] == '\t') && len(raw) > 0 {
+			raw[len(raw)-1] += text[1:]
+			continue
+		}
+		raw = append(raw, text)
+		numbers = append(numbers, n)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+
+	lines := make([]contentLine, 0, len(raw))
+	for i, text := range raw {
+		l, err := parseLine(text, numbers[i])
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+	return lines, nil
+}
+
+// parseLine splits a content line, honouring quoted parameter values
+func parseLine(text string, number int) (contentLine, error) {
+	l := contentLine{number: number, params: map[string]string{}}
+	quoted := false
+	colon := -1
+	for i, r := range text {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return l, invalid(number, "expected NAME:VALUE")
+	}
+	l.value = text[colon+1:]
+
+	head := text[:colon]
+	var parts []string
+	start := 0
+	quoted = false
+	for i, r := range head {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ';' && !quoted {
+			parts = append(parts, head[start:i])
+			start = i + 1
+		}
+	}
+	parts = append(parts, head[start:])
+
+	l.name = strings.ToUpper(parts[0])
+	for _, p := range parts[1:] {
+		key, value, _ := strings.Cut(p, "=")
+		l.params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+	}
+	return l, nil
+}
+
+func parseTime(l contentLine, floating *time.Location) (time.Time, bool, error) {
+	v := strings.TrimSpace(l.value)
+	if l.params["VALUE"] == "DATE" || len(v) == 8 {
+		t, err := time.ParseInLocation("20060102", v, floating)
+		if err != nil {
+			return time.Time{}, false, invalid(l.number, "bad date %q", v)
+		}
+		return t, true, nil
+	}
+	if strings.HasSuffix(v, "Z") {
+		t, err := time.Parse("20060102T150405Z", v)
+		if err != nil {
+			return time.Time{}, false, invalid(l.number, "bad time %q", v)
+		}
+		return t, false, nil
+	}
+	loc := floating
+	if tzid := strings.TrimPrefix(l.params["TZID"], "/"); tzid != "" {
+		if zone, err := time.LoadLocation(tzid); err == nil && tzid != "Local" {
+			loc = zone
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", v, loc)
+	if err != nil {
+		return time.Time{}, false, invalid(l.number, "bad time %q", v)
+	}
+	return t, false, nil
+}
+
+// parseDuration reads durations such as PT1H30M, P1D or P2W
+func parseDuration(v string) (time.Duration, error) {
+	s := strings.TrimPrefix(v, "+")
+	if !strings.HasPrefix(s, "P") {
+		return 0, fmt.Errorf("bad duration %q", v)
+	}
+	s = s[1:]
+	var d time.Duration
+	inTime := false
+	num := ""
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			num += string(r)
+			continue
+		case r == 'T':
+			inTime = true
+			continue
+		}
+		n, err := strconv.Atoi(num)
+		if err != nil {
+			return 0, fmt.Errorf("bad duration %q", v)
+		}
+		num = ""
+		switch {
+		case r == 'W' && !inTime:
+			d += time.Duration(n) * 7 * 24 * time.Hour
+		case r == 'D' && !inTime:
+			d += time.Duration(n) * 24 * time.Hour
+		case r == 'H' && inTime:
+			d += time.Duration(n) * time.Hour
+		case r == 'M' && inTime:
+			d += time.Duration(n) * time.Minute
+		case r == 'S' && inTime:
+			d += time.Duration(n) * time.Second
+		default:
+			return 0, fmt.Errorf("bad duration %q", v)
+		}
+	}
+	if num != "" {
+		return 0, fmt.Errorf("bad duration %q", v)
+	}
+	return d, nil
+}
+
+func unescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// Occurrences returns the start of each occurrence of the event in
+// [from, to), in order. Recurring events must use a weekly rule.
+func (e Event) Occurrences(from, to time.Time) ([]time.Time, error) {
+	if e.RRule == "" {
+		if !e.Start.Before(from) && e.Start.Before(to) {
+			return []time.Time{e.Start}, nil
+		}
+		return nil, nil
+	}
+	rule, err := schedule.ParseRule(e.RRule)
+	if err != nil {
+		return nil, err
+	}
+	series := schedule.Series{Rule: rule, Start: e.Start}
+	for _, x := range e.ExDates {
+		series.Exceptions = append(series.Exceptions, x.In(e.Start.Location()).Format(schedule.DateLayout))
+	}
+	return series.Between(from, to), nil
+}
+
+// Calendar is a VCALENDAR to publish
+type Calendar struct {
+	Name   string // shown by calendar apps as the subscription title
+	Events []Event
+}
+
+// Write renders the calendar with CRLF line endings and folded lines
+func (cal Calendar) Write(w io.Writer) error {
+	out := &writer{w: bufio.NewWriter(w)}
+	out.line("BEGIN:VCALENDAR")
+	out.line("VERSION:2.0")
+	out.line("PRODID:-//CampusLoop//Calendar//EN")
+	out.line("CALSCALE:GREGORIAN")
+	out.line("METHOD:PUBLISH")
+	if cal.Name != "" {
+		out.line("X-WR-CALNAME:" + escape(cal.Name))
+	}
+
+	events := append([]Event(nil), cal.Events...)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+	now := time.Now()
+	for _, e := range events {
+		stamp := e.Stamp
+		if stamp.IsZero() {
+			stamp = now
+		}
+		out.line("BEGIN:VEVENT")
+		out.line("UID:" + e.UID)
+		out.line("DTSTAMP:" + utc(stamp))
+		if e.AllDay {
+			out.line("DTSTART;VALUE=DATE:" + e.Start.Format("20060102"))
+			out.line("DTEND;VALUE=DATE:" + e.End.Format("20060102"))
+		} else {
+			out.line("DTSTART:" + utc(e.Start))
+			out.line("DTEND:" + utc(e.End))
+		}
+		out.line("SUMMARY:" + escape(e.Summary))
+		if e.Location != "" {
+			out.line("LOCATION:" + escape(e.Location))
+		}
+		if e.Description != "" {
+			out.line("DESCRIPTION:" + escape(e.Description))
+		}
+		if e.URL != "" {
+			out.line("URL:" + e.URL)
+		}
+		if e.RRule != "" {
+			out.line("RRULE:" + e.RRule)
+		}
+		out.line("END:VEVENT")
+	}
+	out.line("END:VCALENDAR")
+	if out.err != nil {
+		return out.err
+	}
+	return out.w.Flush()
+}
+
+func utc(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+type writer struct {
+	w   *bufio.Writer
+	err error
+}
+
+// line writes a content line folded at 75 octets without splitting UTF-8
+// sequences
+func (w *writer) line(s string) {
+	if w.err != nil {
+		return
+	}
+	limit := 75
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && s[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		_, w.err = w.w.WriteString(s[:cut] + "\r\n ")
+		s = s[cut:]
+		limit = 74 // continuation lines start with a space
+	}
+	_, w.err = w.w.WriteString(s + "\r\n")
+}
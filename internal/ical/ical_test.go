@@ -0,0 +1,138 @@
+package ical
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// A timetable export: a weekly lecture with a holiday excluded, a one-off
+// lab given as a duration, and a cancelled seminar
+const timetable = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"PRODID:-//University//Timetable//EN\r\n" +
+	"BEGIN:VTIMEZONE\r\n" +
+	"TZID:Asia/Kolkata\r\n" +
+	"BEGIN:STANDARD\r\n" +
+	"DTSTART:19700101T000000\r\n" +
+	"TZOFFSETFROM:+0530\r\n" +
+	"TZOFFSETTO:+0530\r\n" +
+	"END:STANDARD\r\n" +
+	"END:VTIMEZONE\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:cs101@university.edu\r\n" +
+	"SUMMARY:CS101 Lecture\\, Section A\r\n" +
+	"LOCATION:Main Library\r\n" +
+	"DESCRIPTION:Bring your laptop.\\nRoom 2\r\n" +
+	" 04\r\n" +
+	"DTSTART;TZID=Asia/Kolkata:20261019T090000\r\n" +
+	"DTEND;TZID=Asia/Kolkata:20261019T100000\r\n" +
+	"RRULE:FREQ=WEEKLY;WKST=SU;BYDAY=MO,WE\r\n" +
+	"EXDATE;TZID=Asia/Kolkata:20261021T090000\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"TRIGGER:-PT10M\r\n" +
+	"DESCRIPTION:Reminder\r\n" +
+	"END:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:lab@university.edu\r\n" +
+	"SUMMARY:Physics Lab\r\n" +
+	"LOCATION:Science Block\r\n" +
+	"DTSTART:20261019T053000Z\r\n" +
+	"DURATION:PT1H30M\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:seminar@university.edu\r\n" +
+	"SUMMARY:Seminar\r\n" +
+	"STATUS:CANCELLED\r\n" +
+	"DTSTART:20261020T090000\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseTimetable(t *testing.T) {
+	events, err := Parse(strings.NewReader(timetable), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2 (the seminar is cancelled)", len(events))
+	}
+
+	lecture := events[0]
+	if lecture.Summary != "CS101 Lecture, Section A" || lecture.Description != "Bring your laptop.\nRoom 204" {
+		t.Fatalf("text not unescaped and unfolded: %q / %q", lecture.Summary, lecture.Description)
+	}
+	if lecture.Start.Location().String() != "Asia/Kolkata" || lecture.Start.Hour() != 9 || lecture.End.Sub(lecture.Start) != time.Hour {
+		t.Fatalf("lecture runs %v to %v", lecture.Start, lecture.End)
+	}
+
+	lab := events[1]
+	if lab.End.Sub(lab.Start) != 90*time.Minute || !lab.Start.Equal(time.Date(2026, 10, 19, 5, 30, 0, 0, time.UTC)) {
+		t.Fatalf("lab runs %v to %v", lab.Start, lab.End)
+	}
+
+	ist, _ := time.LoadLocation("Asia/Kolkata")
+	from := time.Date(2026, 10, 19, 0, 0, 0, 0, ist)
+	got, err := lecture.Occurrences(from, from.AddDate(0, 0, 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Monday, then Monday again: Wednesday the 21st is excluded
+	if len(got) != 1 || got[0].Day() != 19 {
+		t.Fatalf("occurrences %v", got)
+	}
+	got, _ = lecture.Occurrences(from, from.AddDate(0, 0, 10))
+	if len(got) != 3 || got[1].Day() != 26 || got[2].Day() != 28 {
+		t.Fatalf("occurrences %v", got)
+	}
+}
+
+func TestParseRejectsMalformedCalendars(t *testing.T) {
+	for name, input := range map[string]string{
+		"not a calendar": "BEGIN:VCARD\r\nEND:VCARD\r\n",
+		"unterminated":   "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART:20261019T090000Z\r\n",
+		"no start":       "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nSUMMARY:x\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+		"bad time":       "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART:tomorrow\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+	} {
+		if _, err := Parse(strings.NewReader(input), time.UTC); !errors.Is(err, ErrInvalid) {
+			t.Errorf("%s: %v, want ErrInvalid", name, err)
+		}
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	start := time.Date(2026, 10, 19, 3, 30, 0, 0, time.UTC)
+	cal := Calendar{Name: "CampusLoop", Events: []Event{{
+		UID:      "plan-1@campusloop",
+		Summary:  "Trip: Main Library → Science Block; walk, 2 seats",
+		Location: "Main Library",
+		Start:    start,
+		End:      start.Add(15 * time.Minute),
+		Description: strings.Repeat("A long description that calendar apps will need unfolded. ", 3) +
+			"Ünïcödé at the fold",
+	}}}
+
+	var buf bytes.Buffer
+	if err := cal.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Fatalf("line longer than 75 octets: %q", line)
+		}
+	}
+
+	events, err := Parse(&buf, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("got %d events", len(events))
+	}
+	e := events[0]
+	if e.Summary != cal.Events[0].Summary || e.Description != cal.Events[0].Description || !e.Start.Equal(start) || e.End.Sub(e.Start) != 15*time.Minute {
+		t.Fatalf("round trip changed the event: %+v", e)
+	}
+}
@@ -0,0 +1,116 @@
+// Package lifecycle starts the server's components and stops them in
+// reverse order. Background workers run until shutdown begins; stop hooks
+// then run newest first under one deadline, so the HTTP server drains
+// before the hub, Redis and the database it depends on are closed.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// hook is a named step of the shutdown
+type hook struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// Manager tracks what has been started
+type Manager struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	hooks    []hook
+	failure  error
+	stopOnce sync.Once
+	stopErr  error
+}
+
+// New returns a manager whose workers run until parent is done, a worker
+// fails or Shutdown is called
+func New(parent context.Context) *Manager {
+	ctx, cancel := context.WithCancel(parent)
+	return &Manager{ctx: ctx, cancel: cancel}
+}
+
+// Context is done once shutdown begins
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// OnStop registers a step to run at shutdown. Steps run in the reverse of
+// the order they were registered.
+func (m *Manager) OnStop(name string, stop func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, hook{name: name, stop: stop})
+}
+
+// Go runs a worker with the manager's context. A worker that fails begins
+// the shutdown; shutdown waits for the worker to return at the point it was
+// started.
+func (m *Manager) Go(name string, run func(ctx context.Context) error) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := run(m.ctx); err != nil && !errors.Is(err, context.Canceled) {
+			m.mu.Lock()
+			if m.failure == nil {
+				m.failure = fmt.Errorf("%s: %w", name, err)
+			}
+			m.mu.Unlock()
+			m.cancel()
+		}
+	}()
+	m.OnStop(name, func(ctx context.Context) error {
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// Wait blocks until shutdown should begin and returns the failure that
+// caused it, if a worker failed
+func (m *Manager) Wait() error {
+	<-m.ctx.Done()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.failure
+}
+
+// Shutdown cancels the workers and runs the stop steps, newest first,
+// within timeout. Every step runs even if an earlier one fails or the
+// deadline passes. Calling it again returns the first result.
+func (m *Manager) Shutdown(timeout time.Duration) error {
+	m.stopOnce.Do(func() {
+		m.cancel()
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		m.mu.Lock()
+		hooks := m.hooks
+		m.mu.Unlock()
+
+		var errs []error
+		for i := len(hooks) - 1; i >= 0; i-- {
+			h := hooks[i]
+			start := time.Now()
+			if err := h.stop(ctx); err != nil {
+				slog.ErrorContext(ctx, "Shutdown Error", "component", h.name, "err", err)
+				errs = append(errs, fmt.Errorf("%s: %w", h.name, err))
+				continue
+			}
+			slog.InfoContext(ctx, "Stopped", "component", h.name, "duration_ms", time.Since(start).Milliseconds())
+		}
+		m.stopErr = errors.Join(errs...)
+	})
+	return m.stopErr
+}
@@ -0,0 +1,85 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShutdownRunsStepsNewestFirst(t *testing.T) {
+	app := New(context.Background())
+	var mu sync.Mutex
+	var order []string
+	record := func(name string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, name)
+	}
+
+	app.OnStop("database", func(context.Context) error { record("database"); return nil })
+	app.Go("worker", func(ctx context.Context) error {
+		<-ctx.Done()
+		record("worker")
+		return ctx.Err()
+	})
+	app.OnStop("server", func(context.Context) error { record("server"); return errors.New("drain failed") })
+
+	err := app.Shutdown(time.Second)
+	if err == nil || err.Error() != "server: drain failed" {
+		t.Fatalf("expected the server's failure, got %v", err)
+	}
+	// The worker is cancelled when shutdown begins, so it may finish before
+	// or after the server drains, but it is awaited before the database it
+	// was started after is closed
+	if len(order) != 3 || order[2] != "database" {
+		t.Fatalf("expected the database to be closed last, got %v", order)
+	}
+	if again := app.Shutdown(time.Second); again != err {
+		t.Fatalf("a second shutdown should return the first result, got %v", again)
+	}
+}
+
+func TestFailingWorkerBeginsShutdown(t *testing.T) {
+	app := New(context.Background())
+	app.Go("listener", func(context.Context) error { return errors.New("address in use") })
+	app.Go("idle", func(ctx context.Context) error {
+		<-ctx.Done()
+		return nil
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- app.Wait() }()
+	select {
+	case err := <-done:
+		if err == nil || err.Error() != "listener: address in use" {
+			t.Fatalf("expected the listener's failure, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait did not return after a worker failed")
+	}
+	if err := app.Shutdown(time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShutdownGivesUpAtTheDeadline(t *testing.T) {
+	app := New(context.Background())
+	closed := false
+	app.OnStop("database", func(context.Context) error { closed = true; return nil })
+	app.Go("stuck", func(context.Context) error {
+		select {} // ignores cancellation
+	})
+
+	start := time.Now()
+	if err := app.Shutdown(50 * time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline to be exceeded, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("shutdown waited past its deadline")
+	}
+	if !closed {
+		t.Fatal("later steps should still run after the deadline")
+	}
+}
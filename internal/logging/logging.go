@@ -0,0 +1,76 @@
+// Package logging sets up the server's structured logs. Lines are written
+// with log/slog, carry the request id and trace id of the context they were
+// logged under, and have personal data and secrets redacted before they are
+// written. Lines from the standard log package go through the same handler.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	"github.com/Woeter69/hackoverflow/internal/config"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestIDKey holds the request id in a context
+type requestIDKey struct{}
+
+// WithRequestID returns ctx carrying the request id
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request id ctx carries, or ""
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Setup makes a logger writing to w as configured the default for both
+// log/slog and the standard log package, and returns it
+func Setup(cfg config.Log, w io.Writer) *slog.Logger {
+	logger := New(cfg, w)
+	slog.SetDefault(logger)
+	return logger
+}
+
+// New returns a logger writing to w as configured
+func New(cfg config.Log, w io.Writer) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		level = slog.LevelInfo
+	}
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr}
+
+	var h slog.Handler
+	if cfg.Format == "text" {
+		h = slog.NewTextHandler(w, opts)
+	} else {
+		h = slog.NewJSONHandler(w, opts)
+	}
+	return slog.New(contextHandler{h})
+}
+
+// contextHandler adds the request and trace ids of the context to each line
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,135 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Woeter69/hackoverflow/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// lines decodes the JSON log lines written to buf
+func lines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var line map[string]any
+		if err := dec.Decode(&line); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+func TestSensitiveValuesAreRedacted(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(config.Default().Log, &buf)
+
+	logger.Info("Emergency toggled by alice@example.edu",
+		"message", "I am stuck in the lift on floor 3",
+		"db_password", "hunter2",
+		"Authorization", "Bearer abc",
+		"err", errors.New("dial postgres://app:hunter2@db:5432/campus: refused"),
+		"note", "header was Bearer eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.c2ln",
+		"user_id", "u-1",
+	)
+	line := lines(t, &buf)[0]
+
+	out, _ := json.Marshal(line)
+	for _, leak := range []string{"alice@example.edu", "stuck in the lift", "hunter2", "eyJ", "abc"} {
+		if strings.Contains(string(out), leak) {
+			t.Fatalf("%q leaked into %s", leak, out)
+		}
+	}
+	if line["user_id"] != "u-1" {
+		t.Fatalf("ids should be logged as is, got %v", line["user_id"])
+	}
+	if line["msg"] != "Emergency toggled by [EMAIL]" {
+		t.Fatalf("the message should be scrubbed, got %v", line["msg"])
+	}
+}
+
+func TestLinesCarryTheRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(config.Log{Level: "info", Format: "json"}, &buf)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestIDMiddleware())
+	r.GET("/errand-requests/:id", func(c *gin.Context) {
+		logger.InfoContext(c.Request.Context(), "handled")
+		c.Status(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/errand-requests/42", nil)
+	req.Header.Set(RequestIDHeader, "abc-123")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if got := w.Header().Get(RequestIDHeader); got != "abc-123" {
+		t.Fatalf("expected the caller's request id back, got %q", got)
+	}
+	if line := lines(t, &buf)[0]; line["request_id"] != "abc-123" {
+		t.Fatalf("expected the request id on the line, got %v", line)
+	}
+
+	// Ids that could forge log lines are replaced
+	req = httptest.NewRequest(http.MethodGet, "/errand-requests/42", nil)
+	req.Header.Set(RequestIDHeader, "x\n{\"level\":\"ERROR\"}")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if got := w.Header().Get(RequestIDHeader); !validRequestID(got) || strings.Contains(got, "level") {
+		t.Fatalf("expected a fresh request id, got %q", got)
+	}
+}
+
+func TestAccessLogNamesTheRoute(t *testing.T) {
+	var buf bytes.Buffer
+	prev, flags := slog.Default(), log.Flags()
+	t.Cleanup(func() {
+		slog.SetDefault(prev)
+		log.SetOutput(os.Stderr)
+		log.SetFlags(flags)
+	})
+	Setup(config.Log{Level: "info", Format: "json"}, &buf)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestIDMiddleware(), AccessLog("/livez"))
+	r.GET("/calendar/:token", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+	r.GET("/livez", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/calendar/s3cr3t?x=1", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/livez", nil))
+
+	got := lines(t, &buf)
+	if len(got) != 1 {
+		t.Fatalf("expected one line, the probe skipped, got %v", got)
+	}
+	line := got[0]
+	if line["route"] != "/calendar/:token" || line["level"] != "WARN" || line["status"] != float64(http.StatusNotFound) {
+		t.Fatalf("unexpected access line %v", line)
+	}
+	if out, _ := json.Marshal(line); strings.Contains(string(out), "s3cr3t") {
+		t.Fatalf("the path leaked into %s", out)
+	}
+}
+
+func TestRequestIDRoundTrips(t *testing.T) {
+	if id := RequestID(context.Background()); id != "" {
+		t.Fatalf("expected no id, got %q", id)
+	}
+	if id := RequestID(WithRequestID(context.Background(), "r1")); id != "r1" {
+		t.Fatalf("expected r1, got %q", id)
+	}
+}
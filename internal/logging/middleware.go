@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the request id from the caller and back
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen bounds the ids accepted from callers
+const maxRequestIDLen = 64
+
+// unmatchedRoute is logged as the route of requests no route matched
+const unmatchedRoute = "unmatched"
+
+// validRequestID accepts ids of letters, digits and "-_.:" only, so a
+// caller cannot inject text into log lines
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// RequestIDMiddleware gives every request an id, taken from the caller's
+// X-Request-ID header when it is well formed, and returns it in the
+// response. Handlers find it with RequestID on the request's context.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		c.Header(RequestIDHeader, id)
+		c.Set("requestID", id)
+		ctx := WithRequestID(c.Request.Context(), id)
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("request.id", id))
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// AccessLog logs one line per request. The route template is logged
+// rather than the path, which may hold secrets such as calendar feed
+// tokens, and the query string is never logged. Requests to the skipped
+// paths, such as probes, are not logged.
+func AccessLog(skip ...string) gin.HandlerFunc {
+	skipped := make(map[string]bool, len(skip))
+	for _, path := range skip {
+		skipped[path] = true
+	}
+	return func(c *gin.Context) {
+		if skipped[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("route", route),
+			slog.Int("status", status),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int("bytes", c.Writer.Size()),
+		}
+		if userID := c.GetString("userID"); userID != "" {
+			attrs = append(attrs, slog.String("user_id", userID))
+		}
+		slog.LogAttrs(c.Request.Context(), level, "request", attrs...)
+	}
+}
@@ -0,0 +1,91 @@
+package logging
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// redacted replaces values that must not be logged
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attributes whose values are never logged: personal data
+// users typed in and contact details
+var sensitiveKeys = map[string]bool{
+	"email":     true,
+	"phone":     true,
+	"message":   true,
+	"content":   true,
+	"body":      true,
+	"statement": true,
+}
+
+// secretKeyParts mark attributes holding credentials, wherever they appear
+// in the key ("db_password", "id_token", "Authorization", ...)
+var secretKeyParts = []string{"password", "secret", "token", "authorization", "cookie", "api_key", "apikey", "dsn"}
+
+// scrubbers mask personal data and credentials inside free text, such as
+// messages from the standard log package and error strings
+var scrubbers = []struct {
+	pattern *regexp.Regexp
+	replace string
+}{
+	// Bearer tokens in echoed headers
+	{regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/=-]+`), "Bearer " + redacted},
+	// JWTs, such as Firebase ID tokens
+	{regexp.MustCompile(`\beyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`), redacted},
+	// Passwords in connection URLs
+	{regexp.MustCompile(`(\b[a-z][a-z0-9+.-]*://[^:/\s@]+:)[^@\s]+@`), "${1}" + redacted + "@"},
+	// Email addresses
+	{regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), "[EMAIL]"},
+}
+
+// Scrub masks personal data and credentials in s
+func Scrub(s string) string {
+	for _, sc := range scrubbers {
+		s = sc.pattern.ReplaceAllString(s, sc.replace)
+	}
+	return s
+}
+
+// sensitiveKey reports whether values under key must not be logged
+func sensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	if sensitiveKeys[key] {
+		return true
+	}
+	for _, part := range secretKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactAttr is the handlers' ReplaceAttr: it drops the values of sensitive
+// attributes and scrubs the text of the rest, including the message
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey || a.Key == slog.SourceKey) {
+		return a
+	}
+	if a.Key != slog.MessageKey && sensitiveKey(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+
+	switch v := a.Value.Resolve(); v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Scrub(v.String()))
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case error:
+			return slog.String(a.Key, Scrub(x.Error()))
+		case []byte:
+			return slog.String(a.Key, Scrub(string(x)))
+		default:
+			// Structs and maps are logged by their fields, which are not
+			// checked one by one, so only their text is scrubbed
+			return slog.String(a.Key, Scrub(v.String()))
+		}
+	}
+	return a
+}
@@ -0,0 +1,141 @@
+// Package metrics holds the server's Prometheus collectors and serves them
+// at /metrics. Collectors are registered on the package's own registry, so
+// tests and the CLI can import instrumented packages without side effects.
+package metrics
+
+import (
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes every metric name
+const namespace = "campusloop"
+
+// unmatchedRoute labels requests no route matched, so unknown paths cannot
+// grow the label set
+const unmatchedRoute = "unmatched"
+
+// Registry holds every collector below plus the Go runtime and process ones
+var Registry = prometheus.NewRegistry()
+
+// HTTP
+var (
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Time taken to serve HTTP requests, by route template and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+)
+
+// Matching
+var (
+	MatchingDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "matching_query_duration_seconds",
+		Help:      "Time taken to find the errands along a travel plan's route.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	})
+	MatchingResults = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "matching_results",
+		Help:      "Errands found along a travel plan's route per query.",
+		Buckets:   []float64{0, 1, 2, 5, 10, 20, 50, 100},
+	})
+)
+
+// WebSocket hub
+var (
+	ConnectedClients = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "ws",
+		Name:      "connected_clients",
+		Help:      "WebSocket connections registered with the hub.",
+	})
+	Broadcasts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ws",
+		Name:      "broadcasts_total",
+		Help:      "Messages handed to the hub, by event type.",
+	}, []string{"event"})
+	SendDrops = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "ws",
+		Name:      "send_buffer_drops_total",
+		Help:      "Messages not delivered because a client's send buffer was full: skipped targeted messages, or broadcasts that disconnected the client.",
+	}, []string{"outcome"})
+)
+
+// Business
+var (
+	ErrandsCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "errands_created_total",
+		Help:      "Errands posted.",
+	})
+	ErrandTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "errand_transitions_total",
+		Help:      "Errand status changes, by the status entered.",
+	}, []string{"status"})
+	CreditsMoved = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "credits_moved_total",
+		Help:      "Credits paid out or clawed back, by reason.",
+	}, []string{"reason"})
+)
+
+// Reasons credits move
+const (
+	CreditsAward    = "errand_completed"
+	CreditsClawback = "dispute_clawback"
+	CreditsPayout   = "dispute_payout"
+)
+
+// Outcomes of a full send buffer
+const (
+	DropSkipped      = "skipped"
+	DropDisconnected = "disconnected"
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		RequestDuration,
+		MatchingDuration, MatchingResults,
+		ConnectedClients, Broadcasts, SendDrops,
+		ErrandsCreated, ErrandTransitions, CreditsMoved,
+	)
+}
+
+// RegisterDB exposes the connection pool statistics of db
+func RegisterDB(db *sql.DB, name string) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the registry in the Prometheus text format
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry}))
+}
+
+// Middleware times every request under its route template
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		RequestDuration.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddlewareLabelsRouteTemplates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/errand-requests/:id", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+	r.GET("/metrics", Handler())
+
+	for _, path := range []string{"/errand-requests/a", "/errand-requests/b", "/wp-admin.php"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if n := testutil.CollectAndCount(RequestDuration); n != 2 {
+		t.Fatalf("expected one series per route template, got %d", n)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	for _, want := range []string{
+		`campusloop_http_request_duration_seconds_count{method="GET",route="/errand-requests/:id",status="404"} 2`,
+		`campusloop_http_request_duration_seconds_count{method="GET",route="unmatched",status="404"} 1`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %s in\n%s", want, body)
+		}
+	}
+}
@@ -1,43 +1,77 @@
 package middleware
 
 import (
-	"context"
-	"log"
+	"log/slog"
 	"net/http"
 	"strings"
 
-	firebase "firebase.google.com/go/v4"
+	"github.com/Woeter69/hackoverflow/internal/apierror"
+	"github.com/Woeter69/hackoverflow/internal/auth"
+	"github.com/Woeter69/hackoverflow/internal/policy"
+	"github.com/Woeter69/hackoverflow/internal/store"
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware validates the Firebase ID Token in the Authorization header
-func AuthMiddleware(app *firebase.App) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		authHeader := c.GetHeader("Authorization")
-		
-		client, err := app.Auth(context.Background())
-		if err != nil {
-			log.Printf("Warning: Auth client error: %v. Bypassing auth for dev.", err)
-			c.Set("userID", "dev-user-123")
-			c.Next()
-			return
-		}
+// WebSocketProtocol is the subprotocol browsers offer, followed by their
+// token, since they cannot set an Authorization header on a WebSocket:
+// new WebSocket(url, ["bearer", token])
+const WebSocketProtocol = "bearer"
 
-		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing Authorization header"})
+// AuthMiddleware validates the bearer token in the Authorization header using
+// the configured verifier (Firebase or local dev issuer). Requests without a
+// valid token are always rejected. Roles granted by admins are read from users.
+func AuthMiddleware(verifier auth.Verifier, users store.Users) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := bearerToken(c)
+		if tokenString == "" {
+			apierror.Respond(c, http.StatusUnauthorized, apierror.CodeMissingToken, "Missing Authorization header")
 			return
 		}
 
-		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
-		token, err := client.VerifyIDToken(context.Background(), tokenString)
+		identity, err := verifier.Verify(c.Request.Context(), tokenString)
 		if err != nil {
-			log.Printf("AuthMiddleware Error: Invalid token: %v", err)
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			slog.WarnContext(c.Request.Context(), "AuthMiddleware Error: Invalid token", "err", err)
+			apierror.Respond(c, http.StatusUnauthorized, apierror.CodeInvalidToken, "Invalid token")
 			return
 		}
 
-		// Store user ID in context for handlers to use
-		c.Set("userID", token.UID)
+		// Store user ID and roles in context for handlers to use
+		c.Set("userID", identity.UID)
+		c.Set("identity", identity)
+		c.Set("roles", loadRoles(c, users, identity.UID, identity.Roles))
 		c.Next()
 	}
 }
+
+// bearerToken returns the token from the Authorization header or, on a
+// WebSocket upgrade, the one offered after the bearer subprotocol
+func bearerToken(c *gin.Context) string {
+	if header := c.GetHeader("Authorization"); header != "" {
+		return strings.TrimPrefix(header, "Bearer ")
+	}
+	if !c.IsWebsocket() {
+		return ""
+	}
+	var protocols []string
+	for _, header := range c.Request.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(header, ",") {
+			protocols = append(protocols, strings.TrimSpace(p))
+		}
+	}
+	for i := 0; i+1 < len(protocols); i++ {
+		if protocols[i] == WebSocketProtocol {
+			return protocols[i+1]
+		}
+	}
+	return ""
+}
+
+// loadRoles merges roles from token claims with the stored ones. A lookup
+// failure only drops the stored roles, it never grants extra ones.
+func loadRoles(c *gin.Context, users store.Users, userID string, claimRoles []policy.Role) []policy.Role {
+	stored, err := users.Roles(c.Request.Context(), userID)
+	if err != nil {
+		slog.WarnContext(c.Request.Context(), "AuthMiddleware Warning: failed to load roles", "user_id", userID, "err", err)
+	}
+	return policy.MergeRoles(claimRoles, stored)
+}
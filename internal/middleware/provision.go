@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Woeter69/hackoverflow/internal/apierror"
+	"github.com/Woeter69/hackoverflow/internal/auth"
+	"github.com/Woeter69/hackoverflow/internal/store"
+	"github.com/gin-gonic/gin"
+)
+
+// provisionTTL bounds how long a cached sync is trusted, so changes to the
+// campus domain allow-list reach users without a restart.
+const provisionTTL = 5 * time.Minute
+
+type provisionedUser struct {
+	claims     store.Claims
+	membership store.Membership
+	syncedAt   time.Time
+}
+
+// Provisioner keeps the users table in step with verified token claims. It
+// remembers which claims have already been synced per user, so the upsert
+// only runs on a user's first request or when their claims change.
+type Provisioner struct {
+	users  store.Users
+	synced sync.Map // uid -> provisionedUser
+}
+
+// NewProvisioner returns a provisioner writing to users
+func NewProvisioner(users store.Users) *Provisioner {
+	return &Provisioner{users: users}
+}
+
+// Handler creates or refreshes the users row for the authenticated caller
+// from their verified token claims, assigns their campus from their email
+// domain and records whether they are a verified member of it. It must run
+// after AuthMiddleware.
+func (p *Provisioner) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, ok := c.Get("identity")
+		if !ok {
+			apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthenticated, "Unauthorized")
+			return
+		}
+		identity := value.(*auth.Identity)
+		claims := store.Claims{Email: identity.Email, Name: identity.Name, EmailVerified: identity.EmailVerified}
+
+		cached, ok := p.synced.Load(identity.UID)
+		if ok {
+			entry := cached.(provisionedUser)
+			if entry.claims == claims && time.Since(entry.syncedAt) < provisionTTL {
+				setCampus(c, entry.membership)
+				c.Next()
+				return
+			}
+		}
+
+		membership, err := p.users.Provision(c.Request.Context(), identity.UID, claims)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "ProvisionUser Error", "user_id", identity.UID, "err", err)
+			apierror.Respond(c, http.StatusServiceUnavailable, apierror.CodeUnavailable, "Failed to provision user")
+			return
+		}
+		p.synced.Store(identity.UID, provisionedUser{claims: claims, membership: membership, syncedAt: time.Now()})
+		setCampus(c, membership)
+		c.Next()
+	}
+}
+
+func setCampus(c *gin.Context, m store.Membership) {
+	c.Set("campusID", m.CampusID)
+	c.Set("campusVerified", m.CampusVerified)
+}
+
+// Reset forgets every cached sync, e.g. after the domain allow-list changes
+func (p *Provisioner) Reset() {
+	p.synced.Range(func(key, _ interface{}) bool {
+		p.synced.Delete(key)
+		return true
+	})
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/Woeter69/hackoverflow/internal/apierror"
+	"github.com/Woeter69/hackoverflow/internal/policy"
+	"github.com/gin-gonic/gin"
+)
+
+// Subject builds the policy subject for the authenticated user in the request
+func Subject(c *gin.Context) policy.Subject {
+	s := policy.Subject{
+		UserID:         c.GetString("userID"),
+		CampusID:       c.GetString("campusID"),
+		CampusVerified: c.GetBool("campusVerified"),
+	}
+	if roles, ok := c.Get("roles"); ok {
+		s.Roles, _ = roles.([]policy.Role)
+	}
+	return s
+}
+
+// RequireRole aborts the request unless the user holds at least one of the roles.
+// It must run after AuthMiddleware.
+func RequireRole(roles ...policy.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s := Subject(c)
+		if s.UserID == "" {
+			apierror.Respond(c, http.StatusUnauthorized, apierror.CodeUnauthenticated, "Unauthorized")
+			return
+		}
+		if !s.HasAnyRole(roles...) {
+			apierror.Respond(c, http.StatusForbidden, apierror.CodeInsufficientRole, "Insufficient role")
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/Woeter69/hackoverflow/internal/geo"
+)
+
+// Point and LineString map to PostGIS geography columns. They scan from
+// ST_AsGeoJSON or ST_AsText output and are written as EWKT, so queries can
+// select and bind them directly.
+
+// Geo converts the point for geometry checks and GeoJSON output
+func (p Point) Geo() geo.Point {
+	return geo.Point{Lng: p.Lng, Lat: p.Lat}
+}
+
+func PointFromGeo(p geo.Point) Point {
+	return Point{Lat: p.Lat, Lng: p.Lng}
+}
+
+func (p Point) Value() (driver.Value, error) {
+	return "SRID=4326;" + p.Geo().WKT(), nil
+}
+
+// Scan reads a PostGIS point; NULL leaves the zero value
+func (p *Point) Scan(src interface{}) error {
+	text, err := geometryText(src)
+	if err != nil || text == "" {
+		return err
+	}
+	pt, err := geo.ParsePoint(text)
+	if err != nil {
+		return err
+	}
+	*p = PointFromGeo(pt)
+	return nil
+}
+
+// LineString is a route, ordered from origin to destination
+type LineString []Point
+
+func (l LineString) Geo() geo.LineString {
+	line := make(geo.LineString, len(l))
+	for i, p := range l {
+		line[i] = p.Geo()
+	}
+	return line
+}
+
+func LineStringFromGeo(l geo.LineString) LineString {
+	line := make(LineString, len(l))
+	for i, p := range l {
+		line[i] = PointFromGeo(p)
+	}
+	return line
+}
+
+func (l LineString) Value() (driver.Value, error) {
+	if len(l) == 0 {
+		return nil, nil
+	}
+	return "SRID=4326;" + l.Geo().WKT(), nil
+}
+
+// Scan reads a PostGIS linestring; NULL leaves the line empty
+func (l *LineString) Scan(src interface{}) error {
+	text, err := geometryText(src)
+	if err != nil {
+		return err
+	}
+	if text == "" {
+		*l = nil
+		return nil
+	}
+	line, err := geo.ParseLineString(text)
+	if err != nil {
+		return err
+	}
+	*l = LineStringFromGeo(line)
+	return nil
+}
+
+func geometryText(src interface{}) (string, error) {
+	switch v := src.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	default:
+		return "", fmt.Errorf("cannot scan %T into a geometry", src)
+	}
+}
@@ -0,0 +1,42 @@
+package models
+
+import "testing"
+
+func TestPointMapsToPostGIS(t *testing.T) {
+	p := Point{Lat: 12.9716, Lng: 77.5946}
+	v, err := p.Value()
+	if err != nil || v != "SRID=4326;POINT(77.5946 12.9716)" {
+		t.Fatalf("Value() = %v, %v", v, err)
+	}
+
+	var scanned Point
+	if err := scanned.Scan([]byte(`{"type":"Point","coordinates":[77.5946,12.9716]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if scanned != p {
+		t.Fatalf("scanned %v, want %v", scanned, p)
+	}
+	if err := scanned.Scan(int64(1)); err == nil {
+		t.Fatal("expected an error scanning a number")
+	}
+}
+
+func TestLineStringMapsToPostGIS(t *testing.T) {
+	var route LineString
+	if err := route.Scan("LINESTRING(77.594 12.971,77.595 12.972)"); err != nil {
+		t.Fatal(err)
+	}
+	if len(route) != 2 || route[1] != (Point{Lat: 12.972, Lng: 77.595}) {
+		t.Fatalf("unexpected route %v", route)
+	}
+	if v, _ := route.Value(); v != "SRID=4326;LINESTRING(77.594 12.971, 77.595 12.972)" {
+		t.Fatalf("Value() = %v", v)
+	}
+
+	if err := route.Scan(nil); err != nil || route != nil {
+		t.Fatalf("NULL route = %v, %v", route, err)
+	}
+	if v, _ := route.Value(); v != nil {
+		t.Fatalf("empty route should be NULL, got %v", v)
+	}
+}
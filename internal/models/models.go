@@ -1,19 +1,50 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type User struct {
-	ID           string    `json:"id"`
-	Username     string    `json:"username"`
-	Email        string    `json:"email"`
-	Credits      int       `json:"credits"`
-	XP           int       `json:"xp"`
-	Rating       float64   `json:"rating"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID            string    `json:"id"`
+	Username      string    `json:"username"`
+	Email         string    `json:"email"`
+	DisplayName   string    `json:"display_name"`
+	EmailVerified bool      `json:"email_verified"`
+	Credits       int       `json:"credits"`
+	XP            int       `json:"xp"`
+	Rating        float64   `json:"rating"`
+	CampusID      string    `json:"campus_id,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Campus is a tenant: a university sharing the deployment with its own
+// service area and settings
+type Campus struct {
+	ID             string          `json:"id"`
+	Name           string          `json:"name"`
+	Boundary       json.RawMessage `json:"boundary,omitempty"` // GeoJSON polygon
+	CurrencyName   string          `json:"currency_name"`
+	DefaultBufferM float64         `json:"default_buffer_m"`
+	Categories     []string        `json:"categories"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// Place is a named point of interest on a campus
+type Place struct {
+	ID        string          `json:"id"`
+	CampusID  string          `json:"campus_id"`
+	Name      string          `json:"name"`
+	Kind      string          `json:"kind"` // building, cafe, gate, hostel, other
+	Code      string          `json:"code,omitempty"`
+	Location  Point           `json:"location"`
+	Footprint json.RawMessage `json:"footprint,omitempty"` // GeoJSON polygon
+	Entrances json.RawMessage `json:"entrances,omitempty"` // GeoJSON multipoint
+	Distance  *float64        `json:"distance_m,omitempty"` // set by nearest-place lookups
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
 }
 
 type Message struct {
@@ -31,23 +62,50 @@ type Point struct {
 }
 
 type TravelPlan struct {
-	ID              uuid.UUID `json:"id"`
-	UserID          uuid.UUID `json:"user_id"`
-	OriginName      string    `json:"origin_name"`
-	DestinationName string    `json:"destination_name"`
-	Origin          Point     `json:"origin"`
-	Destination     Point     `json:"destination"`
-	Route           []Point   `json:"route,omitempty"` // LineString
-	Mode            string    `json:"mode"`           // walk, cycle, car
-	StartTime       time.Time `json:"start_time"`
-	SeatsAvailable  int       `json:"seats_available"`
-	IsActive        bool      `json:"is_active"`
-	CreatedAt       time.Time `json:"created_at"`
+	ID                 uuid.UUID  `json:"id"`
+	UserID             string     `json:"user_id"`
+	CampusID           string     `json:"campus_id"`
+	OriginName         string     `json:"origin_name"`
+	DestinationName    string     `json:"destination_name"`
+	OriginPlaceID      string     `json:"origin_place_id,omitempty"`
+	DestinationPlaceID string     `json:"destination_place_id,omitempty"`
+	Origin             Point      `json:"origin"`
+	Destination        Point      `json:"destination"`
+	Route              LineString `json:"route,omitempty"`
+	Mode               string     `json:"mode"` // walk, cycle, car
+	StartTime          time.Time  `json:"start_time"`
+	SeatsAvailable     int        `json:"seats_available"`
+	IsActive           bool       `json:"is_active"`
+	SeriesID           string     `json:"series_id,omitempty"` // set on departures of a recurring plan
+	CreatedAt          time.Time  `json:"created_at"`
+}
+
+// TravelPlanSeries is a recurring travel plan. Its departures are
+// materialized ahead of time as ordinary travel plans.
+type TravelPlanSeries struct {
+	ID                 string      `json:"id"`
+	UserID             string      `json:"user_id"`
+	CampusID           string      `json:"campus_id"`
+	OriginName         string      `json:"origin_name"`
+	DestinationName    string      `json:"destination_name"`
+	OriginPlaceID      string      `json:"origin_place_id,omitempty"`
+	DestinationPlaceID string      `json:"destination_place_id,omitempty"`
+	Route              LineString  `json:"route"`
+	Mode               string      `json:"mode"`
+	SeatsAvailable     int         `json:"seats_available"`
+	RRule              string      `json:"rrule"`      // weekly RRULE, e.g. FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR
+	Start              time.Time   `json:"start"`      // first departure
+	Timezone           string      `json:"timezone"`   // IANA zone whose wall clock departures follow
+	Exceptions         []string    `json:"exceptions"` // YYYY-MM-DD dates without a departure
+	Paused             bool        `json:"paused"`
+	NextDepartures     []time.Time `json:"next_departures,omitempty"`
+	CreatedAt          time.Time   `json:"created_at"`
+	UpdatedAt          time.Time   `json:"updated_at"`
 }
 
 type ErrandRequest struct {
 	ID             uuid.UUID `json:"id"`
-	UserID         uuid.UUID `json:"user_id"`
+	UserID         string    `json:"user_id"`
 	Title          string    `json:"title"`
 	Description    string    `json:"description"`
 	Pickup         Point     `json:"pickup"`
@@ -57,10 +115,100 @@ type ErrandRequest struct {
 	RunnerID       string    `json:"runner_id,omitempty"`
 	UrgencyLevel   int       `json:"urgency_level"`
 	RewardEstimate float64   `json:"reward_estimate"`
+	CampusID       string    `json:"campus_id"`
 	CreatedAt      time.Time `json:"created_at"`
+
+	PickupPlaceID    string `json:"pickup_place_id,omitempty"`
+	PickupPlaceName  string `json:"pickup_place_name,omitempty"`
+	DropoffPlaceID   string `json:"dropoff_place_id,omitempty"`
+	DropoffPlaceName string `json:"dropoff_place_name,omitempty"`
 }
 
 type MatchResponse struct {
 	Errand          ErrandRequest `json:"errand"`
 	DistanceFromRoute float64     `json:"distance_from_route"` // in meters
 }
+
+// Beacon is an emergency raised on a campus. A campus has at most one active
+// beacon; it lapses at ExpiresAt unless it is cleared first.
+type Beacon struct {
+	ID         string    `json:"id"`
+	CampusID   string    `json:"campus_id"`
+	UserID     string    `json:"user_id"`
+	Message    string    `json:"message,omitempty"`
+	BuildingID int       `json:"building_id,omitempty"`
+	PlaceID    string    `json:"place_id,omitempty"`
+	PlaceName  string    `json:"place_name,omitempty"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type ErrandEvent struct {
+	ID        int64                  `json:"id"`
+	ErrandID  uuid.UUID              `json:"errand_id"`
+	ActorID   string                 `json:"actor_id"`
+	Event     string                 `json:"event"`
+	Details   map[string]interface{} `json:"details"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+type Dispute struct {
+	ID             uuid.UUID          `json:"id"`
+	ErrandID       uuid.UUID          `json:"errand_id"`
+	OpenedBy       string             `json:"opened_by"`
+	Reason         string             `json:"reason"`
+	PreviousStatus string             `json:"previous_status"`
+	Status         string             `json:"status"`            // open, resolved
+	Outcome        string             `json:"outcome,omitempty"` // refund, payout, partial
+	PayoutAmount   float64            `json:"payout_amount"`
+	RefundAmount   float64            `json:"refund_amount"`
+	ResolutionNote string             `json:"resolution_note,omitempty"`
+	ResolvedBy     string             `json:"resolved_by,omitempty"`
+	ResolvedAt     *time.Time         `json:"resolved_at,omitempty"`
+	HeldAmount     float64            `json:"held_amount"`
+	Statements     []DisputeStatement `json:"statements"`
+	CreatedAt      time.Time          `json:"created_at"`
+}
+
+type DisputeStatement struct {
+	ID           uuid.UUID `json:"id"`
+	DisputeID    uuid.UUID `json:"dispute_id"`
+	AuthorID     string    `json:"author_id"`
+	Statement    string    `json:"statement"`
+	EvidenceURLs []string  `json:"evidence_urls"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// AuditEntry is one row of the hash-chained audit log
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	Actor     string          `json:"actor"`
+	Action    string          `json:"action"`
+	Target    string          `json:"target"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	RequestID string          `json:"request_id,omitempty"`
+	PrevHash  string          `json:"prev_hash"`
+	Hash      string          `json:"hash"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// RunnerVerification is an application for the verified runner tier
+type RunnerVerification struct {
+	ID           string     `json:"id"`
+	UserID       string     `json:"user_id"`
+	DocumentPath string     `json:"-"` // uploaded student ID, relative to the upload dir
+	DocumentType string     `json:"document_type"`
+	Status       string     `json:"status"` // pending, approved, rejected
+	ReviewNotes  string     `json:"review_notes,omitempty"`
+	ReviewedBy   string     `json:"reviewed_by,omitempty"`
+	ReviewedAt   *time.Time `json:"reviewed_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// EmailDomain is an institutional email domain whose users join a campus
+type EmailDomain struct {
+	Domain    string    `json:"domain"`
+	CampusID  string    `json:"campus_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,162 @@
+package policy
+
+// Role is a capability tier assigned to a user. Every authenticated user is
+// implicitly a student; the other roles are granted by admins.
+type Role string
+
+const (
+	RoleStudent         Role = "student"
+	RoleVerifiedRunner  Role = "verified_runner"
+	RoleCampusResponder Role = "campus_responder"
+	RoleModerator       Role = "moderator"
+	RoleAdmin           Role = "admin"
+)
+
+// AllRoles lists every role that can be stored or granted
+var AllRoles = []Role{RoleStudent, RoleVerifiedRunner, RoleCampusResponder, RoleModerator, RoleAdmin}
+
+// ParseRole validates a role name coming from the API, the database or token claims
+func ParseRole(s string) (Role, bool) {
+	for _, r := range AllRoles {
+		if string(r) == s {
+			return r, true
+		}
+	}
+	return "", false
+}
+
+// Subject is the authenticated user an authorization decision is made for
+type Subject struct {
+	UserID string
+	Roles  []Role
+
+	// CampusID is the tenant the user belongs to ("" if none)
+	CampusID string
+
+	// CampusVerified is set when the user's email is verified and belongs to
+	// one of the campus's institutional domains
+	CampusVerified bool
+}
+
+// HasRole reports whether the subject holds the role. Admins hold every role.
+func (s Subject) HasRole(role Role) bool {
+	if s.UserID == "" {
+		return false
+	}
+	if role == RoleStudent {
+		return true
+	}
+	for _, r := range s.Roles {
+		if r == role || r == RoleAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAnyRole reports whether the subject holds at least one of the roles
+func (s Subject) HasAnyRole(roles ...Role) bool {
+	for _, r := range roles {
+		if s.HasRole(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsStaff reports whether the subject may override ownership checks
+func (s Subject) IsStaff() bool {
+	return s.HasAnyRole(RoleModerator, RoleAdmin)
+}
+
+// Action is something a subject attempts to do
+type Action string
+
+const (
+	ActionCreateErrand        Action = "errand:create"
+	ActionAcceptErrand        Action = "errand:accept"
+	ActionUpdateErrandStatus  Action = "errand:update_status"
+	ActionCancelErrand        Action = "errand:cancel"
+	ActionViewTravelPlan      Action = "travel_plan:view"
+	ActionManageTravelPlan    Action = "travel_plan:manage"
+	ActionViewChat            Action = "chat:view"
+	ActionSendMessage         Action = "chat:send"
+	ActionOpenDispute         Action = "dispute:open"
+	ActionAddDisputeStatement Action = "dispute:add_statement"
+	ActionViewDispute         Action = "dispute:view"
+	ActionResolveDispute      Action = "dispute:resolve"
+	ActionRaiseEmergency      Action = "emergency:raise"
+	ActionClearEmergency      Action = "emergency:clear"
+	ActionManageRoles         Action = "roles:manage"
+	ActionApplyVerifiedRunner Action = "runner:apply"
+	ActionReviewVerification  Action = "runner:review"
+	ActionManagePlaces        Action = "places:manage"
+)
+
+// HighValueThreshold is the reward at or above which only verified runners may
+// accept an errand. It is configured at startup.
+var HighValueThreshold = 50.0
+
+// Resource describes who owns the object an action targets. For errands the
+// owner is the requester and the assignee is the runner.
+type Resource struct {
+	OwnerID    string
+	AssigneeID string
+	CampusID   string  // tenant the object belongs to ("" = not tenant-scoped)
+	Value      float64 // reward at stake, used for high-value checks
+}
+
+// isParty reports whether the subject is the owner or assignee of the resource
+func (r Resource) isParty(s Subject) bool {
+	if s.UserID == "" {
+		return false
+	}
+	return s.UserID == r.OwnerID || s.UserID == r.AssigneeID
+}
+
+// Can decides whether the subject may perform the action on the resource.
+// Pass a zero Resource for actions that don't target a specific object.
+func Can(s Subject, action Action, res Resource) bool {
+	if s.UserID == "" {
+		return false
+	}
+	// Tenants are isolated; only admins operate across campuses
+	if res.CampusID != "" && res.CampusID != s.CampusID && !s.HasRole(RoleAdmin) {
+		return false
+	}
+
+	switch action {
+	case ActionCreateErrand:
+		return s.CampusVerified
+	case ActionAcceptErrand:
+		// Campus members can pick up errands; high-value ones need the verified runner tier
+		if !s.CampusVerified {
+			return false
+		}
+		return res.Value < HighValueThreshold || s.HasRole(RoleVerifiedRunner)
+	case ActionApplyVerifiedRunner:
+		return s.CampusVerified
+	case ActionReviewVerification:
+		return s.HasRole(RoleAdmin)
+	case ActionUpdateErrandStatus, ActionCancelErrand,
+		ActionViewChat, ActionOpenDispute, ActionAddDisputeStatement, ActionViewDispute:
+		return res.isParty(s) || s.IsStaff()
+	case ActionSendMessage:
+		// Staff can read a mission's chat but only the two parties talk in it
+		return res.isParty(s)
+	case ActionViewTravelPlan:
+		return s.UserID == res.OwnerID || s.IsStaff()
+	case ActionManageTravelPlan:
+		return s.UserID == res.OwnerID || s.HasRole(RoleAdmin)
+	case ActionResolveDispute:
+		return s.IsStaff()
+	case ActionRaiseEmergency:
+		return true
+	case ActionClearEmergency:
+		return s.UserID == res.OwnerID || s.HasAnyRole(RoleCampusResponder, RoleModerator, RoleAdmin)
+	case ActionManageRoles, ActionManagePlaces:
+		return s.HasRole(RoleAdmin)
+	}
+
+	return false
+}
@@ -0,0 +1,153 @@
+package policy
+
+import "testing"
+
+func TestCanErrandActionsRequireParty(t *testing.T) {
+	errand := Resource{OwnerID: "alice", AssigneeID: "runner"}
+	actions := []Action{
+		ActionUpdateErrandStatus,
+		ActionCancelErrand,
+		ActionViewChat,
+		ActionSendMessage,
+		ActionOpenDispute,
+		ActionAddDisputeStatement,
+		ActionViewDispute,
+	}
+
+	for _, action := range actions {
+		if !Can(Subject{UserID: "alice"}, action, errand) {
+			t.Errorf("%s: requester should be allowed", action)
+		}
+		if !Can(Subject{UserID: "runner"}, action, errand) {
+			t.Errorf("%s: runner should be allowed", action)
+		}
+		if Can(Subject{UserID: "mallory"}, action, errand) {
+			t.Errorf("%s: unrelated user should be denied", action)
+		}
+		if Can(Subject{UserID: "mallory", Roles: []Role{RoleVerifiedRunner, RoleCampusResponder}}, action, errand) {
+			t.Errorf("%s: non-staff roles should not override ownership", action)
+		}
+	}
+}
+
+func TestCanStaffOverrides(t *testing.T) {
+	errand := Resource{OwnerID: "alice", AssigneeID: "runner"}
+	mod := Subject{UserID: "mod", Roles: []Role{RoleModerator}}
+	admin := Subject{UserID: "root", Roles: []Role{RoleAdmin}}
+
+	for _, s := range []Subject{mod, admin} {
+		if !Can(s, ActionCancelErrand, errand) {
+			t.Errorf("%s should be able to cancel any errand", s.UserID)
+		}
+		if !Can(s, ActionResolveDispute, Resource{}) {
+			t.Errorf("%s should be able to resolve disputes", s.UserID)
+		}
+		if Can(s, ActionSendMessage, errand) {
+			t.Errorf("%s should not be able to speak in someone else's chat", s.UserID)
+		}
+	}
+
+	if Can(mod, ActionManageRoles, Resource{}) {
+		t.Error("moderators must not manage roles")
+	}
+	if !Can(admin, ActionManageRoles, Resource{}) {
+		t.Error("admins must manage roles")
+	}
+}
+
+func TestCanRejectsAnonymous(t *testing.T) {
+	for _, action := range []Action{ActionAcceptErrand, ActionRaiseEmergency, ActionViewTravelPlan} {
+		if Can(Subject{}, action, Resource{}) {
+			t.Errorf("%s: anonymous subject should be denied", action)
+		}
+	}
+}
+
+func TestCanClearEmergency(t *testing.T) {
+	beacon := Resource{OwnerID: "alice"}
+
+	if !Can(Subject{UserID: "alice"}, ActionClearEmergency, beacon) {
+		t.Error("the user who raised the emergency should be able to clear it")
+	}
+	if Can(Subject{UserID: "bob"}, ActionClearEmergency, beacon) {
+		t.Error("other students must not clear someone else's emergency")
+	}
+	if !Can(Subject{UserID: "guard", Roles: []Role{RoleCampusResponder}}, ActionClearEmergency, beacon) {
+		t.Error("campus responders should be able to clear any emergency")
+	}
+}
+
+func TestCanManageTravelPlan(t *testing.T) {
+	plan := Resource{OwnerID: "alice", CampusID: "north"}
+
+	if !Can(Subject{UserID: "alice", CampusID: "north"}, ActionManageTravelPlan, plan) {
+		t.Error("owners should be able to edit their own plans")
+	}
+	if Can(Subject{UserID: "mod", CampusID: "north", Roles: []Role{RoleModerator}}, ActionManageTravelPlan, plan) {
+		t.Error("moderators may view but not edit someone else's plans")
+	}
+	if !Can(Subject{UserID: "root", Roles: []Role{RoleAdmin}}, ActionManageTravelPlan, plan) {
+		t.Error("admins should be able to edit any plan")
+	}
+}
+
+func TestRolesFromClaims(t *testing.T) {
+	claims := map[string]interface{}{
+		"roles": []interface{}{"moderator", "bogus"},
+		"role":  "campus_responder",
+		"admin": true,
+	}
+	got := RolesFromClaims(claims)
+	want := map[Role]bool{RoleModerator: true, RoleCampusResponder: true, RoleAdmin: true}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for _, r := range got {
+		if !want[r] {
+			t.Errorf("unexpected role %q", r)
+		}
+	}
+}
+
+func TestCanCampusGates(t *testing.T) {
+	outsider := Subject{UserID: "gmail-user"}
+	member := Subject{UserID: "student", CampusVerified: true}
+	runner := Subject{UserID: "runner", CampusVerified: true, Roles: []Role{RoleVerifiedRunner}}
+	cheap := Resource{OwnerID: "alice", Value: HighValueThreshold - 1}
+	pricey := Resource{OwnerID: "alice", Value: HighValueThreshold}
+
+	if Can(outsider, ActionCreateErrand, Resource{}) || Can(outsider, ActionAcceptErrand, cheap) {
+		t.Error("users without a verified campus email must not post or accept errands")
+	}
+	if !Can(member, ActionCreateErrand, Resource{}) || !Can(member, ActionAcceptErrand, cheap) {
+		t.Error("campus members should post and accept regular errands")
+	}
+	if Can(member, ActionAcceptErrand, pricey) {
+		t.Error("high-value errands must require the verified runner tier")
+	}
+	if !Can(runner, ActionAcceptErrand, pricey) {
+		t.Error("verified runners should accept high-value errands")
+	}
+	if Can(Subject{UserID: "runner", Roles: []Role{RoleVerifiedRunner}}, ActionAcceptErrand, pricey) {
+		t.Error("the runner tier must not bypass campus email verification")
+	}
+}
+
+func TestCanIsolatesCampuses(t *testing.T) {
+	errand := Resource{OwnerID: "alice", CampusID: "north"}
+	southMod := Subject{UserID: "mod", CampusID: "south", Roles: []Role{RoleModerator}}
+	admin := Subject{UserID: "root", CampusID: "south", Roles: []Role{RoleAdmin}}
+
+	if Can(Subject{UserID: "alice", CampusID: "south"}, ActionCancelErrand, errand) {
+		t.Error("ownership must not carry across campuses")
+	}
+	if Can(southMod, ActionCancelErrand, errand) {
+		t.Error("moderators are limited to their own campus")
+	}
+	if !Can(admin, ActionCancelErrand, errand) {
+		t.Error("admins operate across campuses")
+	}
+	if !Can(Subject{UserID: "alice", CampusID: "north"}, ActionCancelErrand, errand) {
+		t.Error("owner on the same campus should be allowed")
+	}
+}
@@ -0,0 +1,45 @@
+package policy
+
+// RolesFromClaims extracts roles from Firebase custom claims. Both
+// {"roles": ["moderator"]} and {"role": "admin"} shapes are accepted, as
+// well as the common {"admin": true} flag.
+func RolesFromClaims(claims map[string]interface{}) []Role {
+	var roles []Role
+	add := func(v interface{}) {
+		if s, ok := v.(string); ok {
+			if r, ok := ParseRole(s); ok {
+				roles = append(roles, r)
+			}
+		}
+	}
+
+	switch v := claims["roles"].(type) {
+	case []interface{}:
+		for _, item := range v {
+			add(item)
+		}
+	case string:
+		add(v)
+	}
+	add(claims["role"])
+	if isAdmin, ok := claims["admin"].(bool); ok && isAdmin {
+		roles = append(roles, RoleAdmin)
+	}
+
+	return roles
+}
+
+// MergeRoles combines role sets without duplicates
+func MergeRoles(sets ...[]Role) []Role {
+	seen := map[Role]bool{}
+	var merged []Role
+	for _, set := range sets {
+		for _, r := range set {
+			if !seen[r] {
+				seen[r] = true
+				merged = append(merged, r)
+			}
+		}
+	}
+	return merged
+}
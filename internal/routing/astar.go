@@ -0,0 +1,125 @@
+package routing
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Woeter69/hackoverflow/internal/geo"
+)
+
+// Route is a computed path between two locations
+type Route struct {
+	Mode     Mode
+	Path     geo.LineString
+	Distance float64       // meters
+	Duration time.Duration // estimated travel time
+}
+
+// Route finds the fastest path for the mode with A*. The origin and
+// destination are snapped to the nearest usable vertex, and the walk to and
+// from the network is included in the result.
+func (g *Graph) Route(from, to geo.Point, mode Mode) (*Route, error) {
+	speed, ok := speeds[mode]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownMode, mode)
+	}
+
+	start, startGap := g.nearest(from, mode)
+	goal, goalGap := g.nearest(to, mode)
+	if start < 0 || goal < 0 || startGap > MaxSnapDistance || goalGap > MaxSnapDistance {
+		return nil, ErrOffNetwork
+	}
+
+	// Costs are seconds. Every factor is >= 1, so straight-line distance at
+	// full speed never overestimates and A* stays optimal.
+	heuristic := func(n int) float64 {
+		return geo.Distance(g.nodes[n], g.nodes[goal]) / speed
+	}
+
+	cost := map[int]float64{start: 0}
+	prev := map[int]int{}
+	open := &frontier{{node: start, priority: heuristic(start)}}
+	closed := map[int]bool{}
+
+	for open.Len() > 0 {
+		current := heap.Pop(open).(item).node
+		if current == goal {
+			break
+		}
+		if closed[current] {
+			continue
+		}
+		closed[current] = true
+
+		for _, e := range g.adj[current] {
+			factor := e.factor[mode]
+			if factor <= 0 || closed[e.to] {
+				continue
+			}
+			next := cost[current] + e.length/speed*factor
+			if known, seen := cost[e.to]; !seen || next < known {
+				cost[e.to] = next
+				prev[e.to] = current
+				heap.Push(open, item{node: e.to, priority: next + heuristic(e.to)})
+			}
+		}
+	}
+
+	seconds, reached := cost[goal]
+	if !reached {
+		return nil, ErrNoRoute
+	}
+
+	var nodes []int
+	for n := goal; ; n = prev[n] {
+		nodes = append(nodes, n)
+		if n == start {
+			break
+		}
+	}
+
+	path := geo.LineString{from}
+	for i := len(nodes) - 1; i >= 0; i-- {
+		if p := g.nodes[nodes[i]]; p != path[len(path)-1] {
+			path = append(path, p)
+		}
+	}
+	if to != path[len(path)-1] {
+		path = append(path, to)
+	}
+	if len(path) < 2 {
+		// Origin and destination coincide
+		path = append(path, to)
+	}
+
+	// Getting on and off the network is done on foot at walking pace
+	seconds += (startGap + goalGap) / speeds[ModeWalk]
+
+	return &Route{
+		Mode:     mode,
+		Path:     path,
+		Distance: path.Length(),
+		Duration: time.Duration(math.Round(seconds)) * time.Second,
+	}, nil
+}
+
+type item struct {
+	node     int
+	priority float64
+}
+
+// frontier is a min-heap of nodes by estimated total cost
+type frontier []item
+
+func (f frontier) Len() int            { return len(f) }
+func (f frontier) Less(i, j int) bool  { return f[i].priority < f[j].priority }
+func (f frontier) Swap(i, j int)       { f[i], f[j] = f[j], f[i] }
+func (f *frontier) Push(x interface{}) { *f = append(*f, x.(item)) }
+func (f *frontier) Pop() interface{} {
+	old := *f
+	last := old[len(old)-1]
+	*f = old[:len(old)-1]
+	return last
+}
@@ -0,0 +1,233 @@
+// Package routing computes walking, cycling and driving routes over a
+// campus path network held in memory. Networks are loaded from OSM XML or
+// GeoJSON extracts on disk, one per campus.
+package routing
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Woeter69/hackoverflow/internal/geo"
+)
+
+// Mode is how a route is travelled. Values match travel_plans.mode.
+type Mode string
+
+const (
+	ModeWalk  Mode = "walk"
+	ModeCycle Mode = "cycle"
+	ModeCar   Mode = "car"
+	ModeCab   Mode = "cab"
+)
+
+// Typical speeds in meters per second
+var speeds = map[Mode]float64{
+	ModeWalk:  1.4,
+	ModeCycle: 4.5,
+	ModeCar:   8.3, // 30 km/h on campus roads
+}
+
+var (
+	ErrUnknownMode = errors.New("unknown travel mode")
+	ErrOffNetwork  = errors.New("location is too far from any path")
+	ErrNoRoute     = errors.New("no route between these locations")
+)
+
+// MaxSnapDistance is how far (meters) an origin or destination may be from
+// the nearest usable path
+var MaxSnapDistance = 250.0
+
+// ParseMode normalises a mode name; cabs route like cars
+func ParseMode(s string) (Mode, error) {
+	m := Mode(strings.ToLower(strings.TrimSpace(s)))
+	if m == "" {
+		return ModeWalk, nil
+	}
+	if m == ModeCab {
+		return ModeCar, nil
+	}
+	if _, ok := speeds[m]; !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownMode, s)
+	}
+	return m, nil
+}
+
+// TravelTime estimates how long a distance takes at the mode's typical speed,
+// for trips whose route was not computed on a network
+func TravelTime(mode Mode, meters float64) time.Duration {
+	if mode == ModeCab {
+		mode = ModeCar
+	}
+	speed, ok := speeds[mode]
+	if !ok {
+		speed = speeds[ModeWalk]
+	}
+	return time.Duration(math.Round(meters/speed)) * time.Second
+}
+
+// edge is one directed hop in the graph. factor scales the travel time for
+// each mode; 0 means the mode may not use the edge.
+type edge struct {
+	to     int
+	length float64 // meters
+	factor map[Mode]float64
+}
+
+// Graph is a routable path network
+type Graph struct {
+	nodes  []geo.Point
+	adj    [][]edge
+	usable map[Mode][]bool  // vertices touched by an edge the mode may use
+	index  map[[2]int64]int // rounded coordinate -> node, to join shared vertices
+}
+
+func NewGraph() *Graph {
+	g := &Graph{usable: make(map[Mode][]bool), index: make(map[[2]int64]int)}
+	for m := range speeds {
+		g.usable[m] = nil
+	}
+	return g
+}
+
+// Nodes returns the number of vertices in the network
+func (g *Graph) Nodes() int {
+	return len(g.nodes)
+}
+
+// node returns the vertex at p, creating it if needed. Coordinates within
+// about a centimetre are treated as the same vertex.
+func (g *Graph) node(p geo.Point) int {
+	key := [2]int64{int64(math.Round(p.Lng * 1e7)), int64(math.Round(p.Lat * 1e7))}
+	if id, ok := g.index[key]; ok {
+		return id
+	}
+	g.nodes = append(g.nodes, p)
+	g.adj = append(g.adj, nil)
+	for m := range g.usable {
+		g.usable[m] = append(g.usable[m], false)
+	}
+	g.index[key] = len(g.nodes) - 1
+	return len(g.nodes) - 1
+}
+
+// AddWay adds a polyline to the network. Tags follow OpenStreetMap
+// conventions (highway, foot, bicycle, access, oneway) and decide which modes
+// may use it and how fast.
+func (g *Graph) AddWay(points []geo.Point, tags map[string]string) {
+	forward, backward := wayAccess(tags)
+	if len(forward) == 0 && len(backward) == 0 {
+		return
+	}
+	for i := 1; i < len(points); i++ {
+		a, b := g.node(points[i-1]), g.node(points[i])
+		if a == b {
+			continue
+		}
+		length := geo.Distance(points[i-1], points[i])
+		for m := range forward {
+			g.usable[m][a], g.usable[m][b] = true, true
+		}
+		for m := range backward {
+			g.usable[m][a], g.usable[m][b] = true, true
+		}
+		if len(forward) > 0 {
+			g.adj[a] = append(g.adj[a], edge{to: b, length: length, factor: forward})
+		}
+		if len(backward) > 0 {
+			g.adj[b] = append(g.adj[b], edge{to: a, length: length, factor: backward})
+		}
+	}
+}
+
+// wayAccess returns the per-mode time factors for travelling along and
+// against the way
+func wayAccess(tags map[string]string) (forward, backward map[Mode]float64) {
+	highway := tags["highway"]
+	if highway == "" || tags["access"] == "no" || tags["access"] == "private" || tags["area"] == "yes" {
+		return nil, nil
+	}
+
+	both := map[Mode]float64{}
+	switch highway {
+	case "footway", "pedestrian", "corridor", "living_street":
+		both[ModeWalk] = 1
+	case "path", "track":
+		both[ModeWalk] = 1
+		both[ModeCycle] = 1.2
+	case "steps":
+		both[ModeWalk] = 2 // slower going
+	case "cycleway":
+		both[ModeCycle] = 1
+		both[ModeWalk] = 1
+	case "motorway", "motorway_link", "trunk", "trunk_link":
+		both[ModeCar] = 1
+	case "primary", "primary_link", "secondary", "secondary_link", "tertiary", "tertiary_link",
+		"unclassified", "residential", "service", "road":
+		both[ModeWalk] = 1
+		both[ModeCycle] = 1
+		both[ModeCar] = 1
+	default:
+		return nil, nil
+	}
+
+	switch tags["foot"] {
+	case "no":
+		delete(both, ModeWalk)
+	case "yes", "designated", "permissive":
+		if _, ok := both[ModeWalk]; !ok {
+			both[ModeWalk] = 1
+		}
+	}
+	switch tags["bicycle"] {
+	case "no", "dismount":
+		delete(both, ModeCycle)
+	case "yes", "designated", "permissive":
+		if _, ok := both[ModeCycle]; !ok {
+			both[ModeCycle] = 1
+		}
+	}
+	switch tags["motor_vehicle"] {
+	case "no", "private":
+		delete(both, ModeCar)
+	}
+
+	forward = both
+	backward = make(map[Mode]float64, len(both))
+	for m, f := range both {
+		backward[m] = f
+	}
+
+	// One-way streets bind vehicles; cyclists may be exempt and walkers always are
+	switch tags["oneway"] {
+	case "yes", "true", "1":
+		delete(backward, ModeCar)
+		if tags["oneway:bicycle"] != "no" {
+			delete(backward, ModeCycle)
+		}
+	case "-1", "reverse":
+		delete(forward, ModeCar)
+		if tags["oneway:bicycle"] != "no" {
+			delete(forward, ModeCycle)
+		}
+	}
+	return forward, backward
+}
+
+// nearest returns the closest vertex with an outgoing or incoming edge
+// usable by the mode
+func (g *Graph) nearest(p geo.Point, mode Mode) (int, float64) {
+	usable := g.usable[mode]
+	best, bestDist := -1, math.Inf(1)
+	for id, n := range g.nodes {
+		if !usable[id] {
+			continue
+		}
+		if d := geo.Distance(p, n); d < bestDist {
+			best, bestDist = id, d
+		}
+	}
+	return best, bestDist
+}
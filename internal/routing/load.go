@@ -0,0 +1,196 @@
+package routing
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Woeter69/hackoverflow/internal/geo"
+)
+
+// ErrPBFUnsupported is returned for .osm.pbf extracts, which need a protobuf
+// decoder this build does not include
+var ErrPBFUnsupported = errors.New("OSM PBF extracts are not supported; convert to OSM XML first (e.g. osmium cat campus.osm.pbf -o campus.osm)")
+
+// LoadOSMXML builds a network from an OSM XML extract. Nodes must appear
+// before the ways that use them, as in standard extracts.
+func LoadOSMXML(r io.Reader) (*Graph, error) {
+	g := NewGraph()
+	nodes := make(map[int64]geo.Point)
+	decoder := xml.NewDecoder(r)
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse OSM XML: %w", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		switch start.Name.Local {
+		case "node":
+			var n struct {
+				ID  int64   `xml:"id,attr"`
+				Lat float64 `xml:"lat,attr"`
+				Lon float64 `xml:"lon,attr"`
+			}
+			if err := decoder.DecodeElement(&n, &start); err != nil {
+				return nil, fmt.Errorf("parse OSM node: %w", err)
+			}
+			nodes[n.ID] = geo.Point{Lng: n.Lon, Lat: n.Lat}
+		case "way":
+			var w struct {
+				ID   int64 `xml:"id,attr"`
+				Refs []struct {
+					Ref int64 `xml:"ref,attr"`
+				} `xml:"nd"`
+				Tags []struct {
+					K string `xml:"k,attr"`
+					V string `xml:"v,attr"`
+				} `xml:"tag"`
+			}
+			if err := decoder.DecodeElement(&w, &start); err != nil {
+				return nil, fmt.Errorf("parse OSM way: %w", err)
+			}
+			tags := make(map[string]string, len(w.Tags))
+			for _, t := range w.Tags {
+				tags[t.K] = t.V
+			}
+			points := make([]geo.Point, 0, len(w.Refs))
+			for _, nd := range w.Refs {
+				// Extracts clipped to a bounding box drop nodes outside it
+				if p, ok := nodes[nd.Ref]; ok {
+					points = append(points, p)
+				}
+			}
+			g.AddWay(points, tags)
+		}
+	}
+	return g, nil
+}
+
+// LoadGeoJSON builds a network from a FeatureCollection of LineString or
+// MultiLineString features whose properties carry OSM-style tags
+func LoadGeoJSON(r io.Reader) (*Graph, error) {
+	var fc struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.NewDecoder(r).Decode(&fc); err != nil {
+		return nil, fmt.Errorf("parse GeoJSON: %w", err)
+	}
+	if fc.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("parse GeoJSON: expected a FeatureCollection, got %q", fc.Type)
+	}
+
+	g := NewGraph()
+	for i, f := range fc.Features {
+		tags := make(map[string]string, len(f.Properties))
+		for k, v := range f.Properties {
+			if v != nil {
+				tags[k] = fmt.Sprint(v)
+			}
+		}
+
+		var lines [][][]float64
+		switch f.Geometry.Type {
+		case "LineString":
+			var line [][]float64
+			if err := json.Unmarshal(f.Geometry.Coordinates, &line); err != nil {
+				return nil, fmt.Errorf("parse GeoJSON feature %d: %w", i, err)
+			}
+			lines = [][][]float64{line}
+		case "MultiLineString":
+			if err := json.Unmarshal(f.Geometry.Coordinates, &lines); err != nil {
+				return nil, fmt.Errorf("parse GeoJSON feature %d: %w", i, err)
+			}
+		default:
+			continue
+		}
+
+		for _, line := range lines {
+			points := make([]geo.Point, 0, len(line))
+			for _, pos := range line {
+				if len(pos) < 2 {
+					return nil, fmt.Errorf("parse GeoJSON feature %d: positions must be [lng, lat]", i)
+				}
+				points = append(points, geo.Point{Lng: pos[0], Lat: pos[1]})
+			}
+			g.AddWay(points, tags)
+		}
+	}
+	return g, nil
+}
+
+// LoadFile loads a network, choosing the format from the file extension
+func LoadFile(path string) (*Graph, error) {
+	lower := strings.ToLower(path)
+	if strings.HasSuffix(lower, ".pbf") {
+		return nil, ErrPBFUnsupported
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	switch {
+	case strings.HasSuffix(lower, ".osm"), strings.HasSuffix(lower, ".xml"):
+		return LoadOSMXML(f)
+	case strings.HasSuffix(lower, ".geojson"), strings.HasSuffix(lower, ".json"):
+		return LoadGeoJSON(f)
+	default:
+		return nil, fmt.Errorf("unrecognised network file %s (want .osm, .xml, .geojson or .json)", path)
+	}
+}
+
+// Networks holds the path network of each campus
+type Networks map[string]*Graph
+
+// LoadDir loads every network file in dir. Files are named after the campus
+// they belong to, e.g. default.osm or north-campus.geojson. A missing
+// directory yields no networks.
+func LoadDir(dir string) (Networks, error) {
+	networks := Networks{}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return networks, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		name := entry.Name()
+		campusID := strings.SplitN(name, ".", 2)[0]
+		if _, dup := networks[campusID]; dup {
+			return nil, fmt.Errorf("campus %s has more than one network file", campusID)
+		}
+		g, err := LoadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("load %s: %w", name, err)
+		}
+		networks[campusID] = g
+	}
+	return networks, nil
+}
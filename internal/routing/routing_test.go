@@ -0,0 +1,166 @@
+package routing
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Woeter69/hackoverflow/internal/geo"
+)
+
+// A small campus: a one-way service road around the east side and a
+// footpath cutting straight across from the gate to the library.
+const campusOSM = `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6">
+  <node id="1" lat="12.9700" lon="77.5900"/>
+  <node id="2" lat="12.9700" lon="77.5920"/>
+  <node id="3" lat="12.9720" lon="77.5920"/>
+  <node id="4" lat="12.9720" lon="77.5900"/>
+  <way id="10">
+    <nd ref="1"/><nd ref="2"/><nd ref="3"/>
+    <tag k="highway" v="service"/>
+    <tag k="oneway" v="yes"/>
+  </way>
+  <way id="11">
+    <nd ref="1"/><nd ref="4"/><nd ref="3"/>
+    <tag k="highway" v="footway"/>
+  </way>
+  <way id="12">
+    <nd ref="4"/><nd ref="2"/>
+    <tag k="highway" v="footway"/>
+    <tag k="access" v="private"/>
+  </way>
+</osm>`
+
+var (
+	gate    = geo.Point{Lng: 77.5900, Lat: 12.9700}
+	library = geo.Point{Lng: 77.5920, Lat: 12.9720}
+)
+
+func loadCampus(t *testing.T) *Graph {
+	t.Helper()
+	g, err := LoadOSMXML(strings.NewReader(campusOSM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Nodes() != 4 {
+		t.Fatalf("expected 4 nodes, got %d", g.Nodes())
+	}
+	return g
+}
+
+func TestRouteFollowsModeAccess(t *testing.T) {
+	g := loadCampus(t)
+
+	walk, err := g.Route(gate, library, ModeWalk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Walkers take the footway via node 4 (the private shortcut is closed)
+	if len(walk.Path) != 3 || walk.Path[1] != (geo.Point{Lng: 77.5900, Lat: 12.9720}) {
+		t.Fatalf("unexpected walking path %v", walk.Path)
+	}
+	if walk.Distance < 400 || walk.Distance > 460 {
+		t.Fatalf("walking distance %.0fm, want ~435m", walk.Distance)
+	}
+	if walk.Duration.Seconds() < 250 || walk.Duration.Seconds() > 350 {
+		t.Fatalf("walking duration %v, want ~5 minutes", walk.Duration)
+	}
+
+	car, err := g.Route(gate, library, ModeCar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Cars must use the service road via node 2
+	if len(car.Path) != 3 || car.Path[1] != (geo.Point{Lng: 77.5920, Lat: 12.9700}) {
+		t.Fatalf("unexpected driving path %v", car.Path)
+	}
+	if car.Duration >= walk.Duration {
+		t.Fatalf("driving (%v) should beat walking (%v)", car.Duration, walk.Duration)
+	}
+}
+
+func TestRouteRespectsOneway(t *testing.T) {
+	g := loadCampus(t)
+	if _, err := g.Route(library, gate, ModeCar); !errors.Is(err, ErrNoRoute) {
+		t.Fatalf("driving against the one-way road: %v", err)
+	}
+	if _, err := g.Route(library, gate, ModeWalk); err != nil {
+		t.Fatalf("walkers ignore one-way roads: %v", err)
+	}
+}
+
+func TestRouteSnapsEndpoints(t *testing.T) {
+	g := loadCampus(t)
+
+	nearGate := geo.Point{Lng: 77.58995, Lat: 12.96995}
+	r, err := g.Route(nearGate, library, ModeWalk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Path[0] != nearGate || r.Path[len(r.Path)-1] != library {
+		t.Fatalf("path should start and end at the requested points: %v", r.Path)
+	}
+
+	farAway := geo.Point{Lng: 77.6500, Lat: 12.9700}
+	if _, err := g.Route(farAway, library, ModeWalk); !errors.Is(err, ErrOffNetwork) {
+		t.Fatalf("far origin: %v", err)
+	}
+}
+
+func TestLoadGeoJSON(t *testing.T) {
+	g, err := LoadGeoJSON(strings.NewReader(`{
+		"type": "FeatureCollection",
+		"features": [
+			{"type": "Feature", "properties": {"highway": "cycleway"},
+			 "geometry": {"type": "LineString", "coordinates": [[77.5900, 12.9700], [77.5910, 12.9710]]}},
+			{"type": "Feature", "properties": {"highway": "steps"},
+			 "geometry": {"type": "MultiLineString", "coordinates": [[[77.5910, 12.9710], [77.5910, 12.9712]]]}},
+			{"type": "Feature", "properties": {"amenity": "cafe"},
+			 "geometry": {"type": "Point", "coordinates": [77.5910, 12.9710]}}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Nodes() != 3 {
+		t.Fatalf("expected 3 nodes, got %d", g.Nodes())
+	}
+	end := geo.Point{Lng: 77.5910, Lat: 12.9712}
+	if _, err := g.Route(gate, end, ModeWalk); err != nil {
+		t.Fatalf("walk over cycleway and steps: %v", err)
+	}
+
+	// With no walking allowance the top of the steps is out of reach by bike
+	defer func(d float64) { MaxSnapDistance = d }(MaxSnapDistance)
+	MaxSnapDistance = 5
+	if _, err := g.Route(gate, end, ModeCycle); !errors.Is(err, ErrOffNetwork) {
+		t.Fatalf("bikes cannot climb steps: %v", err)
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for in, want := range map[string]Mode{"": ModeWalk, "Walk": ModeWalk, "cycle": ModeCycle, "cab": ModeCar} {
+		if got, err := ParseMode(in); err != nil || got != want {
+			t.Errorf("ParseMode(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ParseMode("hovercraft"); !errors.Is(err, ErrUnknownMode) {
+		t.Errorf("unknown mode: %v", err)
+	}
+}
+
+func TestTravelTime(t *testing.T) {
+	if got := TravelTime(ModeWalk, 840); got.Minutes() != 10 {
+		t.Errorf("840m on foot = %v, want 10m", got)
+	}
+	if TravelTime(ModeCab, 1000) != TravelTime(ModeCar, 1000) {
+		t.Errorf("cabs should travel like cars")
+	}
+}
+
+func TestLoadFileRejectsPBF(t *testing.T) {
+	if _, err := LoadFile("campus.osm.pbf"); !errors.Is(err, ErrPBFUnsupported) {
+		t.Fatalf("PBF: %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+// Package schedule expands the weekly recurrence rules of repeating travel
+// plans. Rules use iCalendar RRULE syntax limited to FREQ=WEEKLY with
+// INTERVAL, BYDAY, COUNT and UNTIL, which covers "every weekday at 9:00".
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	// Series are expanded in the traveller's zone; embed the zone database
+	// so minimal container images can load it
+	_ "time/tzdata"
+)
+
+var ErrInvalidRule = errors.New("invalid recurrence rule")
+
+// Rule is a weekly recurrence
+type Rule struct {
+	Interval int            // repeat every Interval weeks
+	Days     []time.Weekday // Monday first; empty means the weekday of the first departure
+	Count    int            // total departures, 0 for no limit
+	Until    time.Time      // last possible departure, zero for no end
+}
+
+var dayCodes = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+// mondayOffset is the number of days from Monday to d (weeks start on Monday)
+func mondayOffset(d time.Weekday) int {
+	return (int(d) + 6) % 7
+}
+
+func invalid(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidRule, fmt.Sprintf(format, args...))
+}
+
+// ParseRule parses an RRULE value such as FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR.
+// A leading "RRULE:" is allowed.
+func ParseRule(s string) (Rule, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(strings.ToUpper(s)), "RRULE:")
+	r := Rule{Interval: 1}
+	seen := map[string]bool{}
+	wkst := "MO"
+	for _, part := range strings.Split(s, ";") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return Rule{}, invalid("expected NAME=VALUE, got %q", part)
+		}
+		if seen[key] {
+			return Rule{}, invalid("%s appears twice", key)
+		}
+		seen[key] = true
+
+		switch key {
+		case "FREQ":
+			if value != "WEEKLY" {
+				return Rule{}, invalid("only FREQ=WEEKLY is supported")
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > 52 {
+				return Rule{}, invalid("INTERVAL must be between 1 and 52")
+			}
+			r.Interval = n
+		case "BYDAY":
+			for _, code := range strings.Split(value, ",") {
+				day, ok := dayCodes[code]
+				if !ok {
+					return Rule{}, invalid("unknown day %q in BYDAY", code)
+				}
+				r.Days = append(r.Days, day)
+			}
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return Rule{}, invalid("COUNT must be a positive number")
+			}
+			r.Count = n
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return Rule{}, err
+			}
+			r.Until = until
+		case "WKST":
+			if _, ok := dayCodes[value]; !ok {
+				return Rule{}, invalid("unknown day %q in WKST", value)
+			}
+			wkst = value
+		default:
+			return Rule{}, invalid("unsupported part %s", key)
+		}
+	}
+	if !seen["FREQ"] {
+		return Rule{}, invalid("FREQ is required")
+	}
+	// The week start only changes which weeks an interval skips; calendar
+	// apps often send WKST=SU on plain weekly rules
+	if wkst != "MO" && r.Interval > 1 {
+		return Rule{}, invalid("only WKST=MO is supported with INTERVAL")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return Rule{}, invalid("COUNT and UNTIL cannot be combined")
+	}
+
+	sort.Slice(r.Days, func(i, j int) bool { return mondayOffset(r.Days[i]) < mondayOffset(r.Days[j]) })
+	days := r.Days[:0]
+	for i, d := range r.Days {
+		if i == 0 || d != r.Days[i-1] {
+			days = append(days, d)
+		}
+	}
+	r.Days = days
+	return r, nil
+}
+
+// parseUntil accepts a UTC date-time (20261231T235959Z) or a date, which
+// includes the whole day
+func parseUntil(v string) (time.Time, error) {
+	if t, err := time.Parse("20060102T150405Z", v); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("20060102", v); err == nil {
+		return t.Add(24*time.Hour - time.Second), nil
+	}
+	return time.Time{}, invalid("UNTIL must be YYYYMMDD or YYYYMMDDTHHMMSSZ")
+}
+
+// String renders the rule in canonical RRULE form
+func (r Rule) String() string {
+	parts := []string{"FREQ=WEEKLY"}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.Days) > 0 {
+		codes := make([]string, len(r.Days))
+		for i, d := range r.Days {
+			codes[i] = strings.ToUpper(d.String()[:2])
+		}
+		parts = append(parts, "BYDAY="+strings.Join(codes, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
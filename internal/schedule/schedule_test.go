@@ -0,0 +1,93 @@
+package schedule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func mustRule(t *testing.T, s string) Rule {
+	t.Helper()
+	r, err := ParseRule(s)
+	if err != nil {
+		t.Fatalf("ParseRule(%q): %v", s, err)
+	}
+	return r
+}
+
+func TestParseRule(t *testing.T) {
+	r := mustRule(t, "RRULE:freq=weekly;byday=FR,MO,WE,MO;interval=2")
+	if r.Interval != 2 || len(r.Days) != 3 || r.Days[0] != time.Monday || r.Days[2] != time.Friday {
+		t.Fatalf("unexpected rule %+v", r)
+	}
+	if r.String() != "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE,FR" {
+		t.Fatalf("String() = %s", r.String())
+	}
+
+	until := mustRule(t, "FREQ=WEEKLY;UNTIL=20261218")
+	if want := time.Date(2026, 12, 18, 23, 59, 59, 0, time.UTC); !until.Until.Equal(want) {
+		t.Fatalf("UNTIL = %v, want %v", until.Until, want)
+	}
+
+	mustRule(t, "FREQ=WEEKLY;WKST=SU;BYDAY=MO,WE")
+
+	for _, bad := range []string{
+		"", "FREQ=DAILY", "FREQ=WEEKLY;INTERVAL=2;WKST=SU", "BYDAY=MO", "FREQ=WEEKLY;BYDAY=1MO", "FREQ=WEEKLY;INTERVAL=0",
+		"FREQ=WEEKLY;COUNT=3;UNTIL=20261231", "FREQ=WEEKLY;BYMONTH=1", "FREQ=WEEKLY;FREQ=WEEKLY",
+	} {
+		if _, err := ParseRule(bad); !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("ParseRule(%q) = %v, want ErrInvalidRule", bad, err)
+		}
+	}
+}
+
+func TestWeekdaysWithHoliday(t *testing.T) {
+	kolkata, _ := time.LoadLocation("Asia/Kolkata")
+	s := Series{
+		Rule:       mustRule(t, "FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"),
+		Start:      time.Date(2026, 10, 21, 9, 0, 0, 0, kolkata), // a Wednesday
+		Exceptions: []string{"2026-10-26"},
+	}
+	got := s.Between(time.Date(2026, 10, 19, 0, 0, 0, 0, kolkata), time.Date(2026, 10, 29, 0, 0, 0, 0, kolkata))
+	want := []string{"2026-10-21", "2026-10-22", "2026-10-23", "2026-10-27", "2026-10-28"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v", got)
+	}
+	for i, d := range want {
+		if got[i].Format(DateLayout) != d || got[i].Hour() != 9 {
+			t.Fatalf("departure %d = %v, want %s 09:00", i, got[i], d)
+		}
+	}
+}
+
+func TestWallClockSurvivesDST(t *testing.T) {
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	s := Series{Rule: mustRule(t, "FREQ=WEEKLY"), Start: time.Date(2026, 10, 19, 9, 0, 0, 0, berlin)}
+	got := s.Next(s.Start, 3)
+	// Clocks go back on 25 October; the departure stays at 9:00 local time
+	if len(got) != 3 || got[1].Hour() != 9 || got[1].Sub(got[0]) != 7*24*time.Hour+time.Hour {
+		t.Fatalf("unexpected departures %v", got)
+	}
+}
+
+func TestCountIntervalAndUntil(t *testing.T) {
+	start := time.Date(2026, 10, 19, 8, 30, 0, 0, time.UTC) // a Monday
+	counted := Series{Rule: mustRule(t, "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH;COUNT=3"), Start: start, Exceptions: []string{"2026-10-22"}}
+	got := counted.Next(start, 10)
+	// The skipped Thursday still counts towards COUNT
+	if len(got) != 2 || got[0].Format(DateLayout) != "2026-10-19" || got[1].Format(DateLayout) != "2026-11-02" {
+		t.Fatalf("counted departures %v", got)
+	}
+
+	bounded := Series{Rule: mustRule(t, "FREQ=WEEKLY;UNTIL=20261102"), Start: start}
+	if got := bounded.Next(start, 10); len(got) != 3 {
+		t.Fatalf("bounded departures %v", got)
+	}
+
+	// Looking far ahead skips the intervening weeks but stays on the cycle
+	far := Series{Rule: mustRule(t, "FREQ=WEEKLY;INTERVAL=3"), Start: start}
+	next := far.Next(start.AddDate(1, 0, 0), 1)
+	if len(next) != 1 || int(next[0].Sub(start).Hours()/24)%21 != 0 {
+		t.Fatalf("far departure %v", next)
+	}
+}
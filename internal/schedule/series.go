@@ -0,0 +1,99 @@
+package schedule
+
+import "time"
+
+// DateLayout is how exception dates are written
+const DateLayout = "2006-01-02"
+
+// Series is a rule anchored at its first departure. Departures keep the
+// wall-clock time of Start in its location, so a 9:00 trip stays at 9:00
+// across daylight-saving changes.
+type Series struct {
+	Rule
+	Start time.Time
+
+	// Exceptions are dates (DateLayout, in Start's location) with no
+	// departure, e.g. holidays. They still count towards Rule.Count.
+	Exceptions []string
+}
+
+// Between returns the departures in [from, to), in order
+func (s Series) Between(from, to time.Time) []time.Time {
+	var out []time.Time
+	s.walk(from, func(t time.Time) bool {
+		if !t.Before(to) {
+			return false
+		}
+		out = append(out, t)
+		return true
+	})
+	return out
+}
+
+// Next returns up to n departures at or after from
+func (s Series) Next(from time.Time, n int) []time.Time {
+	var out []time.Time
+	if n <= 0 {
+		return out
+	}
+	s.walk(from, func(t time.Time) bool {
+		out = append(out, t)
+		return len(out) < n
+	})
+	return out
+}
+
+// walk calls fn with each departure at or after from until fn returns
+// false or the series ends
+func (s Series) walk(from time.Time, fn func(time.Time) bool) {
+	start := s.Start
+	loc := start.Location()
+	interval := s.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	days := s.Days
+	if len(days) == 0 {
+		days = []time.Weekday{start.Weekday()}
+	}
+	skip := make(map[string]bool, len(s.Exceptions))
+	for _, d := range s.Exceptions {
+		skip[d] = true
+	}
+
+	y, m, d := start.Date()
+	firstMonday := time.Date(y, m, d, 0, 0, 0, 0, loc).AddDate(0, 0, -mondayOffset(start.Weekday()))
+
+	// Without a count to keep track of, jump straight to the weeks near from
+	week := 0
+	if s.Count == 0 && from.After(start) {
+		if behind := int(from.Sub(firstMonday).Hours()/(24*7)) - 1; behind > 0 {
+			week = behind - behind%interval
+		}
+	}
+
+	departures := 0
+	for ; ; week += interval {
+		monday := firstMonday.AddDate(0, 0, 7*week)
+		for _, day := range days {
+			date := monday.AddDate(0, 0, mondayOffset(day))
+			t := time.Date(date.Year(), date.Month(), date.Day(), start.Hour(), start.Minute(), start.Second(), 0, loc)
+			if t.Before(start) {
+				continue
+			}
+			departures++
+			if s.Count > 0 && departures > s.Count {
+				return
+			}
+			if !s.Until.IsZero() && t.After(s.Until) {
+				return
+			}
+			if t.Before(from) || skip[t.Format(DateLayout)] {
+				continue
+			}
+			if !fn(t) {
+				return
+			}
+		}
+	}
+}
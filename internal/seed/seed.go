@@ -0,0 +1,218 @@
+// Package seed generates synthetic campus activity for development and load
+// testing: users on a campus, errands between random points inside its
+// boundary and travel plans crossing it. Generated users are marked by the
+// seed.invalid email domain so they are easy to find and remove.
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/Woeter69/hackoverflow/internal/geo"
+	"github.com/Woeter69/hackoverflow/internal/models"
+	"github.com/Woeter69/hackoverflow/internal/store"
+)
+
+// EmailDomain is the domain of every generated user's email
+const EmailDomain = "seed.invalid"
+
+// DefaultRadius (meters) is the area points are scattered over on campuses
+// without a boundary
+const DefaultRadius = 500
+
+// maxSamples bounds the attempts to find a point inside a boundary, which
+// can cover little of its bounding box
+const maxSamples = 1000
+
+// Options sizes the generated data. Center and Radius are only used when
+// the campus has no boundary.
+type Options struct {
+	CampusID string
+	Users    int
+	Errands  int
+	Plans    int
+	Center   geo.Point
+	Radius   float64
+	Rand     *rand.Rand
+}
+
+// Summary counts what Generate created
+type Summary struct {
+	Users   int
+	Errands int
+	Plans   int
+}
+
+var (
+	firstNames = []string{"Aarav", "Diya", "Kabir", "Meera", "Rohan", "Ananya", "Vikram", "Ishita", "Arjun", "Sara"}
+	lastNames  = []string{"Sharma", "Iyer", "Khan", "Reddy", "Das", "Patel", "Nair", "Gupta", "Singh", "Rao"}
+	modes      = []string{"walk", "walk", "cycle"}
+	titles     = map[string][]string{
+		"delivery": {"Pick up my parcel", "Bring lunch from the canteen", "Collect printouts"},
+		"borrow":   {"Need a calculator for the exam", "Borrow a lab coat", "Need a phone charger"},
+		"favor":    {"Return my library books", "Drop off an assignment", "Water the plants"},
+	}
+	genericTitles = []string{"Quick errand", "Small favour needed", "Help me out"}
+)
+
+// area picks random points on a campus
+type area struct {
+	boundary geo.Polygon
+	bounds   geo.BBox
+	rnd      *rand.Rand
+}
+
+func newArea(campus models.Campus, opts Options, rnd *rand.Rand) (*area, error) {
+	a := &area{rnd: rnd}
+	if len(campus.Boundary) > 0 {
+		boundary, err := geo.ParsePolygon(string(campus.Boundary))
+		if err != nil {
+			return nil, fmt.Errorf("campus %s has an unreadable boundary: %w", campus.ID, err)
+		}
+		a.boundary = boundary
+		a.bounds = boundary.Bounds()
+		return a, nil
+	}
+
+	radius := opts.Radius
+	if radius <= 0 {
+		radius = DefaultRadius
+	}
+	if opts.Center == (geo.Point{}) {
+		return nil, errors.New("a center is required for a campus without a boundary")
+	}
+	// Degrees of latitude are ~111 km everywhere; longitude shrinks with it
+	dLat := radius / 111_320
+	dLng := dLat / math.Cos(opts.Center.Lat*math.Pi/180)
+	a.bounds = geo.BBox{
+		MinLng: opts.Center.Lng - dLng, MinLat: opts.Center.Lat - dLat,
+		MaxLng: opts.Center.Lng + dLng, MaxLat: opts.Center.Lat + dLat,
+	}
+	return a, nil
+}
+
+func (a *area) point() (geo.Point, error) {
+	for i := 0; i < maxSamples; i++ {
+		pt := geo.Point{
+			Lng: a.bounds.MinLng + a.rnd.Float64()*(a.bounds.MaxLng-a.bounds.MinLng),
+			Lat: a.bounds.MinLat + a.rnd.Float64()*(a.bounds.MaxLat-a.bounds.MinLat),
+		}
+		if len(a.boundary) == 0 || a.boundary.Contains(pt) {
+			return pt, nil
+		}
+	}
+	return geo.Point{}, errors.New("could not find a point inside the campus boundary")
+}
+
+func pick(rnd *rand.Rand, options []string) string {
+	return options[rnd.Intn(len(options))]
+}
+
+// Generate creates the requested users on the campus, then errands and
+// travel plans owned by them. Users are keyed by campus and number, so
+// running it again refreshes the same users instead of adding more.
+func Generate(ctx context.Context, st *store.Store, opts Options) (Summary, error) {
+	var sum Summary
+	if opts.Users < 1 && (opts.Errands > 0 || opts.Plans > 0) {
+		return sum, errors.New("errands and plans need at least one user")
+	}
+	campus, err := st.Campuses.Get(ctx, opts.CampusID)
+	if err != nil {
+		return sum, fmt.Errorf("campus %s: %w", opts.CampusID, err)
+	}
+	rnd := opts.Rand
+	if rnd == nil {
+		rnd = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	where, err := newArea(campus, opts, rnd)
+	if err != nil {
+		return sum, err
+	}
+
+	userIDs := make([]string, 0, opts.Users)
+	for i := 1; i <= opts.Users; i++ {
+		id := fmt.Sprintf("seed-%s-%d", campus.ID, i)
+		claims := store.Claims{
+			Email:         fmt.Sprintf("%s@%s", id, EmailDomain),
+			Name:          pick(rnd, firstNames) + " " + pick(rnd, lastNames),
+			EmailVerified: true,
+		}
+		if _, err := st.Users.Provision(ctx, id, claims); err != nil {
+			return sum, fmt.Errorf("user %s: %w", id, err)
+		}
+		if err := st.Users.AssignCampus(ctx, id, campus.ID); err != nil {
+			return sum, fmt.Errorf("user %s: %w", id, err)
+		}
+		userIDs = append(userIDs, id)
+		sum.Users++
+	}
+
+	categories := campus.Categories
+	if len(categories) == 0 {
+		categories = []string{"delivery"}
+	}
+	for i := 0; i < opts.Errands; i++ {
+		pickup, err := where.point()
+		if err != nil {
+			return sum, err
+		}
+		dropoff, err := where.point()
+		if err != nil {
+			return sum, err
+		}
+		category := pick(rnd, categories)
+		title := pick(rnd, genericTitles)
+		if options, ok := titles[category]; ok {
+			title = pick(rnd, options)
+		}
+		e := models.ErrandRequest{
+			UserID:         userIDs[rnd.Intn(len(userIDs))],
+			CampusID:       campus.ID,
+			Title:          title,
+			Description:    "Generated sample errand",
+			Pickup:         models.PointFromGeo(pickup),
+			Dropoff:        models.PointFromGeo(dropoff),
+			Category:       category,
+			UrgencyLevel:   1 + rnd.Intn(3), // 1 (whenever) to 3 (as soon as possible)
+			RewardEstimate: float64(5 * (1 + rnd.Intn(20))),
+		}
+		if err := st.Errands.Create(ctx, &e); err != nil {
+			return sum, fmt.Errorf("errand: %w", err)
+		}
+		sum.Errands++
+	}
+
+	now := time.Now()
+	for i := 0; i < opts.Plans; i++ {
+		origin, err := where.point()
+		if err != nil {
+			return sum, err
+		}
+		destination, err := where.point()
+		if err != nil {
+			return sum, err
+		}
+		p := models.TravelPlan{
+			UserID:          userIDs[rnd.Intn(len(userIDs))],
+			CampusID:        campus.ID,
+			OriginName:      "Sample origin",
+			DestinationName: "Sample destination",
+			Origin:          models.PointFromGeo(origin),
+			Destination:     models.PointFromGeo(destination),
+			Route:           models.LineStringFromGeo(geo.LineString{origin, destination}),
+			Mode:            pick(rnd, modes),
+			// Departures spread over the next two days, on the quarter hour
+			StartTime:      now.Add(time.Duration(1+rnd.Intn(192)) * 15 * time.Minute).Truncate(15 * time.Minute),
+			SeatsAvailable: 1,
+		}
+		if err := st.TravelPlans.Create(ctx, &p); err != nil {
+			return sum, fmt.Errorf("travel plan: %w", err)
+		}
+		sum.Plans++
+	}
+	return sum, nil
+}
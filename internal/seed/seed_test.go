@@ -0,0 +1,79 @@
+package seed
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"github.com/Woeter69/hackoverflow/internal/geo"
+	"github.com/Woeter69/hackoverflow/internal/models"
+	"github.com/Woeter69/hackoverflow/internal/store"
+)
+
+func TestGenerateStaysInsideBoundary(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory().Store()
+	// An L-shaped campus, so sampling its bounding box alone would miss
+	boundary := geo.Polygon{{
+		{Lng: 0, Lat: 0}, {Lng: 0.01, Lat: 0}, {Lng: 0.01, Lat: 0.002},
+		{Lng: 0.002, Lat: 0.002}, {Lng: 0.002, Lat: 0.01}, {Lng: 0, Lat: 0.01}, {Lng: 0, Lat: 0},
+	}}
+	campus := models.Campus{ID: "ell", Name: "L Campus", Categories: []string{"delivery", "borrow"}}
+	if err := st.Campuses.Create(ctx, &campus, boundary); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := Options{CampusID: "ell", Users: 3, Errands: 20, Plans: 5, Rand: rand.New(rand.NewSource(1))}
+	sum, err := Generate(ctx, st, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum != (Summary{Users: 3, Errands: 20, Plans: 5}) {
+		t.Fatalf("unexpected summary %+v", sum)
+	}
+
+	page, err := st.Errands.Feed(ctx, store.ErrandQuery{CampusID: "ell", Statuses: []string{"pending"}, Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 20 {
+		t.Fatalf("expected 20 errands, got %d", page.Total)
+	}
+	for _, e := range page.Errands {
+		if !boundary.Contains(e.Pickup.Geo()) || !boundary.Contains(e.Dropoff.Geo()) {
+			t.Fatalf("errand %s lies outside the campus", e.ID)
+		}
+		if e.Category != "delivery" && e.Category != "borrow" {
+			t.Fatalf("errand %s has category %q", e.ID, e.Category)
+		}
+	}
+
+	u, err := st.Users.Get(ctx, "seed-ell-1")
+	if err != nil || u.CampusID != "ell" {
+		t.Fatalf("expected a seeded user on the campus, got %+v, %v", u, err)
+	}
+
+	// Users are reused on a second run
+	if _, err := Generate(ctx, st, Options{CampusID: "ell", Users: 3, Rand: opts.Rand}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := st.Users.Get(ctx, "seed-ell-4"); err != store.ErrNotFound {
+		t.Fatalf("expected no fourth user, got %v", err)
+	}
+}
+
+func TestGenerateNeedsACenterWithoutBoundary(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory().Store()
+	if _, err := Generate(ctx, st, Options{CampusID: "default", Users: 1, Errands: 1}); err == nil {
+		t.Fatal("expected an error without a boundary or center")
+	}
+	center := geo.Point{Lng: 77.5946, Lat: 12.9716}
+	sum, err := Generate(ctx, st, Options{CampusID: "default", Users: 1, Errands: 3, Center: center, Radius: 200})
+	if err != nil || sum.Errands != 3 {
+		t.Fatalf("expected 3 errands, got %+v, %v", sum, err)
+	}
+	if _, err := Generate(ctx, st, Options{CampusID: "nowhere", Users: 1}); err == nil {
+		t.Fatal("expected an unknown campus to fail")
+	}
+}
@@ -0,0 +1,247 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Woeter69/hackoverflow/internal/models"
+	"github.com/Woeter69/hackoverflow/internal/policy"
+)
+
+// Audited actions
+const (
+	AuditCreditsAward       = "credits.award"    // reward paid on completion
+	AuditCreditsClawback    = "credits.clawback" // reward frozen by a dispute
+	AuditCreditsAdjust      = "credits.adjust"   // operator correction
+	AuditErrandCancel       = "errand.cancel"
+	AuditEmergencyRaise     = "emergency.raise"
+	AuditEmergencyClear     = "emergency.clear"
+	AuditRoleGrant          = "role.grant"
+	AuditRoleRevoke         = "role.revoke"
+	AuditCampusAssign       = "user.assign_campus"  // admin moved a user
+	AuditDisputeResolve     = "dispute.resolve"     // admin released a held reward
+	AuditVerificationReview = "verification.review" // admin decided a runner application
+)
+
+// AuditGenesis is the previous hash of the first entry
+var AuditGenesis = strings.Repeat("0", 64)
+
+// Audit holds the append-only log of sensitive actions. Every entry carries
+// a hash of its contents and of the entry before it, so altering, removing
+// or reordering entries is detected by VerifyAudit.
+type Audit interface {
+	// Append chains an entry onto the log, filling in its ID, PrevHash,
+	// Hash and CreatedAt
+	Append(ctx context.Context, e *models.AuditEntry) error
+	// List returns the entries matching q, newest first
+	List(ctx context.Context, q AuditQuery) ([]models.AuditEntry, error)
+	// Walk calls fn with every entry, oldest first, and stops at the first
+	// error fn returns
+	Walk(ctx context.Context, fn func(models.AuditEntry) error) error
+}
+
+// AuditQuery selects audit entries. Zero values disable a filter.
+type AuditQuery struct {
+	Actor  string
+	Action string
+	Target string
+	Since  time.Time // inclusive
+	Until  time.Time // exclusive
+	Before int64     // only entries with a lower id, for paging
+	Limit  int
+}
+
+// Actor is who makes an audited change, and the request they made it in
+// (empty outside a request, e.g. for operator commands). Writes that move
+// credits or roles record their audit entry in the same transaction, so the
+// change and its entry commit or fail together.
+type Actor struct {
+	ID        string
+	RequestID string
+}
+
+// entry describes an action taken by a on target
+func (a Actor) entry(action, target string, before, after interface{}) models.AuditEntry {
+	return models.AuditEntry{
+		Actor:     a.ID,
+		Action:    action,
+		Target:    target,
+		Before:    Snapshot(before),
+		After:     Snapshot(after),
+		RequestID: a.RequestID,
+	}
+}
+
+// balance is a user's credits and experience, as audited around a move
+type balance struct {
+	Credits int
+	XP      int
+}
+
+// snapshot records the balance along with details of the move
+func (b balance) snapshot(details map[string]interface{}) map[string]interface{} {
+	snap := map[string]interface{}{"credits": b.Credits, "xp": b.XP}
+	for k, v := range details {
+		snap[k] = v
+	}
+	return snap
+}
+
+// resolutionSnapshot records a dispute and its runner's balance before the
+// resolution r, or after it
+func resolutionSnapshot(errandID, runnerID string, held int, runner balance, r *DisputeResolution) map[string]interface{} {
+	snap := map[string]interface{}{
+		"status":         "open",
+		"errand_id":      errandID,
+		"held_amount":    held,
+		"runner_id":      runnerID,
+		"runner_credits": runner.Credits,
+		"runner_xp":      runner.XP,
+	}
+	if r != nil {
+		snap["status"] = "resolved"
+		snap["outcome"] = r.Outcome
+		snap["payout_amount"] = r.Payout
+		snap["refund_amount"] = r.Refund
+		snap["errand_status"] = r.ErrandStatus
+	}
+	return snap
+}
+
+// reviewSnapshot records a runner application's status around its review
+func reviewSnapshot(userID, status string) map[string]interface{} {
+	return map[string]interface{}{"status": status, "user_id": userID}
+}
+
+// beaconSnapshot is a beacon as the audit log records it, nil for none:
+// without the message, which is the user's own words
+func beaconSnapshot(b *models.Beacon) interface{} {
+	if b == nil {
+		return nil
+	}
+	return map[string]interface{}{
+		"id":          b.ID,
+		"user_id":     b.UserID,
+		"building_id": b.BuildingID,
+		"place_id":    b.PlaceID,
+		"expires_at":  b.ExpiresAt,
+	}
+}
+
+// rolesSnapshot records a user's stored roles, none as [] rather than null
+func rolesSnapshot(roles []policy.Role) map[string]interface{} {
+	if roles == nil {
+		roles = []policy.Role{}
+	}
+	return map[string]interface{}{"roles": roles}
+}
+
+// AuditTarget names the thing an action was taken on, e.g. "errand:<id>"
+func AuditTarget(kind, id string) string {
+	return kind + ":" + id
+}
+
+// Snapshot encodes the state of a target before or after an action; nil
+// records no state
+func Snapshot(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// canonicalJSON re-encodes a snapshot with sorted keys and no spacing, so
+// its hash survives the round trip through a JSONB column
+func canonicalJSON(raw json.RawMessage) (json.RawMessage, error) {
+	if len(raw) == 0 {
+		return json.RawMessage("null"), nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// AuditHash is the hash an entry must carry: SHA-256 over its fields,
+// including the previous entry's hash
+func AuditHash(e models.AuditEntry) (string, error) {
+	before, err := canonicalJSON(e.Before)
+	if err != nil {
+		return "", fmt.Errorf("before: %w", err)
+	}
+	after, err := canonicalJSON(e.After)
+	if err != nil {
+		return "", fmt.Errorf("after: %w", err)
+	}
+	fields, err := json.Marshal([]interface{}{
+		e.ID, e.CreatedAt.UTC().Format(time.RFC3339Nano), e.Actor, e.Action, e.Target,
+		before, after, e.RequestID, e.PrevHash,
+	})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(fields)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// seal fills in the hash of an entry whose ID, PrevHash and CreatedAt are set
+func seal(e *models.AuditEntry) error {
+	hash, err := AuditHash(*e)
+	if err != nil {
+		return &InvalidError{Reason: "snapshot is not JSON: " + err.Error()}
+	}
+	e.Hash = hash
+	return nil
+}
+
+// AuditBreak is where the audit chain stops verifying
+type AuditBreak struct {
+	ID     int64
+	Reason string
+}
+
+func (b *AuditBreak) Error() string {
+	return fmt.Sprintf("audit entry %d: %s", b.ID, b.Reason)
+}
+
+// VerifyAudit recomputes the audit chain from the first entry. It returns
+// the number of entries and the hash of the last one, or an *AuditBreak at
+// the first entry that was altered or does not follow the one before it.
+// Entries removed from the end leave a shorter, valid chain, so the last
+// hash should be compared with one recorded elsewhere.
+func VerifyAudit(ctx context.Context, a Audit) (int64, string, error) {
+	var n int64
+	prev := AuditGenesis
+	err := a.Walk(ctx, func(e models.AuditEntry) error {
+		n++
+		switch {
+		case e.ID != n:
+			return &AuditBreak{ID: e.ID, Reason: fmt.Sprintf("expected entry %d; entries are missing", n)}
+		case e.PrevHash != prev:
+			return &AuditBreak{ID: e.ID, Reason: "does not follow the entry before it"}
+		}
+		hash, err := AuditHash(e)
+		if err != nil {
+			return &AuditBreak{ID: e.ID, Reason: err.Error()}
+		}
+		if hash != e.Hash {
+			return &AuditBreak{ID: e.ID, Reason: "contents do not match its hash"}
+		}
+		prev = e.Hash
+		return nil
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	return n, prev, nil
+}
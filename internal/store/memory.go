@@ -0,0 +1,1615 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Woeter69/hackoverflow/internal/geo"
+	"github.com/Woeter69/hackoverflow/internal/models"
+	"github.com/Woeter69/hackoverflow/internal/policy"
+	"github.com/google/uuid"
+)
+
+// Memory is an in-process store for tests. Distances are great-circle or
+// measured on a local plane, which agrees with PostGIS to well under a meter
+// at campus scale.
+type Memory struct {
+	mu       sync.Mutex
+	campuses map[string]*memCampus
+	domains  map[string]models.EmailDomain
+	users    map[string]*memUser
+	errands  map[uuid.UUID]*models.ErrandRequest
+	events   []models.ErrandEvent
+	plans    map[uuid.UUID]*models.TravelPlan
+	messages []models.Message
+	beacons  map[string]models.Beacon // active beacon per campus
+	places   map[string]*memPlace
+	// credit corrections, as credit_adjustments records them
+	adjustments []memAdjustment
+	audit       []models.AuditEntry // oldest first
+	disputes    []*memDispute       // oldest first
+	series      map[string]*models.TravelPlanSeries
+	// runner applications, oldest first
+	verifications []*models.RunnerVerification
+	feeds         map[string]string // user -> calendar feed token hash
+}
+
+type memAdjustment struct {
+	CreditAdjustment
+	userID  string
+	actor   string
+	balance int
+	at      time.Time
+}
+
+type memCampus struct {
+	models.Campus
+	boundary geo.Polygon
+}
+
+type memUser struct {
+	models.User
+	campusVerified bool
+	roles          map[policy.Role]bool
+}
+
+type memPlace struct {
+	models.Place
+	shape PlaceShape
+}
+
+// NewMemory returns an empty store holding only the default campus, as a
+// freshly migrated database does
+func NewMemory() *Memory {
+	m := &Memory{
+		campuses: map[string]*memCampus{},
+		domains:  map[string]models.EmailDomain{},
+		users:    map[string]*memUser{},
+		errands:  map[uuid.UUID]*models.ErrandRequest{},
+		plans:    map[uuid.UUID]*models.TravelPlan{},
+		beacons:  map[string]models.Beacon{},
+		places:   map[string]*memPlace{},
+		series:   map[string]*models.TravelPlanSeries{},
+		feeds:    map[string]string{},
+	}
+	m.campuses["default"] = &memCampus{Campus: models.Campus{
+		ID:             "default",
+		Name:           "Main Campus",
+		CurrencyName:   "Credits",
+		DefaultBufferM: 200,
+		Categories:     []string{"delivery", "borrow", "favor"},
+		CreatedAt:      now(),
+	}}
+	return m
+}
+
+// Store returns the repositories backed by m
+func (m *Memory) Store() *Store {
+	return &Store{
+		Errands:       (*memErrands)(m),
+		TravelPlans:   (*memTravelPlans)(m),
+		Users:         (*memUsers)(m),
+		Messages:      (*memMessages)(m),
+		Beacons:       (*memBeacons)(m),
+		Campuses:      (*memCampuses)(m),
+		Places:        (*memPlaces)(m),
+		Audit:         (*memAudit)(m),
+		Disputes:      (*memDisputes)(m),
+		Series:        (*memSeries)(m),
+		Verifications: (*memVerifications)(m),
+		EmailDomains:  (*memEmailDomains)(m),
+		CalendarFeeds: (*memCalendarFeeds)(m),
+	}
+}
+
+// AddEmailDomain allow-lists an email domain for a campus, so users signing
+// in from it are provisioned onto the campus
+func (m *Memory) AddEmailDomain(domain, campusID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	domain = normalizeText(domain)
+	m.domains[domain] = models.EmailDomain{Domain: domain, CampusID: campusID, CreatedAt: now()}
+}
+
+// now matches the microsecond precision of Postgres timestamps, so values
+// survive a round trip through a feed cursor
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// geometryJSON renders a geometry as the GeoJSON PostGIS would return
+func geometryJSON(g geo.Geometry) json.RawMessage {
+	data, _ := json.Marshal(g)
+	return data
+}
+
+// errandDistance is how far pt is from an errand's pickup
+func errandDistance(e *models.ErrandRequest, pt geo.Point) float64 {
+	return geo.Distance(e.Pickup.Geo(), pt)
+}
+
+type memErrands Memory
+
+// withPlaces fills in the names of an errand's places
+func (s *memErrands) withPlaces(e models.ErrandRequest) models.ErrandRequest {
+	if p, ok := s.places[e.PickupPlaceID]; ok {
+		e.PickupPlaceName = p.Name
+	} else {
+		e.PickupPlaceID, e.PickupPlaceName = "", ""
+	}
+	if p, ok := s.places[e.DropoffPlaceID]; ok {
+		e.DropoffPlaceName = p.Name
+	} else {
+		e.DropoffPlaceID, e.DropoffPlaceName = "", ""
+	}
+	return e
+}
+
+func (s *memErrands) Create(ctx context.Context, e *models.ErrandRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.campuses[e.CampusID]; !ok {
+		return ErrNotFound
+	}
+	if _, ok := s.users[e.UserID]; !ok {
+		return ErrNotFound
+	}
+	e.ID = uuid.New()
+	e.Status = "pending"
+	e.CreatedAt = now()
+	stored := *e
+	s.errands[e.ID] = &stored
+	return nil
+}
+
+func (s *memErrands) Get(ctx context.Context, id string) (models.ErrandRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, err := uuid.Parse(id)
+	if err != nil {
+		return models.ErrandRequest{}, ErrNotFound
+	}
+	e, ok := s.errands[key]
+	if !ok {
+		return models.ErrandRequest{}, ErrNotFound
+	}
+	return s.withPlaces(*e), nil
+}
+
+// sortKey is the value an errand is ranked by in the feed
+func sortKey(e *models.ErrandRequest, q ErrandQuery) float64 {
+	switch q.Sort {
+	case SortReward:
+		return e.RewardEstimate
+	case SortUrgency:
+		return float64(e.UrgencyLevel)
+	case SortDistance:
+		return errandDistance(e, *q.Near)
+	}
+	return 0
+}
+
+// comparePositions orders feed positions ascending by key, age and id
+func comparePositions(a, b FeedPosition) int {
+	switch {
+	case a.Key != b.Key:
+		return compare(a.Key < b.Key)
+	case !a.CreatedAt.Equal(b.CreatedAt):
+		return compare(a.CreatedAt.Before(b.CreatedAt))
+	case a.ID != b.ID:
+		return compare(a.ID < b.ID)
+	}
+	return 0
+}
+
+func compare(less bool) int {
+	if less {
+		return -1
+	}
+	return 1
+}
+
+func contains(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memErrands) matches(e *models.ErrandRequest, q ErrandQuery) bool {
+	pickup := e.Pickup.Geo()
+	switch {
+	case e.CampusID != q.CampusID || !contains(q.Statuses, e.Status):
+		return false
+	case q.Near != nil && geo.Distance(pickup, *q.Near) > q.Radius:
+		return false
+	case q.Area != nil && !q.Area.Contains(pickup):
+		return false
+	case len(q.Categories) > 0 && !contains(q.Categories, e.Category):
+		return false
+	case q.MinReward > 0 && e.RewardEstimate < q.MinReward:
+		return false
+	case q.MinUrgency > 0 && e.UrgencyLevel < q.MinUrgency:
+		return false
+	}
+	return true
+}
+
+func (s *memErrands) Feed(ctx context.Context, q ErrandQuery) (ErrandPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type ranked struct {
+		errand *models.ErrandRequest
+		pos    FeedPosition
+	}
+	var all []ranked
+	for _, e := range s.errands {
+		if s.matches(e, q) {
+			all = append(all, ranked{e, FeedPosition{Key: sortKey(e, q), CreatedAt: e.CreatedAt, ID: e.ID.String()}})
+		}
+	}
+	// direction is 1 for ascending orders and -1 for descending ones
+	direction := 1
+	if q.Sort.descending() {
+		direction = -1
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return comparePositions(all[i].pos, all[j].pos)*direction < 0
+	})
+
+	page := ErrandPage{Errands: []FeedErrand{}, Total: len(all)}
+	var last FeedPosition
+	for _, r := range all {
+		if q.After != nil && comparePositions(r.pos, *q.After)*direction <= 0 {
+			continue
+		}
+		if len(page.Errands) == q.Limit {
+			page.Next = &last
+			break
+		}
+		last = r.pos
+		e := FeedErrand{ErrandRequest: s.withPlaces(*r.errand)}
+		if q.Near != nil {
+			d := errandDistance(r.errand, *q.Near)
+			e.Distance = &d
+		}
+		page.Errands = append(page.Errands, e)
+	}
+	return page, nil
+}
+
+func (s *memErrands) NearRoute(ctx context.Context, campusID string, route geo.LineString, within float64) ([]models.MatchResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var matches []models.MatchResponse
+	for _, e := range s.errands {
+		if e.CampusID != campusID || e.Status != "pending" {
+			continue
+		}
+		if d := route.DistanceTo(e.Pickup.Geo()); d <= within {
+			matches = append(matches, models.MatchResponse{Errand: s.withPlaces(*e), DistanceFromRoute: d})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].DistanceFromRoute < matches[j].DistanceFromRoute
+	})
+	return matches, nil
+}
+
+func (s *memErrands) Accept(ctx context.Context, id, runnerID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, err := uuid.Parse(id)
+	if err != nil {
+		return ErrNotFound
+	}
+	if _, ok := s.users[runnerID]; !ok {
+		return ErrNotFound
+	}
+	e, ok := s.errands[key]
+	if !ok || e.Status != "pending" {
+		return ErrConflict
+	}
+	e.Status, e.RunnerID = "matched", runnerID
+	return nil
+}
+
+func (s *memErrands) SetStatus(ctx context.Context, id, status string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, err := uuid.Parse(id)
+	if err != nil {
+		return ErrNotFound
+	}
+	e, ok := s.errands[key]
+	if !ok {
+		return ErrNotFound
+	}
+	if e.Status == "completed" || e.Status == "disputed" {
+		return ErrConflict
+	}
+	e.Status = status
+	return nil
+}
+
+func (s *memErrands) Complete(ctx context.Context, id string, xp int, by Actor) (models.ErrandRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, err := uuid.Parse(id)
+	if err != nil {
+		return models.ErrandRequest{}, ErrNotFound
+	}
+	e, ok := s.errands[key]
+	if !ok {
+		return models.ErrandRequest{}, ErrNotFound
+	}
+	runner, ok := s.users[e.RunnerID]
+	if e.Status != "matched" || !ok {
+		return models.ErrandRequest{}, ErrConflict
+	}
+	before := balance{runner.Credits, runner.XP}
+	after := balance{before.Credits + int(e.RewardEstimate), before.XP + xp}
+	entry := by.entry(AuditCreditsAward, AuditTarget("user", e.RunnerID),
+		before.snapshot(nil), after.snapshot(map[string]interface{}{"errand_id": id}))
+	if err := (*Memory)(s).appendAudit(&entry); err != nil {
+		return models.ErrandRequest{}, err
+	}
+	e.Status = "completed"
+	runner.Credits, runner.XP = after.Credits, after.XP
+	return s.withPlaces(*e), nil
+}
+
+// recordEvent appends an entry to an errand's history; m.mu is held
+func (m *Memory) recordEvent(errandID uuid.UUID, actorID, event string, details map[string]interface{}) error {
+	// Round-trip the details as the JSONB column would
+	payload, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	stored := map[string]interface{}{}
+	json.Unmarshal(payload, &stored)
+	m.events = append(m.events, models.ErrandEvent{
+		ID:        int64(len(m.events) + 1),
+		ErrandID:  errandID,
+		ActorID:   actorID,
+		Event:     event,
+		Details:   stored,
+		CreatedAt: now(),
+	})
+	return nil
+}
+
+func (s *memErrands) RecordEvent(ctx context.Context, errandID, actorID, event string, details map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, err := uuid.Parse(errandID)
+	if err != nil {
+		return &InvalidError{Reason: "invalid input syntax for type uuid: " + errandID}
+	}
+	if _, ok := s.errands[key]; !ok {
+		return ErrNotFound
+	}
+	return (*Memory)(s).recordEvent(key, actorID, event, details)
+}
+
+func (s *memErrands) Events(ctx context.Context, errandID string) ([]models.ErrandEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	events := []models.ErrandEvent{}
+	for _, e := range s.events {
+		if e.ErrandID.String() == errandID {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (s *memErrands) AcceptedBy(ctx context.Context, runnerID string) ([]AcceptedErrand, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	errands := []AcceptedErrand{}
+	for _, e := range s.errands {
+		if e.RunnerID != runnerID || e.Status != "matched" {
+			continue
+		}
+		// An errand was accepted when it last changed to matched
+		accepted := e.CreatedAt
+		for _, ev := range s.events {
+			if ev.ErrandID == e.ID && ev.Event == "status_changed" && ev.Details["status"] == "matched" && ev.CreatedAt.After(accepted) {
+				accepted = ev.CreatedAt
+			}
+		}
+		errands = append(errands, AcceptedErrand{ErrandRequest: s.withPlaces(*e), AcceptedAt: accepted})
+	}
+	return errands, nil
+}
+
+type memTravelPlans Memory
+
+func (s *memTravelPlans) Create(ctx context.Context, p *models.TravelPlan) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.campuses[p.CampusID]; !ok {
+		return ErrNotFound
+	}
+	if _, ok := s.users[p.UserID]; !ok {
+		return ErrNotFound
+	}
+	p.ID = uuid.New()
+	if p.SeatsAvailable == 0 {
+		p.SeatsAvailable = 1
+	}
+	p.IsActive = true
+	p.StartTime = p.StartTime.Truncate(time.Microsecond)
+	p.CreatedAt = now()
+	stored := *p
+	s.plans[p.ID] = &stored
+	return nil
+}
+
+func (s *memTravelPlans) Get(ctx context.Context, id string) (models.TravelPlan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, err := uuid.Parse(id)
+	if err != nil {
+		return models.TravelPlan{}, ErrNotFound
+	}
+	p, ok := s.plans[key]
+	if !ok {
+		return models.TravelPlan{}, ErrNotFound
+	}
+	return *p, nil
+}
+
+func (s *memTravelPlans) ListActive(ctx context.Context, userID, campusID string) ([]models.TravelPlan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	plans := []models.TravelPlan{}
+	for _, p := range s.plans {
+		if p.UserID == userID && p.CampusID == campusID && p.IsActive {
+			plans = append(plans, *p)
+		}
+	}
+	sort.Slice(plans, func(i, j int) bool { return plans[i].StartTime.Before(plans[j].StartTime) })
+	return plans, nil
+}
+
+func (s *memTravelPlans) TravelersNear(ctx context.Context, campusID string, pt geo.Point, within float64) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	seen := map[string]bool{}
+	var userIDs []string
+	for _, p := range s.plans {
+		if !p.IsActive || p.CampusID != campusID || len(p.Route) == 0 || seen[p.UserID] {
+			continue
+		}
+		if p.Route.Geo().DistanceTo(pt) <= within {
+			seen[p.UserID] = true
+			userIDs = append(userIDs, p.UserID)
+		}
+	}
+	return userIDs, nil
+}
+
+func (s *memTravelPlans) Since(ctx context.Context, userID string, since time.Time, limit int) ([]models.TravelPlan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	plans := []models.TravelPlan{}
+	for _, p := range s.plans {
+		if p.UserID == userID && p.IsActive && p.StartTime.After(since) {
+			plans = append(plans, *p)
+		}
+	}
+	sort.Slice(plans, func(i, j int) bool { return plans[i].StartTime.Before(plans[j].StartTime) })
+	if len(plans) > limit {
+		plans = plans[:limit]
+	}
+	return plans, nil
+}
+
+type memUsers Memory
+
+// campusForEmail returns the campus of the most specific allow-listed domain
+// matching the email, if any
+func (s *memUsers) campusForEmail(email string) (string, bool) {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return "", false
+	}
+	host := normalizeText(email[at+1:])
+	best, campusID := "", ""
+	for domain, d := range s.domains {
+		if (host == domain || strings.HasSuffix(host, "."+domain)) && len(domain) > len(best) {
+			best, campusID = domain, d.CampusID
+		}
+	}
+	return campusID, best != ""
+}
+
+func (s *memUsers) Provision(ctx context.Context, uid string, claims Claims) (Membership, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	campusID, matched := s.campusForEmail(claims.Email)
+	u, ok := s.users[uid]
+	if !ok {
+		u = &memUser{User: models.User{ID: uid, Credits: 100, Rating: 5.0, CreatedAt: now()}, roles: map[policy.Role]bool{}}
+		s.users[uid] = u
+	}
+	if claims.Email != "" {
+		u.Email = claims.Email
+	}
+	if u.DisplayName == "" {
+		u.DisplayName = claims.Name
+	}
+	u.EmailVerified = claims.EmailVerified
+	if matched {
+		u.CampusID = campusID
+	}
+	u.campusVerified = claims.EmailVerified && matched
+	return Membership{CampusID: u.CampusID, CampusVerified: u.campusVerified}, nil
+}
+
+func (s *memUsers) Get(ctx context.Context, id string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[id]
+	if !ok {
+		return models.User{}, ErrNotFound
+	}
+	return u.User, nil
+}
+
+func (s *memUsers) UpdateProfile(ctx context.Context, id string, update ProfileUpdate) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[id]
+	if !ok {
+		return models.User{}, ErrNotFound
+	}
+	if update.Username != nil {
+		for _, other := range s.users {
+			if other != u && other.Username != "" && strings.EqualFold(other.Username, *update.Username) {
+				return models.User{}, ErrConflict
+			}
+		}
+		u.Username = *update.Username
+	}
+	if update.DisplayName != nil {
+		u.DisplayName = *update.DisplayName
+	}
+	return u.User, nil
+}
+
+func (s *memUsers) Award(ctx context.Context, id string, credits, xp int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	u.Credits += credits
+	u.XP += xp
+	return nil
+}
+
+func (s *memUsers) AdjustCredits(ctx context.Context, id string, adj CreditAdjustment, by Actor) (int, error) {
+	if err := adj.validate(by); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[id]
+	if !ok {
+		return 0, ErrNotFound
+	}
+	before := balance{u.Credits, u.XP}
+	after := balance{before.Credits + adj.Amount, before.XP}
+	if after.Credits < 0 {
+		return 0, &InvalidError{Reason: fmt.Sprintf("the balance would be %d", after.Credits)}
+	}
+	e := by.entry(AuditCreditsAdjust, AuditTarget("user", id),
+		before.snapshot(nil), after.snapshot(map[string]interface{}{"reason": adj.Reason}))
+	if err := (*Memory)(s).appendAudit(&e); err != nil {
+		return 0, err
+	}
+	u.Credits = after.Credits
+	s.adjustments = append(s.adjustments, memAdjustment{CreditAdjustment: adj, userID: id, actor: by.ID, balance: after.Credits, at: now()})
+	return after.Credits, nil
+}
+
+func (s *memUsers) AssignCampus(ctx context.Context, id, campusID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[id]
+	if _, known := s.campuses[campusID]; !ok || !known {
+		return ErrNotFound
+	}
+	u.CampusID = campusID
+	return nil
+}
+
+func (s *memUsers) Roles(ctx context.Context, id string) ([]policy.Role, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[id]
+	if !ok {
+		return nil, nil
+	}
+	return u.sortedRoles(), nil
+}
+
+// sortedRoles lists the user's stored roles in order, nil for none
+func (u *memUser) sortedRoles() []policy.Role {
+	var roles []policy.Role
+	for r := range u.roles {
+		roles = append(roles, r)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i] < roles[j] })
+	return roles
+}
+
+func (s *memUsers) GrantRole(ctx context.Context, id string, role policy.Role, by Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return (*Memory)(s).changeRoles(id, role, true, AuditRoleGrant, by)
+}
+
+func (s *memUsers) RevokeRole(ctx context.Context, id string, role policy.Role, by Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return (*Memory)(s).changeRoles(id, role, false, AuditRoleRevoke, by)
+}
+
+// changeRoles grants or revokes a role and audits the user's roles before
+// and after; m.mu is held
+func (m *Memory) changeRoles(id string, role policy.Role, held bool, action string, by Actor) error {
+	u, ok := m.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	before := u.sortedRoles()
+	after := []policy.Role{}
+	for _, r := range before {
+		if r != role {
+			after = append(after, r)
+		}
+	}
+	if held {
+		after = append(after, role)
+		sort.Slice(after, func(i, j int) bool { return after[i] < after[j] })
+	}
+	e := by.entry(action, AuditTarget("user", id), rolesSnapshot(before), rolesSnapshot(after))
+	if err := m.appendAudit(&e); err != nil {
+		return err
+	}
+	if held {
+		u.roles[role] = true
+	} else {
+		delete(u.roles, role)
+	}
+	return nil
+}
+
+type memMessages Memory
+
+func (s *memMessages) List(ctx context.Context, errandID string) ([]models.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	messages := []models.Message{}
+	for _, m := range s.messages {
+		if m.ErrandID.String() == errandID {
+			messages = append(messages, m)
+		}
+	}
+	return messages, nil
+}
+
+func (s *memMessages) Create(ctx context.Context, m *models.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.errands[m.ErrandID]; !ok {
+		return ErrNotFound
+	}
+	if _, ok := s.users[m.SenderID]; !ok {
+		return ErrNotFound
+	}
+	m.ID = uuid.New()
+	m.IsEncrypted = true // the column default
+	m.CreatedAt = now()
+	s.messages = append(s.messages, *m)
+	return nil
+}
+
+type memBeacons Memory
+
+func (s *memBeacons) Active(ctx context.Context, campusID string) (models.Beacon, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.beacons[campusID]
+	if !ok || !b.ExpiresAt.After(time.Now()) {
+		return models.Beacon{}, ErrNotFound
+	}
+	if p, ok := s.places[b.PlaceID]; ok {
+		b.PlaceName = p.Name
+	}
+	return b, nil
+}
+
+func (s *memBeacons) Raise(ctx context.Context, b *models.Beacon, by Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.campuses[b.CampusID]; !ok {
+		return ErrNotFound
+	}
+	if _, ok := s.places[b.PlaceID]; b.PlaceID != "" && !ok {
+		return ErrNotFound
+	}
+	raised := *b
+	raised.ID = uuid.NewString()
+	raised.CreatedAt = now()
+	e := by.entry(AuditEmergencyRaise, AuditTarget("campus", b.CampusID), beaconSnapshot((*Memory)(s).activeBeacon(b.CampusID)), beaconSnapshot(&raised))
+	if err := (*Memory)(s).appendAudit(&e); err != nil {
+		return err
+	}
+	*b = raised
+	s.beacons[b.CampusID] = raised
+	return nil
+}
+
+func (s *memBeacons) Clear(ctx context.Context, campusID string, by Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Standing down nothing is not worth an entry
+	if before := (*Memory)(s).activeBeacon(campusID); before != nil {
+		e := by.entry(AuditEmergencyClear, AuditTarget("campus", campusID), beaconSnapshot(before), nil)
+		if err := (*Memory)(s).appendAudit(&e); err != nil {
+			return err
+		}
+	}
+	delete(s.beacons, campusID)
+	return nil
+}
+
+// activeBeacon returns the campus's unexpired beacon, nil for none; m.mu is
+// held
+func (m *Memory) activeBeacon(campusID string) *models.Beacon {
+	b, ok := m.beacons[campusID]
+	if !ok || !b.ExpiresAt.After(time.Now()) {
+		return nil
+	}
+	return &b
+}
+
+type memCampuses Memory
+
+func (s *memCampuses) Get(ctx context.Context, id string) (models.Campus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.campuses[id]
+	if !ok {
+		return models.Campus{}, ErrNotFound
+	}
+	return c.Campus, nil
+}
+
+func (s *memCampuses) List(ctx context.Context) ([]models.Campus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	campuses := []models.Campus{}
+	for _, c := range s.campuses {
+		campuses = append(campuses, c.Campus)
+	}
+	sort.Slice(campuses, func(i, j int) bool { return campuses[i].ID < campuses[j].ID })
+	return campuses, nil
+}
+
+// setBoundary stores a campus boundary and its GeoJSON rendering
+func (c *memCampus) setBoundary(boundary geo.Polygon) {
+	c.boundary = boundary
+	c.Boundary = nil
+	if len(boundary) > 0 {
+		c.Boundary = geometryJSON(boundary.Geometry())
+	}
+}
+
+func (s *memCampuses) Create(ctx context.Context, c *models.Campus, boundary geo.Polygon) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.campuses[c.ID]; ok {
+		return ErrConflict
+	}
+	stored := &memCampus{Campus: *c}
+	if stored.Categories == nil {
+		stored.Categories = []string{}
+	}
+	stored.CreatedAt = now()
+	stored.setBoundary(boundary)
+	s.campuses[c.ID] = stored
+	*c = stored.Campus
+	return nil
+}
+
+func (s *memCampuses) Update(ctx context.Context, id string, update CampusUpdate) (models.Campus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.campuses[id]
+	if !ok {
+		return models.Campus{}, ErrNotFound
+	}
+	if update.Name != nil {
+		c.Name = *update.Name
+	}
+	if update.Boundary != nil {
+		c.setBoundary(*update.Boundary)
+	}
+	if update.CurrencyName != nil {
+		c.CurrencyName = *update.CurrencyName
+	}
+	if update.DefaultBufferM != nil {
+		c.DefaultBufferM = *update.DefaultBufferM
+	}
+	if update.Categories != nil {
+		c.Categories = update.Categories
+	}
+	return c.Campus, nil
+}
+
+type memPlaces Memory
+
+// distance is how far pt is from the place, measured to its footprint when
+// it has one
+func (p *memPlace) distance(pt geo.Point) float64 {
+	if len(p.shape.Footprint) > 0 {
+		return p.shape.Footprint.DistanceTo(pt)
+	}
+	return geo.Distance(p.Location.Geo(), pt)
+}
+
+// pointOnFootprint picks a marker position for a place drawn only as an
+// outline: the vertex average when it falls inside, else the first vertex
+func pointOnFootprint(footprint geo.Polygon) geo.Point {
+	ring := footprint[0]
+	if len(ring) > 1 {
+		ring = ring[:len(ring)-1]
+	}
+	var c geo.Point
+	for _, pt := range ring {
+		c.Lng += pt.Lng / float64(len(ring))
+		c.Lat += pt.Lat / float64(len(ring))
+	}
+	if footprint.Contains(c) {
+		return c
+	}
+	return footprint[0][0]
+}
+
+// setShape stores a place's geometry and its GeoJSON rendering
+func (p *memPlace) setShape(shape PlaceShape) {
+	p.shape = shape
+	if shape.Location != nil {
+		p.Location = models.PointFromGeo(*shape.Location)
+	} else if len(shape.Footprint) > 0 {
+		p.Location = models.PointFromGeo(pointOnFootprint(shape.Footprint))
+	}
+	p.Footprint, p.Entrances = nil, nil
+	if len(shape.Footprint) > 0 {
+		p.Footprint = geometryJSON(shape.Footprint.Geometry())
+	}
+	if len(shape.Entrances) > 0 {
+		coords := make([][2]float64, len(shape.Entrances))
+		for i, pt := range shape.Entrances {
+			coords[i] = [2]float64{pt.Lng, pt.Lat}
+		}
+		p.Entrances = geometryJSON(geo.Geometry{Type: "MultiPoint", Coordinates: coords})
+	}
+}
+
+// nameTaken reports whether another place on the campus has the name
+func (s *memPlaces) nameTaken(p *models.Place) bool {
+	for _, other := range s.places {
+		if other.ID != p.ID && other.CampusID == p.CampusID && strings.EqualFold(other.Name, p.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *memPlaces) Get(ctx context.Context, id string) (models.Place, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.places[id]
+	if !ok {
+		return models.Place{}, ErrNotFound
+	}
+	return p.Place, nil
+}
+
+func (s *memPlaces) Nearest(ctx context.Context, campusID string, pt geo.Point, within float64, kind string) (models.Place, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var nearest *memPlace
+	best := math.Inf(1)
+	for _, p := range s.places {
+		if p.CampusID != campusID || (kind != "" && p.Kind != kind) {
+			continue
+		}
+		d := p.distance(pt)
+		if (within <= 0 || d <= within) && d < best {
+			nearest, best = p, d
+		}
+	}
+	if nearest == nil {
+		return models.Place{}, ErrNotFound
+	}
+	place := nearest.Place
+	place.Distance = &best
+	return place, nil
+}
+
+func (s *memPlaces) Search(ctx context.Context, q PlaceSearch) ([]models.Place, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	type ranked struct {
+		place        models.Place
+		code, prefix bool
+	}
+	var found []ranked
+	for _, p := range s.places {
+		name, code := strings.ToLower(p.Name), strings.ToLower(p.Code)
+		if p.CampusID != q.CampusID || (q.Kind != "" && p.Kind != q.Kind) {
+			continue
+		}
+		if strings.Contains(name, q.Query) || strings.Contains(code, q.Query) {
+			found = append(found, ranked{p.Place, code == q.Query, strings.HasPrefix(name, q.Query)})
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		if a.code != b.code {
+			return a.code
+		}
+		if a.prefix != b.prefix {
+			return a.prefix
+		}
+		return a.place.Name < b.place.Name
+	})
+	places := []models.Place{}
+	for i := 0; i < len(found) && i < q.Limit; i++ {
+		places = append(places, found[i].place)
+	}
+	return places, nil
+}
+
+func (s *memPlaces) Match(ctx context.Context, campusID, text string) (models.Place, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	text = normalizeText(text)
+	var match *memPlace
+	for _, p := range s.places {
+		if p.CampusID != campusID {
+			continue
+		}
+		if p.Code != "" && strings.ToLower(p.Code) == text {
+			return p.Place, nil
+		}
+		if strings.Contains(text, strings.ToLower(p.Name)) && (match == nil || len(p.Name) > len(match.Name)) {
+			match = p
+		}
+	}
+	if match == nil {
+		return models.Place{}, ErrNotFound
+	}
+	return match.Place, nil
+}
+
+func (s *memPlaces) Create(ctx context.Context, p *models.Place, shape PlaceShape) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.campuses[p.CampusID]; !ok {
+		return ErrNotFound
+	}
+	if s.nameTaken(p) {
+		return ErrConflict
+	}
+	stored := &memPlace{Place: *p}
+	stored.ID = uuid.NewString()
+	stored.CreatedAt = now()
+	stored.UpdatedAt = stored.CreatedAt
+	stored.Distance = nil
+	stored.setShape(shape)
+	s.places[stored.ID] = stored
+	*p = stored.Place
+	return nil
+}
+
+func (s *memPlaces) Update(ctx context.Context, p *models.Place, shape PlaceShape) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.places[p.ID]
+	if !ok || stored.CampusID != p.CampusID {
+		return ErrNotFound
+	}
+	if s.nameTaken(p) {
+		return ErrConflict
+	}
+	stored.Name, stored.Kind, stored.Code = p.Name, p.Kind, p.Code
+	stored.UpdatedAt = now()
+	stored.setShape(shape)
+	*p = stored.Place
+	return nil
+}
+
+func (s *memPlaces) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.places[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.places, id)
+	return nil
+}
+
+type memAudit Memory
+
+func (s *memAudit) Append(ctx context.Context, e *models.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return (*Memory)(s).appendAudit(e)
+}
+
+// appendAudit chains an entry onto the log; m.mu is held. Audited writes
+// append before changing anything, so a failed append changes nothing.
+func (m *Memory) appendAudit(e *models.AuditEntry) error {
+	e.ID, e.PrevHash = 1, AuditGenesis
+	if n := len(m.audit); n > 0 {
+		e.ID, e.PrevHash = m.audit[n-1].ID+1, m.audit[n-1].Hash
+	}
+	e.CreatedAt = now()
+	if err := seal(e); err != nil {
+		return err
+	}
+	m.audit = append(m.audit, *e)
+	return nil
+}
+
+func (s *memAudit) List(ctx context.Context, q AuditQuery) ([]models.AuditEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := []models.AuditEntry{}
+	for i := len(s.audit) - 1; i >= 0 && len(entries) < q.Limit; i-- {
+		e := s.audit[i]
+		switch {
+		case q.Actor != "" && e.Actor != q.Actor,
+			q.Action != "" && e.Action != q.Action,
+			q.Target != "" && e.Target != q.Target,
+			!q.Since.IsZero() && e.CreatedAt.Before(q.Since),
+			!q.Until.IsZero() && !e.CreatedAt.Before(q.Until),
+			q.Before > 0 && e.ID >= q.Before:
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func (s *memAudit) Walk(ctx context.Context, fn func(models.AuditEntry) error) error {
+	s.mu.Lock()
+	entries := append([]models.AuditEntry(nil), s.audit...)
+	s.mu.Unlock()
+	for _, e := range entries {
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type memDispute struct {
+	models.Dispute
+	runnerID string
+	held     bool // the credit hold has not been released
+}
+
+// copy returns the dispute with its own statement slice
+func (d *memDispute) copy() models.Dispute {
+	c := d.Dispute
+	c.Statements = append([]models.DisputeStatement{}, d.Statements...)
+	return c
+}
+
+type memDisputes Memory
+
+func (s *memDisputes) Open(ctx context.Context, o DisputeOpening, by Actor) (models.Dispute, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, err := uuid.Parse(o.ErrandID)
+	if err != nil {
+		return models.Dispute{}, ErrNotFound
+	}
+	e, ok := s.errands[key]
+	if !ok {
+		return models.Dispute{}, ErrNotFound
+	}
+	if (e.Status != "matched" && e.Status != "completed") || e.RunnerID == "" {
+		return models.Dispute{}, ErrConflict
+	}
+
+	// A mission disputed before pays what the last resolution released
+	held := int(e.RewardEstimate)
+	for _, prev := range s.disputes {
+		if prev.ErrandID == key && prev.Status == "resolved" {
+			held = int(prev.PayoutAmount)
+		}
+	}
+
+	d := &memDispute{
+		Dispute: models.Dispute{
+			ID:             uuid.New(),
+			ErrandID:       key,
+			OpenedBy:       by.ID,
+			Reason:         o.Reason,
+			PreviousStatus: e.Status,
+			Status:         "open",
+			HeldAmount:     float64(held),
+			CreatedAt:      now(),
+		},
+		runnerID: e.RunnerID,
+		held:     true,
+	}
+
+	// Freeze the payout. Completed missions have already been paid, so reverse it.
+	clawedBack := e.Status == "completed"
+	if u, ok := s.users[e.RunnerID]; ok && clawedBack {
+		before := balance{u.Credits, u.XP}
+		after := balance{before.Credits - held, max(before.XP-o.ClawbackXP, 0)}
+		entry := by.entry(AuditCreditsClawback, AuditTarget("user", e.RunnerID), before.snapshot(nil),
+			after.snapshot(map[string]interface{}{"errand_id": o.ErrandID, "dispute_id": d.ID}))
+		if err := (*Memory)(s).appendAudit(&entry); err != nil {
+			return models.Dispute{}, err
+		}
+		u.Credits, u.XP = after.Credits, after.XP
+	}
+	d.Statements = []models.DisputeStatement{{
+		ID:           uuid.New(),
+		DisputeID:    d.ID,
+		AuthorID:     by.ID,
+		Statement:    o.Reason,
+		EvidenceURLs: nonNilStrings(o.EvidenceURLs),
+		CreatedAt:    d.CreatedAt,
+	}}
+	s.disputes = append(s.disputes, d)
+	e.Status = "disputed"
+	(*Memory)(s).recordEvent(key, by.ID, "dispute_opened", map[string]interface{}{
+		"dispute_id":      d.ID,
+		"previous_status": d.PreviousStatus,
+		"held_amount":     held,
+		"clawed_back":     clawedBack,
+	})
+	return d.copy(), nil
+}
+
+func (s *memDisputes) Latest(ctx context.Context, errandID string) (models.Dispute, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.disputes) - 1; i >= 0; i-- {
+		if d := s.disputes[i]; d.ErrandID.String() == errandID {
+			return d.copy(), nil
+		}
+	}
+	return models.Dispute{}, ErrNotFound
+}
+
+// find returns the dispute with the given ID, or nil
+func (s *memDisputes) find(id uuid.UUID) *memDispute {
+	for _, d := range s.disputes {
+		if d.ID == id {
+			return d
+		}
+	}
+	return nil
+}
+
+func (s *memDisputes) AddStatement(ctx context.Context, st *models.DisputeStatement) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d := s.find(st.DisputeID)
+	if d == nil || d.Status != "open" {
+		return ErrNotFound
+	}
+	st.ID = uuid.New()
+	st.EvidenceURLs = nonNilStrings(st.EvidenceURLs)
+	st.CreatedAt = now()
+	d.Statements = append(d.Statements, *st)
+	return (*Memory)(s).recordEvent(d.ErrandID, st.AuthorID, "dispute_statement_added", map[string]interface{}{"dispute_id": st.DisputeID})
+}
+
+func (s *memDisputes) Resolve(ctx context.Context, disputeID string, r DisputeResolution, by Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, err := uuid.Parse(disputeID)
+	if err != nil {
+		return ErrNotFound
+	}
+	d := s.find(key)
+	if d == nil || d.Status != "open" || !d.held {
+		return ErrConflict
+	}
+	e := s.errands[d.ErrandID]
+	runner, ok := s.users[d.runnerID]
+	if !ok {
+		return ErrNotFound
+	}
+
+	errandID := d.ErrandID.String()
+	before := balance{runner.Credits, runner.XP}
+	after := balance{before.Credits + r.Payout, before.XP + r.RunnerXP}
+	entry := by.entry(AuditDisputeResolve, AuditTarget("dispute", disputeID),
+		resolutionSnapshot(errandID, d.runnerID, int(d.HeldAmount), before, nil),
+		resolutionSnapshot(errandID, d.runnerID, int(d.HeldAmount), after, &r))
+	if err := (*Memory)(s).appendAudit(&entry); err != nil {
+		return err
+	}
+	runner.Credits, runner.XP = after.Credits, after.XP
+	runner.Rating = math.Max(runner.Rating-r.RunnerPenalty, 0)
+	// Requesters never paid the reward, so a refund credits nobody
+	if u, ok := s.users[e.UserID]; ok {
+		u.Rating = math.Max(u.Rating-r.RequesterPenalty, 0)
+	}
+
+	resolvedAt := now()
+	d.held = false
+	d.Status = "resolved"
+	d.Outcome = r.Outcome
+	d.PayoutAmount = float64(r.Payout)
+	d.RefundAmount = float64(r.Refund)
+	d.ResolutionNote = r.Note
+	d.ResolvedBy = by.ID
+	d.ResolvedAt = &resolvedAt
+	e.Status = r.ErrandStatus
+	return (*Memory)(s).recordEvent(d.ErrandID, by.ID, "dispute_resolved", map[string]interface{}{
+		"dispute_id":    disputeID,
+		"outcome":       r.Outcome,
+		"payout_amount": r.Payout,
+		"refund_amount": r.Refund,
+		"note":          r.Note,
+		"status":        r.ErrandStatus,
+	})
+}
+
+type memSeries Memory
+
+func (s *memSeries) Create(ctx context.Context, ps *models.TravelPlanSeries) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(ps.Route) < 2 {
+		return &InvalidError{Reason: "a route needs at least two points"}
+	}
+	if _, ok := s.campuses[ps.CampusID]; !ok {
+		return ErrNotFound
+	}
+	if _, ok := s.users[ps.UserID]; !ok {
+		return ErrNotFound
+	}
+	ps.ID = uuid.NewString()
+	ps.Start = ps.Start.Truncate(time.Microsecond)
+	ps.Exceptions = nonNilStrings(ps.Exceptions)
+	ps.CreatedAt = now()
+	ps.UpdatedAt = ps.CreatedAt
+	stored := *ps
+	s.series[ps.ID] = &stored
+	return nil
+}
+
+func (s *memSeries) Get(ctx context.Context, id string) (models.TravelPlanSeries, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ps, ok := s.series[id]
+	if !ok {
+		return models.TravelPlanSeries{}, ErrNotFound
+	}
+	return *ps, nil
+}
+
+func (s *memSeries) List(ctx context.Context, userID, campusID string) ([]models.TravelPlanSeries, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	series := []models.TravelPlanSeries{}
+	for _, ps := range s.series {
+		if ps.UserID == userID && ps.CampusID == campusID {
+			series = append(series, *ps)
+		}
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].CreatedAt.After(series[j].CreatedAt) })
+	return series, nil
+}
+
+func (s *memSeries) ListActive(ctx context.Context) ([]models.TravelPlanSeries, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	series := []models.TravelPlanSeries{}
+	for _, ps := range s.series {
+		if !ps.Paused {
+			series = append(series, *ps)
+		}
+	}
+	return series, nil
+}
+
+func (s *memSeries) Update(ctx context.Context, ps *models.TravelPlanSeries) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.series[ps.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	stored.RRule = ps.RRule
+	stored.Start = ps.Start.Truncate(time.Microsecond)
+	stored.Timezone = ps.Timezone
+	stored.Exceptions = nonNilStrings(ps.Exceptions)
+	stored.Paused = ps.Paused
+	stored.SeatsAvailable = ps.SeatsAvailable
+	stored.UpdatedAt = now()
+	*ps = *stored
+	return nil
+}
+
+func (s *memSeries) Delete(ctx context.Context, id string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.series[id]; !ok {
+		return ErrNotFound
+	}
+	for key, p := range s.plans {
+		switch {
+		case p.SeriesID != id:
+		case p.StartTime.After(now):
+			delete(s.plans, key)
+		default:
+			// Past departures outlive the series, as ON DELETE SET NULL leaves them
+			p.SeriesID = ""
+		}
+	}
+	delete(s.series, id)
+	return nil
+}
+
+func (s *memSeries) Materialize(ctx context.Context, ps models.TravelPlanSeries, departures []time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.materialize(ps.ID, departures), nil
+}
+
+// materialize creates the departures of a series that do not exist yet;
+// s.mu is held
+func (s *memSeries) materialize(seriesID string, departures []time.Time) int64 {
+	ps, ok := s.series[seriesID]
+	if !ok || ps.Paused {
+		return 0
+	}
+	existing := map[time.Time]bool{}
+	for _, p := range s.plans {
+		if p.SeriesID == seriesID {
+			existing[p.StartTime.UTC()] = true
+		}
+	}
+	var n int64
+	for _, d := range departures {
+		d = d.UTC().Truncate(time.Microsecond)
+		if existing[d] {
+			continue
+		}
+		existing[d] = true
+		p := &models.TravelPlan{
+			ID:                 uuid.New(),
+			UserID:             ps.UserID,
+			CampusID:           ps.CampusID,
+			OriginName:         ps.OriginName,
+			DestinationName:    ps.DestinationName,
+			OriginPlaceID:      ps.OriginPlaceID,
+			DestinationPlaceID: ps.DestinationPlaceID,
+			Origin:             ps.Route[0],
+			Destination:        ps.Route[len(ps.Route)-1],
+			Route:              ps.Route,
+			Mode:               ps.Mode,
+			StartTime:          d,
+			SeatsAvailable:     ps.SeatsAvailable,
+			IsActive:           true,
+			SeriesID:           seriesID,
+			CreatedAt:          now(),
+		}
+		s.plans[p.ID] = p
+		n++
+	}
+	return n
+}
+
+func (s *memSeries) Reconcile(ctx context.Context, ps models.TravelPlanSeries, departures []time.Time, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keep := map[time.Time]bool{}
+	for _, d := range departures {
+		keep[d.UTC().Truncate(time.Microsecond)] = true
+	}
+	for key, p := range s.plans {
+		if p.SeriesID != ps.ID || !p.StartTime.After(now) {
+			continue
+		}
+		if !keep[p.StartTime.UTC()] {
+			delete(s.plans, key)
+			continue
+		}
+		p.SeatsAvailable = ps.SeatsAvailable
+	}
+	s.materialize(ps.ID, departures)
+	return nil
+}
+
+type memVerifications Memory
+
+func (s *memVerifications) Submit(ctx context.Context, v *models.RunnerVerification) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[v.UserID]; !ok {
+		return ErrNotFound
+	}
+	for _, other := range s.verifications {
+		if other.UserID == v.UserID && other.Status == "pending" {
+			return ErrConflict
+		}
+	}
+	v.ID = uuid.NewString()
+	v.Status = "pending"
+	v.ReviewNotes, v.ReviewedBy, v.ReviewedAt = "", "", nil
+	v.CreatedAt = now()
+	stored := *v
+	s.verifications = append(s.verifications, &stored)
+	return nil
+}
+
+func (s *memVerifications) Get(ctx context.Context, id string) (models.RunnerVerification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range s.verifications {
+		if v.ID == id {
+			return *v, nil
+		}
+	}
+	return models.RunnerVerification{}, ErrNotFound
+}
+
+func (s *memVerifications) Latest(ctx context.Context, userID string) (models.RunnerVerification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.verifications) - 1; i >= 0; i-- {
+		if v := s.verifications[i]; v.UserID == userID {
+			return *v, nil
+		}
+	}
+	return models.RunnerVerification{}, ErrNotFound
+}
+
+func (s *memVerifications) List(ctx context.Context, status string, limit int) ([]models.RunnerVerification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	list := []models.RunnerVerification{}
+	for _, v := range s.verifications {
+		if v.Status == status && len(list) < limit {
+			list = append(list, *v)
+		}
+	}
+	return list, nil
+}
+
+func (s *memVerifications) Review(ctx context.Context, id string, approve bool, notes string, by Actor) (models.RunnerVerification, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range s.verifications {
+		if v.ID != id || v.Status != "pending" {
+			continue
+		}
+		status := "rejected"
+		if approve {
+			status = "approved"
+			if _, ok := s.users[v.UserID]; !ok {
+				return models.RunnerVerification{}, ErrNotFound
+			}
+		}
+		after := reviewSnapshot(v.UserID, status)
+		after["notes"] = notes
+		e := by.entry(AuditVerificationReview, AuditTarget("verification", v.ID), reviewSnapshot(v.UserID, "pending"), after)
+		if err := (*Memory)(s).appendAudit(&e); err != nil {
+			return models.RunnerVerification{}, err
+		}
+		if approve {
+			if err := (*Memory)(s).changeRoles(v.UserID, policy.RoleVerifiedRunner, true, AuditRoleGrant, by); err != nil {
+				return models.RunnerVerification{}, err
+			}
+		}
+		reviewedAt := now()
+		v.Status, v.ReviewNotes, v.ReviewedBy, v.ReviewedAt = status, notes, by.ID, &reviewedAt
+		return *v, nil
+	}
+	return models.RunnerVerification{}, ErrNotFound
+}
+
+type memEmailDomains Memory
+
+func (s *memEmailDomains) List(ctx context.Context) ([]models.EmailDomain, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	domains := []models.EmailDomain{}
+	for _, d := range s.domains {
+		domains = append(domains, d)
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		if domains[i].CampusID != domains[j].CampusID {
+			return domains[i].CampusID < domains[j].CampusID
+		}
+		return domains[i].Domain < domains[j].Domain
+	})
+	return domains, nil
+}
+
+func (s *memEmailDomains) Add(ctx context.Context, domain, campusID string) (models.EmailDomain, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.campuses[campusID]; !ok {
+		return models.EmailDomain{}, ErrNotFound
+	}
+	d, ok := s.domains[domain]
+	if !ok {
+		d = models.EmailDomain{Domain: domain, CreatedAt: now()}
+	}
+	d.CampusID = campusID
+	s.domains[domain] = d
+	return d, nil
+}
+
+func (s *memEmailDomains) Remove(ctx context.Context, domain string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.domains[domain]; !ok {
+		return ErrNotFound
+	}
+	delete(s.domains, domain)
+	return nil
+}
+
+type memCalendarFeeds Memory
+
+func (s *memCalendarFeeds) Issue(ctx context.Context, userID, tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[userID]; !ok {
+		return ErrNotFound
+	}
+	s.feeds[userID] = tokenHash
+	return nil
+}
+
+func (s *memCalendarFeeds) Revoke(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.feeds, userID)
+	return nil
+}
+
+func (s *memCalendarFeeds) Owner(ctx context.Context, tokenHash string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for userID, hash := range s.feeds {
+		if hash == tokenHash {
+			return userID, nil
+		}
+	}
+	return "", ErrNotFound
+}
@@ -0,0 +1,814 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Woeter69/hackoverflow/internal/geo"
+	"github.com/Woeter69/hackoverflow/internal/models"
+	"github.com/Woeter69/hackoverflow/internal/policy"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// NewPostgres returns a store backed by a migrated PostGIS database
+func NewPostgres(db *sql.DB) *Store {
+	return &Store{
+		Errands:       &pgErrands{db},
+		TravelPlans:   &pgTravelPlans{db},
+		Users:         &pgUsers{db},
+		Messages:      &pgMessages{db},
+		Beacons:       &pgBeacons{db},
+		Campuses:      &pgCampuses{db},
+		Places:        &pgPlaces{db},
+		Audit:         &pgAudit{db},
+		Disputes:      &pgDisputes{db},
+		Series:        &pgSeries{db},
+		Verifications: &pgVerifications{db},
+		EmailDomains:  &pgEmailDomains{db},
+		CalendarFeeds: &pgCalendarFeeds{db},
+	}
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// pgError maps missing rows and constraint violations onto the store's errors
+func pgError(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch {
+		case pqErr.Code == "23505":
+			return fmt.Errorf("%w: %s", ErrConflict, pqErr.Message)
+		case pqErr.Code == "23503":
+			return fmt.Errorf("%w: %s", ErrNotFound, pqErr.Message)
+		case pqErr.Code.Class() == "22":
+			return &InvalidError{Reason: pqErr.Message}
+		}
+	}
+	return err
+}
+
+// likeEscaper quotes the LIKE wildcards in user input
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// validID reports whether id can name a UUID row; anything else cannot exist
+func validID(id string) bool {
+	_, err := uuid.Parse(id)
+	return err == nil
+}
+
+// affected turns an update that touched no rows into ErrNotFound
+func affected(result sql.Result, err error) error {
+	if err != nil {
+		return pgError(err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type pgTravelPlans struct{ db *sql.DB }
+
+const travelPlanColumns = `t.id, COALESCE(t.user_id, ''), t.campus_id,
+	COALESCE(t.origin_name, ''), COALESCE(t.destination_name, ''),
+	COALESCE(t.origin_place_id::TEXT, ''), COALESCE(t.destination_place_id::TEXT, ''),
+	ST_AsGeoJSON(t.origin_geom), ST_AsGeoJSON(t.destination_geom), ST_AsGeoJSON(t.route_geom),
+	COALESCE(t.mode, 'walk'), t.start_time, COALESCE(t.seats_available, 1), COALESCE(t.is_active, TRUE),
+	COALESCE(t.series_id::TEXT, ''), t.created_at`
+
+func scanTravelPlan(row rowScanner, p *models.TravelPlan) error {
+	return row.Scan(
+		&p.ID, &p.UserID, &p.CampusID,
+		&p.OriginName, &p.DestinationName,
+		&p.OriginPlaceID, &p.DestinationPlaceID,
+		&p.Origin, &p.Destination, &p.Route,
+		&p.Mode, &p.StartTime, &p.SeatsAvailable, &p.IsActive,
+		&p.SeriesID, &p.CreatedAt,
+	)
+}
+
+func (s *pgTravelPlans) Create(ctx context.Context, p *models.TravelPlan) error {
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO travel_plans (user_id, origin_name, destination_name, origin_geom, destination_geom, route_geom, mode, start_time, campus_id, origin_place_id, destination_place_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, '')::UUID, NULLIF($11, '')::UUID)
+		RETURNING id, COALESCE(seats_available, 1), is_active, created_at
+	`, p.UserID, p.OriginName, p.DestinationName, p.Origin, p.Destination, p.Route,
+		p.Mode, p.StartTime, p.CampusID, p.OriginPlaceID, p.DestinationPlaceID,
+	).Scan(&p.ID, &p.SeatsAvailable, &p.IsActive, &p.CreatedAt)
+	return pgError(err)
+}
+
+func (s *pgTravelPlans) Get(ctx context.Context, id string) (models.TravelPlan, error) {
+	var p models.TravelPlan
+	if !validID(id) {
+		return p, ErrNotFound
+	}
+	err := scanTravelPlan(s.db.QueryRowContext(ctx, "SELECT "+travelPlanColumns+" FROM travel_plans t WHERE t.id = $1", id), &p)
+	return p, pgError(err)
+}
+
+func (s *pgTravelPlans) ListActive(ctx context.Context, userID, campusID string) ([]models.TravelPlan, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+travelPlanColumns+`
+		FROM travel_plans t
+		WHERE t.user_id = $1 AND t.campus_id = $2 AND t.is_active = TRUE
+		ORDER BY t.start_time ASC
+	`, userID, campusID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plans := []models.TravelPlan{}
+	for rows.Next() {
+		var p models.TravelPlan
+		if err := scanTravelPlan(rows, &p); err != nil {
+			return nil, err
+		}
+		plans = append(plans, p)
+	}
+	return plans, rows.Err()
+}
+
+func (s *pgTravelPlans) TravelersNear(ctx context.Context, campusID string, pt geo.Point, within float64) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT user_id
+		FROM travel_plans
+		WHERE is_active = TRUE
+		AND campus_id = $2
+		AND ST_DWithin(route_geom, $1::geography, $3)
+	`, models.PointFromGeo(pt), campusID, within)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []string
+	for rows.Next() {
+		var uid string
+		if err := rows.Scan(&uid); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, uid)
+	}
+	return userIDs, rows.Err()
+}
+
+func (s *pgTravelPlans) Since(ctx context.Context, userID string, since time.Time, limit int) ([]models.TravelPlan, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+travelPlanColumns+`
+		FROM travel_plans t
+		WHERE t.user_id = $1 AND t.is_active AND t.start_time > $2
+		ORDER BY t.start_time ASC
+		LIMIT $3
+	`, userID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	plans := []models.TravelPlan{}
+	for rows.Next() {
+		var p models.TravelPlan
+		if err := scanTravelPlan(rows, &p); err != nil {
+			return nil, err
+		}
+		plans = append(plans, p)
+	}
+	return plans, rows.Err()
+}
+
+type pgUsers struct{ db *sql.DB }
+
+const userColumns = `id, COALESCE(username, ''), COALESCE(email, ''), COALESCE(display_name, ''), email_verified, credits, xp, rating, COALESCE(campus_id, ''), created_at`
+
+func scanUser(row rowScanner, u *models.User) error {
+	return row.Scan(&u.ID, &u.Username, &u.Email, &u.DisplayName, &u.EmailVerified, &u.Credits, &u.XP, &u.Rating, &u.CampusID, &u.CreatedAt)
+}
+
+func (s *pgUsers) Provision(ctx context.Context, uid string, claims Claims) (Membership, error) {
+	var m Membership
+	var campusID sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+		WITH matched AS (
+			SELECT campus_id FROM campus_email_domains d
+			WHERE LOWER(SPLIT_PART($2, '@', 2)) = d.domain
+			   OR LOWER(SPLIT_PART($2, '@', 2)) LIKE '%.' || d.domain
+			ORDER BY LENGTH(d.domain) DESC
+			LIMIT 1
+		)
+		INSERT INTO users (id, email, display_name, email_verified, campus_id, campus_verified)
+		VALUES (
+			$1, NULLIF($2, ''), NULLIF($3, ''), $4,
+			(SELECT campus_id FROM matched),
+			$4 AND EXISTS (SELECT 1 FROM matched)
+		)
+		ON CONFLICT (id) DO UPDATE SET
+			email = COALESCE(EXCLUDED.email, users.email),
+			display_name = COALESCE(users.display_name, EXCLUDED.display_name),
+			email_verified = EXCLUDED.email_verified,
+			campus_id = COALESCE(EXCLUDED.campus_id, users.campus_id),
+			campus_verified = EXCLUDED.campus_verified,
+			updated_at = CURRENT_TIMESTAMP
+		RETURNING campus_id, campus_verified
+	`, uid, claims.Email, claims.Name, claims.EmailVerified).Scan(&campusID, &m.CampusVerified)
+	m.CampusID = campusID.String
+	return m, err
+}
+
+func (s *pgUsers) Get(ctx context.Context, id string) (models.User, error) {
+	var u models.User
+	err := scanUser(s.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id), &u)
+	return u, pgError(err)
+}
+
+func (s *pgUsers) UpdateProfile(ctx context.Context, id string, update ProfileUpdate) (models.User, error) {
+	var u models.User
+	err := scanUser(s.db.QueryRowContext(ctx, `
+		UPDATE users
+		SET username = COALESCE($1, username),
+			display_name = COALESCE($2, display_name),
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = $3
+		RETURNING `+userColumns,
+		update.Username, update.DisplayName, id,
+	), &u)
+	return u, pgError(err)
+}
+
+func (s *pgUsers) Award(ctx context.Context, id string, credits, xp int) error {
+	return affected(s.db.ExecContext(ctx, "UPDATE users SET credits = credits + $1, xp = xp + $2 WHERE id = $3", credits, xp, id))
+}
+
+func (s *pgUsers) AdjustCredits(ctx context.Context, id string, adj CreditAdjustment, by Actor) (int, error) {
+	if err := adj.validate(by); err != nil {
+		return 0, err
+	}
+	tx, err := beginAudited(ctx, s.db)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	before, err := lockBalance(ctx, tx, id)
+	if err != nil {
+		return 0, err
+	}
+	if before.Credits+adj.Amount < 0 {
+		return 0, &InvalidError{Reason: fmt.Sprintf("the balance would be %d", before.Credits+adj.Amount)}
+	}
+	after, err := addBalance(ctx, tx, id, adj.Amount, 0)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx,
+		"INSERT INTO credit_adjustments (user_id, amount, balance, reason, actor) VALUES ($1, $2, $3, $4, $5)",
+		id, adj.Amount, after.Credits, adj.Reason, by.ID,
+	); err != nil {
+		return 0, pgError(err)
+	}
+	e := by.entry(AuditCreditsAdjust, AuditTarget("user", id),
+		before.snapshot(nil), after.snapshot(map[string]interface{}{"reason": adj.Reason}))
+	if err := appendAudit(ctx, tx, &e); err != nil {
+		return 0, err
+	}
+	return after.Credits, tx.Commit()
+}
+
+// lockBalance reads a user's balance and locks it for the rest of tx
+func lockBalance(ctx context.Context, tx *sql.Tx, id string) (balance, error) {
+	var b balance
+	err := tx.QueryRowContext(ctx, "SELECT credits, xp FROM users WHERE id = $1 FOR UPDATE", id).Scan(&b.Credits, &b.XP)
+	return b, pgError(err)
+}
+
+// addBalance moves a user's credits and experience, never taking xp below
+// zero, and returns the new balance
+func addBalance(ctx context.Context, tx *sql.Tx, id string, credits, xp int) (balance, error) {
+	var b balance
+	err := tx.QueryRowContext(ctx, `
+		UPDATE users SET credits = credits + $2, xp = GREATEST(xp + $3, 0), updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING credits, xp
+	`, id, credits, xp).Scan(&b.Credits, &b.XP)
+	return b, pgError(err)
+}
+
+func (s *pgUsers) AssignCampus(ctx context.Context, id, campusID string) error {
+	return affected(s.db.ExecContext(ctx, "UPDATE users SET campus_id = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2", campusID, id))
+}
+
+func (s *pgUsers) Roles(ctx context.Context, id string) ([]policy.Role, error) {
+	return userRoles(ctx, s.db, id)
+}
+
+// userRoles reads a user's stored roles
+func userRoles(ctx context.Context, db rowsQueryer, id string) ([]policy.Role, error) {
+	rows, err := db.QueryContext(ctx, "SELECT role FROM user_roles WHERE user_id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []policy.Role
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if r, ok := policy.ParseRole(name); ok {
+			roles = append(roles, r)
+		}
+	}
+	return roles, rows.Err()
+}
+
+func (s *pgUsers) GrantRole(ctx context.Context, id string, role policy.Role, by Actor) error {
+	return s.changeRoles(ctx, id, AuditRoleGrant, by, func(tx *sql.Tx) error {
+		return insertRole(ctx, tx, id, role, by.ID)
+	})
+}
+
+func (s *pgUsers) RevokeRole(ctx context.Context, id string, role policy.Role, by Actor) error {
+	return s.changeRoles(ctx, id, AuditRoleRevoke, by, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "DELETE FROM user_roles WHERE user_id = $1 AND role = $2", id, string(role))
+		return err
+	})
+}
+
+// insertRole grants a role; it is a no-op when the user already holds it
+func insertRole(ctx context.Context, tx *sql.Tx, id string, role policy.Role, grantedBy string) error {
+	_, err := tx.ExecContext(ctx,
+		"INSERT INTO user_roles (user_id, role, granted_by) VALUES ($1, $2, $3) ON CONFLICT (user_id, role) DO NOTHING",
+		id, string(role), grantedBy,
+	)
+	return pgError(err)
+}
+
+// changeRoles applies change to a user's roles and audits the roles before
+// and after it in the same transaction
+func (s *pgUsers) changeRoles(ctx context.Context, id, action string, by Actor, change func(tx *sql.Tx) error) error {
+	tx, err := beginAudited(ctx, s.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := auditRoles(ctx, tx, id, action, by, change); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// auditRoles applies change to a user's roles within a transaction from
+// beginAudited and audits the roles before and after it
+func auditRoles(ctx context.Context, tx *sql.Tx, id, action string, by Actor, change func(tx *sql.Tx) error) error {
+	// An unknown user is not found rather than an audited no-op
+	var exists bool
+	if err := tx.QueryRowContext(ctx, "SELECT true FROM users WHERE id = $1 FOR UPDATE", id).Scan(&exists); err != nil {
+		return pgError(err)
+	}
+	before, err := userRoles(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	if err := change(tx); err != nil {
+		return err
+	}
+	after, err := userRoles(ctx, tx, id)
+	if err != nil {
+		return err
+	}
+	e := by.entry(action, AuditTarget("user", id), rolesSnapshot(before), rolesSnapshot(after))
+	return appendAudit(ctx, tx, &e)
+}
+
+type pgEmailDomains struct{ db *sql.DB }
+
+func (s *pgEmailDomains) List(ctx context.Context) ([]models.EmailDomain, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT domain, campus_id, created_at FROM campus_email_domains ORDER BY campus_id, domain")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	domains := []models.EmailDomain{}
+	for rows.Next() {
+		var d models.EmailDomain
+		if err := rows.Scan(&d.Domain, &d.CampusID, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		domains = append(domains, d)
+	}
+	return domains, rows.Err()
+}
+
+func (s *pgEmailDomains) Add(ctx context.Context, domain, campusID string) (models.EmailDomain, error) {
+	var d models.EmailDomain
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO campus_email_domains (domain, campus_id) VALUES ($1, $2)
+		ON CONFLICT (domain) DO UPDATE SET campus_id = EXCLUDED.campus_id
+		RETURNING domain, campus_id, created_at
+	`, domain, campusID).Scan(&d.Domain, &d.CampusID, &d.CreatedAt)
+	return d, pgError(err)
+}
+
+func (s *pgEmailDomains) Remove(ctx context.Context, domain string) error {
+	return affected(s.db.ExecContext(ctx, "DELETE FROM campus_email_domains WHERE domain = $1", domain))
+}
+
+type pgCalendarFeeds struct{ db *sql.DB }
+
+func (s *pgCalendarFeeds) Issue(ctx context.Context, userID, tokenHash string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO calendar_feeds (user_id, token_hash) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET token_hash = EXCLUDED.token_hash, created_at = CURRENT_TIMESTAMP
+	`, userID, tokenHash)
+	return pgError(err)
+}
+
+func (s *pgCalendarFeeds) Revoke(ctx context.Context, userID string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM calendar_feeds WHERE user_id = $1", userID)
+	return err
+}
+
+func (s *pgCalendarFeeds) Owner(ctx context.Context, tokenHash string) (string, error) {
+	var userID string
+	err := s.db.QueryRowContext(ctx, "SELECT user_id FROM calendar_feeds WHERE token_hash = $1", tokenHash).Scan(&userID)
+	return userID, pgError(err)
+}
+
+type pgMessages struct{ db *sql.DB }
+
+func (s *pgMessages) List(ctx context.Context, errandID string) ([]models.Message, error) {
+	messages := []models.Message{}
+	if !validID(errandID) {
+		return messages, nil
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, errand_id, COALESCE(sender_id, ''), content, is_encrypted, created_at
+		FROM messages
+		WHERE errand_id = $1
+		ORDER BY created_at ASC
+	`, errandID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m models.Message
+		if err := rows.Scan(&m.ID, &m.ErrandID, &m.SenderID, &m.Content, &m.IsEncrypted, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
+
+func (s *pgMessages) Create(ctx context.Context, m *models.Message) error {
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO messages (errand_id, sender_id, content)
+		VALUES ($1, $2, $3)
+		RETURNING id, is_encrypted, created_at
+	`, m.ErrandID, m.SenderID, m.Content).Scan(&m.ID, &m.IsEncrypted, &m.CreatedAt)
+	return pgError(err)
+}
+
+type pgBeacons struct{ db *sql.DB }
+
+func (s *pgBeacons) Active(ctx context.Context, campusID string) (models.Beacon, error) {
+	var b models.Beacon
+	err := s.db.QueryRowContext(ctx, `
+		SELECT b.id, b.campus_id, COALESCE(b.user_id, ''), COALESCE(b.message, ''), COALESCE(b.building_id, 0),
+		       COALESCE(b.place_id::TEXT, ''), COALESCE(p.name, ''), b.expires_at, b.created_at
+		FROM emergency_beacons b
+		LEFT JOIN places p ON p.id = b.place_id
+		WHERE b.campus_id = $1 AND b.is_active AND b.expires_at > NOW()
+		ORDER BY b.created_at DESC
+		LIMIT 1
+	`, campusID).Scan(&b.ID, &b.CampusID, &b.UserID, &b.Message, &b.BuildingID, &b.PlaceID, &b.PlaceName, &b.ExpiresAt, &b.CreatedAt)
+	return b, pgError(err)
+}
+
+func (s *pgBeacons) Raise(ctx context.Context, b *models.Beacon, by Actor) error {
+	tx, err := beginAudited(ctx, s.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockActiveBeacon(ctx, tx, b.CampusID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE emergency_beacons SET is_active = FALSE WHERE campus_id = $1 AND is_active", b.CampusID); err != nil {
+		return err
+	}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO emergency_beacons (user_id, campus_id, message, building_id, place_id, current_location, expires_at)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, 0), NULLIF($5, '')::UUID,
+		        (SELECT location FROM places WHERE id::TEXT = $5), $6)
+		RETURNING id, created_at
+	`, b.UserID, b.CampusID, b.Message, b.BuildingID, b.PlaceID, b.ExpiresAt).Scan(&b.ID, &b.CreatedAt)
+	if err != nil {
+		return pgError(err)
+	}
+	e := by.entry(AuditEmergencyRaise, AuditTarget("campus", b.CampusID), beaconSnapshot(before), beaconSnapshot(b))
+	if err := appendAudit(ctx, tx, &e); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *pgBeacons) Clear(ctx context.Context, campusID string, by Actor) error {
+	tx, err := beginAudited(ctx, s.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := lockActiveBeacon(ctx, tx, campusID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE emergency_beacons SET is_active = FALSE WHERE campus_id = $1 AND is_active", campusID); err != nil {
+		return err
+	}
+	// Standing down nothing is not worth an entry
+	if before != nil {
+		e := by.entry(AuditEmergencyClear, AuditTarget("campus", campusID), beaconSnapshot(before), nil)
+		if err := appendAudit(ctx, tx, &e); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// lockActiveBeacon locks the campus's unexpired beacon, nil for none
+func lockActiveBeacon(ctx context.Context, tx *sql.Tx, campusID string) (*models.Beacon, error) {
+	var b models.Beacon
+	err := tx.QueryRowContext(ctx, `
+		SELECT id, campus_id, COALESCE(user_id, ''), COALESCE(building_id, 0), COALESCE(place_id::TEXT, ''), expires_at, created_at
+		FROM emergency_beacons
+		WHERE campus_id = $1 AND is_active AND expires_at > NOW()
+		ORDER BY created_at DESC
+		LIMIT 1
+		FOR UPDATE
+	`, campusID).Scan(&b.ID, &b.CampusID, &b.UserID, &b.BuildingID, &b.PlaceID, &b.ExpiresAt, &b.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+type pgCampuses struct{ db *sql.DB }
+
+const campusColumns = `id, name, ST_AsGeoJSON(boundary), COALESCE(currency_name, 'Credits'), COALESCE(default_buffer_m, 200), COALESCE(categories, '{}'), created_at`
+
+func scanCampus(row rowScanner, campus *models.Campus) error {
+	var boundary *string
+	if err := row.Scan(&campus.ID, &campus.Name, &boundary, &campus.CurrencyName, &campus.DefaultBufferM, pq.Array(&campus.Categories), &campus.CreatedAt); err != nil {
+		return err
+	}
+	if boundary != nil {
+		campus.Boundary = []byte(*boundary)
+	}
+	if campus.Categories == nil {
+		campus.Categories = []string{}
+	}
+	return nil
+}
+
+// wkt renders an optional polygon, "" for none
+func wkt(p geo.Polygon) string {
+	if len(p) == 0 {
+		return ""
+	}
+	return p.WKT()
+}
+
+func (s *pgCampuses) Get(ctx context.Context, id string) (models.Campus, error) {
+	var campus models.Campus
+	err := scanCampus(s.db.QueryRowContext(ctx, "SELECT "+campusColumns+" FROM campuses WHERE id = $1", id), &campus)
+	return campus, pgError(err)
+}
+
+func (s *pgCampuses) List(ctx context.Context) ([]models.Campus, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+campusColumns+" FROM campuses ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	campuses := []models.Campus{}
+	for rows.Next() {
+		var campus models.Campus
+		if err := scanCampus(rows, &campus); err != nil {
+			return nil, err
+		}
+		campuses = append(campuses, campus)
+	}
+	return campuses, rows.Err()
+}
+
+func (s *pgCampuses) Create(ctx context.Context, c *models.Campus, boundary geo.Polygon) error {
+	err := scanCampus(s.db.QueryRowContext(ctx, `
+		INSERT INTO campuses (id, name, boundary, currency_name, default_buffer_m, categories)
+		VALUES ($1, $2, ST_GeogFromText(NULLIF($3, '')), $4, $5, $6)
+		RETURNING `+campusColumns,
+		c.ID, c.Name, wkt(boundary), c.CurrencyName, c.DefaultBufferM, pq.Array(c.Categories),
+	), c)
+	return pgError(err)
+}
+
+func (s *pgCampuses) Update(ctx context.Context, id string, update CampusUpdate) (models.Campus, error) {
+	var categories interface{}
+	if update.Categories != nil {
+		categories = pq.Array(update.Categories)
+	}
+	var boundary *string
+	if update.Boundary != nil {
+		text := wkt(*update.Boundary)
+		boundary = &text
+	}
+
+	var campus models.Campus
+	err := scanCampus(s.db.QueryRowContext(ctx, `
+		UPDATE campuses SET
+			name = COALESCE($2, name),
+			boundary = CASE WHEN $3::TEXT IS NULL THEN boundary ELSE ST_GeogFromText(NULLIF($3, '')) END,
+			currency_name = COALESCE($4, currency_name),
+			default_buffer_m = COALESCE($5, default_buffer_m),
+			categories = COALESCE($6, categories)
+		WHERE id = $1
+		RETURNING `+campusColumns,
+		id, update.Name, boundary, update.CurrencyName, update.DefaultBufferM, categories,
+	), &campus)
+	return campus, pgError(err)
+}
+
+type pgPlaces struct{ db *sql.DB }
+
+const placeColumns = `p.id, p.campus_id, p.name, p.kind, COALESCE(p.code, ''),
+	ST_AsGeoJSON(p.location), ST_AsGeoJSON(p.footprint), ST_AsGeoJSON(p.entrances), p.created_at, p.updated_at`
+
+func scanPlace(row rowScanner, p *models.Place, extra ...interface{}) error {
+	var footprint, entrances sql.NullString
+	dest := []interface{}{
+		&p.ID, &p.CampusID, &p.Name, &p.Kind, &p.Code,
+		&p.Location, &footprint, &entrances, &p.CreatedAt, &p.UpdatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	if footprint.Valid {
+		p.Footprint = json.RawMessage(footprint.String)
+	}
+	if entrances.Valid {
+		p.Entrances = json.RawMessage(entrances.String)
+	}
+	return nil
+}
+
+// shapeWKT renders a place's geometries for binding, "" for those it lacks
+func shapeWKT(shape PlaceShape) (location, footprint, entrances string) {
+	if shape.Location != nil {
+		location = shape.Location.WKT()
+	}
+	if len(shape.Entrances) > 0 {
+		entrances = geo.MultiPointWKT(shape.Entrances)
+	}
+	return location, wkt(shape.Footprint), entrances
+}
+
+func (s *pgPlaces) Get(ctx context.Context, id string) (models.Place, error) {
+	var p models.Place
+	if !validID(id) {
+		return p, ErrNotFound
+	}
+	err := scanPlace(s.db.QueryRowContext(ctx, "SELECT "+placeColumns+" FROM places p WHERE p.id = $1", id), &p)
+	return p, pgError(err)
+}
+
+func (s *pgPlaces) Nearest(ctx context.Context, campusID string, pt geo.Point, within float64, kind string) (models.Place, error) {
+	var p models.Place
+	var distance float64
+	err := scanPlace(s.db.QueryRowContext(ctx, `
+		SELECT `+placeColumns+`, ST_Distance(COALESCE(p.footprint, p.location), $2::geography) AS distance
+		FROM places p
+		WHERE p.campus_id = $1
+		  AND ($3 = '' OR p.kind = $3)
+		  AND ($4::FLOAT8 <= 0 OR ST_DWithin(COALESCE(p.footprint, p.location), $2::geography, $4::FLOAT8))
+		ORDER BY distance ASC
+		LIMIT 1
+	`, campusID, models.PointFromGeo(pt), kind, within), &p, &distance)
+	if err != nil {
+		return p, pgError(err)
+	}
+	p.Distance = &distance
+	return p, nil
+}
+
+func (s *pgPlaces) Search(ctx context.Context, q PlaceSearch) ([]models.Place, error) {
+	pattern := "%" + likeEscaper.Replace(q.Query) + "%"
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+placeColumns+`
+		FROM places p
+		WHERE p.campus_id = $1
+		  AND ($2 = '' OR p.kind = $2)
+		  AND (LOWER(p.name) LIKE $3 OR LOWER(COALESCE(p.code, '')) LIKE $3)
+		ORDER BY
+			LOWER(COALESCE(p.code, '')) = $4 DESC,
+			LOWER(p.name) LIKE $4 || '%' DESC,
+			p.name ASC
+		LIMIT $5
+	`, q.CampusID, q.Kind, pattern, q.Query, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	places := []models.Place{}
+	for rows.Next() {
+		var p models.Place
+		if err := scanPlace(rows, &p); err != nil {
+			return nil, err
+		}
+		places = append(places, p)
+	}
+	return places, rows.Err()
+}
+
+func (s *pgPlaces) Match(ctx context.Context, campusID, text string) (models.Place, error) {
+	var p models.Place
+	err := scanPlace(s.db.QueryRowContext(ctx, `
+		SELECT `+placeColumns+`
+		FROM places p
+		WHERE p.campus_id = $1
+		  AND (LOWER(COALESCE(p.code, '')) = $2 OR STRPOS($2, LOWER(p.name)) > 0)
+		ORDER BY LOWER(COALESCE(p.code, '')) = $2 DESC, LENGTH(p.name) DESC
+		LIMIT 1
+	`, campusID, normalizeText(text)), &p)
+	return p, pgError(err)
+}
+
+func (s *pgPlaces) Create(ctx context.Context, p *models.Place, shape PlaceShape) error {
+	location, footprint, entrances := shapeWKT(shape)
+	err := scanPlace(s.db.QueryRowContext(ctx, `
+		WITH inserted AS (
+			INSERT INTO places (campus_id, name, kind, code, location, footprint, entrances)
+			VALUES (
+				$1, $2, $3, NULLIF($4, ''),
+				COALESCE(ST_GeomFromText(NULLIF($5, ''), 4326), ST_PointOnSurface(ST_GeomFromText(NULLIF($6, ''), 4326)))::geography,
+				ST_GeogFromText(NULLIF($6, '')),
+				ST_GeogFromText(NULLIF($7, ''))
+			)
+			RETURNING *
+		)
+		SELECT `+placeColumns+` FROM inserted p
+	`, p.CampusID, p.Name, p.Kind, p.Code, location, footprint, entrances), p)
+	return pgError(err)
+}
+
+func (s *pgPlaces) Update(ctx context.Context, p *models.Place, shape PlaceShape) error {
+	if !validID(p.ID) {
+		return ErrNotFound
+	}
+	location, footprint, entrances := shapeWKT(shape)
+	err := scanPlace(s.db.QueryRowContext(ctx, `
+		WITH updated AS (
+			UPDATE places SET
+				name = $3, kind = $4, code = NULLIF($5, ''),
+				location = COALESCE(ST_GeomFromText(NULLIF($6, ''), 4326), ST_PointOnSurface(ST_GeomFromText(NULLIF($7, ''), 4326)))::geography,
+				footprint = ST_GeogFromText(NULLIF($7, '')),
+				entrances = ST_GeogFromText(NULLIF($8, '')),
+				updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1 AND campus_id = $2
+			RETURNING *
+		)
+		SELECT `+placeColumns+` FROM updated p
+	`, p.ID, p.CampusID, p.Name, p.Kind, p.Code, location, footprint, entrances), p)
+	return pgError(err)
+}
+
+func (s *pgPlaces) Delete(ctx context.Context, id string) error {
+	if !validID(id) {
+		return ErrNotFound
+	}
+	return affected(s.db.ExecContext(ctx, "DELETE FROM places WHERE id = $1", id))
+}
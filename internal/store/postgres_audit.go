@@ -0,0 +1,149 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/Woeter69/hackoverflow/internal/models"
+)
+
+type pgAudit struct{ db *sql.DB }
+
+const auditColumns = `id, actor, action, target, before, after, request_id, prev_hash, hash, created_at`
+
+func scanAuditEntry(row rowScanner, e *models.AuditEntry) error {
+	var before, after []byte
+	if err := row.Scan(&e.ID, &e.Actor, &e.Action, &e.Target, &before, &after, &e.RequestID, &e.PrevHash, &e.Hash, &e.CreatedAt); err != nil {
+		return err
+	}
+	e.Before, e.After = json.RawMessage(before), json.RawMessage(after)
+	return nil
+}
+
+// nullJSON stores an empty snapshot as NULL
+func nullJSON(raw json.RawMessage) interface{} {
+	if len(raw) == 0 {
+		return nil
+	}
+	return string(raw)
+}
+
+func (s *pgAudit) Append(ctx context.Context, e *models.AuditEntry) error {
+	tx, err := beginAudited(ctx, s.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := appendAudit(ctx, tx, e); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// beginAudited starts a transaction that will append to the audit log.
+// Appends are serialized so each entry chains onto the latest one; readers
+// are not blocked. The lock is taken before any row locks, so audited
+// transactions cannot deadlock on each other.
+func beginAudited(ctx context.Context, db *sql.DB) (*sql.Tx, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, "LOCK TABLE audit_log IN EXCLUSIVE MODE"); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return tx, nil
+}
+
+// appendAudit chains an entry onto the log within a transaction from
+// beginAudited, filling in its ID, PrevHash, Hash and CreatedAt
+func appendAudit(ctx context.Context, tx *sql.Tx, e *models.AuditEntry) error {
+	e.ID, e.PrevHash = 1, AuditGenesis
+	err := tx.QueryRowContext(ctx, "SELECT id + 1, hash FROM audit_log ORDER BY id DESC LIMIT 1").Scan(&e.ID, &e.PrevHash)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	e.CreatedAt = now()
+	if err := seal(e); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO audit_log (id, actor, action, target, before, after, request_id, prev_hash, hash, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, e.ID, e.Actor, e.Action, e.Target, nullJSON(e.Before), nullJSON(e.After), e.RequestID, e.PrevHash, e.Hash, e.CreatedAt,
+	)
+	return pgError(err)
+}
+
+func (s *pgAudit) List(ctx context.Context, q AuditQuery) ([]models.AuditEntry, error) {
+	var where []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	if q.Actor != "" {
+		where = append(where, "actor = "+arg(q.Actor))
+	}
+	if q.Action != "" {
+		where = append(where, "action = "+arg(q.Action))
+	}
+	if q.Target != "" {
+		where = append(where, "target = "+arg(q.Target))
+	}
+	if !q.Since.IsZero() {
+		where = append(where, "created_at >= "+arg(q.Since))
+	}
+	if !q.Until.IsZero() {
+		where = append(where, "created_at < "+arg(q.Until))
+	}
+	if q.Before > 0 {
+		where = append(where, "id < "+arg(q.Before))
+	}
+	query := "SELECT " + auditColumns + " FROM audit_log"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC LIMIT " + arg(q.Limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var e models.AuditEntry
+		if err := scanAuditEntry(rows, &e); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (s *pgAudit) Walk(ctx context.Context, fn func(models.AuditEntry) error) error {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+auditColumns+" FROM audit_log ORDER BY id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e models.AuditEntry
+		if err := scanAuditEntry(rows, &e); err != nil {
+			return err
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,289 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/Woeter69/hackoverflow/internal/models"
+	"github.com/lib/pq"
+)
+
+type pgDisputes struct{ db *sql.DB }
+
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+func (s *pgDisputes) Open(ctx context.Context, o DisputeOpening, by Actor) (models.Dispute, error) {
+	var d models.Dispute
+	if !validID(o.ErrandID) {
+		return d, ErrNotFound
+	}
+	tx, err := beginAudited(ctx, s.db)
+	if err != nil {
+		return d, err
+	}
+	defer tx.Rollback()
+
+	var runnerID string
+	var reward float64
+	err = tx.QueryRowContext(ctx,
+		"SELECT COALESCE(runner_id, ''), status, COALESCE(reward_estimate, 0) FROM errand_requests WHERE id = $1 FOR UPDATE",
+		o.ErrandID,
+	).Scan(&runnerID, &d.PreviousStatus, &reward)
+	if err != nil {
+		return d, pgError(err)
+	}
+	if (d.PreviousStatus != "matched" && d.PreviousStatus != "completed") || runnerID == "" {
+		return d, ErrConflict
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO errand_disputes (errand_id, opened_by, reason, previous_status) VALUES ($1, $2, $3, $4)
+		RETURNING id, errand_id, opened_by, reason, status, created_at
+	`, o.ErrandID, by.ID, o.Reason, d.PreviousStatus,
+	).Scan(&d.ID, &d.ErrandID, &d.OpenedBy, &d.Reason, &d.Status, &d.CreatedAt)
+	if err != nil {
+		return d, pgError(err)
+	}
+
+	// A mission disputed before pays what the last resolution released
+	var released float64
+	err = tx.QueryRowContext(ctx,
+		"SELECT payout_amount FROM errand_disputes WHERE errand_id = $1 AND status = 'resolved' ORDER BY created_at DESC LIMIT 1",
+		o.ErrandID,
+	).Scan(&released)
+	if err == nil {
+		reward = released
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return d, err
+	}
+
+	// Freeze the payout. Completed missions have already been paid, so reverse it.
+	held := int(reward)
+	clawedBack := d.PreviousStatus == "completed"
+	if clawedBack {
+		before, err := lockBalance(ctx, tx, runnerID)
+		if err != nil {
+			return d, err
+		}
+		after, err := addBalance(ctx, tx, runnerID, -held, -o.ClawbackXP)
+		if err != nil {
+			return d, err
+		}
+		e := by.entry(AuditCreditsClawback, AuditTarget("user", runnerID), before.snapshot(nil),
+			after.snapshot(map[string]interface{}{"errand_id": o.ErrandID, "dispute_id": d.ID}))
+		if err := appendAudit(ctx, tx, &e); err != nil {
+			return d, err
+		}
+	}
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO credit_holds (errand_id, dispute_id, runner_id, amount, clawed_back) VALUES ($1, $2, $3, $4, $5)",
+		o.ErrandID, d.ID, runnerID, held, clawedBack,
+	)
+	if err != nil {
+		return d, err
+	}
+	d.HeldAmount = float64(held)
+
+	statement := models.DisputeStatement{DisputeID: d.ID, AuthorID: by.ID, Statement: o.Reason, EvidenceURLs: nonNilStrings(o.EvidenceURLs)}
+	if err := insertStatement(ctx, tx, &statement); err != nil {
+		return d, err
+	}
+	d.Statements = []models.DisputeStatement{statement}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE errand_requests SET status = 'disputed' WHERE id = $1", o.ErrandID); err != nil {
+		return d, err
+	}
+	err = insertEvent(ctx, tx, o.ErrandID, by.ID, "dispute_opened", map[string]interface{}{
+		"dispute_id":      d.ID,
+		"previous_status": d.PreviousStatus,
+		"held_amount":     held,
+		"clawed_back":     clawedBack,
+	})
+	if err != nil {
+		return d, err
+	}
+	return d, tx.Commit()
+}
+
+// insertStatement stores a statement, filling in its ID and CreatedAt
+func insertStatement(ctx context.Context, tx *sql.Tx, st *models.DisputeStatement) error {
+	err := tx.QueryRowContext(ctx,
+		"INSERT INTO dispute_statements (dispute_id, author_id, statement, evidence_urls) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
+		st.DisputeID, st.AuthorID, st.Statement, pq.Array(nonNilStrings(st.EvidenceURLs)),
+	).Scan(&st.ID, &st.CreatedAt)
+	return pgError(err)
+}
+
+func (s *pgDisputes) Latest(ctx context.Context, errandID string) (models.Dispute, error) {
+	var d models.Dispute
+	if !validID(errandID) {
+		return d, ErrNotFound
+	}
+	var outcome, note, resolvedBy sql.NullString
+	var payout, refund sql.NullFloat64
+	var resolvedAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, `
+		SELECT d.id, d.errand_id, d.opened_by, d.reason, d.previous_status, d.status,
+			d.outcome, d.payout_amount, d.refund_amount, d.resolution_note, d.resolved_by, d.resolved_at, d.created_at,
+			COALESCE(h.amount, 0)
+		FROM errand_disputes d
+		LEFT JOIN credit_holds h ON h.dispute_id = d.id
+		WHERE d.errand_id = $1
+		ORDER BY d.created_at DESC
+		LIMIT 1
+	`, errandID).Scan(
+		&d.ID, &d.ErrandID, &d.OpenedBy, &d.Reason, &d.PreviousStatus, &d.Status,
+		&outcome, &payout, &refund, &note, &resolvedBy, &resolvedAt, &d.CreatedAt,
+		&d.HeldAmount,
+	)
+	if err != nil {
+		return d, pgError(err)
+	}
+	d.Outcome = outcome.String
+	d.PayoutAmount = payout.Float64
+	d.RefundAmount = refund.Float64
+	d.ResolutionNote = note.String
+	d.ResolvedBy = resolvedBy.String
+	if resolvedAt.Valid {
+		d.ResolvedAt = &resolvedAt.Time
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, dispute_id, author_id, statement, evidence_urls, created_at
+		FROM dispute_statements
+		WHERE dispute_id = $1
+		ORDER BY created_at ASC
+	`, d.ID)
+	if err != nil {
+		return d, err
+	}
+	defer rows.Close()
+
+	d.Statements = []models.DisputeStatement{}
+	for rows.Next() {
+		var st models.DisputeStatement
+		if err := rows.Scan(&st.ID, &st.DisputeID, &st.AuthorID, &st.Statement, pq.Array(&st.EvidenceURLs), &st.CreatedAt); err != nil {
+			return d, err
+		}
+		st.EvidenceURLs = nonNilStrings(st.EvidenceURLs)
+		d.Statements = append(d.Statements, st)
+	}
+	return d, rows.Err()
+}
+
+func (s *pgDisputes) AddStatement(ctx context.Context, st *models.DisputeStatement) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var errandID string
+	err = tx.QueryRowContext(ctx, "SELECT errand_id FROM errand_disputes WHERE id = $1 AND status = 'open' FOR SHARE", st.DisputeID).Scan(&errandID)
+	if err != nil {
+		return pgError(err)
+	}
+	st.EvidenceURLs = nonNilStrings(st.EvidenceURLs)
+	if err := insertStatement(ctx, tx, st); err != nil {
+		return err
+	}
+	if err := insertEvent(ctx, tx, errandID, st.AuthorID, "dispute_statement_added", map[string]interface{}{"dispute_id": st.DisputeID}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *pgDisputes) Resolve(ctx context.Context, disputeID string, r DisputeResolution, by Actor) error {
+	if !validID(disputeID) {
+		return ErrNotFound
+	}
+	tx, err := beginAudited(ctx, s.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var errandID, requesterID, runnerID string
+	var held float64
+	err = tx.QueryRowContext(ctx, `
+		SELECT d.errand_id, COALESCE(e.user_id, ''), COALESCE(e.runner_id, ''), h.amount
+		FROM errand_disputes d
+		JOIN errand_requests e ON e.id = d.errand_id
+		JOIN credit_holds h ON h.dispute_id = d.id AND h.status = 'held'
+		WHERE d.id = $1 AND d.status = 'open'
+		FOR UPDATE OF d, h
+	`, disputeID).Scan(&errandID, &requesterID, &runnerID, &held)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrConflict
+	} else if err != nil {
+		return err
+	}
+
+	before, err := lockBalance(ctx, tx, runnerID)
+	if err != nil {
+		return err
+	}
+	after := before
+	if r.Payout > 0 || r.RunnerXP > 0 {
+		if after, err = addBalance(ctx, tx, runnerID, r.Payout, r.RunnerXP); err != nil {
+			return err
+		}
+	}
+	if r.RunnerPenalty > 0 {
+		_, err = tx.ExecContext(ctx, "UPDATE users SET rating = GREATEST(rating - $1, 0) WHERE id = $2", r.RunnerPenalty, runnerID)
+		if err != nil {
+			return err
+		}
+	}
+	// Requesters never paid the reward, so a refund credits nobody
+	if r.RequesterPenalty > 0 {
+		_, err = tx.ExecContext(ctx,
+			"UPDATE users SET rating = GREATEST(rating - $1, 0) WHERE id = $2",
+			r.RequesterPenalty, requesterID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE credit_holds SET status = 'released', released_at = CURRENT_TIMESTAMP WHERE dispute_id = $1", disputeID); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE errand_disputes
+		SET status = 'resolved', outcome = $1, payout_amount = $2, refund_amount = $3,
+			resolution_note = $4, resolved_by = $5, resolved_at = CURRENT_TIMESTAMP
+		WHERE id = $6
+	`, r.Outcome, r.Payout, r.Refund, r.Note, by.ID, disputeID)
+	if err != nil {
+		return err
+	}
+	if _, err = tx.ExecContext(ctx, "UPDATE errand_requests SET status = $1 WHERE id = $2", r.ErrandStatus, errandID); err != nil {
+		return err
+	}
+
+	err = insertEvent(ctx, tx, errandID, by.ID, "dispute_resolved", map[string]interface{}{
+		"dispute_id":    disputeID,
+		"outcome":       r.Outcome,
+		"payout_amount": r.Payout,
+		"refund_amount": r.Refund,
+		"note":          r.Note,
+		"status":        r.ErrandStatus,
+	})
+	if err != nil {
+		return err
+	}
+	e := by.entry(AuditDisputeResolve, AuditTarget("dispute", disputeID),
+		resolutionSnapshot(errandID, runnerID, int(held), before, nil),
+		resolutionSnapshot(errandID, runnerID, int(held), after, &r))
+	if err := appendAudit(ctx, tx, &e); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,360 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Woeter69/hackoverflow/internal/geo"
+	"github.com/Woeter69/hackoverflow/internal/models"
+	"github.com/lib/pq"
+)
+
+type pgErrands struct{ db *sql.DB }
+
+const errandColumns = `e.id, COALESCE(e.user_id, ''), COALESCE(e.runner_id, ''), e.status, e.title, COALESCE(e.description, ''), COALESCE(e.category, ''),
+	COALESCE(e.reward_estimate, 0)::FLOAT8, COALESCE(e.urgency_level, 1), e.campus_id, e.created_at,
+	ST_AsGeoJSON(e.pickup_geom), ST_AsGeoJSON(e.dropoff_geom),
+	COALESCE(pp.id::TEXT, ''), COALESCE(pp.name, ''),
+	COALESCE(dp.id::TEXT, ''), COALESCE(dp.name, '')`
+
+// errandPlaces joins the places an errand is picked up from and dropped off at
+const errandPlaces = `
+	LEFT JOIN places pp ON pp.id = e.pickup_place_id
+	LEFT JOIN places dp ON dp.id = e.dropoff_place_id`
+
+func scanErrand(row rowScanner, e *models.ErrandRequest, extra ...interface{}) error {
+	dest := []interface{}{
+		&e.ID, &e.UserID, &e.RunnerID, &e.Status, &e.Title, &e.Description, &e.Category,
+		&e.RewardEstimate, &e.UrgencyLevel, &e.CampusID, &e.CreatedAt,
+		&e.Pickup, &e.Dropoff,
+		&e.PickupPlaceID, &e.PickupPlaceName,
+		&e.DropoffPlaceID, &e.DropoffPlaceName,
+	}
+	return row.Scan(append(dest, extra...)...)
+}
+
+func (s *pgErrands) Create(ctx context.Context, e *models.ErrandRequest) error {
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO errand_requests (user_id, title, description, category, pickup_geom, dropoff_geom, status, urgency_level, reward_estimate, campus_id, pickup_place_id, dropoff_place_id)
+		VALUES ($1, $2, $3, $4, $5, $6, 'pending', $7, $8, $9, NULLIF($10, '')::UUID, NULLIF($11, '')::UUID)
+		RETURNING id, status, created_at
+	`, e.UserID, e.Title, e.Description, e.Category, e.Pickup, e.Dropoff,
+		e.UrgencyLevel, e.RewardEstimate, e.CampusID, e.PickupPlaceID, e.DropoffPlaceID,
+	).Scan(&e.ID, &e.Status, &e.CreatedAt)
+	return pgError(err)
+}
+
+func (s *pgErrands) Get(ctx context.Context, id string) (models.ErrandRequest, error) {
+	var e models.ErrandRequest
+	if !validID(id) {
+		return e, ErrNotFound
+	}
+	err := scanErrand(s.db.QueryRowContext(ctx, "SELECT "+errandColumns+" FROM errand_requests e"+errandPlaces+" WHERE e.id = $1", id), &e)
+	return e, pgError(err)
+}
+
+// errandSortKeys is the SQL each feed order ranks by. $2 is the near point.
+var errandSortKeys = map[ErrandSort]string{
+	SortAge:      "0::FLOAT8",
+	SortReward:   "COALESCE(e.reward_estimate, 0)::FLOAT8",
+	SortUrgency:  "COALESCE(e.urgency_level, 1)::FLOAT8",
+	SortDistance: "ST_Distance(e.pickup_geom, $2::geography)",
+}
+
+// descending reports whether the largest key comes first. Ties are broken
+// by age and then id in the same direction, which keeps keyset pagination
+// stable.
+func (s ErrandSort) descending() bool {
+	return s != SortDistance
+}
+
+// feedWhere builds the filter shared by the page and the total. $1 is the
+// campus and, when near is given, $2 is the point distances are measured from.
+func feedWhere(q ErrandQuery) (string, []interface{}) {
+	args := []interface{}{q.CampusID}
+	if q.Near != nil {
+		args = append(args, models.PointFromGeo(*q.Near))
+	}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	conds := []string{"e.campus_id = $1", "e.status = ANY(" + arg(pq.Array(q.Statuses)) + ")"}
+	// Both spatial filters can use the GIST index on pickup_geom
+	if q.Near != nil {
+		conds = append(conds, "ST_DWithin(e.pickup_geom, $2::geography, "+arg(q.Radius)+")")
+	}
+	if q.Area != nil {
+		conds = append(conds, fmt.Sprintf("e.pickup_geom && ST_MakeEnvelope(%s, %s, %s, %s, 4326)::geography",
+			arg(q.Area.MinLng), arg(q.Area.MinLat), arg(q.Area.MaxLng), arg(q.Area.MaxLat)))
+	}
+	if len(q.Categories) > 0 {
+		conds = append(conds, "e.category = ANY("+arg(pq.Array(q.Categories))+")")
+	}
+	if q.MinReward > 0 {
+		conds = append(conds, "COALESCE(e.reward_estimate, 0) >= "+arg(q.MinReward))
+	}
+	if q.MinUrgency > 0 {
+		conds = append(conds, "COALESCE(e.urgency_level, 1) >= "+arg(q.MinUrgency))
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+func (s *pgErrands) Feed(ctx context.Context, q ErrandQuery) (ErrandPage, error) {
+	page := ErrandPage{Errands: []FeedErrand{}}
+	where, args := feedWhere(q)
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM errand_requests e WHERE "+where, args...).Scan(&page.Total); err != nil {
+		return page, err
+	}
+
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	key := errandSortKeys[q.Sort]
+	dir, cmp := "ASC", ">"
+	if q.Sort.descending() {
+		dir, cmp = "DESC", "<"
+	}
+	if q.After != nil {
+		where += fmt.Sprintf(" AND (%s, e.created_at, e.id) %s (%s::FLOAT8, %s::TIMESTAMPTZ, %s::UUID)",
+			key, cmp, arg(q.After.Key), arg(q.After.CreatedAt), arg(q.After.ID))
+	}
+	distance := "NULL::FLOAT8"
+	if q.Near != nil {
+		distance = "ST_Distance(e.pickup_geom, $2::geography)"
+	}
+
+	// One extra row tells whether another page follows
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+errandColumns+`, `+distance+`, `+key+`
+		FROM errand_requests e`+errandPlaces+`
+		WHERE `+where+`
+		ORDER BY `+key+` `+dir+`, e.created_at `+dir+`, e.id `+dir+`
+		LIMIT `+arg(q.Limit+1), args...)
+	if err != nil {
+		return page, err
+	}
+	defer rows.Close()
+
+	var last FeedPosition
+	for rows.Next() {
+		var e FeedErrand
+		var pos FeedPosition
+		if err := scanErrand(rows, &e.ErrandRequest, &e.Distance, &pos.Key); err != nil {
+			return page, err
+		}
+		if len(page.Errands) == q.Limit {
+			page.Next = &last
+			break
+		}
+		pos.CreatedAt, pos.ID = e.CreatedAt, e.ID.String()
+		last = pos
+		page.Errands = append(page.Errands, e)
+	}
+	return page, rows.Err()
+}
+
+func (s *pgErrands) NearRoute(ctx context.Context, campusID string, route geo.LineString, within float64) ([]models.MatchResponse, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+errandColumns+`, ST_Distance(e.pickup_geom, $2::geography) AS distance_from_route
+		FROM errand_requests e`+errandPlaces+`
+		WHERE e.campus_id = $1
+		  AND e.status = 'pending'
+		  AND ST_DWithin(e.pickup_geom, $2::geography, $3)
+		ORDER BY distance_from_route ASC
+	`, campusID, models.LineStringFromGeo(route), within)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var matches []models.MatchResponse
+	for rows.Next() {
+		var m models.MatchResponse
+		if err := scanErrand(rows, &m.Errand, &m.DistanceFromRoute); err != nil {
+			return nil, err
+		}
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
+
+func (s *pgErrands) Accept(ctx context.Context, id, runnerID string) error {
+	if !validID(id) {
+		return ErrNotFound
+	}
+	err := affected(s.db.ExecContext(ctx, "UPDATE errand_requests SET status = 'matched', runner_id = $1 WHERE id = $2 AND status = 'pending'", runnerID, id))
+	if err == ErrNotFound {
+		return ErrConflict
+	}
+	return err
+}
+
+func (s *pgErrands) SetStatus(ctx context.Context, id, status string) error {
+	if !validID(id) {
+		return ErrNotFound
+	}
+	err := affected(s.db.ExecContext(ctx,
+		"UPDATE errand_requests SET status = $1 WHERE id = $2 AND status NOT IN ('completed', 'disputed')",
+		status, id,
+	))
+	if err == ErrNotFound {
+		return s.settled(ctx, s.db, id)
+	}
+	return err
+}
+
+// settled explains why an errand could not be changed: ErrConflict when it
+// exists, ErrNotFound otherwise
+func (s *pgErrands) settled(ctx context.Context, db queryer, id string) error {
+	var exists bool
+	if err := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM errand_requests WHERE id = $1)", id).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrConflict
+	}
+	return ErrNotFound
+}
+
+func (s *pgErrands) Complete(ctx context.Context, id string, xp int, by Actor) (models.ErrandRequest, error) {
+	if !validID(id) {
+		return models.ErrandRequest{}, ErrNotFound
+	}
+	tx, err := beginAudited(ctx, s.db)
+	if err != nil {
+		return models.ErrandRequest{}, err
+	}
+	defer tx.Rollback()
+
+	// The status check, the award and its audit entry commit together, so
+	// a mission pays out once
+	var runnerID string
+	var reward float64
+	err = tx.QueryRowContext(ctx, `
+		UPDATE errand_requests SET status = 'completed'
+		WHERE id = $1 AND status = 'matched' AND runner_id IS NOT NULL
+		RETURNING runner_id, COALESCE(reward_estimate, 0)
+	`, id).Scan(&runnerID, &reward)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.ErrandRequest{}, s.settled(ctx, tx, id)
+	} else if err != nil {
+		return models.ErrandRequest{}, err
+	}
+	before, err := lockBalance(ctx, tx, runnerID)
+	if err != nil {
+		return models.ErrandRequest{}, err
+	}
+	after, err := addBalance(ctx, tx, runnerID, int(reward), xp)
+	if err != nil {
+		return models.ErrandRequest{}, err
+	}
+	e := by.entry(AuditCreditsAward, AuditTarget("user", runnerID),
+		before.snapshot(nil), after.snapshot(map[string]interface{}{"errand_id": id}))
+	if err := appendAudit(ctx, tx, &e); err != nil {
+		return models.ErrandRequest{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.ErrandRequest{}, err
+	}
+	return s.Get(ctx, id)
+}
+
+// execer is satisfied by both *sql.DB and *sql.Tx, so history can be written
+// inside or outside a transaction
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// rowsQueryer is satisfied by both *sql.DB and *sql.Tx
+type rowsQueryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// insertEvent appends an entry to an errand's history
+func insertEvent(ctx context.Context, db execer, errandID, actorID, event string, details map[string]interface{}) error {
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	payload, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx,
+		"INSERT INTO errand_events (errand_id, actor_id, event, details) VALUES ($1, $2, $3, $4)",
+		errandID, actorID, event, string(payload),
+	)
+	return pgError(err)
+}
+
+func (s *pgErrands) RecordEvent(ctx context.Context, errandID, actorID, event string, details map[string]interface{}) error {
+	return insertEvent(ctx, s.db, errandID, actorID, event, details)
+}
+
+func (s *pgErrands) Events(ctx context.Context, errandID string) ([]models.ErrandEvent, error) {
+	events := []models.ErrandEvent{}
+	if !validID(errandID) {
+		return events, nil
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, errand_id, COALESCE(actor_id, ''), event, details, created_at
+		FROM errand_events
+		WHERE errand_id = $1
+		ORDER BY created_at ASC, id ASC
+	`, errandID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e models.ErrandEvent
+		var details []byte
+		if err := rows.Scan(&e.ID, &e.ErrandID, &e.ActorID, &e.Event, &details, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(details, &e.Details); err != nil {
+			e.Details = map[string]interface{}{}
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func (s *pgErrands) AcceptedBy(ctx context.Context, runnerID string) ([]AcceptedErrand, error) {
+	// An errand was accepted when it last changed to matched
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+errandColumns+`,
+		       COALESCE((SELECT MAX(ev.created_at) FROM errand_events ev
+		                 WHERE ev.errand_id = e.id AND ev.event = 'status_changed' AND ev.details->>'status' = 'matched'), e.created_at)
+		FROM errand_requests e`+errandPlaces+`
+		WHERE e.runner_id = $1 AND e.status = 'matched'
+		LIMIT 500
+	`, runnerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	errands := []AcceptedErrand{}
+	for rows.Next() {
+		var e AcceptedErrand
+		if err := scanErrand(rows, &e.ErrandRequest, &e.AcceptedAt); err != nil {
+			return nil, err
+		}
+		errands = append(errands, e)
+	}
+	return errands, rows.Err()
+}
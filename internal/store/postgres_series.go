@@ -0,0 +1,179 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Woeter69/hackoverflow/internal/models"
+	"github.com/lib/pq"
+)
+
+type pgSeries struct{ db *sql.DB }
+
+const seriesColumns = `s.id, s.user_id, s.campus_id, COALESCE(s.origin_name, ''), COALESCE(s.destination_name, ''),
+	COALESCE(s.origin_place_id::TEXT, ''), COALESCE(s.destination_place_id::TEXT, ''),
+	ST_AsGeoJSON(s.route_geom), s.mode, COALESCE(s.seats_available, 1),
+	s.rrule, s.first_departure, s.timezone, s.exceptions::TEXT[], s.paused, s.created_at, s.updated_at`
+
+func scanSeries(row rowScanner, s *models.TravelPlanSeries) error {
+	err := row.Scan(
+		&s.ID, &s.UserID, &s.CampusID, &s.OriginName, &s.DestinationName,
+		&s.OriginPlaceID, &s.DestinationPlaceID,
+		&s.Route, &s.Mode, &s.SeatsAvailable,
+		&s.RRule, &s.Start, &s.Timezone, pq.Array(&s.Exceptions), &s.Paused, &s.CreatedAt, &s.UpdatedAt,
+	)
+	if s.Exceptions == nil {
+		s.Exceptions = []string{}
+	}
+	return err
+}
+
+// timestamps lists departures as Postgres reads them in an array
+func timestamps(departures []time.Time) interface{} {
+	stamps := make([]string, len(departures))
+	for i, t := range departures {
+		stamps[i] = t.Format(time.RFC3339)
+	}
+	return pq.Array(stamps)
+}
+
+func (s *pgSeries) querySeries(ctx context.Context, query string, args ...interface{}) ([]models.TravelPlanSeries, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	series := []models.TravelPlanSeries{}
+	for rows.Next() {
+		var ps models.TravelPlanSeries
+		if err := scanSeries(rows, &ps); err != nil {
+			return nil, err
+		}
+		series = append(series, ps)
+	}
+	return series, rows.Err()
+}
+
+func (s *pgSeries) Create(ctx context.Context, ps *models.TravelPlanSeries) error {
+	route := ps.Route.Geo()
+	if len(route) < 2 {
+		return &InvalidError{Reason: "a route needs at least two points"}
+	}
+	err := scanSeries(s.db.QueryRowContext(ctx, `
+		WITH inserted AS (
+			INSERT INTO travel_plan_series (user_id, campus_id, origin_name, destination_name, origin_place_id, destination_place_id,
+				origin_geom, destination_geom, route_geom, mode, seats_available, rrule, first_departure, timezone, exceptions)
+			VALUES ($1, $2, $3, $4, NULLIF($5, '')::UUID, NULLIF($6, '')::UUID, $7, $8, $9, $10, $11, $12, $13, $14, $15::DATE[])
+			RETURNING *
+		)
+		SELECT `+seriesColumns+` FROM inserted s
+	`, ps.UserID, ps.CampusID, ps.OriginName, ps.DestinationName, ps.OriginPlaceID, ps.DestinationPlaceID,
+		models.PointFromGeo(route[0]), models.PointFromGeo(route[len(route)-1]), ps.Route,
+		ps.Mode, ps.SeatsAvailable, ps.RRule, ps.Start, ps.Timezone, pq.Array(nonNilStrings(ps.Exceptions)),
+	), ps)
+	return pgError(err)
+}
+
+func (s *pgSeries) Get(ctx context.Context, id string) (models.TravelPlanSeries, error) {
+	var ps models.TravelPlanSeries
+	if !validID(id) {
+		return ps, ErrNotFound
+	}
+	err := scanSeries(s.db.QueryRowContext(ctx, "SELECT "+seriesColumns+" FROM travel_plan_series s WHERE s.id = $1", id), &ps)
+	return ps, pgError(err)
+}
+
+func (s *pgSeries) List(ctx context.Context, userID, campusID string) ([]models.TravelPlanSeries, error) {
+	return s.querySeries(ctx, `
+		SELECT `+seriesColumns+`
+		FROM travel_plan_series s
+		WHERE s.user_id = $1 AND s.campus_id = $2
+		ORDER BY s.created_at DESC
+	`, userID, campusID)
+}
+
+func (s *pgSeries) ListActive(ctx context.Context) ([]models.TravelPlanSeries, error) {
+	return s.querySeries(ctx, "SELECT "+seriesColumns+" FROM travel_plan_series s WHERE NOT s.paused")
+}
+
+func (s *pgSeries) Update(ctx context.Context, ps *models.TravelPlanSeries) error {
+	if !validID(ps.ID) {
+		return ErrNotFound
+	}
+	err := scanSeries(s.db.QueryRowContext(ctx, `
+		WITH updated AS (
+			UPDATE travel_plan_series SET
+				rrule = $2, first_departure = $3, timezone = $4, exceptions = $5::DATE[],
+				paused = $6, seats_available = $7, updated_at = CURRENT_TIMESTAMP
+			WHERE id = $1
+			RETURNING *
+		)
+		SELECT `+seriesColumns+` FROM updated s
+	`, ps.ID, ps.RRule, ps.Start, ps.Timezone, pq.Array(nonNilStrings(ps.Exceptions)), ps.Paused, ps.SeatsAvailable), ps)
+	return pgError(err)
+}
+
+func (s *pgSeries) Delete(ctx context.Context, id string, now time.Time) error {
+	if !validID(id) {
+		return ErrNotFound
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM travel_plans WHERE series_id = $1 AND start_time > $2", id, now); err != nil {
+		return err
+	}
+	if err := affected(tx.ExecContext(ctx, "DELETE FROM travel_plan_series WHERE id = $1", id)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *pgSeries) Materialize(ctx context.Context, ps models.TravelPlanSeries, departures []time.Time) (int64, error) {
+	return materialize(ctx, s.db, ps.ID, departures)
+}
+
+// materialize creates the departures of a series that do not exist yet
+func materialize(ctx context.Context, db execer, seriesID string, departures []time.Time) (int64, error) {
+	if len(departures) == 0 {
+		return 0, nil
+	}
+	res, err := db.ExecContext(ctx, `
+		INSERT INTO travel_plans (user_id, origin_name, destination_name, origin_geom, destination_geom, route_geom, mode, start_time, seats_available, campus_id, origin_place_id, destination_place_id, series_id)
+		SELECT s.user_id, s.origin_name, s.destination_name, s.origin_geom, s.destination_geom, s.route_geom, s.mode, d, s.seats_available, s.campus_id, s.origin_place_id, s.destination_place_id, s.id
+		FROM travel_plan_series s, UNNEST($2::TIMESTAMPTZ[]) AS d
+		WHERE s.id = $1 AND NOT s.paused
+		ON CONFLICT (series_id, start_time) WHERE series_id IS NOT NULL DO NOTHING
+	`, seriesID, timestamps(departures))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+func (s *pgSeries) Reconcile(ctx context.Context, ps models.TravelPlanSeries, departures []time.Time, now time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM travel_plans
+		WHERE series_id = $1 AND start_time > $2 AND NOT (start_time = ANY($3::TIMESTAMPTZ[]))
+	`, ps.ID, now, timestamps(departures)); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE travel_plans SET seats_available = $2 WHERE series_id = $1 AND start_time > $3", ps.ID, ps.SeatsAvailable, now); err != nil {
+		return err
+	}
+	if _, err := materialize(ctx, tx, ps.ID, departures); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
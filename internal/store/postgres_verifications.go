@@ -0,0 +1,115 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/Woeter69/hackoverflow/internal/models"
+	"github.com/Woeter69/hackoverflow/internal/policy"
+)
+
+type pgVerifications struct{ db *sql.DB }
+
+const verificationColumns = `id, user_id, document_path, COALESCE(document_type, ''), status, COALESCE(review_notes, ''), COALESCE(reviewed_by, ''), reviewed_at, created_at`
+
+func scanVerification(row rowScanner, v *models.RunnerVerification) error {
+	var reviewedAt sql.NullTime
+	if err := row.Scan(&v.ID, &v.UserID, &v.DocumentPath, &v.DocumentType, &v.Status, &v.ReviewNotes, &v.ReviewedBy, &reviewedAt, &v.CreatedAt); err != nil {
+		return err
+	}
+	if reviewedAt.Valid {
+		v.ReviewedAt = &reviewedAt.Time
+	}
+	return nil
+}
+
+func (s *pgVerifications) Submit(ctx context.Context, v *models.RunnerVerification) error {
+	err := scanVerification(s.db.QueryRowContext(ctx,
+		"INSERT INTO runner_verifications (user_id, document_path, document_type) VALUES ($1, $2, $3) RETURNING "+verificationColumns,
+		v.UserID, v.DocumentPath, v.DocumentType,
+	), v)
+	return pgError(err)
+}
+
+func (s *pgVerifications) Get(ctx context.Context, id string) (models.RunnerVerification, error) {
+	var v models.RunnerVerification
+	if !validID(id) {
+		return v, ErrNotFound
+	}
+	err := scanVerification(s.db.QueryRowContext(ctx, "SELECT "+verificationColumns+" FROM runner_verifications WHERE id = $1", id), &v)
+	return v, pgError(err)
+}
+
+func (s *pgVerifications) Latest(ctx context.Context, userID string) (models.RunnerVerification, error) {
+	var v models.RunnerVerification
+	err := scanVerification(s.db.QueryRowContext(ctx,
+		"SELECT "+verificationColumns+" FROM runner_verifications WHERE user_id = $1 ORDER BY created_at DESC LIMIT 1",
+		userID,
+	), &v)
+	return v, pgError(err)
+}
+
+func (s *pgVerifications) List(ctx context.Context, status string, limit int) ([]models.RunnerVerification, error) {
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT "+verificationColumns+" FROM runner_verifications WHERE status = $1 ORDER BY created_at ASC LIMIT $2",
+		status, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []models.RunnerVerification{}
+	for rows.Next() {
+		var v models.RunnerVerification
+		if err := scanVerification(rows, &v); err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, rows.Err()
+}
+
+func (s *pgVerifications) Review(ctx context.Context, id string, approve bool, notes string, by Actor) (models.RunnerVerification, error) {
+	var v models.RunnerVerification
+	if !validID(id) {
+		return v, ErrNotFound
+	}
+	status := "rejected"
+	if approve {
+		status = "approved"
+	}
+
+	tx, err := beginAudited(ctx, s.db)
+	if err != nil {
+		return v, err
+	}
+	defer tx.Rollback()
+
+	err = scanVerification(tx.QueryRowContext(ctx, `
+		UPDATE runner_verifications
+		SET status = $1, review_notes = $2, reviewed_by = $3, reviewed_at = CURRENT_TIMESTAMP
+		WHERE id = $4 AND status = 'pending'
+		RETURNING `+verificationColumns,
+		status, notes, by.ID, id,
+	), &v)
+	if err != nil {
+		return v, pgError(err)
+	}
+	after := reviewSnapshot(v.UserID, status)
+	after["notes"] = notes
+	e := by.entry(AuditVerificationReview, AuditTarget("verification", v.ID), reviewSnapshot(v.UserID, "pending"), after)
+	if err := appendAudit(ctx, tx, &e); err != nil {
+		return v, err
+	}
+
+	if approve {
+		err = auditRoles(ctx, tx, v.UserID, AuditRoleGrant, by, func(tx *sql.Tx) error {
+			return insertRole(ctx, tx, v.UserID, policy.RoleVerifiedRunner, by.ID)
+		})
+		if err != nil {
+			return v, err
+		}
+	}
+	return v, tx.Commit()
+}
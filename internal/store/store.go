@@ -0,0 +1,410 @@
+// Package store is the persistence layer behind the API. Each aggregate has
+// an interface with two implementations: Postgres/PostGIS for deployments
+// (NewPostgres) and an in-memory one with the same spatial behavior for
+// tests (NewMemory).
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Woeter69/hackoverflow/internal/geo"
+	"github.com/Woeter69/hackoverflow/internal/models"
+	"github.com/Woeter69/hackoverflow/internal/policy"
+)
+
+var (
+	// ErrNotFound is returned when the requested row does not exist
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when a write collides with existing data, e.g.
+	// a taken username or an errand that is no longer pending
+	ErrConflict = errors.New("conflict")
+)
+
+// InvalidError is returned when the database rejects a value, with its reason
+type InvalidError struct {
+	Reason string
+}
+
+func (e *InvalidError) Error() string {
+	return "invalid value: " + e.Reason
+}
+
+// Store bundles the repositories the handlers depend on
+type Store struct {
+	Errands       Errands
+	TravelPlans   TravelPlans
+	Users         Users
+	Messages      Messages
+	Beacons       Beacons
+	Campuses      Campuses
+	Places        Places
+	Audit         Audit
+	Disputes      Disputes
+	Series        Series
+	Verifications Verifications
+	EmailDomains  EmailDomains
+	CalendarFeeds CalendarFeeds
+}
+
+// Errands holds errand requests and their history
+type Errands interface {
+	// Create stores a new pending errand, filling in its ID and CreatedAt
+	Create(ctx context.Context, e *models.ErrandRequest) error
+	// Get returns an errand with the names of its places
+	Get(ctx context.Context, id string) (models.ErrandRequest, error)
+	// Feed returns one page of a campus's errand feed
+	Feed(ctx context.Context, q ErrandQuery) (ErrandPage, error)
+	// NearRoute lists pending errands picked up within the given distance
+	// (meters) of a route, closest first
+	NearRoute(ctx context.Context, campusID string, route geo.LineString, within float64) ([]models.MatchResponse, error)
+	// Accept assigns a pending errand to a runner. It returns ErrConflict
+	// when the errand is no longer pending.
+	Accept(ctx context.Context, id, runnerID string) error
+	// SetStatus moves an errand to another status. Completed and disputed
+	// errands are settled, so ErrConflict is returned for them.
+	SetStatus(ctx context.Context, id, status string) error
+	// Complete marks a matched errand completed and awards its runner the
+	// reward and xp in the same transaction, audited as by's award.
+	// ErrConflict is returned when the errand is not matched to a runner.
+	Complete(ctx context.Context, id string, xp int, by Actor) (models.ErrandRequest, error)
+	// RecordEvent appends an entry to the errand's history
+	RecordEvent(ctx context.Context, errandID, actorID, event string, details map[string]interface{}) error
+	// Events returns the errand's history, oldest first
+	Events(ctx context.Context, errandID string) ([]models.ErrandEvent, error)
+	// AcceptedBy lists the errands a runner is running, with when they
+	// accepted each
+	AcceptedBy(ctx context.Context, runnerID string) ([]AcceptedErrand, error)
+}
+
+// AcceptedErrand is an errand a runner has taken on
+type AcceptedErrand struct {
+	models.ErrandRequest
+	AcceptedAt time.Time
+}
+
+// ErrandSort is an order the errand feed can be ranked by
+type ErrandSort string
+
+const (
+	SortAge      ErrandSort = "age"      // newest first
+	SortDistance ErrandSort = "distance" // closest to ErrandQuery.Near first
+	SortReward   ErrandSort = "reward"   // highest reward first
+	SortUrgency  ErrandSort = "urgency"  // most urgent first
+)
+
+// Valid reports whether the feed can be ranked by s
+func (s ErrandSort) Valid() bool {
+	switch s {
+	case SortAge, SortDistance, SortReward, SortUrgency:
+		return true
+	}
+	return false
+}
+
+// ErrandQuery selects errands for the feed. Zero values disable a filter.
+type ErrandQuery struct {
+	CampusID   string
+	Statuses   []string
+	Near       *geo.Point // picked up within Radius meters of Near
+	Radius     float64
+	Area       *geo.BBox // picked up inside the box
+	Categories []string
+	MinReward  float64
+	MinUrgency int
+	Sort       ErrandSort
+	Limit      int
+	After      *FeedPosition // continue after this errand
+}
+
+// FeedPosition is the place of an errand in a ranked feed. Key is the value
+// ranked by; ties are broken by age and then id.
+type FeedPosition struct {
+	Key       float64
+	CreatedAt time.Time
+	ID        string
+}
+
+// FeedErrand is an errand in the feed with its distance from the query's
+// near point, when one was given
+type FeedErrand struct {
+	models.ErrandRequest
+	Distance *float64
+}
+
+// ErrandPage is one page of the feed. Total counts every match and Next is
+// set when more follow.
+type ErrandPage struct {
+	Errands []FeedErrand
+	Total   int
+	Next    *FeedPosition
+}
+
+// TravelPlans holds one-off travel plans and the departures of recurring ones
+type TravelPlans interface {
+	// Create stores a new active plan, filling in its ID and CreatedAt
+	Create(ctx context.Context, p *models.TravelPlan) error
+	Get(ctx context.Context, id string) (models.TravelPlan, error)
+	// ListActive returns a user's active plans on a campus, soonest first
+	ListActive(ctx context.Context, userID, campusID string) ([]models.TravelPlan, error)
+	// TravelersNear returns the users with an active plan on the campus whose
+	// route passes within the given distance (meters) of pt
+	TravelersNear(ctx context.Context, campusID string, pt geo.Point, within float64) ([]string, error)
+	// Since returns up to limit of a user's active plans on any campus that
+	// start after since, soonest first
+	Since(ctx context.Context, userID string, since time.Time, limit int) ([]models.TravelPlan, error)
+}
+
+// Series holds recurring travel plans. Their departures are materialized as
+// travel plans carrying the series id.
+type Series interface {
+	// Create stores a new series, filling in its ID, CreatedAt and UpdatedAt.
+	// Its origin and destination are the ends of its route.
+	Create(ctx context.Context, s *models.TravelPlanSeries) error
+	Get(ctx context.Context, id string) (models.TravelPlanSeries, error)
+	// List returns a user's series on a campus, newest first
+	List(ctx context.Context, userID, campusID string) ([]models.TravelPlanSeries, error)
+	// ListActive returns every series that is not paused
+	ListActive(ctx context.Context) ([]models.TravelPlanSeries, error)
+	// Update stores the schedule, pause and seat fields of s
+	Update(ctx context.Context, s *models.TravelPlanSeries) error
+	// Delete removes a series and its departures after now; earlier ones
+	// stay as ordinary travel plans
+	Delete(ctx context.Context, id string, now time.Time) error
+	// Materialize creates the given departures of an active series that do
+	// not exist yet and returns how many it created. It is safe to run
+	// repeatedly and from several instances at once.
+	Materialize(ctx context.Context, s models.TravelPlanSeries, departures []time.Time) (int64, error)
+	// Reconcile brings the departures after now in line with the given
+	// ones: others are removed, the rest take the series' seat count and
+	// missing ones are created. Departures that stay keep their ids.
+	Reconcile(ctx context.Context, s models.TravelPlanSeries, departures []time.Time, now time.Time) error
+}
+
+// Claims are the verified token claims a user is provisioned from
+type Claims struct {
+	Email         string
+	Name          string
+	EmailVerified bool
+}
+
+// Membership is the campus a user belongs to and whether they are a
+// verified member of it
+type Membership struct {
+	CampusID       string
+	CampusVerified bool
+}
+
+// ProfileUpdate changes the user-editable profile fields that are set
+type ProfileUpdate struct {
+	Username    *string
+	DisplayName *string
+}
+
+// CreditAdjustment is a manual correction to a user's credits, recorded
+// with who made it and why
+type CreditAdjustment struct {
+	Amount int
+	Reason string
+}
+
+func (a CreditAdjustment) validate(by Actor) error {
+	switch {
+	case a.Amount == 0:
+		return &InvalidError{Reason: "amount must not be zero"}
+	case strings.TrimSpace(a.Reason) == "":
+		return &InvalidError{Reason: "a reason is required"}
+	case by.ID == "":
+		return &InvalidError{Reason: "the actor is required"}
+	}
+	return nil
+}
+
+// Users holds accounts, their campus membership and stored roles
+type Users interface {
+	// Provision creates or refreshes a user from their token claims. The
+	// campus comes from the most specific allow-listed domain matching the
+	// email; users on no listed domain keep any campus an admin assigned
+	// them. The display name is only seeded, since users can edit it.
+	Provision(ctx context.Context, uid string, claims Claims) (Membership, error)
+	Get(ctx context.Context, id string) (models.User, error)
+	// UpdateProfile returns ErrConflict when the username is taken
+	UpdateProfile(ctx context.Context, id string, update ProfileUpdate) (models.User, error)
+	// Award adds credits and experience to a user
+	Award(ctx context.Context, id string, credits, xp int) error
+	// AdjustCredits applies, records and audits a manual correction and
+	// returns the new balance. A correction that would leave the balance
+	// negative is rejected with an InvalidError.
+	AdjustCredits(ctx context.Context, id string, adj CreditAdjustment, by Actor) (int, error)
+	// AssignCampus returns ErrNotFound for an unknown user or campus
+	AssignCampus(ctx context.Context, id, campusID string) error
+	Roles(ctx context.Context, id string) ([]policy.Role, error)
+	// GrantRole and RevokeRole audit the user's roles before and after,
+	// even when they are unchanged. GrantRole returns ErrNotFound for an
+	// unknown user.
+	GrantRole(ctx context.Context, id string, role policy.Role, by Actor) error
+	RevokeRole(ctx context.Context, id string, role policy.Role, by Actor) error
+}
+
+// Messages holds the chat of each errand
+type Messages interface {
+	// List returns an errand's messages, oldest first
+	List(ctx context.Context, errandID string) ([]models.Message, error)
+	// Create stores a message, filling in its ID and CreatedAt
+	Create(ctx context.Context, m *models.Message) error
+}
+
+// Beacons holds campus emergencies
+type Beacons interface {
+	// Active returns the campus's unexpired beacon, or ErrNotFound
+	Active(ctx context.Context, campusID string) (models.Beacon, error)
+	// Raise replaces any active beacon on the campus, filling in ID and
+	// CreatedAt. The beacons before and after are audited in the same
+	// transaction.
+	Raise(ctx context.Context, b *models.Beacon, by Actor) error
+	// Clear stands down the campus's active beacon, if any, auditing the
+	// beacon it stood down in the same transaction
+	Clear(ctx context.Context, campusID string, by Actor) error
+}
+
+// CampusUpdate changes the campus fields that are set. A Boundary of
+// length 0 clears the boundary.
+type CampusUpdate struct {
+	Name           *string
+	Boundary       *geo.Polygon
+	CurrencyName   *string
+	DefaultBufferM *float64
+	Categories     []string
+}
+
+// Campuses holds the tenants sharing the deployment
+type Campuses interface {
+	Get(ctx context.Context, id string) (models.Campus, error)
+	List(ctx context.Context) ([]models.Campus, error)
+	// Create returns ErrConflict when the id is taken
+	Create(ctx context.Context, c *models.Campus, boundary geo.Polygon) error
+	Update(ctx context.Context, id string, update CampusUpdate) (models.Campus, error)
+}
+
+// PlaceShape is the geometry of a place. Location defaults to a point on
+// the footprint.
+type PlaceShape struct {
+	Location  *geo.Point
+	Footprint geo.Polygon
+	Entrances []geo.Point
+}
+
+// PlaceSearch finds places on a campus by name or code
+type PlaceSearch struct {
+	CampusID string
+	Query    string // lowercase
+	Kind     string
+	Limit    int
+}
+
+// Places holds the named points of interest on each campus
+type Places interface {
+	// Get returns a place on any campus
+	Get(ctx context.Context, id string) (models.Place, error)
+	// Nearest returns the place closest to pt, measured to its footprint
+	// when it has one, and sets its Distance. within (meters) bounds the
+	// search; 0 means no limit.
+	Nearest(ctx context.Context, campusID string, pt geo.Point, within float64, kind string) (models.Place, error)
+	// Search ranks exact codes first, then name prefixes, then any place
+	// whose name or code contains the query
+	Search(ctx context.Context, q PlaceSearch) ([]models.Place, error)
+	// Match finds the place a free-text location refers to: one whose code
+	// is the text, or else the one with the longest name the text contains
+	Match(ctx context.Context, campusID, text string) (models.Place, error)
+	// Create returns ErrConflict when the name is taken on the campus
+	Create(ctx context.Context, p *models.Place, shape PlaceShape) error
+	// Update replaces a place's details; CampusID and ID pick the place
+	Update(ctx context.Context, p *models.Place, shape PlaceShape) error
+	Delete(ctx context.Context, id string) error
+}
+
+// DisputeResolution is a staff decision on an open dispute: how much of
+// the held reward goes to each party and what it costs their reputation
+type DisputeResolution struct {
+	Outcome          string // refund, payout or partial
+	Payout           int    // credits released to the runner
+	Refund           int    // credits withheld from the runner
+	RunnerXP         int
+	RunnerPenalty    float64 // rating taken from the runner
+	RequesterPenalty float64 // rating taken from the requester
+	ErrandStatus     string  // the errand's status once resolved
+	Note             string
+}
+
+// DisputeOpening is a dispute about to be opened on an errand. The reason
+// is also its first statement.
+type DisputeOpening struct {
+	ErrandID     string
+	Reason       string
+	EvidenceURLs []string
+	ClawbackXP   int // experience taken back with a reward already paid out
+}
+
+// Disputes holds disputes over errands and the rewards they freeze
+type Disputes interface {
+	// Open freezes the reward of a matched or completed errand under a new
+	// dispute and marks the errand disputed. A reward already paid out is
+	// clawed back from the runner; after an earlier dispute, only what its
+	// resolution released is, and audited as a clawback. ErrConflict is
+	// returned when the errand cannot be disputed.
+	Open(ctx context.Context, o DisputeOpening, by Actor) (models.Dispute, error)
+	// Latest returns the most recent dispute on an errand with its statements
+	Latest(ctx context.Context, errandID string) (models.Dispute, error)
+	// AddStatement adds to an open dispute, filling in the statement's ID
+	// and CreatedAt. ErrNotFound is returned when the dispute is not open.
+	AddStatement(ctx context.Context, s *models.DisputeStatement) error
+	// Resolve releases the hold of an open dispute, settles the errand and
+	// audits the resolution. ErrConflict is returned when the dispute is no
+	// longer open.
+	Resolve(ctx context.Context, disputeID string, r DisputeResolution, by Actor) error
+}
+
+// Verifications holds applications for the verified runner tier
+type Verifications interface {
+	// Submit stores a pending application, filling in its ID, Status and
+	// CreatedAt. ErrConflict is returned when the user already has one
+	// under review.
+	Submit(ctx context.Context, v *models.RunnerVerification) error
+	Get(ctx context.Context, id string) (models.RunnerVerification, error)
+	// Latest returns a user's most recent application
+	Latest(ctx context.Context, userID string) (models.RunnerVerification, error)
+	// List returns up to limit applications in a status, oldest first
+	List(ctx context.Context, status string, limit int) ([]models.RunnerVerification, error)
+	// Review decides a pending application; approval grants the verified
+	// runner role with it. The review and any grant are audited in the same
+	// transaction. ErrNotFound is returned when no pending application has
+	// the id.
+	Review(ctx context.Context, id string, approve bool, notes string, by Actor) (models.RunnerVerification, error)
+}
+
+// EmailDomains holds the institutional domains whose users join a campus
+type EmailDomains interface {
+	// List returns every domain by campus
+	List(ctx context.Context) ([]models.EmailDomain, error)
+	// Add allow-lists a domain for a campus, moving it there if another
+	// campus had it. ErrNotFound is returned for an unknown campus.
+	Add(ctx context.Context, domain, campusID string) (models.EmailDomain, error)
+	Remove(ctx context.Context, domain string) error
+}
+
+// CalendarFeeds holds the private calendar feed of each user. Only a hash
+// of the feed token is stored.
+type CalendarFeeds interface {
+	// Issue sets a user's token hash, replacing any earlier one
+	Issue(ctx context.Context, userID, tokenHash string) error
+	Revoke(ctx context.Context, userID string) error
+	// Owner returns the user whose token has the hash
+	Owner(ctx context.Context, tokenHash string) (string, error)
+}
+
+// normalizeText prepares free text for case-insensitive matching
+func normalizeText(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
@@ -0,0 +1,447 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Woeter69/hackoverflow/internal/database"
+	"github.com/Woeter69/hackoverflow/internal/geo"
+	"github.com/Woeter69/hackoverflow/internal/models"
+	"github.com/Woeter69/hackoverflow/internal/policy"
+	_ "github.com/lib/pq"
+)
+
+// Each test runs against the in-memory store and, when TEST_DB_URL names a
+// PostGIS database, against Postgres too (migrated first), so the two stay
+// interchangeable.
+
+type backend struct {
+	name  string
+	store *Store
+	db    *sql.DB // nil for the in-memory store
+}
+
+func backends(t *testing.T) []backend {
+	t.Helper()
+	list := []backend{{name: "memory", store: NewMemory().Store()}}
+	if url := os.Getenv("TEST_DB_URL"); url != "" {
+		db, err := sql.Open("postgres", url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		if _, err := database.MigrateUp(context.Background(), db); err != nil {
+			t.Fatal(err)
+		}
+		list = append(list, backend{name: "postgres", store: NewPostgres(db), db: db})
+	}
+	return list
+}
+
+// testCampus creates a campus of its own for a test run
+func testCampus(t *testing.T, b backend) string {
+	t.Helper()
+	id := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	campus := models.Campus{ID: id, Name: "Test Campus", CurrencyName: "Credits", DefaultBufferM: 200, Categories: []string{}}
+	if err := b.store.Campuses.Create(context.Background(), &campus, nil); err != nil {
+		t.Fatal(err)
+	}
+	if b.db != nil {
+		t.Cleanup(func() {
+			b.db.Exec("DELETE FROM errand_requests WHERE campus_id = $1", id)
+			b.db.Exec("DELETE FROM travel_plans WHERE campus_id = $1", id)
+			b.db.Exec("DELETE FROM places WHERE campus_id = $1", id)
+			b.db.Exec("DELETE FROM campuses WHERE id = $1", id)
+		})
+	}
+	return id
+}
+
+// testUser provisions a unique user for a test run
+func testUser(t *testing.T, b backend, name string) string {
+	t.Helper()
+	uid := fmt.Sprintf("test-%s-%d", name, time.Now().UnixNano())
+	if _, err := b.store.Users.Provision(context.Background(), uid, Claims{}); err != nil {
+		t.Fatal(err)
+	}
+	if b.db != nil {
+		t.Cleanup(func() { b.db.Exec("DELETE FROM users WHERE id = $1", uid) })
+	}
+	return uid
+}
+
+func pt(lng, lat float64) geo.Point {
+	return geo.Point{Lng: lng, Lat: lat}
+}
+
+func createErrand(t *testing.T, s *Store, userID, campusID string, pickup geo.Point, reward float64) models.ErrandRequest {
+	t.Helper()
+	e := models.ErrandRequest{
+		UserID:         userID,
+		Title:          "Coffee",
+		Category:       "delivery",
+		Pickup:         models.PointFromGeo(pickup),
+		Dropoff:        models.PointFromGeo(pickup),
+		UrgencyLevel:   1,
+		RewardEstimate: reward,
+		CampusID:       campusID,
+	}
+	if err := s.Errands.Create(context.Background(), &e); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func near(got, want, tolerance float64) bool {
+	return math.Abs(got-want) <= tolerance
+}
+
+func TestErrandsNearRoute(t *testing.T) {
+	ctx := context.Background()
+	for _, b := range backends(t) {
+		t.Run(b.name, func(t *testing.T) {
+			campus := testCampus(t, b)
+			owner, runner := testUser(t, b, "owner"), testUser(t, b, "runner")
+			// ~111m north of the route's midpoint, and ~1km away
+			close := createErrand(t, b.store, owner, campus, pt(77.595, 12.972), 5)
+			createErrand(t, b.store, owner, campus, pt(77.595, 12.980), 5)
+
+			route := geo.LineString{pt(77.594, 12.971), pt(77.596, 12.971)}
+			matches, err := b.store.Errands.NearRoute(ctx, campus, route, 200)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(matches) != 1 || matches[0].Errand.ID != close.ID {
+				t.Fatalf("expected only the close errand, got %+v", matches)
+			}
+			if d := matches[0].DistanceFromRoute; !near(d, 111, 2) {
+				t.Fatalf("expected ~111m from the route, got %.1f", d)
+			}
+
+			if err := b.store.Errands.Accept(ctx, close.ID.String(), runner); err != nil {
+				t.Fatal(err)
+			}
+			if err := b.store.Errands.Accept(ctx, close.ID.String(), runner); !errors.Is(err, ErrConflict) {
+				t.Fatalf("expected a conflict accepting twice, got %v", err)
+			}
+			if matches, _ := b.store.Errands.NearRoute(ctx, campus, route, 200); len(matches) != 0 {
+				t.Fatalf("matched errands should leave the route matches, got %+v", matches)
+			}
+		})
+	}
+}
+
+func TestErrandFeedPages(t *testing.T) {
+	ctx := context.Background()
+	for _, b := range backends(t) {
+		t.Run(b.name, func(t *testing.T) {
+			campus := testCampus(t, b)
+			owner := testUser(t, b, "owner")
+			origin := pt(77.595, 12.971)
+			for i := 1; i <= 5; i++ {
+				createErrand(t, b.store, owner, campus, pt(77.595, 12.971+float64(i)*0.001), float64(i))
+			}
+			createErrand(t, b.store, owner, campus, pt(77.595, 12.990), 10) // outside the radius
+
+			q := ErrandQuery{CampusID: campus, Statuses: []string{"pending"}, Near: &origin, Radius: 600, Sort: SortDistance, Limit: 2}
+			var distances []float64
+			for {
+				got, err := b.store.Errands.Feed(ctx, q)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Total != 5 {
+					t.Fatalf("expected 5 errands in range, got %d", got.Total)
+				}
+				for _, e := range got.Errands {
+					distances = append(distances, *e.Distance)
+				}
+				if got.Next == nil {
+					break
+				}
+				q.After = got.Next
+			}
+			if len(distances) != 5 {
+				t.Fatalf("expected 5 errands across pages, got %v", distances)
+			}
+			for i, d := range distances {
+				if !near(d, float64(i+1)*111.2, 2) {
+					t.Fatalf("expected errands ~111m apart closest first, got %v", distances)
+				}
+			}
+
+			q = ErrandQuery{CampusID: campus, Statuses: []string{"pending"}, Sort: SortReward, MinReward: 3, Limit: 10}
+			got, err := b.store.Errands.Feed(ctx, q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got.Errands) != 4 || got.Errands[0].RewardEstimate != 10 || got.Errands[3].RewardEstimate != 3 {
+				t.Fatalf("expected rewards 10..3 highest first, got %+v", got.Errands)
+			}
+		})
+	}
+}
+
+func TestNearestPlaceMeasuresToFootprint(t *testing.T) {
+	ctx := context.Background()
+	for _, b := range backends(t) {
+		t.Run(b.name, func(t *testing.T) {
+			campus := testCampus(t, b)
+			footprint := geo.Polygon{{pt(77.594, 12.970), pt(77.596, 12.970), pt(77.596, 12.972), pt(77.594, 12.972), pt(77.594, 12.970)}}
+			library := models.Place{CampusID: campus, Name: "Library", Kind: "building", Code: "LIB"}
+			if err := b.store.Places.Create(ctx, &library, PlaceShape{Footprint: footprint}); err != nil {
+				t.Fatal(err)
+			}
+			gate := models.Place{CampusID: campus, Name: "North Gate", Kind: "gate"}
+			gateAt := pt(77.595, 12.9745)
+			if err := b.store.Places.Create(ctx, &gate, PlaceShape{Location: &gateAt}); err != nil {
+				t.Fatal(err)
+			}
+			dup := models.Place{CampusID: campus, Name: "library", Kind: "other"}
+			if err := b.store.Places.Create(ctx, &dup, PlaceShape{Location: &gateAt}); !errors.Is(err, ErrConflict) {
+				t.Fatalf("expected a conflict for a duplicate name, got %v", err)
+			}
+
+			// 0.001° north of the footprint is closer to the library's wall
+			// than to the gate, though farther from its center
+			got, err := b.store.Places.Nearest(ctx, campus, pt(77.595, 12.973), 0, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ID != library.ID || !near(*got.Distance, 111, 2) {
+				t.Fatalf("expected the library ~111m away, got %s at %v", got.Name, *got.Distance)
+			}
+			inside, err := b.store.Places.Nearest(ctx, campus, pt(77.595, 12.971), 0, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if inside.ID != library.ID || *inside.Distance != 0 {
+				t.Fatalf("expected to be inside the library, got %s at %v", inside.Name, *inside.Distance)
+			}
+			if _, err := b.store.Places.Nearest(ctx, campus, pt(77.595, 12.990), 100, ""); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("expected nothing within 100m, got %v", err)
+			}
+
+			match, err := b.store.Places.Match(ctx, campus, "Meet outside the Library cafe")
+			if err != nil || match.ID != library.ID {
+				t.Fatalf("expected the text to match the library, got %+v, %v", match, err)
+			}
+			found, err := b.store.Places.Search(ctx, PlaceSearch{CampusID: campus, Query: "lib", Limit: 5})
+			if err != nil || len(found) != 1 || found[0].ID != library.ID {
+				t.Fatalf("expected to find the library by code, got %+v, %v", found, err)
+			}
+		})
+	}
+}
+
+func TestTravelersNear(t *testing.T) {
+	ctx := context.Background()
+	for _, b := range backends(t) {
+		t.Run(b.name, func(t *testing.T) {
+			campus := testCampus(t, b)
+			route := geo.LineString{pt(77.594, 12.971), pt(77.596, 12.971)}
+			plan := models.TravelPlan{
+				UserID:      testUser(t, b, "traveler"),
+				CampusID:    campus,
+				Origin:      models.PointFromGeo(route[0]),
+				Destination: models.PointFromGeo(route[1]),
+				Route:       models.LineStringFromGeo(route),
+				Mode:        "walk",
+				StartTime:   time.Now().Add(time.Hour),
+			}
+			if err := b.store.TravelPlans.Create(ctx, &plan); err != nil {
+				t.Fatal(err)
+			}
+
+			travelers, err := b.store.TravelPlans.TravelersNear(ctx, campus, pt(77.595, 12.972), 200)
+			if err != nil || len(travelers) != 1 || travelers[0] != plan.UserID {
+				t.Fatalf("expected the traveler within 200m, got %v, %v", travelers, err)
+			}
+			if travelers, _ := b.store.TravelPlans.TravelersNear(ctx, campus, pt(77.595, 12.972), 100); len(travelers) != 0 {
+				t.Fatalf("expected no traveler within 100m, got %v", travelers)
+			}
+		})
+	}
+}
+
+func TestAdjustCredits(t *testing.T) {
+	ctx := context.Background()
+	for _, b := range backends(t) {
+		t.Run(b.name, func(t *testing.T) {
+			user := testUser(t, b, "adjusted")
+			if b.db != nil {
+				t.Cleanup(func() { b.db.Exec("DELETE FROM credit_adjustments WHERE user_id = $1", user) })
+			}
+
+			ops := Actor{ID: "ops", RequestID: "req-1"}
+			balance, err := b.store.Users.AdjustCredits(ctx, user, CreditAdjustment{Amount: -30, Reason: "refund for a duplicate payout"}, ops)
+			if err != nil || balance != 70 {
+				t.Fatalf("expected a balance of 70, got %d, %v", balance, err)
+			}
+			var invalid *InvalidError
+			if _, err := b.store.Users.AdjustCredits(ctx, user, CreditAdjustment{Amount: -71, Reason: "too much"}, ops); !errors.As(err, &invalid) {
+				t.Fatalf("expected an overdraft to be rejected, got %v", err)
+			}
+			if _, err := b.store.Users.AdjustCredits(ctx, user, CreditAdjustment{Amount: 5}, ops); !errors.As(err, &invalid) {
+				t.Fatalf("expected a missing reason to be rejected, got %v", err)
+			}
+			if _, err := b.store.Users.AdjustCredits(ctx, user, CreditAdjustment{Amount: 5, Reason: "bonus"}, Actor{}); !errors.As(err, &invalid) {
+				t.Fatalf("expected a missing actor to be rejected, got %v", err)
+			}
+			if _, err := b.store.Users.AdjustCredits(ctx, "nobody", CreditAdjustment{Amount: 5, Reason: "bonus"}, ops); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("expected an unknown user to be not found, got %v", err)
+			}
+			if u, _ := b.store.Users.Get(ctx, user); u.Credits != 70 {
+				t.Fatalf("rejected corrections changed the balance to %d", u.Credits)
+			}
+
+			// Only the applied correction is audited, with the balance around it
+			entries, err := b.store.Audit.List(ctx, AuditQuery{Target: AuditTarget("user", user), Limit: 10})
+			if err != nil || len(entries) != 1 {
+				t.Fatalf("expected one audit entry, got %+v (%v)", entries, err)
+			}
+			e := entries[0]
+			if e.Action != AuditCreditsAdjust || e.Actor != "ops" || e.RequestID != "req-1" {
+				t.Fatalf("unexpected audit entry %+v", e)
+			}
+			var before, after struct{ Credits int }
+			if json.Unmarshal(e.Before, &before) != nil || json.Unmarshal(e.After, &after) != nil || before.Credits != 100 || after.Credits != 70 {
+				t.Fatalf("expected credits 100 before and 70 after, got %s and %s", e.Before, e.After)
+			}
+		})
+	}
+}
+
+func TestReviewVerificationIsAudited(t *testing.T) {
+	ctx := context.Background()
+	for _, b := range backends(t) {
+		t.Run(b.name, func(t *testing.T) {
+			user := testUser(t, b, "applicant")
+			if b.db != nil {
+				t.Cleanup(func() { b.db.Exec("DELETE FROM runner_verifications WHERE user_id = $1", user) })
+			}
+			v := models.RunnerVerification{UserID: user, DocumentPath: "id.png", DocumentType: "student_id"}
+			if err := b.store.Verifications.Submit(ctx, &v); err != nil {
+				t.Fatal(err)
+			}
+
+			ops := Actor{ID: "ops", RequestID: "req-2"}
+			reviewed, err := b.store.Verifications.Review(ctx, v.ID, true, "looks right", ops)
+			if err != nil || reviewed.Status != "approved" || reviewed.ReviewedBy != "ops" {
+				t.Fatalf("expected an approval by ops, got %+v (%v)", reviewed, err)
+			}
+			if _, err := b.store.Verifications.Review(ctx, v.ID, false, "", ops); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("expected a decided application to be not found, got %v", err)
+			}
+
+			entries, err := b.store.Audit.List(ctx, AuditQuery{Target: AuditTarget("verification", v.ID), Limit: 10})
+			if err != nil || len(entries) != 1 || entries[0].Action != AuditVerificationReview || entries[0].RequestID != "req-2" {
+				t.Fatalf("expected one review entry, got %+v (%v)", entries, err)
+			}
+			var review struct{ Status, Notes string }
+			if json.Unmarshal(entries[0].After, &review) != nil || review.Status != "approved" || review.Notes != "looks right" {
+				t.Fatalf("unexpected review snapshot %s", entries[0].After)
+			}
+
+			// Approval grants the role in the same transaction, audited with it
+			entries, err = b.store.Audit.List(ctx, AuditQuery{Target: AuditTarget("user", user), Limit: 10})
+			if err != nil || len(entries) != 1 || entries[0].Action != AuditRoleGrant || entries[0].Actor != "ops" {
+				t.Fatalf("expected one role grant entry, got %+v (%v)", entries, err)
+			}
+			var before, after struct{ Roles []string }
+			if json.Unmarshal(entries[0].Before, &before) != nil || json.Unmarshal(entries[0].After, &after) != nil ||
+				len(before.Roles) != 0 || len(after.Roles) != 1 || after.Roles[0] != string(policy.RoleVerifiedRunner) {
+				t.Fatalf("expected the verified runner role to be granted, got %s and %s", entries[0].Before, entries[0].After)
+			}
+		})
+	}
+}
+
+func TestAuditChain(t *testing.T) {
+	ctx := context.Background()
+	for _, b := range backends(t) {
+		t.Run(b.name, func(t *testing.T) {
+			actor := fmt.Sprintf("test-%d", time.Now().UnixNano())
+			target := AuditTarget("user", actor)
+			for i, action := range []string{AuditRoleGrant, AuditCreditsAdjust, AuditRoleRevoke} {
+				e := models.AuditEntry{
+					Actor:     actor,
+					Action:    action,
+					Target:    target,
+					Before:    Snapshot(map[string]interface{}{"credits": i, "roles": []string{"student"}}),
+					After:     Snapshot(map[string]interface{}{"credits": i + 1, "note": "<ok> & done"}),
+					RequestID: "req-1",
+				}
+				if err := b.store.Audit.Append(ctx, &e); err != nil {
+					t.Fatal(err)
+				}
+				if e.Hash == "" || e.PrevHash == e.Hash {
+					t.Fatalf("entry was not chained: %+v", e)
+				}
+			}
+
+			got, err := b.store.Audit.List(ctx, AuditQuery{Actor: actor, Limit: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 3 || got[0].Action != AuditRoleRevoke || got[1].Hash != got[0].PrevHash {
+				t.Fatalf("expected the entries newest first, got %+v", got)
+			}
+			got, err = b.store.Audit.List(ctx, AuditQuery{Actor: actor, Action: AuditCreditsAdjust, Since: got[2].CreatedAt, Limit: 10})
+			if err != nil || len(got) != 1 || got[0].Target != target {
+				t.Fatalf("expected the one adjustment, got %+v (%v)", got, err)
+			}
+
+			// Snapshots read back from JSONB still match their hashes
+			if _, _, err := VerifyAudit(ctx, b.store.Audit); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestVerifyAuditFindsTampering(t *testing.T) {
+	ctx := context.Background()
+	for name, tamper := range map[string]func(log []models.AuditEntry) []models.AuditEntry{
+		"edited": func(log []models.AuditEntry) []models.AuditEntry {
+			log[1].After = Snapshot(map[string]int{"credits": 1000000})
+			return log
+		},
+		"removed": func(log []models.AuditEntry) []models.AuditEntry {
+			return append(log[:1], log[2:]...)
+		},
+		"rehashed": func(log []models.AuditEntry) []models.AuditEntry {
+			// Recomputing an edited entry's hash breaks the link to the next
+			log[1].Actor = "someone-else"
+			log[1].Hash, _ = AuditHash(log[1])
+			return log
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m := NewMemory()
+			for i := 0; i < 3; i++ {
+				e := models.AuditEntry{Actor: "admin", Action: AuditCreditsAward, Target: AuditTarget("user", "u1"), After: Snapshot(map[string]int{"credits": i})}
+				if err := m.Store().Audit.Append(ctx, &e); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if n, head, err := VerifyAudit(ctx, m.Store().Audit); err != nil || n != 3 || head != m.audit[2].Hash {
+				t.Fatalf("expected an intact chain of 3, got %d %s %v", n, head, err)
+			}
+
+			m.audit = tamper(m.audit)
+			_, _, err := VerifyAudit(ctx, m.Store().Audit)
+			var broken *AuditBreak
+			if !errors.As(err, &broken) || broken.ID > 3 {
+				t.Fatalf("expected the chain to break, got %v", err)
+			}
+		})
+	}
+}
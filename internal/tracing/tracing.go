@@ -0,0 +1,125 @@
+// Package tracing sets up OpenTelemetry. HTTP requests, SQL statements,
+// Redis commands and hub broadcasts are recorded as spans and exported to a
+// collector over OTLP/HTTP, or printed as JSON for local debugging and
+// tests. Trace context travels in W3C traceparent headers, and in the
+// "trace" field of WebSocket event envelopes.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/Woeter69/hackoverflow/internal/config"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// scope names the instrumentation of the spans this repo starts itself
+const scope = "github.com/Woeter69/hackoverflow"
+
+// Tracer starts spans for the server's own operations
+func Tracer() trace.Tracer {
+	return otel.Tracer(scope)
+}
+
+// Init installs the global tracer provider and trace context propagation.
+// The stdout exporter writes to w. The returned function flushes spans
+// still buffered and must be called at shutdown.
+func Init(ctx context.Context, cfg config.Tracing, w io.Writer) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case "none":
+		// Spans are still started, so trace context is propagated, but nothing records them
+		return func(context.Context) error { return nil }, nil
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(w))
+	case "otlp":
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(cfg.ServiceName)))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Middleware starts a server span for every request, continuing the trace
+// of the caller's traceparent header. Requests to the skipped paths, such
+// as probes and metrics scrapes, are not traced.
+func Middleware(skip ...string) gin.HandlerFunc {
+	skipped := make(map[string]bool, len(skip))
+	for _, path := range skip {
+		skipped[path] = true
+	}
+	return func(c *gin.Context) {
+		if skipped[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		ctx, span := Tracer().Start(ctx, c.Request.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(c.Request.Method),
+				semconv.UserAgentOriginal(c.Request.UserAgent()),
+			),
+		)
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		if route := c.FullPath(); route != "" {
+			span.SetName(c.Request.Method + " " + route)
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= 500 {
+			span.SetStatus(codes.Error, "")
+		}
+		for _, err := range c.Errors {
+			span.RecordError(err.Err)
+		}
+	}
+}
+
+// Inject returns the trace context of ctx as a map, for messages that do
+// not travel in HTTP requests. It is nil when ctx carries no trace.
+func Inject(ctx context.Context) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return nil
+	}
+	return carrier
+}
@@ -0,0 +1,72 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Woeter69/hackoverflow/internal/config"
+	"github.com/gin-gonic/gin"
+)
+
+// exported is the part of the stdout exporter's span records the test reads
+type exported struct {
+	Name        string
+	SpanContext struct{ TraceID, SpanID string }
+	Parent      struct{ TraceID, SpanID string }
+}
+
+func TestRequestSpansContinueTheCallersTrace(t *testing.T) {
+	var out bytes.Buffer
+	cfg := config.Default().Tracing
+	cfg.Exporter = "stdout"
+	flush, err := Init(context.Background(), cfg, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware("/livez"))
+	r.GET("/errand-requests/:id", func(c *gin.Context) {
+		_, span := Tracer().Start(c.Request.Context(), "store.get")
+		span.End()
+		c.Status(http.StatusOK)
+	})
+	r.GET("/livez", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/errand-requests/42", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if err := flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := map[string]exported{}
+	dec := json.NewDecoder(&out)
+	for {
+		var s exported
+		if err := dec.Decode(&s); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		spans[s.Name] = s
+	}
+	if len(spans) != 2 {
+		t.Fatalf("expected the request and store spans only, got %v", spans)
+	}
+	server, ok := spans["GET /errand-requests/:id"]
+	if !ok || server.SpanContext.TraceID != traceID || server.Parent.SpanID != "00f067aa0ba902b7" {
+		t.Fatalf("the request span should continue the caller's trace, got %+v", server)
+	}
+	if child := spans["store.get"]; child.Parent.SpanID != server.SpanContext.SpanID {
+		t.Fatalf("handler spans should be children of the request span, got %+v", child)
+	}
+}
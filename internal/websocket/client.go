@@ -1,7 +1,7 @@
 package websocket
 
 import (
-	"log"
+	"log/slog"
 	"net/http"
 	"time"
 
@@ -9,23 +9,17 @@ import (
 	"github.com/gorilla/websocket"
 )
 
-const (
-	// Time allowed to write a message to the peer.
-	writeWait = 10 * time.Second
-
-	// Time allowed to read the next pong message from the peer.
-	pongWait = 60 * time.Second
-
-	// Send pings to peer with this period. Must be less than pongWait.
-	pingPeriod = (pongWait * 9) / 10
-
-	// Maximum message size allowed from peer.
-	maxMessageSize = 512
-)
+// restartFrame tells clients the server is going away and they should
+// reconnect, which puts them on another instance during a rolling deploy
+var restartFrame = websocket.FormatCloseMessage(websocket.CloseServiceRestart, "server restarting, reconnect")
 
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
+	// Browsers send their token after the "bearer" subprotocol (see
+	// middleware.AuthMiddleware), and drop the connection unless the
+	// server picks one of the protocols they offered
+	Subprotocols: []string{"bearer"},
 	// Allow all CORS for this hackathon
 	CheckOrigin: func(r *http.Request) bool {
 		return true
@@ -39,6 +33,9 @@ type Client struct {
 	// UserID for targeted messaging
 	UserID string
 
+	// CampusID partitions broadcasts per tenant
+	CampusID string
+
 	// The websocket connection.
 	conn *websocket.Conn
 
@@ -49,17 +46,21 @@ type Client struct {
 // readPump pumps messages from the websocket connection to the hub.
 func (c *Client) readPump() {
 	defer func() {
-		c.hub.unregister <- c
+		select {
+		case c.hub.unregister <- c:
+		case <-c.hub.done:
+		}
 		c.conn.Close()
 	}()
-	c.conn.SetReadLimit(maxMessageSize)
-	c.conn.SetReadDeadline(time.Now().Add(pongWait))
-	c.conn.SetPongHandler(func(string) error { c.conn.SetReadDeadline(time.Now().Add(pongWait)); return nil })
+	limits := c.hub.limits
+	c.conn.SetReadLimit(limits.MaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(limits.PongWait))
+	c.conn.SetPongHandler(func(string) error { c.conn.SetReadDeadline(time.Now().Add(limits.PongWait)); return nil })
 	for {
 		_, _, err := c.conn.ReadMessage()
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("error: %v", err)
+				slog.Warn("WebSocket read Error", "user_id", c.UserID, "err", err)
 			}
 			break
 		}
@@ -68,10 +69,13 @@ func (c *Client) readPump() {
 
 // writePump pumps messages from the hub to the websocket connection.
 func (c *Client) writePump() {
-	ticker := time.NewTicker(pingPeriod)
+	writeWait := c.hub.limits.WriteWait
+	// Send pings to peer before it would be dropped for missing a pong
+	ticker := time.NewTicker(c.hub.limits.PongWait * 9 / 10)
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
+		c.hub.writers.Done()
 	}()
 	for {
 		select {
@@ -79,7 +83,7 @@ func (c *Client) writePump() {
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
 			if !ok {
 				// The hub closed the channel.
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				c.conn.WriteMessage(websocket.CloseMessage, c.hub.closeFrame())
 				return
 			}
 
@@ -108,22 +112,34 @@ func (c *Client) writePump() {
 }
 
 // ServeWs handles websocket requests from the peer.
-func ServeWs(hub *Hub, c *gin.Context) {
-	userID := c.Query("userId")
-	if userID == "" {
-		userID = "anonymous"
-	}
-
+func ServeWs(hub *Hub, c *gin.Context, userID, campusID string) {
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
-		log.Println(err)
+		slog.WarnContext(c.Request.Context(), "WebSocket upgrade Error", "err", err)
+		return
+	}
+	client := &Client{hub: hub, UserID: userID, CampusID: campusID, conn: conn, send: make(chan []byte, hub.limits.SendBuffer)}
+	hub.writers.Add(1)
+	select {
+	case hub.register <- client:
+	case <-hub.done:
+		// Shutting down: send the client on to another instance
+		conn.WriteControl(websocket.CloseMessage, restartFrame, time.Now().Add(hub.limits.WriteWait))
+		conn.Close()
+		hub.writers.Done()
 		return
 	}
-	client := &Client{hub: hub, UserID: userID, conn: conn, send: make(chan []byte, 256)}
-	client.hub.register <- client
 
 	// Allow collection of memory referenced by the caller by doing all work in
 	// new goroutines.
 	go client.writePump()
 	go client.readPump()
-}
\ No newline at end of file
+}
+
+// closeFrame is the close message sent when the hub drops a connection
+func (h *Hub) closeFrame() []byte {
+	if h.stopping() {
+		return restartFrame
+	}
+	return []byte{}
+}
@@ -1,15 +1,46 @@
 package websocket
 
-import "encoding/json"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/Woeter69/hackoverflow/internal/config"
+	"github.com/Woeter69/hackoverflow/internal/logging"
+	"github.com/Woeter69/hackoverflow/internal/metrics"
+	"github.com/Woeter69/hackoverflow/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// outbound is a message addressed to a campus, a single user, or everyone
+type outbound struct {
+	// CampusID limits delivery to clients on that campus ("" = every campus)
+	campusID string
+
+	// UserID limits delivery to that user's connections ("" = every user)
+	userID string
+
+	// event is the message's type, for metrics and tracing
+	event string
+
+	data []byte
+
+	// span times the broadcast until it has been fanned out
+	span trace.Span
+}
 
 // Hub maintains the set of active clients and broadcasts messages to the
-// clients.
+// clients. Clients are partitioned by campus so each tenant only sees its
+// own errands, chats and emergencies.
 type Hub struct {
 	// Registered clients.
 	clients map[*Client]bool
 
-	// Inbound messages from the clients.
-	broadcast chan []byte
+	// Outbound messages for the clients.
+	broadcast chan outbound
 
 	// Register requests from the clients.
 	register chan *Client
@@ -17,89 +48,231 @@ type Hub struct {
 	// Unregister requests from clients.
 	unregister chan *Client
 
-	// Persistent Emergency State
-	emergencyState []byte
+	// probe is received by Run to show its loop is still turning
+	probe chan struct{}
+
+	// Per-connection limits
+	limits config.WebSocket
+
+	// stop asks Run to close every connection and return; done is closed
+	// once it has
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+
+	// writers counts the connections still flushing their last messages
+	writers sync.WaitGroup
+
+	// Persistent Emergency State per campus
+	emergencyMu    sync.RWMutex
+	emergencyState map[string][]byte
 }
 
-func NewHub() *Hub {
+// NewHub returns a hub whose connections are held to the given limits
+func NewHub(limits config.WebSocket) *Hub {
 	return &Hub{
-		broadcast:  make(chan []byte),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
-		clients:    make(map[*Client]bool),
+		limits:         limits,
+		broadcast:      make(chan outbound),
+		register:       make(chan *Client),
+		unregister:     make(chan *Client),
+		probe:          make(chan struct{}),
+		clients:        make(map[*Client]bool),
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+		emergencyState: make(map[string][]byte),
 	}
 }
 
+// Run delivers messages until Shutdown is called
 func (h *Hub) Run() {
+	defer close(h.done)
 	for {
 		select {
+		case <-h.stop:
+			// Closing send makes each writePump say goodbye with a restart
+			// close frame, so clients reconnect to another instance
+			for client := range h.clients {
+				delete(h.clients, client)
+				close(client.send)
+				metrics.ConnectedClients.Dec()
+			}
+			return
+		case <-h.probe:
 		case client := <-h.register:
 			h.clients[client] = true
-			// If there's an active emergency, notify the new client immediately
-			if h.emergencyState != nil {
-				client.send <- h.emergencyState
+			metrics.ConnectedClients.Inc()
+			// If there's an active emergency on the client's campus, notify it immediately
+			if state := h.campusEmergency(client.CampusID); state != nil {
+				client.send <- state
 			}
 		case client := <-h.unregister:
 			if _, ok := h.clients[client]; ok {
 				delete(h.clients, client)
 				close(client.send)
+				metrics.ConnectedClients.Dec()
 			}
 		case message := <-h.broadcast:
+			recipients, dropped := 0, 0
 			for client := range h.clients {
+				if message.campusID != "" && client.CampusID != message.campusID {
+					continue
+				}
+				if message.userID != "" && client.UserID != message.userID {
+					continue
+				}
 				select {
-				case client.send <- message:
+				case client.send <- message.data:
+					recipients++
 				default:
+					dropped++
+					if message.userID != "" {
+						// A slow client misses targeted notifications but stays connected
+						metrics.SendDrops.WithLabelValues(metrics.DropSkipped).Inc()
+						continue
+					}
 					close(client.send)
 					delete(h.clients, client)
+					metrics.SendDrops.WithLabelValues(metrics.DropDisconnected).Inc()
+					metrics.ConnectedClients.Dec()
 				}
 			}
+			message.span.SetAttributes(attribute.Int("ws.recipients", recipients), attribute.Int("ws.dropped", dropped))
+			message.span.End()
 		}
 	}
 }
 
-// SetEmergencyState updates the persistent state and broadcasts it
-func (h *Hub) SetEmergencyState(payload interface{}) {
-	msg := map[string]interface{}{
-		"type":    "EMERGENCY_STATE",
-		"payload": payload,
+// Shutdown closes every connection with a "service restart" close frame and
+// waits, until ctx is done, for the frames to be written. Messages sent
+// after Shutdown are dropped.
+func (h *Hub) Shutdown(ctx context.Context) error {
+	h.stopOnce.Do(func() { close(h.stop) })
+	select {
+	case <-h.done:
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	bytes, err := json.Marshal(msg)
-	if err == nil {
-		h.emergencyState = bytes
-		h.broadcast <- bytes
+
+	flushed := make(chan struct{})
+	go func() {
+		h.writers.Wait()
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
 
-// BroadcastJSON is a helper to send JSON structs to all clients
-func (h *Hub) BroadcastJSON(eventType string, payload interface{}) {
-	msg := map[string]interface{}{
-		"type":    eventType,
-		"payload": payload,
+// Ping checks that Run is still delivering messages: it fails if Run has
+// returned or is stuck for longer than ctx allows
+func (h *Hub) Ping(ctx context.Context) error {
+	select {
+	case h.probe <- struct{}{}:
+		return nil
+	case <-h.done:
+		return errors.New("hub has stopped")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// stopping reports whether Shutdown has been called
+func (h *Hub) stopping() bool {
+	select {
+	case <-h.stop:
+		return true
+	default:
+		return false
 	}
-	bytes, err := json.Marshal(msg)
-	if err == nil {
-		h.broadcast <- bytes
+}
+
+// enqueue hands a message to Run, or drops it once the hub has stopped
+func (h *Hub) enqueue(msg outbound) {
+	metrics.Broadcasts.WithLabelValues(msg.event).Inc()
+	select {
+	case h.broadcast <- msg:
+	case <-h.done:
+		msg.span.SetStatus(codes.Error, "hub stopped")
+		msg.span.End()
 	}
 }
 
-// SendToUser sends a message to a specific user
-func (h *Hub) SendToUser(userID string, eventType string, payload interface{}) {
+func (h *Hub) campusEmergency(campusID string) []byte {
+	h.emergencyMu.RLock()
+	defer h.emergencyMu.RUnlock()
+	return h.emergencyState[campusID]
+}
+
+func encode(ctx context.Context, eventType string, payload interface{}) ([]byte, error) {
 	msg := map[string]interface{}{
 		"type":    eventType,
 		"payload": payload,
 	}
-	bytes, err := json.Marshal(msg)
+	// Clients can report receipt against the trace that caused the event
+	if carrier := tracing.Inject(ctx); carrier != nil {
+		msg["trace"] = carrier
+	}
+	// and the request that caused it
+	if id := logging.RequestID(ctx); id != "" {
+		msg["request_id"] = id
+	}
+	return json.Marshal(msg)
+}
+
+// prepare starts the span of a broadcast and encodes the message under it.
+// Run ends the span once the message has been handed to every recipient.
+func (h *Hub) prepare(ctx context.Context, msg outbound, payload interface{}) (outbound, bool) {
+	ctx, span := tracing.Tracer().Start(ctx, "ws.broadcast "+msg.event,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("ws.event", msg.event),
+			attribute.String("campus.id", msg.campusID),
+			attribute.Bool("ws.targeted", msg.userID != ""),
+		),
+	)
+	data, err := encode(ctx, msg.event, payload)
 	if err != nil {
-		return
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "encode failed")
+		span.End()
+		return msg, false
+	}
+	msg.data = data
+	msg.span = span
+	return msg, true
+}
+
+// SetEmergencyState updates the campus's persistent state and broadcasts it to that campus
+func (h *Hub) SetEmergencyState(ctx context.Context, campusID string, payload interface{}) {
+	msg, ok := h.prepare(ctx, outbound{campusID: campusID, event: "EMERGENCY_STATE"}, payload)
+	if ok {
+		h.emergencyMu.Lock()
+		h.emergencyState[campusID] = msg.data
+		h.emergencyMu.Unlock()
+		h.enqueue(msg)
 	}
+}
 
-	for client := range h.clients {
-		if client.UserID == userID {
-			select {
-			case client.send <- bytes:
-			default:
-				// If send channel is full, do nothing or handle accordingly
-			}
-		}
+// BroadcastJSON is a helper to send JSON structs to all clients on every campus
+func (h *Hub) BroadcastJSON(ctx context.Context, eventType string, payload interface{}) {
+	if msg, ok := h.prepare(ctx, outbound{event: eventType}, payload); ok {
+		h.enqueue(msg)
+	}
+}
+
+// BroadcastToCampus sends JSON structs to every client on one campus
+func (h *Hub) BroadcastToCampus(ctx context.Context, campusID string, eventType string, payload interface{}) {
+	if msg, ok := h.prepare(ctx, outbound{campusID: campusID, event: eventType}, payload); ok {
+		h.enqueue(msg)
+	}
+}
+
+// SendToUser sends a message to a specific user
+func (h *Hub) SendToUser(ctx context.Context, userID string, eventType string, payload interface{}) {
+	if msg, ok := h.prepare(ctx, outbound{userID: userID, event: eventType}, payload); ok {
+		h.enqueue(msg)
 	}
 }
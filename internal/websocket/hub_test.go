@@ -0,0 +1,93 @@
+package websocket
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Woeter69/hackoverflow/internal/config"
+	"github.com/Woeter69/hackoverflow/internal/metrics"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestShutdownAsksClientsToReconnect(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	hub := NewHub(config.Default().WebSocket)
+	go hub.Run()
+
+	r := gin.New()
+	r.GET("/ws", func(c *gin.Context) { ServeWs(hub, c, "u1", "default") })
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Make sure the client is registered before shutting down. The event
+	// carries the trace of the request that caused it.
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	caused := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled,
+	}))
+	hub.SendToUser(caused, "u1", "PING", nil)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var event struct {
+		Type  string            `json:"type"`
+		Trace map[string]string `json:"trace"`
+	}
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != "PING" || event.Trace["traceparent"] != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Fatalf("expected the trace context in the envelope, got %+v", event)
+	}
+	if n := testutil.ToFloat64(metrics.ConnectedClients); n != 1 {
+		t.Fatalf("expected 1 connected client, got %v", n)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := hub.Ping(ctx); err != nil {
+		t.Fatalf("a running hub should answer pings, got %v", err)
+	}
+	if err := hub.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := hub.Ping(ctx); err == nil {
+		t.Fatal("a stopped hub should fail pings")
+	}
+	if n := testutil.ToFloat64(metrics.ConnectedClients); n != 0 {
+		t.Fatalf("expected no connected clients after shutdown, got %v", n)
+	}
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseServiceRestart) {
+		t.Fatalf("expected a service restart close frame, got %v", err)
+	}
+
+	// Broadcasts after shutdown are dropped instead of blocking
+	hub.BroadcastJSON(context.Background(), "LATE", nil)
+
+	// New connections are turned away with the same code
+	late, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer late.Close()
+	late.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := late.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseServiceRestart) {
+		t.Fatalf("expected a late client to be sent on, got %v", err)
+	}
+}
@@ -3,121 +3,231 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"strconv"
+	"time"
 
-	firebase "firebase.google.com/go/v4"
+	"github.com/Woeter69/hackoverflow/internal/apierror"
+	"github.com/Woeter69/hackoverflow/internal/auth"
+	"github.com/Woeter69/hackoverflow/internal/config"
 	"github.com/Woeter69/hackoverflow/internal/database"
+	"github.com/Woeter69/hackoverflow/internal/geo"
 	"github.com/Woeter69/hackoverflow/internal/handlers"
+	"github.com/Woeter69/hackoverflow/internal/health"
+	"github.com/Woeter69/hackoverflow/internal/lifecycle"
+	"github.com/Woeter69/hackoverflow/internal/logging"
+	"github.com/Woeter69/hackoverflow/internal/metrics"
 	"github.com/Woeter69/hackoverflow/internal/middleware"
+	"github.com/Woeter69/hackoverflow/internal/policy"
+	"github.com/Woeter69/hackoverflow/internal/routing"
+	"github.com/Woeter69/hackoverflow/internal/tracing"
 	"github.com/Woeter69/hackoverflow/internal/websocket"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
 )
 
-func main() {
-	// Try loading from current dir or parent for Docker/Air flexibility
-	_ = godotenv.Load()
-	_ = godotenv.Load("/app/.env")
+// serve runs the API server until ctx is done or a component fails, then
+// shuts it down in order: in-flight requests are drained before the
+// WebSocket clients, Redis and the database are let go
+func serve(ctx context.Context, cfg config.Config) (err error) {
+	app := lifecycle.New(ctx)
+	defer func() {
+		slog.Info("Shutting down")
+		if stopErr := app.Shutdown(cfg.Server.ShutdownTimeout); err == nil {
+			err = stopErr
+		}
+	}()
 
-	// Initialize Websocket Hub
-	wsHub := websocket.NewHub()
-	go wsHub.Run()
-	handlers.SetHub(wsHub)
+	// Tracing is set up first and flushed last, so the spans of startup and
+	// of the shutdown itself are exported
+	flushSpans, err := tracing.Init(ctx, cfg.Tracing, os.Stdout)
+	if err != nil {
+		return fmt.Errorf("failed to initialize tracing: %w", err)
+	}
+	app.OnStop("tracing", flushSpans)
 
-	// Initialize Firebase App
-	ctx := context.Background()
-	firebaseApp, err := firebase.NewApp(ctx, nil)
+	// Campus path networks for route generation, one file per campus
+	networkDir := cfg.Server.NetworkDir
+	networks, err := routing.LoadDir(networkDir)
 	if err != nil {
-		log.Printf("Warning: Failed to initialize Firebase: %v", err)
-		// We don't fatal here just yet to allow local dev without keys if needed, 
-		// but middleware will fail if called.
+		return fmt.Errorf("failed to load path networks from %s: %w", networkDir, err)
 	}
+	for campusID, graph := range networks {
+		slog.Info("Loaded path network", "campus_id", campusID, "nodes", graph.Nodes())
+	}
+
+	// Rewards at or above this need a verified runner
+	policy.HighValueThreshold = cfg.Matching.HighValueRewardThreshold
 
-	dbURL := os.Getenv("DB_URL")
-	if dbURL == "" {
-		log.Println("DB_URL not found in environment, falling back to components...")
-		// Fallback for local development if DB_URL isn't fully formed in .env
-		dbUser := os.Getenv("DB_USER")
-		dbPass := os.Getenv("DB_PASSWORD")
-		dbHost := os.Getenv("DB_HOST")
-		dbPort := os.Getenv("DB_PORT")
-		dbName := os.Getenv("DB_NAME")
-		dbSSL := os.Getenv("DB_SSLMODE")
-		
-		if dbHost == "" { dbHost = "localhost" } // Default for local run outside docker
-
-		dbURL = fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=%s",
-			dbUser, dbPass, dbHost, dbPort, dbName, dbSSL)
+	// Uploaded GPX tracks and polylines are simplified to this tolerance
+	geo.DefaultSimplifyTolerance = cfg.Matching.RouteSimplifyToleranceM
+
+	// Initialize Auth (fail closed: never start without a working verifier).
+	// Local mode is refused in release mode when the config is validated.
+	authMode := cfg.Auth.Mode
+	verifier, devIssuer, err := auth.New(ctx, auth.Config{
+		Mode:        authMode,
+		LocalSecret: cfg.Auth.LocalSecret,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to initialize %s auth: %w", authMode, err)
 	}
+	slog.Info("Auth initialized", "mode", authMode)
+
+	st, err := openStore(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	app.OnStop("database", func(context.Context) error { return database.DB.Close() })
+	if cfg.Features.Metrics {
+		metrics.RegisterDB(database.DB, "campusloop")
+	}
+
+	if cfg.Features.Redis {
+		if err := database.InitRedis(ctx, cfg.Redis); err != nil {
+			slog.Warn("Failed to connect to Redis", "err", err)
+		} else {
+			slog.Info("Connected to Redis")
+		}
+		app.OnStop("redis", func(context.Context) error { return database.RedisClient.Close() })
+	}
+
+	// Initialize Websocket Hub. It is stopped after the HTTP server, so
+	// requests still draining can broadcast.
+	wsHub := websocket.NewHub(cfg.WebSocket)
+	app.Go("websocket hub", func(context.Context) error {
+		wsHub.Run()
+		return nil
+	})
+	app.OnStop("websocket hub", wsHub.Shutdown)
+
+	provisioner := middleware.NewProvisioner(st.Users)
+	h := handlers.New(handlers.Deps{
+		Store:       st,
+		Hub:         wsHub,
+		Networks:    networks,
+		Provisioner: provisioner,
+		// Origin used in private calendar feed links
+		PublicBaseURL:  cfg.Server.PublicBaseURL,
+		UploadDir:      cfg.Server.UploadDir,
+		DefaultBufferM: cfg.Matching.DefaultBufferM,
+	})
 
-	// Mask password in logs
-	log.Printf("Initializing DB connection... (Host: %s, Port: %s, DB: %s)\n", os.Getenv("DB_HOST"), os.Getenv("DB_PORT"), os.Getenv("DB_NAME"))
-	database.InitDB(dbURL)
-	defer database.DB.Close()
+	// Keep the departures of recurring travel plans materialized ahead of time
+	if cfg.Features.SeriesScheduler {
+		app.Go("series scheduler", func(ctx context.Context) error {
+			h.RunSeriesScheduler(ctx, time.Hour)
+			return nil
+		})
+	}
 
-	if err := database.InitRedis(); err != nil {
-		log.Printf("Warning: Failed to connect to Redis: %v", err)
-	} else {
-		fmt.Println("Connected to Redis")
+	// Probes for the container orchestrator. Postgres, PostGIS and the hub
+	// are needed by every request; without Redis or the Firebase signing keys
+	// the server is degraded but still serves what it can.
+	checks := health.New(app.Context())
+	checks.Live(health.Check{Name: "websocket hub", Critical: true, Run: wsHub.Ping})
+	checks.Ready(health.Check{Name: "postgres", Critical: true, Run: database.DB.PingContext})
+	checks.Ready(health.Check{Name: "postgis", Critical: true, Run: func(ctx context.Context) error {
+		return database.CheckPostGIS(ctx, database.DB)
+	}})
+	if cfg.Features.Redis {
+		checks.Ready(health.Check{Name: "redis", Run: func(ctx context.Context) error {
+			return database.RedisClient.Ping(ctx).Err()
+		}})
+	}
+	if keys, ok := verifier.(auth.KeyChecker); ok {
+		checks.Ready(health.Check{Name: "firebase keys", Run: keys.CheckKeys})
 	}
 
 	// Set Gin mode
-	mode := os.Getenv("GIN_MODE")
-	if mode != "" {
-		gin.SetMode(mode)
+	if cfg.Server.Mode != "" {
+		gin.SetMode(cfg.Server.Mode)
 	}
 
-	r := gin.Default()
+	probes := []string{"/health", "/livez", "/readyz", "/metrics"}
+	r := gin.New()
+	r.Use(apierror.Recovery())
+	r.Use(tracing.Middleware(probes...))
+	r.Use(logging.RequestIDMiddleware())
+	r.Use(logging.AccessLog(probes...))
+	if cfg.Features.Metrics {
+		r.Use(metrics.Middleware())
+		r.GET("/metrics", metrics.Handler())
+	}
 
-	// Health check
+	// Health check, kept for the frontend; probes should use /livez and /readyz
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{"status": "healthy"})
 	})
+	r.GET("/livez", checks.ServeLive)
+	r.GET("/readyz", checks.ServeReady)
 
-	// WebSocket Route
-	r.GET("/ws", func(c *gin.Context) {
-		websocket.ServeWs(wsHub, c)
-	})
+	// WebSocket Route (broadcasts are partitioned by campus). Browsers pass
+	// their token as a subprotocol, and the campus comes from membership.
+	r.GET("/ws", middleware.AuthMiddleware(verifier, st.Users), provisioner.Handler(), h.ServeWebSocket)
+
+	// Local token issuer, only available in local auth mode
+	if devIssuer != nil {
+		r.POST("/dev/token", handlers.IssueDevToken(devIssuer))
+	}
+
+	// Private calendar feeds authenticate with the token in the URL, since
+	// calendar apps cannot send a bearer token
+	r.GET("/calendar/:token", h.ServeCalendarFeed)
 
 	// API Routes
 	api := r.Group("/api/v1")
-	// Protect all API routes with Firebase Auth
-	if firebaseApp != nil {
-		api.Use(middleware.AuthMiddleware(firebaseApp))
-	}
-	{
-		api.POST("/travel-plans", handlers.CreateTravelPlan)
-		api.GET("/travel-plans/:id/matches", handlers.FindMatchingErrands)
-		api.POST("/errand-requests", handlers.CreateErrandRequest)
-		api.GET("/errand-requests", handlers.GetPendingErrands)
-		api.PUT("/errand-requests/:id/status", handlers.UpdateErrandStatus)
-		api.POST("/emergency", handlers.ToggleEmergency)
-		api.GET("/profile", handlers.GetUserProfile)
-		api.GET("/errand-requests/:id/chat", handlers.GetChatHistory)
-		api.POST("/errand-requests/:id/chat", handlers.SendMessage)
-	}
+	// Protect all API routes with the configured auth verifier and make sure
+	// every authenticated caller has a users row
+	api.Use(middleware.AuthMiddleware(verifier, st.Users), provisioner.Handler())
+	h.RegisterRoutes(api)
 
 	// Serve Frontend Static Files
-	r.Static("/assets", "./dist/assets")
-	r.StaticFile("/favicon.ico", "./dist/favicon.ico")
+	if cfg.Features.Frontend {
+		r.Static("/assets", "./dist/assets")
+		r.StaticFile("/favicon.ico", "./dist/favicon.ico")
+	}
 
 	// SPA Fallback: Serve index.html for any unknown route, except API routes
 	r.NoRoute(func(c *gin.Context) {
 		if len(c.Request.URL.Path) >= 4 && c.Request.URL.Path[:4] == "/api" {
-			c.JSON(404, gin.H{"error": "API endpoint not found"})
+			apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "API endpoint not found")
+			return
+		}
+		if !cfg.Features.Frontend {
+			apierror.Respond(c, http.StatusNotFound, apierror.CodeNotFound, "Not found")
 			return
 		}
 		c.File("./dist/index.html")
 	})
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
+	port := strconv.Itoa(cfg.Server.Port)
+	srv := &http.Server{Addr: ":" + port, Handler: r}
 
-	fmt.Printf("CampusLoop Backend starting on port %s...\n", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	slog.Info("CampusLoop Backend starting", "port", port)
+	app.Go("http server", func(context.Context) error {
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	})
+	// Stop accepting connections and let in-flight requests, such as errand
+	// acceptances, finish
+	app.OnStop("http server", srv.Shutdown)
+
+	// /readyz fails from the moment shutdown begins; keep serving for a
+	// while so load balancers notice before connections are refused
+	if delay := cfg.Server.DrainDelay; delay > 0 {
+		app.OnStop("readiness drain", func(ctx context.Context) error {
+			select {
+			case <-time.After(delay):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
 	}
+
+	return app.Wait()
 }
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/Woeter69/hackoverflow/internal/config"
+	"github.com/Woeter69/hackoverflow/internal/database"
+)
+
+const migrateUsage = `usage: main migrate up [-seed]
+       main migrate down [-steps n]
+       main migrate status`
+
+// runMigrate implements "migrate up|down|status" and returns the exit code
+func runMigrate(cfg config.Config, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	flags := flag.NewFlagSet("migrate "+args[0], flag.ContinueOnError)
+	seed := flags.Bool("seed", false, "load the development sample data after migrating")
+	steps := flags.Int("steps", 1, "number of migrations to roll back")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	ctx := context.Background()
+	if err := database.InitDB(ctx, cfg.Database); err != nil {
+		slog.Error("Database Error", "err", err)
+		return 1
+	}
+	defer database.DB.Close()
+
+	switch args[0] {
+	case "up":
+		applied, err := database.MigrateUp(ctx, database.DB)
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			slog.Error("Migrate Error", "err", err)
+			return 1
+		}
+		if len(applied) == 0 {
+			fmt.Println("schema is up to date")
+		}
+		if *seed {
+			if err := database.Seed(ctx, database.DB); err != nil {
+				slog.Error("Seed Error", "err", err)
+				return 1
+			}
+			fmt.Println("loaded sample data")
+		}
+	case "down":
+		if *steps < 1 {
+			fmt.Fprintln(os.Stderr, "-steps must be at least 1")
+			return 2
+		}
+		rolledBack, err := database.MigrateDown(ctx, database.DB, *steps)
+		for _, m := range rolledBack {
+			fmt.Printf("rolled back %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			slog.Error("Migrate Error", "err", err)
+			return 1
+		}
+	case "status":
+		states, err := database.MigrationStatus(ctx, database.DB)
+		if err != nil {
+			slog.Error("Migrate Error", "err", err)
+			return 1
+		}
+		for _, s := range states {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Printf("%04d_%-40s %s\n", s.Version, s.Name, applied)
+		}
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	return 0
+}
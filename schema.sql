@@ -1,103 +0,0 @@
--- Enable PostGIS extension
-CREATE EXTENSION IF NOT EXISTS postgis;
-
--- Users Table
-CREATE TABLE IF NOT EXISTS users (
-    id TEXT PRIMARY KEY, -- Firebase UID
-    username VARCHAR(50),
-    email VARCHAR(100),
-    credits INT DEFAULT 100,
-    xp INT DEFAULT 0,
-    rating DECIMAL(3, 2) DEFAULT 5.0,
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-);
-
--- Travel Plans (Carpool/Commute)
-CREATE TABLE IF NOT EXISTS travel_plans (
-    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-    user_id TEXT, -- Loose coupling or FK to users if we sync them
-    origin_name TEXT,
-    destination_name TEXT,
-    origin_geom GEOGRAPHY(POINT, 4326),
-    destination_geom GEOGRAPHY(POINT, 4326),
-    -- The route can be simplified to a LineString for matching
-    route_geom GEOGRAPHY(LINESTRING, 4326),
-    mode VARCHAR(20), -- 'walk', 'cycle', 'car', 'cab'
-    start_time TIMESTAMP WITH TIME ZONE NOT NULL,
-    seats_available INT DEFAULT 1,
-    is_active BOOLEAN DEFAULT TRUE,
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-);
-
--- Errand Requests (Piggyback/Orders)
-CREATE TABLE IF NOT EXISTS errand_requests (
-    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-    user_id TEXT,
-    title VARCHAR(100) NOT NULL,
-    description TEXT,
-    category VARCHAR(50), -- Added category field
-    pickup_geom GEOGRAPHY(POINT, 4326) NOT NULL,
-    dropoff_geom GEOGRAPHY(POINT, 4326) NOT NULL,
-    status VARCHAR(20) DEFAULT 'pending', -- 'pending', 'matched', 'picked_up', 'delivered', 'cancelled'
-    urgency_level INT DEFAULT 1,
-    reward_estimate DECIMAL(10, 2),
-    runner_id TEXT, -- The traveler who accepted the errand
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-);
-
--- Emergency Beacons (Panic Button)
-CREATE TABLE IF NOT EXISTS emergency_beacons (
-    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-    user_id TEXT,
-    current_location GEOGRAPHY(POINT, 4326) NOT NULL,
-    is_active BOOLEAN DEFAULT TRUE,
-    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-);
-
--- Spatial Indexes for Performance
-CREATE INDEX IF NOT EXISTS idx_travel_plans_route ON travel_plans USING GIST (route_geom);
-CREATE INDEX IF NOT EXISTS idx_errand_requests_pickup ON errand_requests USING GIST (pickup_geom);
-CREATE INDEX IF NOT EXISTS idx_emergency_beacons_location ON emergency_beacons USING GIST (current_location);
-
--- Chat Messages for Errands
-CREATE TABLE IF NOT EXISTS messages (
-    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-    errand_id UUID REFERENCES errand_requests(id) ON DELETE CASCADE,
-    sender_id TEXT, -- Firebase UID
-    content TEXT NOT NULL,
-    is_encrypted BOOLEAN DEFAULT TRUE, -- Just for flavor/visuals
-    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-);
-
-CREATE INDEX IF NOT EXISTS idx_messages_errand_id ON messages(errand_id);
-
--- Seed Data (Optional, but helpful for initial state)
-INSERT INTO users (id, username, email, credits, xp, rating)
-VALUES ('system-bot', 'CampusGuard', 'bot@campusloop.com', 9999, 1000, 5.0)
-ON CONFLICT (id) DO NOTHING;
-
--- Sample Errand
-INSERT INTO errand_requests (title, description, category, pickup_geom, dropoff_geom, status, reward_estimate)
-VALUES (
-    'Coffee from Starbucks', 
-    'Need a Venti Latte from the library Starbucks.', 
-    'delivery',
-    ST_GeomFromText('POINT(77.5946 12.9716)', 4326)::geography, 
-    ST_GeomFromText('POINT(77.5950 12.9720)', 4326)::geography,
-    'pending',
-    5.00
-) ON CONFLICT DO NOTHING;
-
--- Sample Travel Plan
-INSERT INTO travel_plans (user_id, origin_name, destination_name, origin_geom, destination_geom, route_geom, mode, start_time)
-VALUES (
-    'system-bot',
-    'Main Gate',
-    'Science Block',
-    ST_GeomFromText('POINT(77.5940 12.9710)', 4326)::geography,
-    ST_GeomFromText('POINT(77.5960 12.9730)', 4326)::geography,
-    ST_GeogFromText('LINESTRING(77.5940 12.9710, 77.5950 12.9720, 77.5960 12.9730)'),
-    'walk',
-    CURRENT_TIMESTAMP + INTERVAL '1 hour'
-) ON CONFLICT DO NOTHING;